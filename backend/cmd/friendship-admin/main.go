@@ -0,0 +1,347 @@
+// friendship-admin — консольная утилита для операционных задач бэкенда.
+//
+// Использование:
+//
+//	friendship-admin seed
+//	friendship-admin create-admin -name "Админ" -email admin@example.com -password "..."
+//	friendship-admin promote -email user@example.com [-role admin|user]
+//	friendship-admin recompute-stats -session 42
+//	friendship-admin recompute-stats -from 2025-01-01 -to 2025-02-01
+//	friendship-admin rebuild-stats [-user 7] [-batch 100] [-apply]
+//	friendship-admin rebuild-companions
+//	friendship-admin recount-users [-session 42]
+//	friendship-admin purge-verification
+//	friendship-admin rebuild-popular
+//	friendship-admin rebuild-leaderboards
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"friendship/db"
+	"friendship/services"
+	"friendship/utils"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string) error
+}
+
+var commands = []command{
+	{"seed", "заполнить справочники (категории, статусы, жанры, дни недели)", runSeed},
+	{"create-admin", "создать администратора платформы", runCreateAdmin},
+	{"promote", "изменить роль пользователя на платформе", runPromote},
+	{"recompute-stats", "повторно посчитать статистику для сессии или промежутка дат", runRecomputeStats},
+	{"rebuild-stats", "пересобрать статистику пользователей с нуля и показать расхождения", runRebuildStats},
+	{"rebuild-companions", "пересобрать граф совместных посещений", runRebuildCompanions},
+	{"recount-users", "пересчитать CurrentUsers по session_users", runRecountUsers},
+	{"purge-verification", "удалить зависшие сессии подтверждения в Redis", runPurgeVerification},
+	{"rebuild-popular", "пересобрать кэш популярных сессий", runRebuildPopular},
+	{"rebuild-leaderboards", "пересобрать рейтинги в Redis по завершённым сессиям", runRebuildLeaderboards},
+}
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		_ = v.RegisterValidation("password", utils.PasswordValidation)
+		_ = v.RegisterValidation("username", utils.ValidateNameTag)
+		services.InitValidator(v)
+	}
+
+	name := os.Args[1]
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if err := cmd.run(os.Args[2:]); err != nil {
+			log.Fatalf("%s: %v", cmd.name, err)
+		}
+		return
+	}
+
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Использование: friendship-admin <команда> [флаги]")
+	fmt.Fprintln(os.Stderr, "\nКоманды:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", cmd.name, cmd.description)
+	}
+}
+
+func runSeed(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	fs.Parse(args)
+
+	if err := db.InitDatabase(); err != nil {
+		return err
+	}
+
+	db.SeedCategories()
+	db.SeedCategoriesSessionsVisibility()
+	db.SeedStatusSessions()
+	db.SeedGenres()
+	db.SeedDays()
+
+	log.Println("Справочники заполнены")
+	return nil
+}
+
+func runCreateAdmin(args []string) error {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	name := fs.String("name", "", "имя пользователя")
+	email := fs.String("email", "", "email администратора")
+	password := fs.String("password", "", "пароль (не короче 10 символов, разный регистр и спецсимвол)")
+	fs.Parse(args)
+
+	if *name == "" || *email == "" || *password == "" {
+		fs.Usage()
+		return fmt.Errorf("нужно указать -name, -email и -password")
+	}
+
+	if err := utils.InitPasswordHashing(); err != nil {
+		return err
+	}
+	if err := db.InitDatabase(); err != nil {
+		return err
+	}
+
+	user, err := services.CreatePlatformAdmin(*name, *email, *password)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Администратор создан: id=%d, us=%s, email=%s", user.ID, user.Us, user.Email)
+	return nil
+}
+
+func runPromote(args []string) error {
+	fs := flag.NewFlagSet("promote", flag.ExitOnError)
+	email := fs.String("email", "", "email пользователя")
+	role := fs.String("role", services.PlatformRoleAdmin, "новая роль: admin или user")
+	fs.Parse(args)
+
+	if *email == "" {
+		fs.Usage()
+		return fmt.Errorf("нужно указать -email")
+	}
+
+	if err := db.InitDatabase(); err != nil {
+		return err
+	}
+	// Без Redis не сбросится кэш principal, и старая роль продержится до истечения кэша
+	if err := db.InitRedis(); err != nil {
+		return err
+	}
+
+	if err := services.SetPlatformRole(*email, *role); err != nil {
+		return err
+	}
+
+	log.Printf("Роль пользователя %s изменена на %s", *email, *role)
+	return nil
+}
+
+func runRecomputeStats(args []string) error {
+	fs := flag.NewFlagSet("recompute-stats", flag.ExitOnError)
+	sessionID := fs.Uint("session", 0, "ID завершённой сессии")
+	fromStr := fs.String("from", "", "начало промежутка (YYYY-MM-DD)")
+	toStr := fs.String("to", "", "конец промежутка (YYYY-MM-DD), по умолчанию — сейчас")
+	fs.Parse(args)
+
+	if *sessionID == 0 && *fromStr == "" {
+		fs.Usage()
+		return fmt.Errorf("нужно указать -session или -from")
+	}
+
+	if err := db.InitDatabase(); err != nil {
+		return err
+	}
+	db.InitMongoDB()
+	// Пересчёт обновляет рейтинги в Redis
+	if err := db.InitRedis(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	if *sessionID != 0 {
+		processed, err := services.ReprocessFinishedSession(ctx, *sessionID)
+		if err != nil {
+			return err
+		}
+		if processed {
+			log.Printf("Статистика по сессии %d посчитана", *sessionID)
+		} else {
+			log.Printf("Сессия %d уже была учтена ранее, пропущена", *sessionID)
+		}
+		return nil
+	}
+
+	from, err := time.Parse("2006-01-02", *fromStr)
+	if err != nil {
+		return fmt.Errorf("некорректная дата -from: %v", err)
+	}
+	to := time.Now()
+	if *toStr != "" {
+		to, err = time.Parse("2006-01-02", *toStr)
+		if err != nil {
+			return fmt.Errorf("некорректная дата -to: %v", err)
+		}
+	}
+
+	report, err := services.ReprocessFinishedSessions(ctx, from, to)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Сессий в промежутке: %d, посчитано: %d, уже учтено: %d, ошибок: %d",
+		report.Total, report.Processed, report.AlreadyProcessed, len(report.Failed))
+	for id, msg := range report.Failed {
+		log.Printf("  сессия %d: %s", id, msg)
+	}
+	return nil
+}
+
+func runRebuildStats(args []string) error {
+	fs := flag.NewFlagSet("rebuild-stats", flag.ExitOnError)
+	userID := fs.Uint("user", 0, "ID пользователя (по умолчанию — все)")
+	batch := fs.Int("batch", 100, "размер пачки пользователей")
+	apply := fs.Bool("apply", false, "перезаписать статистику (без флага — только отчёт)")
+	fs.Parse(args)
+
+	if err := db.InitDatabase(); err != nil {
+		return err
+	}
+	db.InitMongoDB()
+
+	report, err := services.RecomputeUserStatistics(context.Background(), services.RecomputeStatsOptions{
+		UserID:    *userID,
+		BatchSize: *batch,
+		Apply:     *apply,
+	})
+	if report != nil {
+		for _, d := range report.Differences {
+			log.Printf("  пользователь %d: %s: сохранено %v, посчитано %v", d.UserID, d.Field, d.Stored, d.Computed)
+		}
+		log.Printf("Проверено пользователей: %d, с расхождениями: %d, перезаписано: %d",
+			report.UsersChecked, report.UsersChanged, report.UsersUpdated)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !*apply && report.UsersChanged > 0 {
+		log.Println("Изменения не записаны, для перезаписи запустите с -apply")
+	}
+	return nil
+}
+
+func runRebuildCompanions(args []string) error {
+	fs := flag.NewFlagSet("rebuild-companions", flag.ExitOnError)
+	fs.Parse(args)
+
+	if err := db.InitDatabase(); err != nil {
+		return err
+	}
+
+	pairs, err := services.RebuildCoAttendance()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Граф совместных посещений пересобран, записей: %d", pairs)
+	return nil
+}
+
+func runRecountUsers(args []string) error {
+	fs := flag.NewFlagSet("recount-users", flag.ExitOnError)
+	sessionID := fs.Uint("session", 0, "ID сессии (по умолчанию — все)")
+	fs.Parse(args)
+
+	if err := db.InitDatabase(); err != nil {
+		return err
+	}
+
+	fixed, err := services.RecountSessionUsers(*sessionID)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Исправлено сессий: %d", fixed)
+	return nil
+}
+
+func runPurgeVerification(args []string) error {
+	fs := flag.NewFlagSet("purge-verification", flag.ExitOnError)
+	fs.Parse(args)
+
+	if err := db.InitRedis(); err != nil {
+		return err
+	}
+
+	removed, err := db.GlobalSessionStore.PurgeStaleSessions()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Удалено сессий подтверждения: %d", removed)
+	return nil
+}
+
+func runRebuildPopular(args []string) error {
+	fs := flag.NewFlagSet("rebuild-popular", flag.ExitOnError)
+	fs.Parse(args)
+
+	if err := db.InitDatabase(); err != nil {
+		return err
+	}
+	db.InitMongoDB()
+	if err := db.InitRedis(); err != nil {
+		return err
+	}
+
+	if err := services.RefreshPopularSessionsCache(); err != nil {
+		return err
+	}
+
+	log.Println("Кэш популярных сессий пересобран")
+	return nil
+}
+
+func runRebuildLeaderboards(args []string) error {
+	fs := flag.NewFlagSet("rebuild-leaderboards", flag.ExitOnError)
+	fs.Parse(args)
+
+	if err := db.InitDatabase(); err != nil {
+		return err
+	}
+	if err := db.InitRedis(); err != nil {
+		return err
+	}
+
+	processed, err := services.RebuildLeaderboards()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Рейтинги пересобраны, учтено сессий: %d", processed)
+	return nil
+}
@@ -32,13 +32,13 @@ func InitDatabase() error {
 	}
 	db.AutoMigrate(&news.News{}, &news.ContentNews{}, &news.Comments{})
 	db.AutoMigrate(&statsusers.SideStats_users{}, &statsusers.SessionStats_users{}, &statsusers.SessionsStatsGenres_users{},
-		&statsusers.Genre{}, statsusers.PopSessionType{}, statsusers.SettingTile{})
-	db.AutoMigrate(&models.User{}, models.StatsProcessedEvent{}, &models.DeviceUser{},
-		&groups.Group{}, &groups.GroupContact{}, &groups.GroupGroupCategory{}, &models.Category{}, &groups.GroupUsers{}, &groups.GroupJoinRequest{}, &groups.GroupJoinInvite{},
+		&statsusers.Genre{}, statsusers.PopSessionType{}, statsusers.SettingTile{}, &statsusers.CoAttendance_users{}, &statsusers.UserAchievement{})
+	db.AutoMigrate(&models.User{}, models.StatsProcessedEvent{}, &models.DeviceUser{}, &models.Friendship{}, &models.Follow{}, &models.UserBlock{}, &models.UserPrivacy{}, &models.UserTwoFactor{}, &models.UserRecoveryCode{},
+		&groups.Group{}, &groups.GroupContact{}, &groups.GroupGroupCategory{}, &models.Category{}, &groups.GroupUsers{}, &groups.GroupJoinRequest{}, &groups.GroupJoinInvite{}, &groups.GroupMemberLeave{}, &groups.GroupOwnershipTransfer{}, &groups.GroupInviteLink{}, &groups.GroupInviteLinkUse{}, &groups.GroupBan{}, &groups.GroupAuditLog{}, &groups.GroupJoinQuestion{}, &groups.GroupJoinRequestAnswer{}, &groups.GroupAnnouncement{},
 		&sessions.Session{}, &sessions.SessionGroupType{}, &sessions.SessionMetadata{}, sessions.Status{},
 	)
 
-	return db.AutoMigrate(&sessions.SessionUser{})
+	return db.AutoMigrate(&sessions.SessionUser{}, &sessions.SessionInvite{})
 }
 
 func GetDB() *gorm.DB {
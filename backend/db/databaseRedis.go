@@ -82,10 +82,92 @@ func (s *SessionStore) UpdateSessionField(sessionID, field string, value interfa
 	return s.redisClient.HSet(ctx, sessionID, field, value).Err()
 }
 
+// Результаты VerifyCode
+const (
+	CodeVerified      = 1
+	CodeWrong         = 0
+	CodeAttemptsSpent = -1 // попытки исчерпаны, сессия удалена
+	CodeNoSession     = -2
+	CodeWrongType     = -3
+)
+
+// verifyCodeScript проверяет код и считает ошибки атомарно, чтобы параллельные запросы
+// не могли перебрать больше кодов, чем разрешено
+var verifyCodeScript = redis.NewScript(`
+local h = redis.call('HMGET', KEYS[1], 'code', 'type')
+if not h[1] then return -2 end
+if h[2] ~= ARGV[1] then return -3 end
+if h[1] == ARGV[2] then
+	redis.call('HSET', KEYS[1], 'is_verified', '1', 'attempts', 0)
+	return 1
+end
+local attempts = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+if attempts >= tonumber(ARGV[3]) then
+	redis.call('DEL', KEYS[1])
+	return -1
+end
+return 0
+`)
+
+// VerifyCode сверяет код сессии подтверждения. После maxAttempts неверных кодов сессия удаляется.
+func (s *SessionStore) VerifyCode(sessionID string, sessionType models.SessionTypeReg, code string, maxAttempts int) (int, error) {
+	result, err := verifyCodeScript.Run(ctx, s.redisClient, []string{sessionID}, string(sessionType), code, maxAttempts).Int()
+	if err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
 func (s *SessionStore) DeleteSession(sessionID string) error {
 	return s.redisClient.Del(ctx, sessionID).Err()
 }
 
+// PurgeStaleSessions удаляет сессии подтверждения, оставшиеся в Redis без срока жизни.
+// Такое бывает, если HSet отработал уже после истечения ключа и пересоздал его без TTL.
+func (s *SessionStore) PurgeStaleSessions() (int, error) {
+	removed := 0
+	iter := s.redisClient.Scan(ctx, 0, "*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+
+		keyType, err := s.redisClient.Type(ctx, key).Result()
+		if err != nil {
+			return removed, err
+		}
+		if keyType != "hash" {
+			continue
+		}
+
+		sessionType, err := s.redisClient.HGet(ctx, key, "type").Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		if sessionType != string(models.SessionTypeRegister) && sessionType != string(models.SessionTypeResetPassword) &&
+			sessionType != string(models.SessionTypeChangeEmail) {
+			continue
+		}
+
+		ttl, err := s.redisClient.TTL(ctx, key).Result()
+		if err != nil {
+			return removed, err
+		}
+		// -1 — ключ без срока жизни, -2 — ключ уже удалён
+		if ttl != -1 {
+			continue
+		}
+
+		if err := s.redisClient.Del(ctx, key).Err(); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, iter.Err()
+}
+
 func (s *SessionStore) GetRedisClient() *redis.Client {
 	return s.redisClient
 }
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"friendship/models"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Данные пользователя для авторизации кэшируются ненадолго, чтобы не ходить в Postgres
+// на каждый запрос. Изменения роли, email и статуса должны сбрасывать кэш через InvalidatePrincipal.
+// Сессия входа из access токена не кэшируется: выход и отзыв сессий действуют сразу.
+
+const (
+	principalCacheKey = "principal:%d"
+	principalCacheTTL = time.Minute
+
+	// AuthSessionKey — сессия входа (семейство refresh токенов), её ведёт services/ServiceAuthSessions.go
+	AuthSessionKey = "auth_session:%s"
+)
+
+var (
+	ErrPrincipalNotFound  = errors.New("пользователь не найден")
+	ErrAuthSessionRevoked = errors.New("сессия завершена, войдите заново")
+)
+
+// LoadSessionPrincipal возвращает данные пользователя, если его сессия входа sid ещё активна.
+// Проверка сессии и чтение кэша идут одним запросом в Redis.
+func LoadSessionPrincipal(userID uint, sid string) (*models.Principal, error) {
+	redisClient := GetRedis()
+	if redisClient == nil {
+		return nil, errors.New("хранилище сессий недоступно")
+	}
+
+	pipe := redisClient.Pipeline()
+	cached := pipe.Get(ctx, fmt.Sprintf(principalCacheKey, userID))
+	active := pipe.Exists(ctx, fmt.Sprintf(AuthSessionKey, sid))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	if active.Val() == 0 {
+		return nil, ErrAuthSessionRevoked
+	}
+
+	if data, err := cached.Bytes(); err == nil {
+		var principal models.Principal
+		if json.Unmarshal(data, &principal) == nil {
+			return &principal, nil
+		}
+	}
+	return loadPrincipalFromDB(redisClient, userID)
+}
+
+// loadPrincipalFromDB читает пользователя из базы и кладёт в кэш
+func loadPrincipalFromDB(redisClient *redis.Client, userID uint) (*models.Principal, error) {
+	var user models.User
+	if err := GetDB().Select("id", "email", "role", "verified_user", "enterprise").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPrincipalNotFound
+		}
+		return nil, err
+	}
+
+	principal := models.NewPrincipal(user)
+
+	if data, err := json.Marshal(principal); err == nil {
+		redisClient.Set(ctx, fmt.Sprintf(principalCacheKey, userID), data, principalCacheTTL)
+	}
+	return &principal, nil
+}
+
+// InvalidatePrincipal сбрасывает кэш пользователя после изменения его учётных данных
+func InvalidatePrincipal(userID uint) {
+	if redisClient := GetRedis(); redisClient != nil {
+		redisClient.Del(ctx, fmt.Sprintf(principalCacheKey, userID))
+	}
+}
@@ -1,16 +1,22 @@
 package handlers
 
 import (
+	"errors"
+	"friendship/middlewares"
+	"friendship/models"
 	"friendship/services"
 	"friendship/utils"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 type UserRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name" binding:"max=100"` // показывается в списке активных сессий
 }
 
 type RefreshRequest struct {
@@ -23,17 +29,25 @@ type AuthResponse struct {
 	AdminGroups  []services.AdminGroupResponse `json:"admin_groups"`
 }
 
+// TwoFactorChallengeResponse — ответ на вход, если у пользователя включена 2FA
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+}
+
 // AuthUser godoc
 // @Summary      Аутентификация пользователя
-// @Description  Проверяет email и пароль, возвращает access и refresh токены
+// @Description  Проверяет email и пароль, возвращает access и refresh токены. Если у пользователя включена 2FA, вместо токенов возвращается challenge_token для /api/users/login/2fa.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        user  body      UserRequest  true  "Данные пользователя"
 // @Success      200   {object}  AuthResponse  "Токены успешно созданы"
+// @Success      202   {object}  TwoFactorChallengeResponse  "Нужен код двухфакторной аутентификации"
 // @Failure      400   {object}  map[string]string  "Некорректный JSON или параметры"
 // @Failure      401   {object}  map[string]string  "Неверный пароль"
 // @Failure      404   {object}  map[string]string  "Пользователь не найден"
+// @Failure      429   {object}  map[string]string  "Слишком много неудачных попыток, см. заголовок Retry-After"
 // @Failure      500   {object}  map[string]string  "Ошибка сервера"
 // @Router       /api/users/login [post]
 func AuthUser(c *gin.Context) {
@@ -43,24 +57,45 @@ func AuthUser(c *gin.Context) {
 		return
 	}
 
-	user, err := services.FindUserByEmail(input.Email)
+	user, err := services.Login(input.Email, input.Password, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		if tooManyAttempts(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный логин или пароль"})
+			return
+		}
+		if err.Error() == "пользователь не найден" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сервера"})
 		return
 	}
 
-	if !utils.ComparePasswords(input.Password, user.Password, user.Salt) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный логин или пароль"})
+	twoFactor, err := services.TwoFactorEnabled(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сервера"})
+		return
+	}
+	if twoFactor {
+		challenge, err := services.StartTwoFactorChallenge(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сервера"})
+			return
+		}
+		c.JSON(http.StatusAccepted, TwoFactorChallengeResponse{TwoFactorRequired: true, ChallengeToken: challenge})
 		return
 	}
 
-	token, err := utils.GenerateTokenPair(int(user.ID), user.Email, user.Name, user.Us, user.Image)
+	token, err := services.StartAuthSession(user, deviceInfo(c, input.DeviceName))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации токена"})
 		return
 	}
 
-	adminGroups, err := services.GetAdminGroups(&user.Email)
+	adminGroups, err := services.GetAdminGroups(models.NewPrincipal(*user))
 	if err != nil {
 		adminGroups = []services.AdminGroupResponse{}
 	}
@@ -72,9 +107,28 @@ func AuthUser(c *gin.Context) {
 	})
 }
 
+// tooManyAttempts отвечает 429 с Retry-After, если попытка отклонена ограничением
+func tooManyAttempts(c *gin.Context, err error) bool {
+	var tooMany *services.TooManyAttemptsError
+	if !errors.As(err, &tooMany) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(tooMany.RetryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	return true
+}
+
+func deviceInfo(c *gin.Context, name string) services.DeviceInfo {
+	return services.DeviceInfo{
+		Name:      name,
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
 // RefreshTokenHandler godoc
 // @Summary      Обновление токенов
-// @Description  По refresh токену выдает новые access и refresh токены
+// @Description  По refresh токену выдает новые access и refresh токены. Refresh токен одноразовый: повторное использование завершает сессию этого входа.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
@@ -91,8 +145,12 @@ func RefreshTokenHandler(c *gin.Context) {
 		return
 	}
 
-	newTokens, err := utils.RefreshTokens(req.RefreshToken, services.FindUserByEmail)
+	newTokens, err := services.RotateRefreshToken(req.RefreshToken, deviceInfo(c, ""))
 	if err != nil {
+		if errors.Is(err, services.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Невалидный или просроченный refresh token"})
 		return
 	}
@@ -103,6 +161,106 @@ func RefreshTokenHandler(c *gin.Context) {
 	})
 }
 
+// JWKSHandler godoc
+// @Summary      Открытые ключи JWT
+// @Description  JWKS с открытыми ключами Ed25519 для проверки подписи токенов другими сервисами. Ключ выбирается по kid из заголовка токена.
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  utils.JWKSet  "Набор ключей"
+// @Router       /.well-known/jwks.json [get]
+func JWKSHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.PublicJWKS())
+}
+
+// LogoutHandler godoc
+// @Summary      Выход
+// @Description  Завершает сессию, которой принадлежит refresh токен. Access токен остаётся действительным до истечения срока.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        refreshRequest  body      RefreshRequest  true  "Refresh токен"
+// @Success      200             {object}  map[string]string  "Сессия завершена"
+// @Failure      400             {object}  map[string]string  "Отсутствует refresh_token"
+// @Failure      401             {object}  map[string]string  "Невалидный refresh token"
+// @Router       /api/users/logout [post]
+func LogoutHandler(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Отсутствует refresh_token в запросе"})
+		return
+	}
+
+	if err := services.Logout(req.RefreshToken); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Сессия завершена"})
+}
+
+// LogoutAllHandler godoc
+// @Summary      Выйти на всех устройствах
+// @Description  Завершает все сессии пользователя, включая текущую.
+// @Tags         auth
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {object}  map[string]string  "Все сессии завершены"
+// @Failure      500  {object}  map[string]string  "Ошибка сервера"
+// @Router       /api/users/logout-all [post]
+func LogoutAllHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	if err := services.LogoutAll(principal); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Все сессии завершены"})
+}
+
+// GetAuthSessionsHandler godoc
+// @Summary      Активные сессии
+// @Description  Список устройств, на которых выполнен вход.
+// @Tags         auth
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}   services.AuthSessionDTO  "Активные сессии"
+// @Failure      500  {object}  map[string]string  "Ошибка сервера"
+// @Router       /api/users/auth-sessions [get]
+func GetAuthSessionsHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	authSessions, err := services.GetAuthSessions(principal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, authSessions)
+}
+
+// RevokeAuthSessionHandler godoc
+// @Summary      Завершить сессию
+// @Description  Завершает вход на выбранном устройстве.
+// @Tags         auth
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id   path      string  true  "ID сессии"
+// @Success      200  {object}  map[string]string  "Сессия завершена"
+// @Failure      404  {object}  map[string]string  "Сессия не найдена"
+// @Router       /api/users/auth-sessions/{id} [delete]
+func RevokeAuthSessionHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	if err := services.RevokeUserAuthSession(principal, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Сессия завершена"})
+}
+
 // RequestPasswordReset godoc
 // @Summary      Запрос на сброс пароля
 // @Description  Пользователь указывает email, на него отправляется код подтверждения для смены пароля.
@@ -131,13 +289,14 @@ func RequestPasswordReset(c *gin.Context) {
 
 // ConfirmPasswordReset godoc
 // @Summary      Сброс пароля
-// @Description  Пользователь вводит session_id, код из email и новый пароль. При успешной верификации пароль меняется.
+// @Description  Пользователь вводит session_id, код из email и новый пароль. Если включена 2FA, нужен ещё two_factor_code — код из приложения или код восстановления. При успешной верификации пароль меняется.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        input  body  services.ConfirmResetPasswordInput  true  "Данные для подтверждения и новый пароль"
 // @Success      200    {object} map[string]string
 // @Failure      400    {object} map[string]string
+// @Failure      429    {object} map[string]string "Слишком много неверных кодов 2FA"
 // @Router       /api/users/confirm-reset [post]
 func ConfirmPasswordReset(c *gin.Context) {
 	var input services.ConfirmResetPasswordInput
@@ -147,6 +306,9 @@ func ConfirmPasswordReset(c *gin.Context) {
 	}
 
 	if err := services.ResetPassword(input); err != nil {
+		if tooManyAttempts(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
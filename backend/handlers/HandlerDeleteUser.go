@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"friendship/middlewares"
 	"friendship/services"
 	"net/http"
 
@@ -18,23 +19,9 @@ import (
 // @Failure      401  {object}  map[string]string "Пользователь не авторизован"
 // @Router       /api/users/delete [delete]
 func DeleteAccount(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 
-	email, ok := emailValue.(string)
-	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат email в контексте"})
-		return
-	}
-
-	if email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "email не может быть пустым"})
-		return
-	}
-	err := services.DeleteAccount(email)
+	err := services.DeleteAccount(principal)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
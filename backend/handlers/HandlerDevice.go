@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"friendship/middlewares"
 	"friendship/services"
 	"net/http"
 
@@ -21,18 +22,7 @@ import (
 // @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
 // @Router /api/device-tokens/register [post]
 func RegisterDeviceToken(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-	email := emailValue.(string)
-
-	user, err := services.FindUserByEmail(email)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не найден"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 
 	var req services.RegisterDeviceTokenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -40,7 +30,7 @@ func RegisterDeviceToken(c *gin.Context) {
 		return
 	}
 
-	response, err := services.RegisterOrUpdateDeviceToken(user.ID, req)
+	response, err := services.RegisterOrUpdateDeviceToken(principal.UserID, req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -63,20 +53,9 @@ func RegisterDeviceToken(c *gin.Context) {
 // @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
 // @Router /api/device-tokens [get]
 func GetMyDevices(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-	email := emailValue.(string)
-
-	user, err := services.FindUserByEmail(email)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не найден"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 
-	devices, err := services.GetUserDevices(user.ID)
+	devices, err := services.GetUserDevices(principal.UserID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -100,18 +79,7 @@ func GetMyDevices(c *gin.Context) {
 // @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
 // @Router /api/device-tokens/deactivate [post]
 func DeactivateDeviceToken(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-	email := emailValue.(string)
-
-	user, err := services.FindUserByEmail(email)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не найден"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 
 	var req struct {
 		DeviceToken string `json:"device_token" binding:"required"`
@@ -121,7 +89,7 @@ func DeactivateDeviceToken(c *gin.Context) {
 		return
 	}
 
-	if err := services.DeactivateDeviceToken(user.ID, req.DeviceToken); err != nil {
+	if err := services.DeactivateDeviceToken(principal.UserID, req.DeviceToken); err != nil {
 		if err.Error() == "токен не найден" {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
@@ -148,18 +116,7 @@ func DeactivateDeviceToken(c *gin.Context) {
 // @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
 // @Router /api/device-tokens [delete]
 func DeleteDeviceToken(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-	email := emailValue.(string)
-
-	user, err := services.FindUserByEmail(email)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не найден"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 
 	deviceToken := c.Query("device_token")
 	if deviceToken == "" {
@@ -167,7 +124,7 @@ func DeleteDeviceToken(c *gin.Context) {
 		return
 	}
 
-	if err := services.DeleteDeviceToken(user.ID, deviceToken); err != nil {
+	if err := services.DeleteDeviceToken(principal.UserID, deviceToken); err != nil {
 		if err.Error() == "токен не найден" {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
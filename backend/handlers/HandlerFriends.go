@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"friendship/middlewares"
+	"friendship/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FriendRequestInput struct {
+	UserID uint `json:"user_id" binding:"required"`
+}
+
+// GetFriendsHandler godoc
+// @Summary      Список друзей
+// @Description  Возвращает друзей текущего пользователя в алфавитном порядке.
+// @Tags         Friends
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}   services.FriendDTO "Друзья"
+// @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router       /api/users/friends [get]
+func GetFriendsHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	friends, err := services.GetFriends(principal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, friends)
+}
+
+// GetFriendRequestsHandler godoc
+// @Summary      Заявки в друзья
+// @Description  Возвращает входящие и исходящие заявки, ожидающие ответа.
+// @Tags         Friends
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {object}  services.FriendRequestsResponse "Заявки"
+// @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router       /api/users/friends/requests [get]
+func GetFriendRequestsHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	requests, err := services.GetFriendRequests(principal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// SendFriendRequestHandler godoc
+// @Summary      Отправить заявку в друзья
+// @Description  Отправляет заявку пользователю. Если он уже отправил встречную заявку, дружба подтверждается сразу.
+// @Tags         Friends
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        input body FriendRequestInput true "ID пользователя"
+// @Success      200  {object}  services.FriendRequestResult "Заявка отправлена или дружба подтверждена"
+// @Failure      400  {object}  map[string]string "Ошибка"
+// @Router       /api/users/friends/requests [post]
+func SendFriendRequestHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	var input FriendRequestInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный json", "details": err.Error()})
+		return
+	}
+
+	res, err := services.SendFriendRequest(principal, input.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// AcceptFriendRequestHandler godoc
+// @Summary      Принять заявку в друзья
+// @Tags         Friends
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id path int true "ID заявки"
+// @Success      200  {object}  map[string]string "Заявка принята"
+// @Failure      400  {object}  map[string]string "Заявка не найдена или уже обработана"
+// @Router       /api/users/friends/requests/{id}/accept [post]
+func AcceptFriendRequestHandler(c *gin.Context) {
+	respondFriendRequest(c, true)
+}
+
+// DeclineFriendRequestHandler godoc
+// @Summary      Отклонить заявку в друзья
+// @Tags         Friends
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id path int true "ID заявки"
+// @Success      200  {object}  map[string]string "Заявка отклонена"
+// @Failure      400  {object}  map[string]string "Заявка не найдена или уже обработана"
+// @Router       /api/users/friends/requests/{id}/decline [post]
+func DeclineFriendRequestHandler(c *gin.Context) {
+	respondFriendRequest(c, false)
+}
+
+func respondFriendRequest(c *gin.Context, accept bool) {
+	principal := middlewares.GetPrincipal(c)
+
+	requestID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || requestID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный id"})
+		return
+	}
+
+	if err := services.RespondFriendRequest(principal, uint(requestID), accept); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if accept {
+		c.JSON(http.StatusOK, gin.H{"message": "Заявка принята"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Заявка отклонена"})
+}
+
+// RemoveFriendHandler godoc
+// @Summary      Удалить из друзей
+// @Description  Удаляет пользователя из друзей. Также отменяет заявку в любом направлении.
+// @Tags         Friends
+// @Security     BearerAuth
+// @Produce      json
+// @Param        userId path int true "ID пользователя"
+// @Success      200  {object}  map[string]string "Пользователь удалён из друзей"
+// @Failure      400  {object}  map[string]string "Пользователь не в друзьях"
+// @Router       /api/users/friends/{userId} [delete]
+func RemoveFriendHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.RemoveFriend(principal, userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Пользователь удалён из друзей"})
+}
+
+// FollowUserHandler godoc
+// @Summary      Подписаться на пользователя
+// @Description  Односторонняя подписка, подтверждение не требуется.
+// @Tags         Friends
+// @Security     BearerAuth
+// @Produce      json
+// @Param        userId path int true "ID пользователя"
+// @Success      200  {object}  map[string]string "Вы подписались"
+// @Failure      400  {object}  map[string]string "Ошибка"
+// @Router       /api/users/follows/{userId} [post]
+func FollowUserHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.FollowUser(principal, userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Вы подписались"})
+}
+
+// UnfollowUserHandler godoc
+// @Summary      Отписаться от пользователя
+// @Tags         Friends
+// @Security     BearerAuth
+// @Produce      json
+// @Param        userId path int true "ID пользователя"
+// @Success      200  {object}  map[string]string "Вы отписались"
+// @Failure      400  {object}  map[string]string "Вы не подписаны"
+// @Router       /api/users/follows/{userId} [delete]
+func UnfollowUserHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.UnfollowUser(principal, userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Вы отписались"})
+}
+
+// GetFollowersHandler godoc
+// @Summary      Подписчики
+// @Tags         Friends
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}   services.FriendDTO "Подписчики"
+// @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router       /api/users/followers [get]
+func GetFollowersHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	followers, err := services.GetFollows(principal, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, followers)
+}
+
+// GetFollowingHandler godoc
+// @Summary      Подписки
+// @Tags         Friends
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}   services.FriendDTO "Пользователи, на которых вы подписаны"
+// @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router       /api/users/following [get]
+func GetFollowingHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	following, err := services.GetFollows(principal, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, following)
+}
+
+func parseUserIDParam(c *gin.Context) (uint, bool) {
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil || userID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID пользователя"})
+		return 0, false
+	}
+	return uint(userID), true
+}
+
+// InviteFriendsToSessionHandler godoc
+// @Summary      Пригласить друзей на сессию
+// @Description  Участник сессии приглашает друзей. Пропускаются не друзья, уже записавшиеся, заблокированные в группе и не состоящие в приватной группе.
+// @Tags         Сессии
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        sessionId path int true "ID сессии"
+// @Param        input body services.InviteFriendsInput true "ID друзей"
+// @Success      200  {object}  services.InviteFriendsResponse "Приглашённые и пропущенные"
+// @Failure      400  {object}  map[string]string "Ошибка"
+// @Router       /api/sessions/{sessionId}/invite [post]
+func InviteFriendsToSessionHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	sessionID, err := strconv.ParseUint(c.Param("sessionId"), 10, 32)
+	if err != nil || sessionID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID сессии"})
+		return
+	}
+
+	var input services.InviteFriendsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный json", "details": err.Error()})
+		return
+	}
+
+	res, err := services.InviteFriendsToSession(principal, uint(sessionID), input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// AcceptSessionInviteHandler godoc
+// @Summary      Принять приглашение на сессию
+// @Description  Записывает пользователя на сессию по приглашению друга.
+// @Tags         Users inf
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id path int true "ID приглашения"
+// @Success      200  {object}  map[string]string "Вы записаны на сессию"
+// @Failure      400  {object}  map[string]string "Ошибка"
+// @Router       /api/users/session-invites/{id}/accept [put]
+func AcceptSessionInviteHandler(c *gin.Context) {
+	respondSessionInvite(c, true)
+}
+
+// DeclineSessionInviteHandler godoc
+// @Summary      Отклонить приглашение на сессию
+// @Tags         Users inf
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id path int true "ID приглашения"
+// @Success      200  {object}  map[string]string "Приглашение отклонено"
+// @Failure      400  {object}  map[string]string "Ошибка"
+// @Router       /api/users/session-invites/{id}/decline [put]
+func DeclineSessionInviteHandler(c *gin.Context) {
+	respondSessionInvite(c, false)
+}
+
+func respondSessionInvite(c *gin.Context, accept bool) {
+	principal := middlewares.GetPrincipal(c)
+
+	inviteID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || inviteID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный id"})
+		return
+	}
+
+	if err := services.RespondSessionInvite(principal, uint(inviteID), accept); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if accept {
+		c.JSON(http.StatusOK, gin.H{"message": "Вы записаны на сессию"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Приглашение отклонено"})
+}
@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"fmt"
+	"friendship/middlewares"
 	"friendship/services"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,13 +24,8 @@ import (
 // @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
 // @Router       /api/admin/groups [get]
 func GetAdminGroups(c *gin.Context) {
-	email := c.MustGet("email").(string)
-	if email == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не передан jwt"})
-		return
-	}
-
-	groups, err := services.GetAdminGroups(&email)
+	principal := middlewares.GetPrincipal(c)
+	groups, err := services.GetAdminGroups(principal)
 	if err != nil {
 		if strings.Contains(err.Error(), "пользователь не найден") {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
@@ -42,7 +40,7 @@ func GetAdminGroups(c *gin.Context) {
 
 // GetInfAdminGroup godoc
 // @Summary      Получить детальную информацию о группе для администратора
-// @Description  Возвращает детальную информацию о группе, созданной пользователем, включая заявки на вступление и сессии в статусе 'Набор'. Доступно только создателю группы.
+// @Description  Возвращает детальную информацию о группе, созданной пользователем, включая заявки на вступление и сессии в статусе 'Набор'. Доступно участникам с правом approve_requests.
 // @Tags         groups_admin
 // @Security     BearerAuth
 // @Produce      json
@@ -50,12 +48,12 @@ func GetAdminGroups(c *gin.Context) {
 // @Success      200  {object}  services.AdminGroupInfResponse "Детальная информация о группе"
 // @Failure      400  {object}  map[string]string "Некорректный ID группы"
 // @Failure      401  {object}  map[string]string "Пользователь не авторизован"
-// @Failure      403  {object}  map[string]string "Доступ запрещен (пользователь не является создателем)"
+// @Failure      403  {object}  map[string]string "Доступ запрещен (нет права approve_requests)"
 // @Failure      404  {object}  map[string]string "Группа не найдена"
 // @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
 // @Router       /api/admin/groups/{groupId}/infGroup [get]
 func GetInfAdminGroup(c *gin.Context) {
-	email := c.MustGet("email").(string)
+	principal := middlewares.GetPrincipal(c)
 
 	groupIDStr := c.Param("groupId")
 	groupID64, err := strconv.ParseUint(groupIDStr, 10, 32)
@@ -64,7 +62,7 @@ func GetInfAdminGroup(c *gin.Context) {
 		return
 	}
 	groupID := uint(groupID64)
-	group, err := services.GetAdminGroupInfo(email, &groupID)
+	group, err := services.GetAdminGroupInfo(principal, &groupID)
 	if err != nil {
 		errStr := err.Error()
 		if strings.Contains(errStr, "invalid group ID") {
@@ -80,3 +78,198 @@ func GetInfAdminGroup(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, group)
 }
+
+// GetGroupCohesion godoc
+// @Summary      Сплочённость группы
+// @Description  Показывает, насколько участники группы ходят на сессии вместе: активные участники, среднее число участников сессии, доля пар, бывших вместе хотя бы раз.
+// @Tags         groups_admin
+// @Security     BearerAuth
+// @Produce      json
+// @Param        groupId path int true "ID группы"
+// @Success      200  {object}  services.GroupCohesion "Метрики сплочённости"
+// @Failure      400  {object}  map[string]string "Некорректный ID группы"
+// @Failure      403  {object}  map[string]string "Нет прав в группе"
+// @Failure      404  {object}  map[string]string "Группа не найдена"
+// @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router       /api/admin/groups/{groupId}/cohesion [get]
+func GetGroupCohesion(c *gin.Context) {
+	groupID64, err := strconv.ParseUint(c.Param("groupId"), 10, 32)
+	if err != nil || groupID64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return
+	}
+
+	cohesion, err := services.GetGroupCohesion(uint(groupID64))
+	if err != nil {
+		if err.Error() == "группа не найдена" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, cohesion)
+}
+
+// GetGroupAnalytics godoc
+// @Summary      Аналитика группы
+// @Description  Сессии по месяцам, заполняемость и средняя посещаемость, рост и отток участников, самые активные участники, популярные жанры и временные слоты.
+// @Tags         groups_admin
+// @Security     BearerAuth
+// @Produce      json
+// @Param        groupId path int true "ID группы"
+// @Param        months query int false "За сколько последних месяцев (по умолчанию 12, максимум 36)"
+// @Success      200  {object}  services.GroupAnalytics "Аналитика группы"
+// @Failure      400  {object}  map[string]string "Некорректные параметры"
+// @Failure      403  {object}  map[string]string "Нет прав в группе"
+// @Failure      404  {object}  map[string]string "Группа не найдена"
+// @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router       /api/admin/groups/{groupId}/analytics [get]
+func GetGroupAnalytics(c *gin.Context) {
+	analytics, ok := loadGroupAnalytics(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, analytics)
+}
+
+// ExportGroupAnalyticsCSV godoc
+// @Summary      Выгрузка аналитики группы в CSV
+// @Description  Та же аналитика, что и /analytics, в виде CSV-файла: сводка, помесячная динамика, активные участники, жанры и временные слоты.
+// @Tags         groups_admin
+// @Security     BearerAuth
+// @Produce      text/csv
+// @Param        groupId path int true "ID группы"
+// @Param        months query int false "За сколько последних месяцев (по умолчанию 12, максимум 36)"
+// @Success      200  {file}    file "CSV-файл"
+// @Failure      400  {object}  map[string]string "Некорректные параметры"
+// @Failure      403  {object}  map[string]string "Нет прав в группе"
+// @Failure      404  {object}  map[string]string "Группа не найдена"
+// @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router       /api/admin/groups/{groupId}/analytics/export [get]
+func ExportGroupAnalyticsCSV(c *gin.Context) {
+	analytics, ok := loadGroupAnalytics(c)
+	if !ok {
+		return
+	}
+
+	data, err := services.GroupAnalyticsCSV(analytics)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось сформировать CSV"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="group_%d_analytics.csv"`, analytics.GroupID))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+}
+
+func loadGroupAnalytics(c *gin.Context) (*services.GroupAnalytics, bool) {
+	groupID64, err := strconv.ParseUint(c.Param("groupId"), 10, 32)
+	if err != nil || groupID64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return nil, false
+	}
+
+	var months int
+	if monthsParam := c.Query("months"); monthsParam != "" {
+		months, err = strconv.Atoi(monthsParam)
+		if err != nil || months <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "некорректное количество месяцев"})
+			return nil, false
+		}
+	}
+
+	analytics, err := services.GetGroupAnalytics(uint(groupID64), months)
+	if err != nil {
+		if err.Error() == "группа не найдена" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return analytics, true
+}
+
+// GetGroupAuditLogHandler godoc
+// @Summary      Журнал действий в группе
+// @Description  Постраничный журнал действий администраторов и операторов: изменения группы, роли, исключения, блокировки, заявки, ссылки-приглашения и сессии. Новые записи первыми. Журнал доступен только администраторам и не редактируется.
+// @Tags         groups_admin
+// @Security     BearerAuth
+// @Produce      json
+// @Param        groupId path int true "ID группы"
+// @Param        action query string false "Тип действия, например member.banned"
+// @Param        actor_id query int false "Кто совершил действие"
+// @Param        target_type query string false "Тип объекта: group, user, session, request, invite_link, transfer"
+// @Param        target_id query int false "ID объекта"
+// @Param        from query string false "Начало периода (RFC3339 или 2006-01-02)"
+// @Param        to query string false "Конец периода, не включительно (RFC3339 или 2006-01-02)"
+// @Param        page query int false "Номер страницы (с 1)"
+// @Param        page_size query int false "Размер страницы (по умолчанию 20, максимум 100)"
+// @Success      200  {object}  services.GroupAuditPage "Страница журнала"
+// @Failure      400  {object}  map[string]string "Некорректные параметры"
+// @Failure      403  {object}  map[string]string "Нет прав в группе"
+// @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router       /api/admin/groups/{groupId}/audit [get]
+func GetGroupAuditLogHandler(c *gin.Context) {
+	groupID64, err := strconv.ParseUint(c.Param("groupId"), 10, 32)
+	if err != nil || groupID64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return
+	}
+
+	filter := services.GroupAuditFilter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+	}
+
+	uintParams := map[string]*uint{"actor_id": &filter.ActorID, "target_id": &filter.TargetID}
+	for name, dst := range uintParams {
+		if raw := c.Query(name); raw != "" {
+			v, err := strconv.ParseUint(raw, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный параметр " + name})
+				return
+			}
+			*dst = uint(v)
+		}
+	}
+
+	intParams := map[string]*int{"page": &filter.Page, "page_size": &filter.PageSize}
+	for name, dst := range intParams {
+		if raw := c.Query(name); raw != "" {
+			v, err := strconv.Atoi(raw)
+			if err != nil || v < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный параметр " + name})
+				return
+			}
+			*dst = v
+		}
+	}
+
+	timeParams := map[string]**time.Time{"from": &filter.From, "to": &filter.To}
+	for name, dst := range timeParams {
+		if raw := c.Query(name); raw != "" {
+			t, err := parseAuditTime(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "некорректная дата " + name + ", ожидается RFC3339 или ГГГГ-ММ-ДД"})
+				return
+			}
+			*dst = &t
+		}
+	}
+
+	page, err := services.GetGroupAuditLog(uint(groupID64), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func parseAuditTime(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", raw, time.Local)
+}
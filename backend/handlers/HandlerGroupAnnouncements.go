@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"fmt"
+	"friendship/middlewares"
+	"friendship/services"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateAnnouncementHandler godoc
+// @Summary      Опубликовать объявление в группе
+// @Description  Публикует объявление для участников группы. Доступно администраторам и операторам. До 4 изображений в поле images, до 3 закреплённых объявлений. Участники получают уведомление по своей настройке в группе.
+// @Tags         groups_admin
+// @Security     BearerAuth
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        groupId path int true "ID группы"
+// @Param        title formData string true "Заголовок (до 120 символов)"
+// @Param        text formData string true "Текст (до 4000 символов)"
+// @Param        pinned formData bool false "Закрепить объявление"
+// @Param        images formData file false "Изображения"
+// @Success      201  {object}  services.AnnouncementResponse "Объявление"
+// @Failure      400  {object}  map[string]string "Ошибка валидации"
+// @Failure      403  {object}  map[string]string "Нет прав в группе"
+// @Failure      500  {object}  map[string]string "Ошибка загрузки изображения"
+// @Router       /api/admin/groups/{groupId}/announcements [post]
+func CreateAnnouncementHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 32)
+	if err != nil || groupID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return
+	}
+
+	var input services.CreateAnnouncementInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные", "details": err.Error()})
+		return
+	}
+
+	var headers []*multipart.FileHeader
+	if form, err := c.MultipartForm(); err == nil {
+		headers = form.File["images"]
+	}
+	if len(headers) > services.MaxAnnouncementImages {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("можно прикрепить не больше %d изображений", services.MaxAnnouncementImages)})
+		return
+	}
+
+	for _, header := range headers {
+		if err := middlewares.ValidateImageMIME(header); err != nil {
+			deleteUploadedImages(input.Images)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неподдерживаемый тип файла", "details": err.Error()})
+			return
+		}
+		if header.Size > 10*1024*1024 {
+			deleteUploadedImages(input.Images)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Файл слишком большой, максимум 10MB"})
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			deleteUploadedImages(input.Images)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось открыть изображение"})
+			return
+		}
+		imageURL, err := middlewares.UploadImageToS3(file, header.Filename, "announcements")
+		file.Close()
+		if err != nil {
+			deleteUploadedImages(input.Images)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка загрузки изображения: " + err.Error()})
+			return
+		}
+		input.Images = append(input.Images, imageURL)
+	}
+
+	announcement, err := services.CreateGroupAnnouncement(principal, uint(groupID), input)
+	if err != nil {
+		deleteUploadedImages(input.Images)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, announcement)
+}
+
+func deleteUploadedImages(urls []string) {
+	for _, url := range urls {
+		middlewares.DeleteImageFromS3(url)
+	}
+}
+
+type PinAnnouncementInput struct {
+	Pinned *bool `json:"pinned" binding:"required"`
+}
+
+// PinAnnouncementHandler godoc
+// @Summary      Закрепить или открепить объявление
+// @Description  Закреплённые объявления показываются первыми, их может быть не больше 3. При закреплении уведомляются участники, выбравшие уведомления только о закреплённых.
+// @Tags         groups_admin
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        groupId path int true "ID группы"
+// @Param        announcementId path int true "ID объявления"
+// @Param        input body PinAnnouncementInput true "pinned: true — закрепить, false — открепить"
+// @Success      200  {object}  map[string]string "Готово"
+// @Failure      400  {object}  map[string]string "Ошибка"
+// @Failure      403  {object}  map[string]string "Нет прав в группе"
+// @Router       /api/admin/groups/{groupId}/announcements/{announcementId}/pin [patch]
+func PinAnnouncementHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	groupID, announcementID, ok := parseAnnouncementParams(c)
+	if !ok {
+		return
+	}
+
+	var input PinAnnouncementInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный json", "details": err.Error()})
+		return
+	}
+
+	if err := services.SetAnnouncementPinned(principal, groupID, announcementID, *input.Pinned); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if *input.Pinned {
+		c.JSON(http.StatusOK, gin.H{"message": "Объявление закреплено"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Объявление откреплено"})
+}
+
+// DeleteAnnouncementHandler godoc
+// @Summary      Удалить объявление
+// @Description  Удаляет объявление группы вместе с изображениями.
+// @Tags         groups_admin
+// @Security     BearerAuth
+// @Produce      json
+// @Param        groupId path int true "ID группы"
+// @Param        announcementId path int true "ID объявления"
+// @Success      200  {object}  map[string]string "Объявление удалено"
+// @Failure      403  {object}  map[string]string "Нет прав в группе"
+// @Failure      404  {object}  map[string]string "Объявление не найдено"
+// @Router       /api/admin/groups/{groupId}/announcements/{announcementId} [delete]
+func DeleteAnnouncementHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	groupID, announcementID, ok := parseAnnouncementParams(c)
+	if !ok {
+		return
+	}
+
+	if err := services.DeleteGroupAnnouncement(principal, groupID, announcementID); err != nil {
+		if err.Error() == "объявление не найдено" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Объявление удалено"})
+}
+
+func parseAnnouncementParams(c *gin.Context) (uint, uint, bool) {
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 32)
+	if err != nil || groupID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return 0, 0, false
+	}
+	announcementID, err := strconv.ParseUint(c.Param("announcementId"), 10, 32)
+	if err != nil || announcementID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID объявления"})
+		return 0, 0, false
+	}
+	return uint(groupID), uint(announcementID), true
+}
+
+// GetGroupAnnouncementsHandler godoc
+// @Summary      Объявления группы
+// @Description  Постраничный список объявлений: закреплённые первыми, затем новые. Для закрытой группы доступно только участникам.
+// @Tags         groups
+// @Security     BearerAuth
+// @Produce      json
+// @Param        groupId path int true "ID группы"
+// @Param        page query int false "Номер страницы" default(1)
+// @Success      200  {object}  services.AnnouncementsPage "Объявления"
+// @Failure      400  {object}  map[string]string "Некорректный ID группы"
+// @Failure      403  {object}  map[string]string "Доступ к приватной группе запрещен"
+// @Router       /api/groups/{groupId}/announcements [get]
+func GetGroupAnnouncementsHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 32)
+	if err != nil || groupID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	res, err := services.GetGroupAnnouncements(principal, groupID, page)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+type GroupNotifySettingsInput struct {
+	Announcements string `json:"announcements" binding:"required" example:"all"` // all, pinned, none
+}
+
+// SetGroupNotifySettingsHandler godoc
+// @Summary      Настройка уведомлений об объявлениях группы
+// @Description  all — уведомлять обо всех объявлениях, pinned — только о закреплённых, none — не уведомлять.
+// @Tags         groups
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        groupId path int true "ID группы"
+// @Param        input body GroupNotifySettingsInput true "Настройка"
+// @Success      200  {object}  map[string]string "Настройка сохранена"
+// @Failure      400  {object}  map[string]string "Некорректная настройка или пользователь не в группе"
+// @Router       /api/groups/{groupId}/notifications [patch]
+func SetGroupNotifySettingsHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 32)
+	if err != nil || groupID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return
+	}
+
+	var input GroupNotifySettingsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный json", "details": err.Error()})
+		return
+	}
+
+	if err := services.SetGroupAnnouncementsNotify(principal, uint(groupID), input.Announcements); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Настройка сохранена"})
+}
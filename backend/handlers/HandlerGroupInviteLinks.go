@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"friendship/middlewares"
+	"friendship/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateInviteLinkHandler godoc
+// @Summary      Создать ссылку-приглашение
+// @Description  Создаёт ссылку с токеном для вступления в группу. Можно задать срок действия в часах, лимит использований и автоодобрение (вступление в закрытую группу без заявки).
+// @Tags         groups_admin
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        groupId path int true "ID группы"
+// @Param        input body services.CreateInviteLinkInput true "Параметры ссылки"
+// @Success      201  {object}  services.InviteLinkResponse "Ссылка создана"
+// @Failure      400  {object}  map[string]string "Некорректные параметры"
+// @Failure      403  {object}  map[string]string "Нет прав в группе"
+// @Router       /api/admin/groups/{groupId}/invite-links [post]
+func CreateInviteLinkHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return
+	}
+
+	var input services.CreateInviteLinkInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "не удалось распарсить json", "details": err.Error()})
+		return
+	}
+
+	link, err := services.CreateInviteLink(principal, uint(groupID), input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, link)
+}
+
+// GetInviteLinksHandler godoc
+// @Summary      Ссылки-приглашения группы
+// @Description  Возвращает все ссылки группы со статистикой: сколько раз использована, сколько человек вступило и сколько отправило заявку.
+// @Tags         groups_admin
+// @Security     BearerAuth
+// @Produce      json
+// @Param        groupId path int true "ID группы"
+// @Success      200  {array}   services.InviteLinkResponse "Ссылки"
+// @Failure      400  {object}  map[string]string "Некорректный ID группы"
+// @Failure      404  {object}  map[string]string "Группа не найдена"
+// @Router       /api/admin/groups/{groupId}/invite-links [get]
+func GetInviteLinksHandler(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return
+	}
+
+	links, err := services.GetGroupInviteLinks(uint(groupID))
+	if err != nil {
+		if err.Error() == "группа не найдена" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
+
+// RevokeInviteLinkHandler godoc
+// @Summary      Отозвать ссылку-приглашение
+// @Description  Делает ссылку недействительной. Вступившие по ней участники остаются в группе.
+// @Tags         groups_admin
+// @Security     BearerAuth
+// @Produce      json
+// @Param        groupId path int true "ID группы"
+// @Param        linkId path int true "ID ссылки"
+// @Success      200  {object}  map[string]string "Ссылка отозвана"
+// @Failure      400  {object}  map[string]string "Некорректные параметры"
+// @Failure      404  {object}  map[string]string "Ссылка не найдена"
+// @Router       /api/admin/groups/{groupId}/invite-links/{linkId} [delete]
+func RevokeInviteLinkHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return
+	}
+	linkID, err := strconv.ParseUint(c.Param("linkId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID ссылки"})
+		return
+	}
+
+	if err := services.RevokeInviteLink(principal, uint(groupID), uint(linkID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ссылка отозвана"})
+}
+
+// GetInviteLinkPreviewHandler godoc
+// @Summary      Информация о группе по ссылке-приглашению
+// @Description  Показывает, в какую группу ведёт ссылка, и действительна ли она.
+// @Tags         groups
+// @Security     BearerAuth
+// @Produce      json
+// @Param        token path string true "Токен ссылки"
+// @Success      200  {object}  services.InviteLinkPreview "Группа"
+// @Failure      404  {object}  map[string]string "Ссылка не найдена или недействительна"
+// @Router       /api/groups/invite/{token} [get]
+func GetInviteLinkPreviewHandler(c *gin.Context) {
+	preview, err := services.GetInviteLinkPreview(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+type RedeemInviteLinkInput struct {
+	Answers []services.JoinAnswerInput `json:"answers"`
+}
+
+// RedeemInviteLinkHandler godoc
+// @Summary      Вступить в группу по ссылке
+// @Description  Вступление по ссылке-приглашению. Работает как joinToGroup: в закрытую группу создаётся заявка, если у ссылки не включено автоодобрение. Тогда в теле передаются ответы на анкету группы.
+// @Tags         groups
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        token path string true "Токен ссылки"
+// @Param        input body RedeemInviteLinkInput false "Ответы на анкету закрытой группы"
+// @Success      200  {object}  map[string]interface{} "Результат вступления"
+// @Failure      400  {object}  map[string]string "Ссылка недействительна или пользователь уже в группе"
+// @Router       /api/groups/invite/{token}/join [post]
+func RedeemInviteLinkHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	var input RedeemInviteLinkInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный json", "details": err.Error()})
+			return
+		}
+	}
+
+	res, err := services.RedeemInviteLink(principal, c.Param("token"), input.Answers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": res.Message,
+		"joined":  res.Joined,
+	})
+}
+
+type botRedeemInviteInput struct {
+	TelegramID string `json:"telegram_id" binding:"required"`
+	Token      string `json:"token" binding:"required"`
+}
+
+// RedeemInviteLinkBot — вступление по ссылке из Telegram-чата через бота
+func RedeemInviteLinkBot(c *gin.Context) {
+	var input botRedeemInviteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := services.RedeemInviteLinkByTelegram(input.TelegramID, input.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": res.Message,
+		"joined":  res.Joined,
+	})
+}
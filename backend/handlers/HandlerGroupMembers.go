@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"friendship/middlewares"
+	"friendship/models"
 	"friendship/services"
 	"net/http"
 	"strconv"
@@ -23,11 +25,11 @@ import (
 // @Failure 500 {object} map[string]string "Внутренняя ошибка"
 // @Router /api/admin/groups/{groupId}/members/{userId} [delete]
 func RemoveUserHandler(c *gin.Context) {
-	email := c.MustGet("email").(string)
+	principal := middlewares.GetPrincipal(c)
 	groupID, _ := strconv.ParseUint(c.Param("groupId"), 10, 64)
 	userID, _ := strconv.ParseUint(c.Param("userId"), 10, 64)
 
-	err := services.RemoveUserFromGroup(email, uint(groupID), uint(userID))
+	err := services.RemoveUserFromGroup(principal, uint(groupID), uint(userID))
 	if err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
@@ -38,21 +40,21 @@ func RemoveUserHandler(c *gin.Context) {
 
 // LeaveGroupHandler godoc
 // @Summary Покинуть группу
-// @Description Удаляет текущего пользователя из группы. Админ не может покинуть группу, если он единственный админ.
+// @Description Удаляет текущего пользователя из группы. Если уходит последний админ, админом становится самый давний оператор или участник; опустевшая группа архивируется.
 // @Tags groups
 // @Security BearerAuth
 // @Param groupId path int true "ID группы"
 // @Produce json
 // @Success 200 {object} map[string]string "Вы покинули группу"
-// @Failure 400 {object} map[string]string "Ошибка — пользователь не найден, не состоит в группе или единственный админ"
+// @Failure 400 {object} map[string]string "Ошибка — пользователь не найден или не состоит в группе"
 // @Failure 401 {object} map[string]string "Не авторизован"
 // @Failure 500 {object} map[string]string "Внутренняя ошибка"
 // @Router /api/groups/{groupId}/leave [delete]
 func LeaveGroupHandler(c *gin.Context) {
-	email := c.MustGet("email").(string)
+	principal := middlewares.GetPrincipal(c)
 	groupID, _ := strconv.ParseUint(c.Param("groupId"), 10, 64)
 
-	err := services.LeaveGroup(email, uint(groupID))
+	err := services.LeaveGroup(principal, uint(groupID))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -60,3 +62,319 @@ func LeaveGroupHandler(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Вы покинули группу"})
 }
+
+// GetMyGroupPermissions godoc
+// @Summary Мои права в группе
+// @Description Возвращает роль текущего пользователя в группе, признак владельца и список прав (create_sessions, edit_sessions, approve_requests, remove_members, edit_group, manage_roles, view_analytics).
+// @Tags groups
+// @Security BearerAuth
+// @Param groupId path int true "ID группы"
+// @Produce json
+// @Success 200 {object} services.GroupPermissionsResponse "Роль и права"
+// @Failure 400 {object} map[string]string "Некорректный ID группы или пользователь не состоит в группе"
+// @Failure 404 {object} map[string]string "Группа не найдена"
+// @Router /api/groups/{groupId}/permissions [get]
+func GetMyGroupPermissions(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 64)
+	if err != nil || groupID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return
+	}
+
+	permissions, err := services.GetMyGroupPermissions(principal, uint(groupID))
+	if err != nil {
+		if err.Error() == "группа не найдена" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, permissions)
+}
+
+// PromoteMemberHandler godoc
+// @Summary Повысить участника
+// @Description Повышает роль участника на одну ступень: member → operator → admin. Требует право manage_roles.
+// @Tags groups_admin
+// @Security BearerAuth
+// @Param groupId path int true "ID группы"
+// @Param userId path int true "ID участника"
+// @Produce json
+// @Success 200 {object} map[string]string "Новая роль"
+// @Failure 400 {object} map[string]string "Роль изменить нельзя"
+// @Failure 403 {object} map[string]string "Нет прав"
+// @Router /api/admin/groups/{groupId}/members/{userId}/promote [post]
+func PromoteMemberHandler(c *gin.Context) {
+	changeMemberRoleHandler(c, services.PromoteMember)
+}
+
+// DemoteMemberHandler godoc
+// @Summary Понизить участника
+// @Description Понижает роль участника на одну ступень: admin → operator → member. Понизить администратора может только владелец группы.
+// @Tags groups_admin
+// @Security BearerAuth
+// @Param groupId path int true "ID группы"
+// @Param userId path int true "ID участника"
+// @Produce json
+// @Success 200 {object} map[string]string "Новая роль"
+// @Failure 400 {object} map[string]string "Роль изменить нельзя"
+// @Failure 403 {object} map[string]string "Нет прав"
+// @Router /api/admin/groups/{groupId}/members/{userId}/demote [post]
+func DemoteMemberHandler(c *gin.Context) {
+	changeMemberRoleHandler(c, services.DemoteMember)
+}
+
+func changeMemberRoleHandler(c *gin.Context, change func(principal models.Principal, groupID, userID uint) (string, error)) {
+	principal := middlewares.GetPrincipal(c)
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID пользователя"})
+		return
+	}
+
+	role, err := change(principal, uint(groupID), uint(userID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"role": role})
+}
+
+// TransferOwnershipHandler godoc
+// @Summary Предложить передачу владения группой
+// @Description Создаёт запрос на передачу группы другому участнику. Владение перейдёт после подтверждения новым владельцем; запрос действует 7 дней. Доступно только владельцу.
+// @Tags groups_admin
+// @Security BearerAuth
+// @Param groupId path int true "ID группы"
+// @Param input body services.TransferOwnershipInput true "Новый владелец"
+// @Accept json
+// @Produce json
+// @Success 201 {object} groups.GroupOwnershipTransfer "Запрос создан"
+// @Failure 400 {object} map[string]string "Передать нельзя"
+// @Failure 403 {object} map[string]string "Нет прав"
+// @Router /api/admin/groups/{groupId}/transfer [post]
+func TransferOwnershipHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return
+	}
+
+	var input services.TransferOwnershipInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "не удалось распарсить json", "details": err.Error()})
+		return
+	}
+
+	transfer, err := services.RequestOwnershipTransfer(principal, uint(groupID), input)
+	if err != nil {
+		if err.Error() == "передать группу может только её владелец" {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, transfer)
+}
+
+// CancelOwnershipTransferHandler godoc
+// @Summary Отменить передачу владения
+// @Description Отменяет ещё не подтверждённый запрос на передачу группы.
+// @Tags groups_admin
+// @Security BearerAuth
+// @Param groupId path int true "ID группы"
+// @Produce json
+// @Success 200 {object} map[string]string "Запрос отменён"
+// @Failure 400 {object} map[string]string "Нет активного запроса"
+// @Router /api/admin/groups/{groupId}/transfer [delete]
+func CancelOwnershipTransferHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return
+	}
+
+	if err := services.CancelOwnershipTransfer(principal, uint(groupID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Запрос на передачу отменён"})
+}
+
+// GetOwnershipTransfersHandler godoc
+// @Summary Входящие запросы на передачу групп
+// @Description Возвращает запросы на передачу владения группами, ожидающие подтверждения текущим пользователем.
+// @Tags groups
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} services.OwnershipTransferResponse "Запросы"
+// @Failure 400 {object} map[string]string "Ошибка"
+// @Router /api/groups/transfers [get]
+func GetOwnershipTransfersHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	transfers, err := services.GetIncomingOwnershipTransfers(principal)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfers)
+}
+
+// AcceptOwnershipTransferHandler godoc
+// @Summary Принять группу
+// @Description Подтверждает запрос на передачу владения: текущий пользователь становится владельцем и администратором группы.
+// @Tags groups
+// @Security BearerAuth
+// @Param transferId path int true "ID запроса"
+// @Produce json
+// @Success 200 {object} map[string]string "Владение передано"
+// @Failure 400 {object} map[string]string "Запрос недействителен"
+// @Router /api/groups/transfers/{transferId}/accept [post]
+func AcceptOwnershipTransferHandler(c *gin.Context) {
+	respondOwnershipTransfer(c, true)
+}
+
+// DeclineOwnershipTransferHandler godoc
+// @Summary Отказаться от группы
+// @Description Отклоняет запрос на передачу владения группой.
+// @Tags groups
+// @Security BearerAuth
+// @Param transferId path int true "ID запроса"
+// @Produce json
+// @Success 200 {object} map[string]string "Запрос отклонён"
+// @Failure 400 {object} map[string]string "Запрос недействителен"
+// @Router /api/groups/transfers/{transferId}/decline [post]
+func DeclineOwnershipTransferHandler(c *gin.Context) {
+	respondOwnershipTransfer(c, false)
+}
+
+func respondOwnershipTransfer(c *gin.Context, accept bool) {
+	principal := middlewares.GetPrincipal(c)
+	transferID, err := strconv.ParseUint(c.Param("transferId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID запроса"})
+		return
+	}
+
+	if err := services.RespondOwnershipTransfer(principal, uint(transferID), accept); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if accept {
+		c.JSON(http.StatusOK, gin.H{"message": "Владение группой передано"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Запрос отклонён"})
+}
+
+// BanUserHandler godoc
+// @Summary Заблокировать пользователя в группе
+// @Description Исключает пользователя из группы и запрещает вступать в неё, получать приглашения и записываться на её сессии. Срок необязателен — без него блокировка бессрочная.
+// @Tags groups_admin
+// @Security BearerAuth
+// @Param groupId path int true "ID группы"
+// @Param input body services.BanUserInput true "Кого и за что блокируем"
+// @Accept json
+// @Produce json
+// @Success 201 {object} groups.GroupBan "Блокировка создана"
+// @Failure 400 {object} map[string]string "Заблокировать нельзя"
+// @Failure 403 {object} map[string]string "Нет прав"
+// @Router /api/admin/groups/{groupId}/bans [post]
+func BanUserHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return
+	}
+
+	var input services.BanUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "не удалось распарсить json", "details": err.Error()})
+		return
+	}
+
+	ban, err := services.BanUserInGroup(principal, uint(groupID), input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ban)
+}
+
+// GetGroupBansHandler godoc
+// @Summary Блокировки группы
+// @Description Возвращает действующие блокировки группы. С history=true — также снятые и истёкшие.
+// @Tags groups_admin
+// @Security BearerAuth
+// @Param groupId path int true "ID группы"
+// @Param history query bool false "Включить историю"
+// @Produce json
+// @Success 200 {array} services.GroupBanResponse "Блокировки"
+// @Failure 400 {object} map[string]string "Некорректный ID группы"
+// @Router /api/admin/groups/{groupId}/bans [get]
+func GetGroupBansHandler(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return
+	}
+
+	bans, err := services.GetGroupBans(uint(groupID), c.Query("history") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, bans)
+}
+
+// LiftBanHandler godoc
+// @Summary Снять блокировку
+// @Description Снимает действующую блокировку пользователя в группе. В группу он не возвращается автоматически.
+// @Tags groups_admin
+// @Security BearerAuth
+// @Param groupId path int true "ID группы"
+// @Param userId path int true "ID пользователя"
+// @Produce json
+// @Success 200 {object} map[string]string "Блокировка снята"
+// @Failure 404 {object} map[string]string "Блокировка не найдена"
+// @Router /api/admin/groups/{groupId}/bans/{userId} [delete]
+func LiftBanHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID пользователя"})
+		return
+	}
+
+	if err := services.LiftGroupBan(principal, uint(groupID), uint(userID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Блокировка снята"})
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"friendship/middlewares"
+	"friendship/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetGroupQuestionnaireHandler godoc
+// @Summary      Анкета для вступления в группу
+// @Description  Вопросы, на которые нужно ответить при подаче заявки в закрытую группу. Пустой список — анкеты нет.
+// @Tags         groups
+// @Security     BearerAuth
+// @Produce      json
+// @Param        groupId path int true "ID группы"
+// @Success      200  {object}  map[string]interface{} "questions: список вопросов"
+// @Failure      400  {object}  map[string]string "Некорректный ID группы"
+// @Failure      404  {object}  map[string]string "Группа не найдена"
+// @Router       /api/groups/{groupId}/questionnaire [get]
+func GetGroupQuestionnaireHandler(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 32)
+	if err != nil || groupID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return
+	}
+
+	questions, err := services.GetGroupQuestionnaire(uint(groupID))
+	if err != nil {
+		if err.Error() == "группа не найдена" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"questions": questions})
+}
+
+// SetGroupQuestionnaireHandler godoc
+// @Summary      Задать анкету для вступления
+// @Description  Полностью заменяет анкету закрытой группы. Тип вопроса: text или choice (с вариантами options). До 10 вопросов, пустой список отключает анкету. Ответы в уже поданных заявках сохраняются.
+// @Tags         groups_admin
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        groupId path int true "ID группы"
+// @Param        input body services.SetQuestionnaireInput true "Вопросы анкеты"
+// @Success      200  {object}  map[string]interface{} "questions: сохранённые вопросы"
+// @Failure      400  {object}  map[string]string "Ошибка валидации"
+// @Failure      403  {object}  map[string]string "Нет прав в группе"
+// @Router       /api/admin/groups/{groupId}/questionnaire [put]
+func SetGroupQuestionnaireHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 32)
+	if err != nil || groupID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный ID группы"})
+		return
+	}
+
+	var input services.SetQuestionnaireInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный json", "details": err.Error()})
+		return
+	}
+
+	questions, err := services.SetGroupQuestionnaire(principal, uint(groupID), input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"questions": questions})
+}
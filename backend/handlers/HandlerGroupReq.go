@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"friendship/middlewares"
 	"friendship/services"
 	"net/http"
 	"strconv"
@@ -9,34 +10,12 @@ import (
 )
 
 type GetJoinRequestsResponseDoc struct {
-	Requests []GroupJoinRequestDoc `json:"requests"`
-}
-
-type GroupJoinRequestDoc struct {
-	ID      uint            `json:"id" example:"3"`
-	UserID  uint            `json:"userId" example:"42"`
-	GroupID uint            `json:"groupId" example:"5"`
-	Status  string          `json:"status" example:"pending"`
-	User    UserPreviewDoc  `json:"user"`
-	Group   GroupPreviewDoc `json:"group"`
-}
-
-type UserPreviewDoc struct {
-	ID    uint   `json:"id" example:"42"`
-	Name  string `json:"name" example:"Иван"`
-	Email string `json:"email" example:"ivan@example.com"`
-	Image string `json:"image" example:"https://img.com/avatar.png"`
-}
-
-type GroupPreviewDoc struct {
-	ID    uint   `json:"id" example:"5"`
-	Name  string `json:"name" example:"Закрытая группа"`
-	Image string `json:"image" example:"https://img.com/group.png"`
+	Requests []services.GroupJoinRequestRes `json:"requests"`
 }
 
 // GetJoinRequests godoc
 // @Summary Получить заявки на вступление
-// @Description Получение всех заявок на вступление в закрытые группы, где текущий пользователь является администратором
+// @Description Получение заявок на вступление в закрытую группу вместе с ответами на анкету группы
 // @Tags groups
 // @Security BearerAuth
 // @Param groupId path int true "ID группы"
@@ -48,7 +27,7 @@ type GroupPreviewDoc struct {
 // @Failure 500 {object} map[string]string "Внутренняя ошибка"
 // @Router /api/groups/requests/{groupId} [get]
 func GetJoinRequests(c *gin.Context) {
-	email := c.MustGet("email").(string)
+	principal := middlewares.GetPrincipal(c)
 
 	groupIDParam := c.Param("groupId")
 	groupID, err := strconv.ParseUint(groupIDParam, 10, 32)
@@ -57,7 +36,7 @@ func GetJoinRequests(c *gin.Context) {
 		return
 	}
 
-	requests, err := services.GetPendingJoinRequestsForAdmin(email, uint(groupID))
+	requests, err := services.GetPendingJoinRequestsForAdmin(principal, uint(groupID))
 	if err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
@@ -80,10 +59,10 @@ func GetJoinRequests(c *gin.Context) {
 // @Failure 500 {object} map[string]string "Ошибка сервера"
 // @Router /api/admin/groups/requests/{requestId}/approve [post]
 func ApproveJoinRequest(c *gin.Context) {
-	email, _ := c.Get("email")
+	principal := middlewares.GetPrincipal(c)
 	requestId := c.Param("requestId")
 
-	if err := services.ApproveJoinRequestByID(email.(string), requestId); err != nil {
+	if err := services.ApproveJoinRequestByID(principal, requestId); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -105,10 +84,10 @@ func ApproveJoinRequest(c *gin.Context) {
 // @Failure 500 {object} map[string]string "Ошибка сервера"
 // @Router /api/admin/groups/requests/{requestId}/reject [post]
 func RejectJoinRequest(c *gin.Context) {
-	email, _ := c.Get("email")
+	principal := middlewares.GetPrincipal(c)
 	requestId := c.Param("requestId")
 
-	if err := services.RejectJoinRequestByID(email.(string), requestId); err != nil {
+	if err := services.RejectJoinRequestByID(principal, requestId); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -130,7 +109,7 @@ func RejectJoinRequest(c *gin.Context) {
 // @Failure 500 {object} map[string]string "Ошибка сервера"
 // @Router /api/admin/groups/requests/all/{groupId}/approveAll [post]
 func ApproveJoinAllRequest(c *gin.Context) {
-	email, _ := c.Get("email")
+	principal := middlewares.GetPrincipal(c)
 	groupIDStr := c.Param("groupId")
 
 	groupID, err := strconv.Atoi(groupIDStr)
@@ -139,7 +118,7 @@ func ApproveJoinAllRequest(c *gin.Context) {
 		return
 	}
 
-	if err := services.ApproveAllJoinRequests(email.(string), uint(groupID)); err != nil {
+	if err := services.ApproveAllJoinRequests(principal, uint(groupID)); err != nil {
 		if err.Error() == "нет ожидающих заявок для этой группы" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
@@ -165,7 +144,7 @@ func ApproveJoinAllRequest(c *gin.Context) {
 // @Failure 500 {object} map[string]string "Ошибка сервера"
 // @Router /api/admin/groups/requests/all/{groupId}/rejectAll [post]
 func RejectJoinAllRequest(c *gin.Context) {
-	email, _ := c.Get("email")
+	principal := middlewares.GetPrincipal(c)
 	groupIDStr := c.Param("groupId")
 
 	groupID, err := strconv.Atoi(groupIDStr)
@@ -174,7 +153,7 @@ func RejectJoinAllRequest(c *gin.Context) {
 		return
 	}
 
-	if err := services.RejectAllJoinRequests(email.(string), uint(groupID)); err != nil {
+	if err := services.RejectAllJoinRequests(principal, uint(groupID)); err != nil {
 		if err.Error() == "нет ожидающих заявок для этой группы" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
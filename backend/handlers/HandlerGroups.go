@@ -22,7 +22,8 @@ type CreateGroupInputDoc struct {
 }
 
 type JoinGroupInputDoc struct {
-	GroupID uint `json:"groupId" binding:"required"`
+	GroupID uint                       `json:"groupId" binding:"required"`
+	Answers []services.JoinAnswerInput `json:"answers"`
 }
 
 type JoinGroupResponseDoc struct {
@@ -51,12 +52,7 @@ type JoinGroupResponseDoc struct {
 // @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
 // @Router /api/groups/createGroup [post]
 func CreateGroup(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-	email := emailValue.(string)
+	principal := middlewares.GetPrincipal(c)
 
 	var input services.CreateGroupInput
 	if err := c.ShouldBind(&input); err != nil {
@@ -89,7 +85,7 @@ func CreateGroup(c *gin.Context) {
 		input.Image = imageURL
 	}
 
-	group, err := services.CreateGroup(&email, input)
+	group, err := services.CreateGroup(principal, input)
 	if err != nil {
 		// Удаляем загруженное изображение при ошибке создания группы
 		if imageURL != "" {
@@ -123,16 +119,11 @@ func CreateGroup(c *gin.Context) {
 // @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
 // @Router /api/groups/{groupId} [get]
 func GetGroupInf(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-	email := emailValue.(string)
+	principal := middlewares.GetPrincipal(c)
 	groupIDStr := c.Param("groupId")
 	groupID, err := strconv.ParseUint(groupIDStr, 10, 64)
 
-	result, err := services.GetGroupInf(&groupID, &email)
+	result, err := services.GetGroupInf(&groupID, principal)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -144,7 +135,7 @@ func GetGroupInf(c *gin.Context) {
 // @Security BearerAuth
 // joinToGroup godoc
 // @Summary Присоединение к группе
-// @Description Новый пользователь присоединяется к группе
+// @Description Новый пользователь присоединяется к группе. Для закрытой группы с анкетой (GET /api/groups/{groupId}/questionnaire) в answers передаются ответы, обязательные вопросы нужно заполнить.
 // @Tags groups
 // @Accept  json
 // @Produce  json
@@ -155,19 +146,14 @@ func GetGroupInf(c *gin.Context) {
 // @Failure 500 {object} map[string]string
 // @Router /api/groups/joinToGroup [post]
 func JoinGroup(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-	email := emailValue.(string)
+	principal := middlewares.GetPrincipal(c)
 
 	var input services.JoinGroupInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный json", "details": err.Error()})
 		return
 	}
-	res, err := services.JoinGroup(email, input)
+	res, err := services.JoinGroup(principal, input)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -199,7 +185,8 @@ func DeleteGroups(c *gin.Context) {
 		return
 	}
 
-	if err := services.DeleteGroup(uint(groupID)); err != nil {
+	principal := middlewares.GetPrincipal(c)
+	if err := services.DeleteGroup(principal, uint(groupID)); err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
 	}
@@ -236,7 +223,8 @@ func UpdateGroupHandler(c *gin.Context) {
 		return
 	}
 
-	if err := services.UpdateGroup(uint(groupID), input); err != nil {
+	principal := middlewares.GetPrincipal(c)
+	if err := services.UpdateGroup(principal, uint(groupID), input); err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
 	}
@@ -260,18 +248,13 @@ func UpdateGroupHandler(c *gin.Context) {
 // @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
 // @Router       /api/admin/groups/requestsForUser [post]
 func SentJoinRequests(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-	email := emailValue.(string)
+	principal := middlewares.GetPrincipal(c)
 	var input services.SentJoinRequestsReq
 	if err := c.ShouldBindQuery(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректные параметры запроса: " + err.Error()})
 		return
 	}
-	res, err := services.SentJoinRequests(email, input)
+	res, err := services.SentJoinRequests(principal, input)
 	if err != nil {
 		errStr := err.Error()
 		if strings.Contains(errStr, "не администратор") {
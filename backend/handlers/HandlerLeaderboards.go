@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"friendship/middlewares"
+	"friendship/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLeaderboard godoc
+// @Summary Рейтинг пользователей
+// @Description Возвращает рейтинг по посещённым сессиям, проведённым сессиям, часам или самой длинной серии — глобально, по группе или по городу, за неделю, месяц или всё время. Пользователи, скрывшие себя из рейтингов, не отображаются.
+// @Tags Users inf
+// @Security BearerAuth
+// @Produce json
+// @Param scope query string false "global (по умолчанию), group или city"
+// @Param group_id query int false "ID группы для scope=group"
+// @Param city query string false "Город для scope=city"
+// @Param metric query string false "attended (по умолчанию), hosted, hours или streak (только window=all)"
+// @Param window query string false "all (по умолчанию), week или month"
+// @Param period query string false "Конкретная неделя (2025-W07) или месяц (2025-03)"
+// @Param limit query int false "Размер топа, до 100"
+// @Success 200 {object} services.LeaderboardResponse "Рейтинг"
+// @Failure 400 {object} map[string]string "Некорректные параметры"
+// @Failure 403 {object} map[string]string "Нет доступа к рейтингу группы"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /api/users/leaderboards [get]
+func GetLeaderboard(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	var query services.LeaderboardQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректные параметры запроса: " + err.Error()})
+		return
+	}
+
+	leaderboard, err := services.GetLeaderboard(principal, query)
+	if err != nil {
+		errStr := err.Error()
+		switch {
+		case strings.Contains(errStr, "только участникам"):
+			c.JSON(http.StatusForbidden, gin.H{"error": errStr})
+		case strings.Contains(errStr, "неизвестн"), strings.Contains(errStr, "не указан"), strings.Contains(errStr, "не найден"),
+			strings.Contains(errStr, "только за всё время"):
+			c.JSON(http.StatusBadRequest, gin.H{"error": errStr})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": errStr})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, leaderboard)
+}
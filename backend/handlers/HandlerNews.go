@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"friendship/middlewares"
 	"friendship/services"
 	"net/http"
 	"strconv"
@@ -107,22 +108,7 @@ func GetNewsByID(c *gin.Context) {
 // @Failure      401 {object} map[string]string
 // @Router       /api/news/{id}/comments [post]
 func AddComment(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-
-	email, ok := emailValue.(string)
-	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат email в контексте"})
-		return
-	}
-
-	if email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "email не может быть пустым"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 
 	newsID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -136,7 +122,7 @@ func AddComment(c *gin.Context) {
 		return
 	}
 
-	comment, err := services.CreateComment(uint(newsID), email, input)
+	comment, err := services.CreateComment(uint(newsID), principal, input)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -160,29 +146,9 @@ func AddComment(c *gin.Context) {
 // @Failure      403 {object} map[string]string
 // @Router       /api/news/{newsId}/comments/{commentId} [delete]
 func DeleteComment(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 
-	email, ok := emailValue.(string)
-	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат email в контексте"})
-		return
-	}
-
-	if email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "email не может быть пустым"})
-		return
-	}
-
-	user, err := services.FindUserByEmail(email)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "пользователь не найден"})
-		return
-	}
-	if user.Role != "admin" {
+	if principal.Role != services.PlatformRoleAdmin {
 		c.JSON(http.StatusForbidden, gin.H{"error": "недостаточно прав"})
 		return
 	}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"friendship/services"
 	"friendship/utils"
 	"log"
@@ -60,9 +61,14 @@ func VerifySessionHandler(c *gin.Context) {
 		return
 	}
 
-	verified, err := services.VerifySession(input)
+	verified, err := services.VerifySession(input, c.ClientIP())
 	if err != nil {
-		if err.Error() == "сессия не найдена или удалена" {
+		if tooManyAttempts(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrVerifyAttemptsSpent) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		} else if err.Error() == "сессия не найдена или удалена" {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Сессия не найдена"})
 		} else {
 			c.JSON(http.StatusTooManyRequests, gin.H{"error": "неизвестная ошибка"})
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"friendship/middlewares"
 	"friendship/services"
 	"net/http"
 	"strconv"
@@ -23,39 +24,18 @@ import (
 // @Security BearerAuth
 // @Router /api/users/search [get]
 func SearchUsers(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-
-	email, ok := emailValue.(string)
-	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат email в контексте"})
-		return
-	}
-
-	if email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "email не может быть пустым"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 
 	name := c.Query("name")
 	pageStr := c.DefaultQuery("page", "1")
 
-	user, err := services.FindUserByEmail(email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка поиска исходного пользователя"})
-		return
-	}
-
 	page, err := strconv.Atoi(pageStr)
 	if err != nil || page < 1 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный номер страницы"})
 		return
 	}
 
-	users, err := services.SearchUsers(name, page, user.ID)
+	users, err := services.SearchUsers(name, page, principal.UserID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка поиска пользователей"})
 		return
@@ -82,12 +62,6 @@ func SearchUsers(c *gin.Context) {
 // @Security BearerAuth
 // @Router /api/groups/search [get]
 func SearchGroups(c *gin.Context) {
-	_, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-
 	name := c.Query("name")
 	pageStr := c.DefaultQuery("page", "1")
 	sortBy := c.DefaultQuery("sort_by", "")
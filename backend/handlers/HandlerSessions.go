@@ -43,19 +43,14 @@ type SessionJoinInputDoc struct {
 // @Security BearerAuth
 // @Router /api/sessions/createSession [post]
 func CreateSession(c *gin.Context) {
-	email := c.MustGet("email").(string)
-	if email == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не передан jwt"})
-		return
-	}
-
+	principal := middlewares.GetPrincipal(c)
 	var input services.SessionInput
 	if err := c.ShouldBind(&input); err != nil {
 		utils.ValidationError(c, err)
 		return
 	}
 
-	ok, err := services.CreateSession(email, input)
+	ok, err := services.CreateSession(principal, input)
 	if err != nil || !ok {
 		if input.Image != "" {
 			middlewares.DeleteImageFromS3(input.Image)
@@ -83,17 +78,13 @@ func CreateSession(c *gin.Context) {
 // @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
 // @Router /api/sessions/join [post]
 func JoinToSession(c *gin.Context) {
-	email := c.MustGet("email").(string)
-	if email == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не передан jwt"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 	var input services.SessionJoinInput
 	if err := c.ShouldBind(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "не удалось разобрать форму: " + err.Error()})
 		return
 	}
-	res := services.JoinToSession(&email, input)
+	res := services.JoinToSession(principal, input)
 	if res != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error()})
 		return
@@ -117,7 +108,7 @@ func JoinToSession(c *gin.Context) {
 // @Failure 500 {object} map[string]string "Ошибка сервера или БД"
 // @Router /api/sessions/sessions/{id} [delete]
 func DeleteSession(c *gin.Context) {
-	email := c.MustGet("email").(string)
+	principal := middlewares.GetPrincipal(c)
 	idStr := c.Param("id")
 
 	sessionID, err := strconv.Atoi(idStr)
@@ -126,7 +117,7 @@ func DeleteSession(c *gin.Context) {
 		return
 	}
 
-	if err := services.DeleteSession(email, uint(sessionID)); err != nil {
+	if err := services.DeleteSession(principal, uint(sessionID)); err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
 	}
@@ -148,7 +139,7 @@ func DeleteSession(c *gin.Context) {
 // @Failure 500 {object} map[string]string "Внутренняя ошибка"
 // @Router /api/sessions/{sessionId}/leave [delete]
 func LeaveSessionHandler(c *gin.Context) {
-	email := c.MustGet("email").(string)
+	principal := middlewares.GetPrincipal(c)
 
 	sessionIDStr := c.Param("sessionId")
 	sessionID, err := strconv.ParseUint(sessionIDStr, 10, 64)
@@ -157,7 +148,7 @@ func LeaveSessionHandler(c *gin.Context) {
 		return
 	}
 
-	if err := services.LeaveSession(email, uint(sessionID)); err != nil {
+	if err := services.LeaveSession(principal, uint(sessionID)); err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
 	}
@@ -183,7 +174,7 @@ func LeaveSessionHandler(c *gin.Context) {
 // @Failure 500 {object} map[string]string "Внутренняя ошибка"
 // @Router /api/admin/sessions/{sessionId} [patch]
 func UpdateSessionHandler(c *gin.Context) {
-	email := c.MustGet("email").(string)
+	principal := middlewares.GetPrincipal(c)
 
 	sessionIDStr := c.Param("sessionId")
 	sessionID, err := strconv.ParseUint(sessionIDStr, 10, 64)
@@ -198,7 +189,7 @@ func UpdateSessionHandler(c *gin.Context) {
 		return
 	}
 
-	if err := services.UpdateSession(email, uint(sessionID), input); err != nil {
+	if err := services.UpdateSession(principal, uint(sessionID), input); err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"message": err.Error()})
 		return
 	}
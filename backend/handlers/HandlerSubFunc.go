@@ -21,12 +21,6 @@ import (
 // @Security     BearerAuth
 // @Router       /api/admin/groups/UploadPhoto [post]
 func ChangePhoto(c *gin.Context) {
-	_, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-
 	header, err := c.FormFile("image")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Не удалось получить файл: " + err.Error()})
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"friendship/middlewares"
+	"friendship/models"
+	"friendship/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required,max=20"` // код из приложения или код восстановления
+	DeviceName     string `json:"device_name" binding:"max=100"`
+}
+
+// TwoFactorLoginHandler godoc
+// @Summary      Второй шаг входа
+// @Description  Обменивает challenge токен, полученный при входе, и код из приложения-аутентификатора (или код восстановления) на access и refresh токены. После 5 неверных кодов нужно войти заново.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        input  body      TwoFactorLoginRequest  true  "Challenge токен и код"
+// @Success      200    {object}  AuthResponse  "Токены успешно созданы"
+// @Failure      400    {object}  map[string]string  "Некорректный JSON"
+// @Failure      401    {object}  map[string]string  "Неверный код или истёк challenge токен"
+// @Failure      429    {object}  map[string]string  "Слишком много неудачных попыток, см. заголовок Retry-After"
+// @Router       /api/users/login/2fa [post]
+func TwoFactorLoginHandler(c *gin.Context) {
+	var input TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный json", "details": err.Error()})
+		return
+	}
+
+	user, err := services.CompleteTwoFactorLogin(input.ChallengeToken, input.Code)
+	if err != nil {
+		if tooManyAttempts(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrTwoFactorInvalidCode) || errors.Is(err, services.ErrTwoFactorChallengeGone) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := services.StartAuthSession(user, deviceInfo(c, input.DeviceName))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации токена"})
+		return
+	}
+
+	adminGroups, err := services.GetAdminGroups(models.NewPrincipal(*user))
+	if err != nil {
+		adminGroups = []services.AdminGroupResponse{}
+	}
+
+	c.JSON(http.StatusOK, AuthResponse{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		AdminGroups:  adminGroups,
+	})
+}
+
+// GetTwoFactorStatusHandler godoc
+// @Summary      Статус 2FA
+// @Description  Включена ли двухфакторная аутентификация и сколько осталось неиспользованных кодов восстановления.
+// @Tags         auth
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {object}  services.TwoFactorStatus  "Статус"
+// @Failure      500  {object}  map[string]string  "Ошибка сервера"
+// @Router       /api/users/2fa [get]
+func GetTwoFactorStatusHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	status, err := services.GetTwoFactorStatus(principal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// BeginTwoFactorSetupHandler godoc
+// @Summary      Подключить 2FA
+// @Description  Выдаёт секрет TOTP и ссылку otpauth:// для QR-кода. 2FA включится после подтверждения кодом из приложения.
+// @Tags         auth
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {object}  services.TwoFactorSetupResponse  "Секрет и ссылка"
+// @Failure      400  {object}  map[string]string  "2FA уже включена"
+// @Router       /api/users/2fa/setup [post]
+func BeginTwoFactorSetupHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	setup, err := services.BeginTwoFactorSetup(principal)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// ConfirmTwoFactorSetupHandler godoc
+// @Summary      Подтвердить подключение 2FA
+// @Description  Включает 2FA, если код из приложения верный. Возвращает коды восстановления — они показываются один раз.
+// @Tags         auth
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        input  body      services.TwoFactorCodeInput  true  "Код из приложения"
+// @Success      200    {object}  services.RecoveryCodesResponse  "Коды восстановления"
+// @Failure      400    {object}  map[string]string  "Неверный код"
+// @Router       /api/users/2fa/confirm [post]
+func ConfirmTwoFactorSetupHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	var input services.TwoFactorCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный json", "details": err.Error()})
+		return
+	}
+
+	codes, err := services.ConfirmTwoFactorSetup(principal, input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, codes)
+}
+
+// DisableTwoFactorHandler godoc
+// @Summary      Отключить 2FA
+// @Description  Отключает двухфакторную аутентификацию. Нужны пароль и код из приложения или код восстановления.
+// @Tags         auth
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        input  body      services.DisableTwoFactorInput  true  "Пароль и код"
+// @Success      200    {object}  map[string]string  "2FA отключена"
+// @Failure      400    {object}  map[string]string  "Неверный пароль или код"
+// @Router       /api/users/2fa/disable [post]
+func DisableTwoFactorHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	var input services.DisableTwoFactorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный json", "details": err.Error()})
+		return
+	}
+
+	if err := services.DisableTwoFactor(principal, input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Двухфакторная аутентификация отключена"})
+}
+
+// RegenerateRecoveryCodesHandler godoc
+// @Summary      Новые коды восстановления
+// @Description  Заменяет все коды восстановления новыми. Старые перестают действовать.
+// @Tags         auth
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        input  body      services.TwoFactorCodeInput  true  "Код из приложения"
+// @Success      200    {object}  services.RecoveryCodesResponse  "Новые коды восстановления"
+// @Failure      400    {object}  map[string]string  "Неверный код"
+// @Router       /api/users/2fa/recovery-codes [post]
+func RegenerateRecoveryCodesHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	var input services.TwoFactorCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный json", "details": err.Error()})
+		return
+	}
+
+	codes, err := services.RegenerateRecoveryCodes(principal, input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, codes)
+}
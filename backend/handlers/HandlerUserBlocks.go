@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"friendship/middlewares"
+	"friendship/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BlockUserHandler godoc
+// @Summary      Заблокировать пользователя
+// @Description  Разрывает дружбу и подписки в обе стороны. Пока блокировка действует, пользователи не видят друг друга в поиске и не могут приглашать друг друга в группы и на сессии.
+// @Tags         Friends
+// @Security     BearerAuth
+// @Produce      json
+// @Param        userId path int true "ID пользователя"
+// @Success      200  {object}  map[string]string "Пользователь заблокирован"
+// @Failure      400  {object}  map[string]string "Ошибка"
+// @Router       /api/users/blocks/{userId} [post]
+func BlockUserHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.BlockUser(principal, userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Пользователь заблокирован"})
+}
+
+// UnblockUserHandler godoc
+// @Summary      Разблокировать пользователя
+// @Description  Снимает блокировку. Дружба и подписки не восстанавливаются.
+// @Tags         Friends
+// @Security     BearerAuth
+// @Produce      json
+// @Param        userId path int true "ID пользователя"
+// @Success      200  {object}  map[string]string "Пользователь разблокирован"
+// @Failure      400  {object}  map[string]string "Пользователь не заблокирован"
+// @Router       /api/users/blocks/{userId} [delete]
+func UnblockUserHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.UnblockUser(principal, userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Пользователь разблокирован"})
+}
+
+// GetBlockedUsersHandler godoc
+// @Summary      Заблокированные пользователи
+// @Tags         Friends
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}   services.BlockedUserDTO "Заблокированные пользователи"
+// @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router       /api/users/blocks [get]
+func GetBlockedUsersHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	users, err := services.GetBlockedUsers(principal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
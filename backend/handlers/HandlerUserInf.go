@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"friendship/middlewares"
 	"friendship/services"
 	"net/http"
 	"strconv"
@@ -23,23 +25,13 @@ import (
 // @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
 // @Router /api/users/subscriptions [get]
 func GetGroupsUserSub(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-	email := emailValue.(string)
+	principal := middlewares.GetPrincipal(c)
 
 	userIDParam := c.Query("id")
 
 	var targetUserID uint
 	if userIDParam == "" {
-		user, err := services.FindUserByEmail(email)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "пользователь не найден"})
-			return
-		}
-		targetUserID = user.ID
+		targetUserID = principal.UserID
 	} else {
 		idUint, err := strconv.ParseUint(userIDParam, 10, 64)
 		if err != nil {
@@ -96,24 +88,9 @@ func UnSubscriptionsTelegramNotify(c *gin.Context) {
 // @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
 // @Router       /api/users/inf [get]
 func GetInfAboutUser(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 
-	email, ok := emailValue.(string)
-	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат email в контексте"})
-		return
-	}
-
-	if email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "email не может быть пустым"})
-		return
-	}
-
-	userInf, err := services.GetInfAboutUser(email)
+	userInf, err := services.GetInfAboutUser(principal)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "внутренняя ошибка сервера"})
 		return
@@ -140,22 +117,7 @@ func GetInfAboutUser(c *gin.Context) {
 // @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
 // @Router       /api/users/inf/{id} [get]
 func GetInfAboutUserByID(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-
-	email, ok := emailValue.(string)
-	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат email в контексте"})
-		return
-	}
-
-	if email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "email не может быть пустым"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 
 	var id = c.Param("id")
 	fmt.Println("id", id)
@@ -171,11 +133,11 @@ func GetInfAboutUserByID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if user.Email == email {
+	if user.ID == principal.UserID {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Нононо мистер фиш, ты не будешь здесь получать информацию о себе"})
 		return
 	}
-	userInf, err := services.GetInfAboutAnotherUser(user.Email)
+	userInf, err := services.GetInfAboutAnotherUser(principal, user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "внутренняя ошибка сервера"})
 		return
@@ -186,6 +148,13 @@ func GetInfAboutUserByID(c *gin.Context) {
 		return
 	}
 
+	relation, err := services.GetUserRelation(principal, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "внутренняя ошибка сервера"})
+		return
+	}
+	userInf.Relation = relation
+
 	c.JSON(http.StatusOK, userInf)
 }
 
@@ -202,17 +171,7 @@ func GetInfAboutUserByID(c *gin.Context) {
 // @Failure      401 {object} map[string]string "Пользователь не авторизован"
 // @Router       /api/users/user/profile [patch]
 func UpdateUserProfile(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-
-	email, ok := emailValue.(string)
-	if !ok || email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат email"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 
 	var req services.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -220,7 +179,7 @@ func UpdateUserProfile(c *gin.Context) {
 		return
 	}
 
-	updatedUser, err := services.UpdateUserProfile(email, req)
+	updatedUser, err := services.UpdateUserProfile(principal, req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -243,7 +202,7 @@ type ChangePasswordInput struct {
 
 // ChangePassword godoc
 // @Summary      Смена пароля пользователя
-// @Description  Обновляет пароль для текущего авторизованного пользователя.
+// @Description  Обновляет пароль для текущего авторизованного пользователя. Сессии входа на других устройствах завершаются.
 // @Tags         Users inf
 // @Accept       json
 // @Produce      json
@@ -254,24 +213,14 @@ type ChangePasswordInput struct {
 // @Failure      401  {object}  map[string]string "Пользователь не авторизован"
 // @Router       /api/users/password [patch]
 func ChangePassword(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-
-	email, ok := emailValue.(string)
-	if !ok || email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат email"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 
 	var input ChangePasswordInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные"})
 		return
 	}
-	res := services.ChangePassword(email, input.NewPassword)
+	res := services.ChangePassword(principal, input.NewPassword)
 	if res != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": res.Error()})
 		return
@@ -279,6 +228,97 @@ func ChangePassword(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Пароль успешно изменён"})
 }
 
+// RequestEmailChange godoc
+// @Summary      Запрос на смену email
+// @Description  Проверяет пароль (и код 2FA, если она включена) и отправляет код подтверждения на новый адрес. Email меняется только после подтверждения кодом.
+// @Tags         Users inf
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        input body services.ChangeEmailRequest true "Новый email и пароль"
+// @Success      200  {object}  models.SessionRegResponse "Код отправлен на новый адрес"
+// @Failure      400  {object}  map[string]string "Неверный пароль или код 2FA"
+// @Failure      409  {object}  map[string]string "Email уже занят"
+// @Failure      429  {object}  map[string]string "Слишком много неудачных попыток, см. заголовок Retry-After"
+// @Router       /api/users/email/change [post]
+func RequestEmailChange(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	var input services.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный json", "details": err.Error()})
+		return
+	}
+
+	resp, err := services.RequestEmailChange(principal, input)
+	if err != nil {
+		if tooManyAttempts(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrEmailTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ConfirmEmailChange godoc
+// @Summary      Подтверждение смены email
+// @Description  Проверяет код, отправленный на новый адрес, и меняет email. Старый адрес получает уведомление, все сессии входа завершаются, в ответе — новые токены для текущего устройства.
+// @Tags         Users inf
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        input body services.ConfirmEmailChangeInput true "Сессия и код из письма"
+// @Success      200  {object}  AuthResponse "Email изменён, новые токены"
+// @Failure      400  {object}  map[string]string "Неверный код"
+// @Failure      404  {object}  map[string]string "Сессия не найдена"
+// @Failure      409  {object}  map[string]string "Email уже занят"
+// @Failure      429  {object}  map[string]string "Слишком много неверных кодов"
+// @Router       /api/users/email/confirm [post]
+func ConfirmEmailChange(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	var input services.ConfirmEmailChangeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный json", "details": err.Error()})
+		return
+	}
+
+	token, err := services.ConfirmEmailChange(principal, input, deviceInfo(c, ""))
+	if err != nil {
+		if tooManyAttempts(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrVerifyAttemptsSpent):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrEmailTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case err.Error() == "сессия не найдена или удалена":
+			c.JSON(http.StatusNotFound, gin.H{"error": "Сессия не найдена"})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	adminGroups, err := services.GetAdminGroups(principal)
+	if err != nil {
+		adminGroups = []services.AdminGroupResponse{}
+	}
+
+	c.JSON(http.StatusOK, AuthResponse{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		AdminGroups:  adminGroups,
+	})
+}
+
 // ChangeTilesPattern godoc
 // @Summary      Изменить порядок отображения плиток статистики
 // @Description  Позволяет пользователю настроить, какие плитки статистики будут отображаться в его профиле.
@@ -293,17 +333,7 @@ func ChangePassword(c *gin.Context) {
 // @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
 // @Router       /api/users/tiles [patch]
 func ChangeTilesPattern(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-
-	email, ok := emailValue.(string)
-	if !ok || email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат email"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 
 	var input services.ChangeTilesPatternInput
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -311,7 +341,7 @@ func ChangeTilesPattern(c *gin.Context) {
 		return
 	}
 
-	if err := services.ChangePattern(email, input); err != nil {
+	if err := services.ChangePattern(principal, input); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"friendship/middlewares"
 	"friendship/services"
 	"net/http"
 	"strconv"
@@ -19,24 +20,9 @@ import (
 // @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
 // @Router       /api/users/notify [get]
 func GetNotify(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-
-	email, ok := emailValue.(string)
-	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат email в контексте"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 
-	if email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "email не может быть пустым"})
-		return
-	}
-
-	res, err := services.GetNotify(email)
+	res, err := services.GetNotify(principal)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -56,24 +42,9 @@ func GetNotify(c *gin.Context) {
 // @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
 // @Router       /api/users/notify/inf [get]
 func GetNotifyInf(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 
-	email, ok := emailValue.(string)
-	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат email в контексте"})
-		return
-	}
-
-	if email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "email не может быть пустым"})
-		return
-	}
-
-	res, err := services.GetNotifyInf(email)
+	res, err := services.GetNotifyInf(principal)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -95,23 +66,7 @@ func GetNotifyInf(c *gin.Context) {
 // @Failure      401  {object}  map[string]string "Пользователь не авторизован"
 // @Router       /api/users/notifications/viewed [post]
 func MarkNotificationViewed(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-
-	email, ok := emailValue.(string)
-	if !ok || email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный email"})
-		return
-	}
-
-	user, err := services.FindUserByEmail(email)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не найден"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 
 	var req struct {
 		ID uint `json:"id"`
@@ -121,7 +76,7 @@ func MarkNotificationViewed(c *gin.Context) {
 		return
 	}
 
-	if err := services.MarkNotificationViewed(req.ID, user.ID); err != nil {
+	if err := services.MarkNotificationViewed(req.ID, principal.UserID); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -141,18 +96,8 @@ func MarkNotificationViewed(c *gin.Context) {
 // @Failure      401  {object}  map[string]string "Пользователь не авторизован"
 // @Router       /api/users/invites/{id}/approve [put]
 func ApproveInvite(c *gin.Context) {
-	// Достаём email из контекста
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-
-	email, ok := emailValue.(string)
-	if !ok || email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат email"})
-		return
-	}
+	// Достаём пользователя из контекста
+	principal := middlewares.GetPrincipal(c)
 
 	inviteIDStr := c.Param("id")
 	inviteID, err := strconv.ParseUint(inviteIDStr, 10, 64)
@@ -162,7 +107,7 @@ func ApproveInvite(c *gin.Context) {
 	}
 
 	// Вызываем сервис
-	if err := services.ApproveInviteByID(email, uint(inviteID)); err != nil {
+	if err := services.ApproveInviteByID(principal, uint(inviteID)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -182,17 +127,7 @@ func ApproveInvite(c *gin.Context) {
 // @Failure      401  {object}  map[string]string "Пользователь не авторизован"
 // @Router       /api/users/invites/{id}/reject [put]
 func RejectInvite(c *gin.Context) {
-	emailValue, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не найден email в контексте"})
-		return
-	}
-
-	email, ok := emailValue.(string)
-	if !ok || email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат email"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 
 	inviteIDStr := c.Param("id")
 	inviteID, err := strconv.ParseUint(inviteIDStr, 10, 64)
@@ -201,7 +136,7 @@ func RejectInvite(c *gin.Context) {
 		return
 	}
 
-	if err := services.RejectInviteByID(email, uint(inviteID)); err != nil {
+	if err := services.RejectInviteByID(principal, uint(inviteID)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
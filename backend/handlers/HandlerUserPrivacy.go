@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"friendship/middlewares"
+	"friendship/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPrivacySettingsHandler godoc
+// @Summary      Настройки приватности
+// @Description  Возвращает настройки приватности профиля текущего пользователя.
+// @Tags         Users inf
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {object}  models.UserPrivacy "Настройки приватности"
+// @Failure      500  {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router       /api/users/privacy [get]
+func GetPrivacySettingsHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	privacy, err := services.GetPrivacySettings(principal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, privacy)
+}
+
+// UpdatePrivacySettingsHandler godoc
+// @Summary      Изменить настройки приватности
+// @Description  profile_visibility: everyone — профиль виден всем, friends — друзьям и участникам общих групп, nobody — никому. hide_upcoming_sessions скрывает предстоящие сессии в профиле и в списках участников, hide_stats — статистику, жанры, значки и компаньонов, unsearchable — исключает из поиска. Меняются только переданные поля.
+// @Tags         Users inf
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        input body services.UpdatePrivacyInput true "Настройки"
+// @Success      200  {object}  models.UserPrivacy "Сохранённые настройки"
+// @Failure      400  {object}  map[string]string "Некорректные данные"
+// @Router       /api/users/privacy [patch]
+func UpdatePrivacySettingsHandler(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	var input services.UpdatePrivacyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный json", "details": err.Error()})
+		return
+	}
+
+	privacy, err := services.UpdatePrivacySettings(principal, input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, privacy)
+}
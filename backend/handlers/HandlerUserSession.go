@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"fmt"
+	"friendship/middlewares"
 	"friendship/services"
 	"net/http"
 	"strconv"
@@ -54,7 +55,7 @@ type CachedPopularSessionsDoc struct {
 // @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
 // @Router /api/users/sessions/new [get]
 // func GetNewSessions(c *gin.Context) {
-// 	email := c.MustGet("email").(string)
+// 	principal := middlewares.GetPrincipal(c)
 // 	if email == "" {
 // 		c.JSON(http.StatusUnauthorized, gin.H{"error": "не передан jwt"})
 // 		return
@@ -68,7 +69,7 @@ type CachedPopularSessionsDoc struct {
 // 		return
 // 	}
 
-// 	response, err := services.GetNewSessions(email, page)
+// 	response, err := services.GetNewSessions(principal, page)
 // 	if err != nil {
 // 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 // 		return
@@ -97,6 +98,28 @@ func GetPopularSessions(c *gin.Context) {
 	c.JSON(http.StatusOK, cachedSessions)
 }
 
+// GetRecommendedSessions godoc
+// @Summary Персональные рекомендации сессий
+// @Description Возвращает открытые сессии в статусе "Набор", отсортированные по интересам пользователя: жанры, типы сессий, город, группы и компаньоны. У каждой сессии есть список причин, почему она предложена. Кэшируется на 30 минут.
+// @Tags Получение данных о сессиях
+// @Security BearerAuth
+// @Produce  json
+// @Success 200 {object} services.CachedRecommendations "Персональная лента"
+// @Failure 401 {object} map[string]string "Пользователь не авторизован"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /api/users/sessions/recommended [get]
+func GetRecommendedSessions(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	recommended, err := services.GetRecommendedSessions(principal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, recommended)
+}
+
 // GetCategorySessions godoc
 // @Summary Получение сессий по категории
 // @Description Возвращает список из 10 сессий по указанной категории из открытых групп. Вводить вам нужно будет иметь мапу с категориями, где ключ - название категории, а значение - id категории. Сейчас такие значнеия: 1 - Фильмы, 2 - Игры. 3 - Настолки, 4 - Другое
@@ -167,12 +190,7 @@ func GetPopularSessions(c *gin.Context) {
 // @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
 // @Router /api/users/sessions/search [get]
 func SearchSessions(c *gin.Context) {
-	email := c.MustGet("email").(string)
-	if email == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не передан jwt"})
-		return
-	}
-
+	principal := middlewares.GetPrincipal(c)
 	var input services.GetSessionsInput
 	if err := c.ShouldBindQuery(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректные параметры запроса: " + err.Error()})
@@ -199,7 +217,7 @@ func SearchSessions(c *gin.Context) {
 		input.Page = 1
 	}
 
-	result, err := services.GetSessions(email, input)
+	result, err := services.GetSessions(principal, input)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -223,12 +241,7 @@ func SearchSessions(c *gin.Context) {
 // @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
 // @Router /api/users/sessions/{sessionId} [get]
 func GetDetailedInfo(c *gin.Context) {
-	email := c.MustGet("email").(string)
-	if email == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не передан jwt"})
-		return
-	}
-
+	principal := middlewares.GetPrincipal(c)
 	sessionIDParam := c.Param("sessionId")
 	if sessionIDParam == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "sessionId не указан"})
@@ -242,7 +255,7 @@ func GetDetailedInfo(c *gin.Context) {
 	}
 	sessionID := uint(sessionIDInt)
 
-	session, err := services.GetInfoAboutSession(&email, &sessionID)
+	session, err := services.GetInfoAboutSession(principal, &sessionID)
 	if err != nil {
 		fmt.Printf("Ошибка при получении сессии: %v\n", err)
 
@@ -280,28 +293,20 @@ func GetDetailedInfo(c *gin.Context) {
 // @Failure 500 {object} object{error=string} "Внутренняя ошибка сервера"
 // @Router /api/users/sessions/user-groups [get]
 func GetSessionsUserGroups(c *gin.Context) {
-	email := c.MustGet("email").(string)
-	if email == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не передан jwt"})
-		return
-	}
+	principal := middlewares.GetPrincipal(c)
 	pageStr := c.DefaultQuery("page", "1")
 	page, err := strconv.Atoi(pageStr)
 	if err != nil || page < 1 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный номер страницы"})
 		return
 	}
-	sessions, err := services.GetSessionsUserGroups(&email, page)
+	sessions, err := services.GetSessionsUserGroups(principal, page)
 	if err != nil {
 		switch {
 		case strings.Contains(err.Error(), "пользователь не найден"):
 			c.JSON(http.StatusNotFound, gin.H{"error": "пользователь не найден"})
 			return
 
-		case strings.Contains(err.Error(), "email не может быть пустым"):
-			c.JSON(http.StatusBadRequest, gin.H{"error": "email не может быть пустым"})
-			return
-
 		case strings.Contains(err.Error(), "статус 'Набор' не найден"):
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка конфигурации системы"})
 			return
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"friendship/middlewares"
+	"friendship/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUserPeriodStats godoc
+// @Summary Статистика пользователя по периодам
+// @Description Возвращает статистику по месяцам выбранного года или по годам: количество сессий, потраченное время, топ жанров, типов сессий и компаньонов.
+// @Tags Users inf
+// @Security BearerAuth
+// @Produce json
+// @Param granularity query string false "month (по умолчанию) или year"
+// @Param year query int false "Год для помесячной статистики (по умолчанию — текущий)"
+// @Success 200 {array} services.PeriodStats "Статистика по периодам"
+// @Failure 400 {object} map[string]string "Некорректные параметры"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /api/users/stats/periods [get]
+func GetUserPeriodStats(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	var year int
+	if yearParam := c.Query("year"); yearParam != "" {
+		parsed, err := strconv.Atoi(yearParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат года"})
+			return
+		}
+		year = parsed
+	}
+
+	granularity := c.DefaultQuery("granularity", services.PeriodMonth)
+	if granularity != services.PeriodMonth && granularity != services.PeriodYear {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "granularity должен быть month или year"})
+		return
+	}
+
+	stats, err := services.GetUserPeriodStats(principal, granularity, year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetYearRecap godoc
+// @Summary Итоги года
+// @Description Собирает итоги года пользователя и возвращает ссылку на PNG-карточку для шаринга.
+// @Tags Users inf
+// @Security BearerAuth
+// @Produce json
+// @Param year path int true "Год"
+// @Success 200 {object} services.YearRecap "Итоги года"
+// @Failure 400 {object} map[string]string "Некорректный год"
+// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
+// @Router /api/users/stats/recap/{year} [get]
+func GetYearRecap(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	year, err := strconv.Atoi(c.Param("year"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат года"})
+		return
+	}
+
+	recap, err := services.GetYearRecap(principal, year)
+	if err != nil {
+		if err.Error() == "некорректный год" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, recap)
+}
+
+// GetAchievements godoc
+// @Summary Достижения пользователя
+// @Description Возвращает все достижения платформы с отметкой, какие из них получены и закреплены в профиле.
+// @Tags Users inf
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} services.AchievementDTO "Список достижений"
+// @Failure 404 {object} map[string]string "Пользователь не найден"
+// @Router /api/users/achievements [get]
+func GetAchievements(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	achievements, err := services.GetAllAchievements(principal)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, achievements)
+}
+
+// PinAchievements godoc
+// @Summary Выбрать достижения для профиля
+// @Description Задаёт, какие из полученных достижений показывать в профиле (не больше 6). Пустой список скрывает все.
+// @Tags Users inf
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body services.PinAchievementsInput true "Коды достижений"
+// @Success 200 {object} map[string]string "Достижения закреплены"
+// @Failure 400 {object} map[string]string "Некорректные данные"
+// @Router /api/users/achievements/tiles [patch]
+func PinAchievements(c *gin.Context) {
+	principal := middlewares.GetPrincipal(c)
+
+	var input services.PinAchievementsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "не удалось распарсить json", "details": err.Error()})
+		return
+	}
+
+	if err := services.PinAchievements(principal, input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "Достижения закреплены"})
+}
@@ -10,6 +10,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -33,8 +34,19 @@ func main() {
 	}
 
 	utils.Init()
+	if err := utils.InitJWTKeys(); err != nil {
+		log.Fatal("Ошибка загрузки ключей JWT:", err)
+	}
+	if err := utils.InitPasswordHashing(); err != nil {
+		log.Fatal("Некорректные параметры хэширования паролей:", err)
+	}
 	r := gin.New()
 
+	// ClientIP используется в ограничениях по IP, поэтому X-Forwarded-For принимаем только от своего прокси
+	if err := r.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatal("Некорректный TRUSTED_PROXIES:", err)
+	}
+
 	r.Use(middlewares.ErrorLogger())
 
 	// Вариант 1: Простое логирование
@@ -104,3 +116,15 @@ func main() {
 	utils.Log.Println("INFO: Starting server on :8080")
 	r.Run(":8080")
 }
+
+// trustedProxies читает TRUSTED_PROXIES — адреса или подсети через запятую. Пусто — сервис
+// смотрит в интернет напрямую, и заголовкам X-Forwarded-For не верим вовсе.
+func trustedProxies() []string {
+	var proxies []string
+	for _, p := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			proxies = append(proxies, p)
+		}
+	}
+	return proxies
+}
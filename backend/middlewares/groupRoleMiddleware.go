@@ -3,6 +3,7 @@ package middlewares
 import (
 	"fmt"
 	"friendship/db"
+	"friendship/models"
 	"friendship/models/groups"
 	"net/http"
 	"strconv"
@@ -10,11 +11,11 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// GroupRoleMiddleware проверяет, имеет ли пользователь одну из указанных ролей в группе.
-// Он извлекает groupID из URL и email из JWT.
-func GroupRoleMiddleware(roles ...string) gin.HandlerFunc {
+// GroupCapabilityMiddleware проверяет, что роль пользователя в группе даёт все указанные права.
+// Он извлекает groupID из URL и пользователя из JWTAuthMiddleware, роль кладёт в контекст как "groupRole".
+func GroupCapabilityMiddleware(capabilities ...groups.Capability) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		email, exists := c.Get("email")
+		value, exists := c.Get(principalContextKey)
 		if !exists {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
 			return
@@ -32,18 +33,22 @@ func GroupRoleMiddleware(roles ...string) gin.HandlerFunc {
 			return
 		}
 
-		var userID uint
-		if err := db.GetDB().Table("users").Select("id").Where("email = ?", email).Scan(&userID).Error; err != nil {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "пользователь не найден"})
-			return
-		}
+		userID := value.(models.Principal).UserID
 
 		var groupUser groups.GroupUsers
-		if err := db.GetDB().Where("user_id = ? AND group_id = ? AND role_in_group IN ?", userID, groupID, roles).First(&groupUser).Error; err != nil {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("у вас нет необходимых прав в этой группе (%v)", roles)})
+		if err := db.GetDB().Where("user_id = ? AND group_id = ?", userID, groupID).First(&groupUser).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "вы не состоите в этой группе"})
 			return
 		}
 
+		for _, capability := range capabilities {
+			if !groups.HasCapability(groupUser.RoleInGroup, capability) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("у вас нет необходимых прав в этой группе (%s)", capability)})
+				return
+			}
+		}
+
+		c.Set("groupRole", groupUser.RoleInGroup)
 		c.Next()
 	}
 }
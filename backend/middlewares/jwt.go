@@ -1,6 +1,9 @@
 package middlewares
 
 import (
+	"errors"
+	"friendship/db"
+	"friendship/models"
 	"friendship/utils"
 	"net/http"
 	"strings"
@@ -8,6 +11,8 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+const principalContextKey = "principal"
+
 func JWTAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -24,15 +29,38 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		email, err := utils.ParseJWT(parts[1])
+		claims, err := utils.ParseAccessToken(parts[1])
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Невалидный токен: " + err.Error()})
 			c.Abort()
 			return
 		}
 
-		// Сохраняем email в контекст
-		c.Set("email", email)
+		principal, err := db.LoadSessionPrincipal(claims.UserID, claims.SessionID)
+		if errors.Is(err, db.ErrAuthSessionRevoked) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if errors.Is(err, db.ErrPrincipalNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден"})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось загрузить пользователя"})
+			c.Abort()
+			return
+		}
+		principal.SessionID = claims.SessionID
+
+		// Сохраняем пользователя в контекст
+		c.Set(principalContextKey, *principal)
 		c.Next()
 	}
 }
+
+// GetPrincipal возвращает пользователя, загруженного JWTAuthMiddleware
+func GetPrincipal(c *gin.Context) models.Principal {
+	return c.MustGet(principalContextKey).(models.Principal)
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+const (
+	FriendshipPending  = "pending"
+	FriendshipAccepted = "accepted"
+	FriendshipDeclined = "declined"
+)
+
+// Friendship — заявка в друзья от RequesterID к AddresseeID. После принятия дружба взаимная.
+// На пару пользователей хранится одна запись, независимо от направления.
+type Friendship struct {
+	ID          uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	RequesterID uint       `json:"requesterId" gorm:"not null;uniqueIndex:idx_friendship_pair"`
+	Requester   User       `json:"-" gorm:"foreignKey:RequesterID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	AddresseeID uint       `json:"addresseeId" gorm:"not null;uniqueIndex:idx_friendship_pair;index"`
+	Addressee   User       `json:"-" gorm:"foreignKey:AddresseeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Status      string     `json:"status" gorm:"not null;default:pending"` // "pending", "accepted", "declined"
+	RespondedAt *time.Time `json:"respondedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// Follow — односторонняя подписка FollowerID на FolloweeID
+type Follow struct {
+	ID         uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	FollowerID uint      `json:"followerId" gorm:"not null;uniqueIndex:idx_follow_pair"`
+	Follower   User      `json:"-" gorm:"foreignKey:FollowerID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	FolloweeID uint      `json:"followeeId" gorm:"not null;uniqueIndex:idx_follow_pair;index"`
+	Followee   User      `json:"-" gorm:"foreignKey:FolloweeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
@@ -0,0 +1,22 @@
+package models
+
+// Principal — пользователь, от имени которого выполняется запрос. Загружается JWTAuthMiddleware
+// по Id из access токена, поэтому не зависит от email, записанного в токен при входе.
+type Principal struct {
+	UserID     uint   `json:"user_id"`
+	Email      string `json:"email"`
+	Role       string `json:"role"`
+	Verified   bool   `json:"verified"`
+	Enterprise bool   `json:"enterprise"`
+	SessionID  string `json:"-"` // sid сессии входа, берётся из токена и не кэшируется
+}
+
+func NewPrincipal(user User) Principal {
+	return Principal{
+		UserID:     user.ID,
+		Email:      user.Email,
+		Role:       user.Role,
+		Verified:   user.VerifiedUser,
+		Enterprise: user.Enterprise,
+	}
+}
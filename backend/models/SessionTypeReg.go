@@ -7,6 +7,7 @@ type SessionTypeReg string
 const (
 	SessionTypeRegister      SessionTypeReg = "register"
 	SessionTypeResetPassword SessionTypeReg = "reset_password"
+	SessionTypeChangeEmail   SessionTypeReg = "change_email"
 )
 
 type SessionRegResponse struct {
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// UserBlock — BlockerID заблокировал BlockedID. Пока блокировка действует, пользователи
+// не видят друг друга в поиске и не могут дружить, подписываться и приглашать друг друга.
+type UserBlock struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	BlockerID uint      `json:"blockerId" gorm:"not null;uniqueIndex:idx_user_block_pair"`
+	Blocker   User      `json:"-" gorm:"foreignKey:BlockerID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	BlockedID uint      `json:"blockedId" gorm:"not null;uniqueIndex:idx_user_block_pair;index"`
+	Blocked   User      `json:"-" gorm:"foreignKey:BlockedID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	CreatedAt time.Time `json:"createdAt"`
+}
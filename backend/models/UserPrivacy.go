@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+const (
+	ProfileVisibilityEveryone = "everyone"
+	ProfileVisibilityFriends  = "friends" // друзья и участники общих групп
+	ProfileVisibilityNobody   = "nobody"
+)
+
+// UserPrivacy — настройки приватности профиля. Если записи нет, действуют значения по умолчанию.
+type UserPrivacy struct {
+	ID                   uint      `json:"-" gorm:"primaryKey;autoIncrement"`
+	UserID               uint      `json:"-" gorm:"not null;uniqueIndex"`
+	User                 User      `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ProfileVisibility    string    `json:"profile_visibility" gorm:"not null;default:everyone"` // everyone, friends, nobody
+	HideUpcomingSessions bool      `json:"hide_upcoming_sessions" gorm:"not null;default:false"`
+	HideStats            bool      `json:"hide_stats" gorm:"not null;default:false"`
+	Unsearchable         bool      `json:"unsearchable" gorm:"not null;default:false"`
+	UpdatedAt            time.Time `json:"-"`
+}
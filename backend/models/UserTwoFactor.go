@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// UserTwoFactor — настройки TOTP пользователя. Пока Enabled = false, секрет только выдан
+// для подключения приложения и при входе не требуется.
+type UserTwoFactor struct {
+	ID           uint       `json:"-" gorm:"primaryKey;autoIncrement"`
+	UserID       uint       `json:"-" gorm:"not null;uniqueIndex"`
+	User         User       `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Secret       string     `json:"-" gorm:"not null"` // base32
+	Enabled      bool       `json:"enabled" gorm:"not null;default:false"`
+	LastUsedStep int64      `json:"-" gorm:"not null;default:0"` // последний принятый шаг TOTP, защита от повтора кода
+	EnabledAt    *time.Time `json:"enabledAt"`
+	CreatedAt    time.Time  `json:"-"`
+	UpdatedAt    time.Time  `json:"-"`
+}
+
+// UserRecoveryCode — одноразовый код восстановления на случай потери устройства. Хранится только хэш.
+type UserRecoveryCode struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement"`
+	UserID    uint   `gorm:"not null;index"`
+	User      User   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	CodeHash  string `gorm:"not null;uniqueIndex"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
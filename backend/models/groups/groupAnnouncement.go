@@ -0,0 +1,27 @@
+package groups
+
+import (
+	"friendship/models"
+	"time"
+)
+
+const (
+	AnnouncementNotifyAll    = "all"
+	AnnouncementNotifyPinned = "pinned"
+	AnnouncementNotifyNone   = "none"
+)
+
+// GroupAnnouncement — объявление группы для её участников. Закреплённые показываются первыми.
+type GroupAnnouncement struct {
+	ID        uint        `json:"id" gorm:"primaryKey;autoIncrement"`
+	GroupID   uint        `json:"groupId" gorm:"not null;index"`
+	Group     Group       `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	AuthorID  *uint       `json:"authorId"`
+	Author    models.User `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Title     string      `json:"title" gorm:"not null"`
+	Text      string      `json:"text" gorm:"not null"`
+	Images    []string    `json:"images" gorm:"type:jsonb;serializer:json"`
+	PinnedAt  *time.Time  `json:"pinnedAt"`
+	CreatedAt time.Time   `json:"createdAt"`
+	UpdatedAt time.Time   `json:"updatedAt"`
+}
@@ -0,0 +1,32 @@
+package groups
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GroupAuditLog — запись журнала действий в группе. Журнал только пополняется.
+// Без внешних ключей: история сохраняется после удаления группы, сессии или пользователя.
+type GroupAuditLog struct {
+	ID         uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	GroupID    uint      `json:"groupId" gorm:"not null;index:idx_group_audit_group_created"`
+	ActorID    *uint     `json:"actorId" gorm:"index"`
+	Action     string    `json:"action" gorm:"not null;index"`
+	TargetType string    `json:"targetType"` // "group", "user", "session", "request", "invite", "invite_link", "ban", "transfer"
+	TargetID   *uint     `json:"targetId"`
+	Before     *string   `json:"before" gorm:"type:jsonb"`
+	After      *string   `json:"after" gorm:"type:jsonb"`
+	CreatedAt  time.Time `json:"createdAt" gorm:"index:idx_group_audit_group_created"`
+}
+
+var ErrAuditLogImmutable = errors.New("журнал аудита нельзя изменять")
+
+func (GroupAuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+func (GroupAuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
@@ -0,0 +1,22 @@
+package groups
+
+import (
+	"friendship/models"
+	"time"
+)
+
+// GroupBan — блокировка пользователя в группе. Действует, пока не снята и не истёк ExpiresAt (nil — бессрочно).
+type GroupBan struct {
+	ID         uint        `json:"id" gorm:"primaryKey;autoIncrement"`
+	GroupID    uint        `json:"groupId" gorm:"not null;index:idx_group_ban_user"`
+	Group      Group       `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserID     uint        `json:"userId" gorm:"not null;index:idx_group_ban_user"`
+	User       models.User `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	BannedByID *uint       `json:"bannedById"`
+	BannedBy   models.User `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Reason     string      `json:"reason"`
+	ExpiresAt  *time.Time  `json:"expiresAt"`
+	LiftedAt   *time.Time  `json:"liftedAt"`
+	LiftedByID *uint       `json:"liftedById"`
+	CreatedAt  time.Time   `json:"createdAt"`
+}
@@ -0,0 +1,33 @@
+package groups
+
+import (
+	"friendship/models"
+	"time"
+)
+
+// GroupInviteLink — ссылка-приглашение в группу по токену.
+// MaxUses == 0 и ExpiresAt == nil означают отсутствие ограничений.
+type GroupInviteLink struct {
+	ID          uint        `json:"id" gorm:"primaryKey;autoIncrement"`
+	GroupID     uint        `json:"groupId" gorm:"not null;index"`
+	Group       Group       `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	CreatedByID *uint       `json:"createdById"`
+	CreatedBy   models.User `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Token       string      `json:"token" gorm:"uniqueIndex;not null"`
+	ExpiresAt   *time.Time  `json:"expiresAt"`
+	MaxUses     uint        `json:"maxUses" gorm:"default:0"`
+	Uses        uint        `json:"uses" gorm:"default:0"`
+	AutoApprove bool        `json:"autoApprove" gorm:"default:false"`
+	RevokedAt   *time.Time  `json:"revokedAt"`
+	CreatedAt   time.Time   `json:"createdAt"`
+}
+
+// GroupInviteLinkUse — факт использования ссылки, для статистики
+type GroupInviteLinkUse struct {
+	ID     uint            `gorm:"primaryKey;autoIncrement"`
+	LinkID uint            `json:"linkId" gorm:"not null;uniqueIndex:idx_invite_link_user"`
+	Link   GroupInviteLink `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserID uint            `json:"userId" gorm:"not null;uniqueIndex:idx_invite_link_user"`
+	Joined bool            `json:"joined"` // false — создана заявка на вступление
+	UsedAt time.Time       `json:"usedAt" gorm:"autoCreateTime"`
+}
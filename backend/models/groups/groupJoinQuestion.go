@@ -0,0 +1,33 @@
+package groups
+
+import "time"
+
+const (
+	QuestionTypeText   = "text"
+	QuestionTypeChoice = "choice"
+)
+
+// GroupJoinQuestion — вопрос анкеты, которую заполняет желающий вступить в закрытую группу
+type GroupJoinQuestion struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	GroupID   uint      `json:"groupId" gorm:"not null;index"`
+	Group     Group     `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Position  int       `json:"position" gorm:"not null;default:0"`
+	Text      string    `json:"text" gorm:"not null"`
+	Type      string    `json:"type" gorm:"not null;default:text"` // "text", "choice"
+	Options   []string  `json:"options" gorm:"type:jsonb;serializer:json"`
+	Required  bool      `json:"required" gorm:"default:false"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// GroupJoinRequestAnswer — ответ на вопрос анкеты, хранится вместе с заявкой.
+// Текст вопроса копируется, чтобы ответ оставался понятным после изменения анкеты.
+type GroupJoinRequestAnswer struct {
+	ID           uint               `json:"id" gorm:"primaryKey;autoIncrement"`
+	RequestID    uint               `json:"requestId" gorm:"not null;index"`
+	Request      GroupJoinRequest   `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	QuestionID   *uint              `json:"questionId"`
+	Question     *GroupJoinQuestion `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	QuestionText string             `json:"questionText" gorm:"not null"`
+	Answer       string             `json:"answer"`
+}
@@ -0,0 +1,17 @@
+package groups
+
+import "time"
+
+// GroupMemberLeave — запись о выходе участника из группы, нужна для подсчёта оттока.
+// UserID без внешнего ключа: запись остаётся и после удаления аккаунта.
+type GroupMemberLeave struct {
+	ID      uint      `gorm:"primaryKey;autoIncrement"`
+	GroupID uint      `json:"groupId" gorm:"not null;index"`
+	Group   Group     `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserID  uint      `json:"userId" gorm:"not null;index"`
+	Reason  string    `json:"reason"` // "left", "removed", "banned"
+	LeftAt  time.Time `json:"leftAt" gorm:"autoCreateTime"`
+	// JoinedAt переносится из group_users, чтобы вступления ушедших тоже попадали в динамику.
+	// nil у старых записей и у участников без даты вступления.
+	JoinedAt *time.Time `json:"joinedAt"`
+}
@@ -0,0 +1,21 @@
+package groups
+
+import (
+	"friendship/models"
+	"time"
+)
+
+// GroupOwnershipTransfer — запрос владельца на передачу группы, вступает в силу после подтверждения новым владельцем
+type GroupOwnershipTransfer struct {
+	ID          uint        `json:"id" gorm:"primaryKey;autoIncrement"`
+	GroupID     uint        `json:"groupId" gorm:"not null;index"`
+	Group       Group       `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	FromUserID  uint        `json:"fromUserId" gorm:"not null"`
+	FromUser    models.User `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ToUserID    uint        `json:"toUserId" gorm:"not null;index"`
+	ToUser      models.User `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Status      string      `json:"status"` // "pending", "accepted", "declined", "cancelled"
+	ExpiresAt   time.Time   `json:"expiresAt"`
+	RespondedAt *time.Time  `json:"respondedAt"`
+	CreatedAt   time.Time   `json:"createdAt"`
+}
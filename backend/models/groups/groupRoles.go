@@ -0,0 +1,80 @@
+package groups
+
+// Роли участника группы и права, которые они дают.
+// Проверки доступа должны идти через HasCapability, а не через сравнение строк ролей.
+
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleMember   = "member"
+)
+
+type Capability string
+
+const (
+	CapCreateSessions    Capability = "create_sessions"
+	CapEditSessions      Capability = "edit_sessions"
+	CapApproveRequests   Capability = "approve_requests"
+	CapRemoveMembers     Capability = "remove_members"
+	CapEditGroup         Capability = "edit_group"
+	CapManageRoles       Capability = "manage_roles"
+	CapViewAnalytics     Capability = "view_analytics"
+	CapViewAuditLog      Capability = "view_audit_log"
+	CapPostAnnouncements Capability = "post_announcements"
+)
+
+// Roles — роли по возрастанию старшинства
+var Roles = []string{RoleMember, RoleOperator, RoleAdmin}
+
+var roleCapabilities = map[string][]Capability{
+	RoleAdmin: {
+		CapCreateSessions, CapEditSessions, CapApproveRequests, CapRemoveMembers,
+		CapEditGroup, CapManageRoles, CapViewAnalytics, CapViewAuditLog, CapPostAnnouncements,
+	},
+	RoleOperator: {
+		CapCreateSessions, CapEditSessions, CapApproveRequests, CapRemoveMembers, CapViewAnalytics,
+		CapPostAnnouncements,
+	},
+	RoleMember: {
+		CapCreateSessions,
+	},
+}
+
+// HasCapability проверяет, даёт ли роль указанное право
+func HasCapability(role string, capability Capability) bool {
+	for _, c := range roleCapabilities[role] {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// CapabilitiesOf возвращает права роли; для неизвестной роли — пустой список
+func CapabilitiesOf(role string) []Capability {
+	caps := roleCapabilities[role]
+	result := make([]Capability, len(caps))
+	copy(result, caps)
+	return result
+}
+
+// RolesWith возвращает все роли, у которых есть право, — для фильтров в запросах
+func RolesWith(capability Capability) []string {
+	var result []string
+	for _, role := range Roles {
+		if HasCapability(role, capability) {
+			result = append(result, role)
+		}
+	}
+	return result
+}
+
+// RoleRank — старшинство роли, -1 для неизвестной
+func RoleRank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
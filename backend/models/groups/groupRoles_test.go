@@ -0,0 +1,76 @@
+package groups
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHasCapability(t *testing.T) {
+	all := []Capability{
+		CapCreateSessions, CapEditSessions, CapApproveRequests, CapRemoveMembers,
+		CapEditGroup, CapManageRoles, CapViewAnalytics, CapViewAuditLog, CapPostAnnouncements,
+	}
+	granted := map[string][]Capability{
+		RoleAdmin: all,
+		RoleOperator: {
+			CapCreateSessions, CapEditSessions, CapApproveRequests, CapRemoveMembers,
+			CapViewAnalytics, CapPostAnnouncements,
+		},
+		RoleMember: {CapCreateSessions},
+		"":         nil,
+		"owner":    nil,
+	}
+
+	for role, caps := range granted {
+		for _, c := range all {
+			want := slices.Contains(caps, c)
+			if got := HasCapability(role, c); got != want {
+				t.Errorf("HasCapability(%q, %s) = %v, want %v", role, c, got, want)
+			}
+		}
+	}
+}
+
+func TestCapabilitiesOfReturnsCopy(t *testing.T) {
+	caps := CapabilitiesOf(RoleMember)
+	if len(caps) != 1 || caps[0] != CapCreateSessions {
+		t.Fatalf("CapabilitiesOf(member) = %v", caps)
+	}
+
+	caps[0] = CapManageRoles
+	if HasCapability(RoleMember, CapManageRoles) {
+		t.Error("изменение результата CapabilitiesOf поменяло права роли")
+	}
+
+	if caps := CapabilitiesOf("unknown"); len(caps) != 0 {
+		t.Errorf("CapabilitiesOf(unknown) = %v, want пустой список", caps)
+	}
+}
+
+func TestRolesWith(t *testing.T) {
+	tests := []struct {
+		capability Capability
+		want       []string
+	}{
+		{CapCreateSessions, []string{RoleMember, RoleOperator, RoleAdmin}},
+		{CapApproveRequests, []string{RoleOperator, RoleAdmin}},
+		{CapManageRoles, []string{RoleAdmin}},
+		{CapViewAuditLog, []string{RoleAdmin}},
+		{Capability("unknown"), nil},
+	}
+
+	for _, tt := range tests {
+		if got := RolesWith(tt.capability); !slices.Equal(got, tt.want) {
+			t.Errorf("RolesWith(%s) = %v, want %v", tt.capability, got, tt.want)
+		}
+	}
+}
+
+func TestRoleRank(t *testing.T) {
+	if !(RoleRank(RoleMember) < RoleRank(RoleOperator) && RoleRank(RoleOperator) < RoleRank(RoleAdmin)) {
+		t.Errorf("неверный порядок ролей: %v", Roles)
+	}
+	if got := RoleRank("owner"); got != -1 {
+		t.Errorf("RoleRank(owner) = %d, want -1", got)
+	}
+}
@@ -1,6 +1,9 @@
 package groups
 
-import "friendship/models"
+import (
+	"friendship/models"
+	"time"
+)
 
 type GroupUsers struct {
 	ID uint `gorm:"primaryKey;autoIncrement"`
@@ -12,4 +15,8 @@ type GroupUsers struct {
 	Group   Group `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 
 	RoleInGroup string `json:"role"`
+
+	JoinedAt *time.Time `json:"joinedAt" gorm:"autoCreateTime"` // nil у участников, вступивших до появления поля
+
+	AnnouncementsNotify string `json:"announcementsNotify" gorm:"not null;default:all"` // "all", "pinned", "none"
 }
@@ -19,6 +19,7 @@ type Group struct {
 	City       string            `json:"city"`
 	Categories []models.Category `gorm:"many2many:group_group_categories;joinForeignKey:GroupID;JoinReferences:GroupCategoryID"`
 	Contacts   []GroupContact    `json:"contacts" gorm:"foreignKey:GroupID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ArchivedAt *time.Time        `json:"archivedAt"` // группа осталась без участников, новые вступления и сессии закрыты
 	CreatedAt  time.Time         `json:"createdAt"`
 	UpdatedAt  time.Time         `json:"updatedAt"`
 }
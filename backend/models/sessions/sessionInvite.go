@@ -0,0 +1,20 @@
+package sessions
+
+import (
+	"friendship/models"
+	"time"
+)
+
+// SessionInvite — личное приглашение друга на сессию
+type SessionInvite struct {
+	ID          uint        `json:"id" gorm:"primaryKey;autoIncrement"`
+	SessionID   uint        `json:"sessionId" gorm:"not null;uniqueIndex:idx_session_invite"`
+	Session     Session     `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	FromUserID  uint        `json:"fromUserId" gorm:"not null"`
+	FromUser    models.User `json:"-" gorm:"foreignKey:FromUserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ToUserID    uint        `json:"toUserId" gorm:"not null;uniqueIndex:idx_session_invite;index"`
+	ToUser      models.User `json:"-" gorm:"foreignKey:ToUserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Status      string      `json:"status" gorm:"not null;default:pending"` // "pending", "accepted", "declined"
+	RespondedAt *time.Time  `json:"respondedAt"`
+	CreatedAt   time.Time   `json:"createdAt"`
+}
@@ -12,4 +12,6 @@ type SettingTile struct {
 	Count_other bool        `json:"count_other" gorm:"default:false"`
 	Count_all   bool        `json:"count_all" gorm:"default:true"`
 	Spent_time  bool        `json:"spent_time" gorm:"default:false"`
+	Companions  bool        `json:"companions" gorm:"default:true"`  // показывать компаньонов и участвовать в «возможно, вы знакомы»
+	Leaderboard bool        `json:"leaderboard" gorm:"default:true"` // показываться в рейтингах
 }
@@ -0,0 +1,17 @@
+package statsusers
+
+import (
+	"friendship/models"
+	"time"
+)
+
+// UserAchievement — выданный пользователю значок. Выдаётся один раз, Pinned — показывать в профиле.
+type UserAchievement struct {
+	ID        uint        `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    uint        `json:"userId" gorm:"not null;uniqueIndex:idx_user_achievement"`
+	User      models.User `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Code      string      `json:"code" gorm:"size:64;not null;uniqueIndex:idx_user_achievement"`
+	SessionID *uint       `json:"session_id"` // сессия, после которой значок получен; nil — выдан при пересчёте
+	Pinned    bool        `json:"pinned" gorm:"default:false"`
+	AwardedAt time.Time   `json:"awarded_at" gorm:"not null"`
+}
@@ -0,0 +1,18 @@
+package statsusers
+
+import (
+	"friendship/models"
+	"time"
+)
+
+// CoAttendance_users — сколько завершённых сессий пользователь посетил вместе с CompanionID.
+// Хранится в обе стороны: на каждую пару две строки.
+type CoAttendance_users struct {
+	ID          uint        `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID      uint        `json:"userId" gorm:"not null;uniqueIndex:idx_user_companion"`
+	User        models.User `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	CompanionID uint        `json:"companionId" gorm:"not null;uniqueIndex:idx_user_companion;index"`
+	Companion   models.User `json:"-" gorm:"foreignKey:CompanionID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Count       uint16      `json:"count" gorm:"not null;default:0"`
+	LastSeenAt  time.Time   `json:"last_seen_at"`
+}
@@ -10,7 +10,7 @@ type User struct {
 	ID           uint      `json:"id" gorm:"primaryKey;autoIncrement"`
 	Name         string    `json:"name" gorm:"not null" validate:"required"`
 	Password     string    `json:"password" gorm:"not null" validate:"required,password"`
-	Salt         string    `json:"-" gorm:"not null" validate:"required"`
+	Salt         string    `json:"-" gorm:"not null;default:''"` // только для хэшей старого формата, в новых соль внутри Password
 	Us           string    `json:"us" gorm:"uniqueIndex;not null" validate:"required"`
 	Email        string    `json:"email" gorm:"uniqueIndex;not null" validate:"required,email"`
 	Image        string    `json:"image" gorm:"default:https://cdn-icons-png.flaticon.com/512/149/149071.png"`
@@ -2,15 +2,28 @@ package routes
 
 import (
 	"friendship/handlers"
+	"friendship/middlewares"
 
 	"github.com/gin-gonic/gin"
 )
 
 func RoutesAuth(r *gin.Engine) {
+	r.GET("/.well-known/jwks.json", handlers.JWKSHandler)
+
 	AuthGroup := r.Group("api/users")
 	{
 		AuthGroup.POST("/login", handlers.AuthUser)
+		AuthGroup.POST("/login/2fa", handlers.TwoFactorLoginHandler)
 		AuthGroup.POST("/refresh", handlers.RefreshTokenHandler)
+		AuthGroup.POST("/logout", handlers.LogoutHandler)
+		AuthGroup.POST("/logout-all", middlewares.JWTAuthMiddleware(), handlers.LogoutAllHandler)
+		AuthGroup.GET("/auth-sessions", middlewares.JWTAuthMiddleware(), handlers.GetAuthSessionsHandler)
+		AuthGroup.DELETE("/auth-sessions/:id", middlewares.JWTAuthMiddleware(), handlers.RevokeAuthSessionHandler)
+		AuthGroup.GET("/2fa", middlewares.JWTAuthMiddleware(), handlers.GetTwoFactorStatusHandler)
+		AuthGroup.POST("/2fa/setup", middlewares.JWTAuthMiddleware(), handlers.BeginTwoFactorSetupHandler)
+		AuthGroup.POST("/2fa/confirm", middlewares.JWTAuthMiddleware(), handlers.ConfirmTwoFactorSetupHandler)
+		AuthGroup.POST("/2fa/disable", middlewares.JWTAuthMiddleware(), handlers.DisableTwoFactorHandler)
+		AuthGroup.POST("/2fa/recovery-codes", middlewares.JWTAuthMiddleware(), handlers.RegenerateRecoveryCodesHandler)
 		AuthGroup.POST("/request-reset", handlers.RequestPasswordReset)
 		AuthGroup.POST("/confirm-reset", handlers.ConfirmPasswordReset)
 	}
@@ -3,6 +3,7 @@ package routes
 import (
 	"friendship/handlers"
 	"friendship/middlewares"
+	"friendship/models/groups"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,6 +16,15 @@ func RouterGroups(r *gin.Engine) {
 		GroupGroup.GET("/requests/:groupId", middlewares.JWTAuthMiddleware(), handlers.GetJoinRequests)
 		GroupGroup.DELETE("/:groupId/leave", middlewares.JWTAuthMiddleware(), handlers.LeaveGroupHandler)
 		GroupGroup.GET("/:groupId", middlewares.JWTAuthMiddleware(), handlers.GetGroupInf)
+		GroupGroup.GET("/:groupId/permissions", middlewares.JWTAuthMiddleware(), handlers.GetMyGroupPermissions)
+		GroupGroup.GET("/:groupId/questionnaire", middlewares.JWTAuthMiddleware(), handlers.GetGroupQuestionnaireHandler)
+		GroupGroup.GET("/:groupId/announcements", middlewares.JWTAuthMiddleware(), handlers.GetGroupAnnouncementsHandler)
+		GroupGroup.PATCH("/:groupId/notifications", middlewares.JWTAuthMiddleware(), handlers.SetGroupNotifySettingsHandler)
+		GroupGroup.GET("/invite/:token", middlewares.JWTAuthMiddleware(), handlers.GetInviteLinkPreviewHandler)
+		GroupGroup.POST("/invite/:token/join", middlewares.JWTAuthMiddleware(), handlers.RedeemInviteLinkHandler)
+		GroupGroup.GET("/transfers", middlewares.JWTAuthMiddleware(), handlers.GetOwnershipTransfersHandler)
+		GroupGroup.POST("/transfers/:transferId/accept", middlewares.JWTAuthMiddleware(), handlers.AcceptOwnershipTransferHandler)
+		GroupGroup.POST("/transfers/:transferId/decline", middlewares.JWTAuthMiddleware(), handlers.DeclineOwnershipTransferHandler)
 		GroupGroup.GET("/search", middlewares.JWTAuthMiddleware(), handlers.SearchGroups)
 	}
 
@@ -28,18 +38,36 @@ func RouterGroups(r *gin.Engine) {
 		GroupAdminGroups.POST("/requests/all/:groupId/rejectAll", middlewares.JWTAuthMiddleware(), handlers.RejectJoinAllRequest)
 		GroupAdminGroups.POST("/UploadPhoto", middlewares.JWTAuthMiddleware(), handlers.ChangePhoto)
 
-		// Роуты, требующие прав администратора или оператора
-		moderatorRequired := GroupAdminGroups.Group("/:groupId", middlewares.JWTAuthMiddleware(), middlewares.GroupRoleMiddleware("admin", "operator"))
+		groupScoped := GroupAdminGroups.Group("/:groupId", middlewares.JWTAuthMiddleware())
 		{
-			moderatorRequired.DELETE("/members/:userId", handlers.RemoveUserHandler)
-			moderatorRequired.GET("/infGroup", handlers.GetInfAdminGroup)
-		}
+			groupScoped.DELETE("/members/:userId", middlewares.GroupCapabilityMiddleware(groups.CapRemoveMembers), handlers.RemoveUserHandler)
+			groupScoped.GET("/infGroup", middlewares.GroupCapabilityMiddleware(groups.CapApproveRequests), handlers.GetInfAdminGroup)
+			groupScoped.GET("/cohesion", middlewares.GroupCapabilityMiddleware(groups.CapViewAnalytics), handlers.GetGroupCohesion)
+			groupScoped.GET("/analytics", middlewares.GroupCapabilityMiddleware(groups.CapViewAnalytics), handlers.GetGroupAnalytics)
+			groupScoped.GET("/analytics/export", middlewares.GroupCapabilityMiddleware(groups.CapViewAnalytics), handlers.ExportGroupAnalyticsCSV)
 
-		// Роуты, требующие прав только администратора
-		adminRequired := GroupAdminGroups.Group("/:groupId", middlewares.JWTAuthMiddleware(), middlewares.GroupRoleMiddleware("admin"))
-		{
-			adminRequired.DELETE("/", handlers.DeleteGroups)
-			adminRequired.PATCH("/", handlers.UpdateGroupHandler)
+			groupScoped.POST("/members/:userId/promote", middlewares.GroupCapabilityMiddleware(groups.CapManageRoles), handlers.PromoteMemberHandler)
+			groupScoped.POST("/members/:userId/demote", middlewares.GroupCapabilityMiddleware(groups.CapManageRoles), handlers.DemoteMemberHandler)
+			groupScoped.POST("/transfer", middlewares.GroupCapabilityMiddleware(groups.CapManageRoles), handlers.TransferOwnershipHandler)
+			groupScoped.DELETE("/transfer", middlewares.GroupCapabilityMiddleware(groups.CapManageRoles), handlers.CancelOwnershipTransferHandler)
+
+			groupScoped.POST("/invite-links", middlewares.GroupCapabilityMiddleware(groups.CapApproveRequests), handlers.CreateInviteLinkHandler)
+			groupScoped.GET("/invite-links", middlewares.GroupCapabilityMiddleware(groups.CapApproveRequests), handlers.GetInviteLinksHandler)
+			groupScoped.DELETE("/invite-links/:linkId", middlewares.GroupCapabilityMiddleware(groups.CapApproveRequests), handlers.RevokeInviteLinkHandler)
+
+			groupScoped.POST("/bans", middlewares.GroupCapabilityMiddleware(groups.CapRemoveMembers), handlers.BanUserHandler)
+			groupScoped.GET("/bans", middlewares.GroupCapabilityMiddleware(groups.CapRemoveMembers), handlers.GetGroupBansHandler)
+			groupScoped.DELETE("/bans/:userId", middlewares.GroupCapabilityMiddleware(groups.CapRemoveMembers), handlers.LiftBanHandler)
+			groupScoped.GET("/audit", middlewares.GroupCapabilityMiddleware(groups.CapViewAuditLog), handlers.GetGroupAuditLogHandler)
+
+			groupScoped.PUT("/questionnaire", middlewares.GroupCapabilityMiddleware(groups.CapEditGroup), handlers.SetGroupQuestionnaireHandler)
+
+			groupScoped.POST("/announcements", middlewares.GroupCapabilityMiddleware(groups.CapPostAnnouncements), handlers.CreateAnnouncementHandler)
+			groupScoped.PATCH("/announcements/:announcementId/pin", middlewares.GroupCapabilityMiddleware(groups.CapPostAnnouncements), handlers.PinAnnouncementHandler)
+			groupScoped.DELETE("/announcements/:announcementId", middlewares.GroupCapabilityMiddleware(groups.CapPostAnnouncements), handlers.DeleteAnnouncementHandler)
+
+			groupScoped.DELETE("/", middlewares.GroupCapabilityMiddleware(groups.CapEditGroup), handlers.DeleteGroups)
+			groupScoped.PATCH("/", middlewares.GroupCapabilityMiddleware(groups.CapEditGroup), handlers.UpdateGroupHandler)
 		}
 	}
 }
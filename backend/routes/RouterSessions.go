@@ -15,6 +15,7 @@ func RouterSessions(r *gin.Engine) {
 		GroupSession.POST("/join", middlewares.JWTAuthMiddleware(), handlers.JoinToSession)
 		GroupSession.DELETE("/sessions/:id", middlewares.JWTAuthMiddleware(), handlers.DeleteSession)
 		GroupSession.DELETE("/:sessionId/leave", middlewares.JWTAuthMiddleware(), handlers.LeaveSessionHandler)
+		GroupSession.POST("/:sessionId/invite", middlewares.JWTAuthMiddleware(), handlers.InviteFriendsToSessionHandler)
 	}
 	GroupSessionAdmin := r.Group("api/admin/sessions")
 	{
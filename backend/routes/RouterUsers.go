@@ -15,6 +15,7 @@ func RouterUsersInfo(r *gin.Engine) {
 		GetSessionGroup.GET("/search", middlewares.JWTAuthMiddleware(), handlers.SearchUsers)
 		// GetSessionGroup.GET("/sessions/new", handlers.GetNewSessions)
 		GetSessionGroup.GET("/sessions/popular", handlers.GetPopularSessions)
+		GetSessionGroup.GET("/sessions/recommended", middlewares.JWTAuthMiddleware(), handlers.GetRecommendedSessions)
 		// GetSessionGroup.GET("/sessions/category", handlers.GetCategorySessions)
 		GetSessionGroup.GET("/sessions/:sessionId", middlewares.JWTAuthMiddleware(), handlers.GetDetailedInfo)
 		GetSessionGroup.GET("/sessions/search", middlewares.JWTAuthMiddleware(), handlers.SearchSessions)
@@ -26,6 +27,7 @@ func RouterUsersInfo(r *gin.Engine) {
 	{
 		BotGroup.POST("/subscriptions", handlers.SubscriptionsTelegramNotify)
 		BotGroup.POST("/unsubscriptions", handlers.UnSubscriptionsTelegramNotify)
+		BotGroup.POST("/invites/redeem", handlers.RedeemInviteLinkBot)
 	}
 	InternalStatsGroup := r.Group("/internal")
 	InternalStatsGroup.Use()
@@ -37,14 +39,38 @@ func RouterUsersInfo(r *gin.Engine) {
 	{
 		UserInfGroup.GET("/inf", handlers.GetInfAboutUser)
 		UserInfGroup.GET("/inf/:id", handlers.GetInfAboutUserByID)
+		UserInfGroup.GET("/stats/periods", handlers.GetUserPeriodStats)
+		UserInfGroup.GET("/stats/recap/:year", handlers.GetYearRecap)
 		UserInfGroup.GET("/notify", handlers.GetNotify)
 		UserInfGroup.GET("/notify/inf", handlers.GetNotifyInf)
 		UserInfGroup.POST("/notifications/viewed", handlers.MarkNotificationViewed)
 		UserInfGroup.PUT("/invites/:id/approve", handlers.ApproveInvite)
 		UserInfGroup.PUT("/invites/:id/reject", handlers.RejectInvite)
+		UserInfGroup.PUT("/session-invites/:id/accept", handlers.AcceptSessionInviteHandler)
+		UserInfGroup.PUT("/session-invites/:id/decline", handlers.DeclineSessionInviteHandler)
+		UserInfGroup.GET("/friends", handlers.GetFriendsHandler)
+		UserInfGroup.GET("/friends/requests", handlers.GetFriendRequestsHandler)
+		UserInfGroup.POST("/friends/requests", handlers.SendFriendRequestHandler)
+		UserInfGroup.POST("/friends/requests/:id/accept", handlers.AcceptFriendRequestHandler)
+		UserInfGroup.POST("/friends/requests/:id/decline", handlers.DeclineFriendRequestHandler)
+		UserInfGroup.DELETE("/friends/:userId", handlers.RemoveFriendHandler)
+		UserInfGroup.POST("/follows/:userId", handlers.FollowUserHandler)
+		UserInfGroup.DELETE("/follows/:userId", handlers.UnfollowUserHandler)
+		UserInfGroup.GET("/followers", handlers.GetFollowersHandler)
+		UserInfGroup.GET("/following", handlers.GetFollowingHandler)
+		UserInfGroup.GET("/blocks", handlers.GetBlockedUsersHandler)
+		UserInfGroup.POST("/blocks/:userId", handlers.BlockUserHandler)
+		UserInfGroup.DELETE("/blocks/:userId", handlers.UnblockUserHandler)
 		UserInfGroup.PATCH("/user/profile", handlers.UpdateUserProfile)
 		UserInfGroup.PATCH("/password", handlers.ChangePassword)
+		UserInfGroup.POST("/email/change", handlers.RequestEmailChange)
+		UserInfGroup.POST("/email/confirm", handlers.ConfirmEmailChange)
 		UserInfGroup.PATCH("/tiles", handlers.ChangeTilesPattern)
+		UserInfGroup.GET("/privacy", handlers.GetPrivacySettingsHandler)
+		UserInfGroup.PATCH("/privacy", handlers.UpdatePrivacySettingsHandler)
+		UserInfGroup.GET("/leaderboards", handlers.GetLeaderboard)
+		UserInfGroup.GET("/achievements", handlers.GetAchievements)
+		UserInfGroup.PATCH("/achievements/tiles", handlers.PinAchievements)
 		UserInfGroup.DELETE("/delete", handlers.DeleteAccount)
 		UserInfGroup.GET("/:us", handlers.GettingUserId)
 	}
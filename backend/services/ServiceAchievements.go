@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"friendship/db"
+	"friendship/models"
+	"friendship/models/sessions"
+	statsusers "friendship/models/stats_users"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Достижения описываются декларативно: код, название и условие над снимком статистики.
+// Проверка идёт после коммита UpdateStatisticsForFinishedSession, выдача — один раз на пользователя.
+
+const (
+	maxPinnedAchievements       = 6
+	achievementNotificationType = "achievement"
+)
+
+type achievementSnapshot struct {
+	CountAll        uint16
+	CountFilms      uint16
+	CountGames      uint16
+	CountTableGames uint16
+	SpentTime       uint64
+
+	CountCreateSession uint16
+	SeriesSessionCount uint16
+	MostBigSession     uint16
+	WeeklyStreak       uint16
+}
+
+type achievementDefinition struct {
+	Code        string
+	Title       string
+	Description string
+	Check       func(s achievementSnapshot) bool
+}
+
+var achievementDefinitions = []achievementDefinition{
+	{"first_session", "Первая встреча", "Посетить первую сессию",
+		func(s achievementSnapshot) bool { return s.CountAll >= 1 }},
+	{"first_session_hosted", "Организатор", "Провести первую сессию",
+		func(s achievementSnapshot) bool { return s.CountCreateSession >= 1 }},
+	{"sessions_10", "Завсегдатай", "Посетить 10 сессий",
+		func(s achievementSnapshot) bool { return s.CountAll >= 10 }},
+	{"sessions_100", "Душа компании", "Посетить 100 сессий",
+		func(s achievementSnapshot) bool { return s.CountAll >= 100 }},
+	{"hosted_10", "Бывалый организатор", "Провести 10 сессий",
+		func(s achievementSnapshot) bool { return s.CountCreateSession >= 10 }},
+	{"films_50", "Киноман", "Посмотреть 50 фильмов",
+		func(s achievementSnapshot) bool { return s.CountFilms >= 50 }},
+	{"games_50", "Геймер", "Сыграть 50 игровых сессий",
+		func(s achievementSnapshot) bool { return s.CountGames >= 50 }},
+	{"table_games_25", "Настольщик", "Сыграть 25 партий в настольные игры",
+		func(s achievementSnapshot) bool { return s.CountTableGames >= 25 }},
+	{"streak_7_days", "Неделя без перерыва", "Ходить на сессии 7 дней подряд",
+		func(s achievementSnapshot) bool { return s.SeriesSessionCount >= 7 }},
+	{"streak_10_weeks", "10 недель подряд", "Посещать сессии 10 недель подряд",
+		func(s achievementSnapshot) bool { return s.WeeklyStreak >= 10 }},
+	{"big_host_20", "Большая компания", "Провести сессию на 20 и более участников",
+		func(s achievementSnapshot) bool { return s.MostBigSession >= 20 }},
+	{"hours_100", "Сто часов вместе", "Провести на сессиях 100 часов",
+		func(s achievementSnapshot) bool { return spentHours(s.SpentTime) >= 100 }},
+}
+
+type AchievementDTO struct {
+	Code        string     `json:"code"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Awarded     bool       `json:"awarded"`
+	AwardedAt   *time.Time `json:"awarded_at,omitempty"`
+	Pinned      bool       `json:"pinned"`
+}
+
+type PinAchievementsInput struct {
+	Codes []string `json:"codes" binding:"max=6"`
+}
+
+func findAchievementDefinition(code string) (achievementDefinition, bool) {
+	for _, d := range achievementDefinitions {
+		if d.Code == code {
+			return d, true
+		}
+	}
+	return achievementDefinition{}, false
+}
+
+// awardAchievements проверяет условия и выдаёт новые значки с уведомлением.
+// sessionID == 0 — выдача при пересчёте, без уведомления.
+func awardAchievements(ctx context.Context, userID, sessionID uint) ([]string, error) {
+	var awarded []string
+
+	err := db.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		snapshot, err := loadAchievementSnapshot(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		var pinned int64
+		if err := tx.Model(&statsusers.UserAchievement{}).
+			Where("user_id = ? AND pinned = ?", userID, true).
+			Count(&pinned).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, def := range achievementDefinitions {
+			if !def.Check(*snapshot) {
+				continue
+			}
+
+			rec := statsusers.UserAchievement{
+				UserID:    userID,
+				Code:      def.Code,
+				Pinned:    pinned < maxPinnedAchievements,
+				AwardedAt: now,
+			}
+			if sessionID != 0 {
+				sid := sessionID
+				rec.SessionID = &sid
+			}
+
+			res := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "code"}},
+				DoNothing: true,
+			}).Create(&rec)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				continue
+			}
+
+			if rec.Pinned {
+				pinned++
+			}
+			awarded = append(awarded, def.Code)
+
+			if sessionID != 0 {
+				if err := createAchievementNotification(tx, userID, sessionID, def); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выдачи достижений пользователю %d: %w", userID, err)
+	}
+
+	return awarded, nil
+}
+
+func loadAchievementSnapshot(tx *gorm.DB, userID uint) (*achievementSnapshot, error) {
+	var sessionStats statsusers.SessionStats_users
+	if err := tx.Where("user_id = ?", userID).Limit(1).Find(&sessionStats).Error; err != nil {
+		return nil, err
+	}
+
+	var sideStats statsusers.SideStats_users
+	if err := tx.Where("user_id = ?", userID).Limit(1).Find(&sideStats).Error; err != nil {
+		return nil, err
+	}
+
+	weekly, err := computeLongestWeeklyStreak(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &achievementSnapshot{
+		CountAll:           sessionStats.CountAll,
+		CountFilms:         sessionStats.CountFilms,
+		CountGames:         sessionStats.CountGames,
+		CountTableGames:    sessionStats.CountTableGames,
+		SpentTime:          sessionStats.SpentTime,
+		CountCreateSession: safeUint16Value(sideStats.CountCreateSession),
+		SeriesSessionCount: safeUint16Value(sideStats.SeriesSesionCount),
+		MostBigSession:     safeUint16Value(sideStats.MostBigSession),
+		WeeklyStreak:       weekly,
+	}, nil
+}
+
+// computeLongestWeeklyStreak — самая длинная серия календарных недель с посещением
+func computeLongestWeeklyStreak(tx *gorm.DB, userID uint) (uint16, error) {
+	type wrow struct{ W time.Time }
+	var weeks []wrow
+	if err := tx.Raw(`
+		select date_trunc('week', s.start_time) as w
+		from session_users su
+		join sessions s on s.id = su.session_id
+		join statuses st on st.id = s.status_id
+		where su.user_id = ?
+		  and st.status = 'Завершена'
+		group by 1
+		order by 1 asc
+	`, userID).Scan(&weeks).Error; err != nil {
+		return 0, err
+	}
+
+	var best, cur int
+	for i, w := range weeks {
+		if i > 0 && w.W.Sub(weeks[i-1].W).Hours() <= 7*24+1 {
+			cur++
+		} else {
+			cur = 1
+		}
+		if cur > best {
+			best = cur
+		}
+	}
+	return uint16(best), nil
+}
+
+func createAchievementNotification(tx *gorm.DB, userID, sessionID uint, def achievementDefinition) error {
+	var nt sessions.NotificationType
+	if err := tx.Where(sessions.NotificationType{Name: achievementNotificationType}).
+		Attrs(sessions.NotificationType{Description: "Новое достижение", HoursBefore: 0}).
+		FirstOrCreate(&nt).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&sessions.Notification{
+		UserID:             userID,
+		SessionID:          sessionID,
+		NotificationTypeID: nt.ID,
+		SendAt:             time.Now(),
+		Title:              "Новое достижение!",
+		Text:               fmt.Sprintf("Вы получили достижение «%s»: %s", def.Title, def.Description),
+	}).Error
+}
+
+// getUserAchievements собирает значки пользователя. onlyPinned — для отображения в профиле.
+func getUserAchievements(database *gorm.DB, userID uint, onlyPinned bool) ([]AchievementDTO, error) {
+	query := database.Where("user_id = ?", userID)
+	if onlyPinned {
+		query = query.Where("pinned = ?", true)
+	}
+
+	var records []statsusers.UserAchievement
+	if err := query.Order("awarded_at ASC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]AchievementDTO, 0, len(records))
+	for _, r := range records {
+		def, ok := findAchievementDefinition(r.Code)
+		if !ok {
+			continue
+		}
+		awardedAt := r.AwardedAt
+		result = append(result, AchievementDTO{
+			Code:        def.Code,
+			Title:       def.Title,
+			Description: def.Description,
+			Awarded:     true,
+			AwardedAt:   &awardedAt,
+			Pinned:      r.Pinned,
+		})
+	}
+	return result, nil
+}
+
+// GetAllAchievements возвращает все достижения с отметкой, какие из них уже получены
+func GetAllAchievements(principal models.Principal) ([]AchievementDTO, error) {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+
+	owned, err := getUserAchievements(database, user.ID, false)
+	if err != nil {
+		return nil, err
+	}
+	ownedByCode := make(map[string]AchievementDTO, len(owned))
+	for _, a := range owned {
+		ownedByCode[a.Code] = a
+	}
+
+	result := make([]AchievementDTO, 0, len(achievementDefinitions))
+	for _, def := range achievementDefinitions {
+		if a, ok := ownedByCode[def.Code]; ok {
+			result = append(result, a)
+			continue
+		}
+		result = append(result, AchievementDTO{
+			Code:        def.Code,
+			Title:       def.Title,
+			Description: def.Description,
+		})
+	}
+	return result, nil
+}
+
+// PinAchievements задаёт, какие из полученных значков показывать в профиле
+func PinAchievements(principal models.Principal, input PinAchievementsInput) error {
+	codes := make([]string, 0, len(input.Codes))
+	seen := make(map[string]struct{}, len(input.Codes))
+	for _, code := range input.Codes {
+		if _, ok := seen[code]; ok {
+			continue
+		}
+		seen[code] = struct{}{}
+		codes = append(codes, code)
+	}
+
+	if len(codes) > maxPinnedAchievements {
+		return fmt.Errorf("в профиле можно закрепить не больше %d достижений", maxPinnedAchievements)
+	}
+
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return errors.New("пользователь не найден")
+	}
+
+	if len(codes) > 0 {
+		var owned int64
+		if err := database.Model(&statsusers.UserAchievement{}).
+			Where("user_id = ? AND code IN ?", user.ID, codes).
+			Count(&owned).Error; err != nil {
+			return err
+		}
+		if int(owned) != len(codes) {
+			return errors.New("можно закрепить только полученные достижения")
+		}
+	}
+
+	return database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&statsusers.UserAchievement{}).
+			Where("user_id = ?", user.ID).
+			Update("pinned", false).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Model(&statsusers.UserAchievement{}).
+			Where("user_id = ? AND code IN ?", user.ID, codes).
+			Update("pinned", true).Error
+	})
+}
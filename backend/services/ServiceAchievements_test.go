@@ -0,0 +1,41 @@
+package services
+
+import "testing"
+
+func TestAchievementHours100(t *testing.T) {
+	def, ok := findAchievementDefinition("hours_100")
+	if !ok {
+		t.Fatal("достижение hours_100 не найдено")
+	}
+
+	// spent_time копится в минутах: 100 часов — это 6000 минут
+	tests := []struct {
+		spentTime uint64
+		want      bool
+	}{
+		{0, false},
+		{100, false},
+		{5999, false},
+		{6000, true},
+		{360000, true},
+	}
+
+	for _, tt := range tests {
+		if got := def.Check(achievementSnapshot{SpentTime: tt.spentTime}); got != tt.want {
+			t.Errorf("hours_100 при spent_time=%d = %v, want %v", tt.spentTime, got, tt.want)
+		}
+	}
+}
+
+func TestAchievementDefinitionsUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for _, def := range achievementDefinitions {
+		if seen[def.Code] {
+			t.Errorf("код достижения %q повторяется", def.Code)
+		}
+		seen[def.Code] = true
+		if def.Check == nil {
+			t.Errorf("у достижения %q нет условия", def.Code)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"friendship/models"
 	"friendship/models/groups"
 	"friendship/models/sessions"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -21,9 +22,9 @@ type AdminGroupResponse struct {
 	MemberCount      *int64    `json:"member_count"`
 }
 
-func GetAdminGroups(email *string) ([]AdminGroupResponse, error) {
+func GetAdminGroups(principal models.Principal) ([]AdminGroupResponse, error) {
 	db := db.GetDB()
-	user, err := FindUserByEmail(*email)
+	user, err := FindUserByID(principal.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("пользователь не найден: %v", err)
 	}
@@ -34,7 +35,7 @@ func GetAdminGroups(email *string) ([]AdminGroupResponse, error) {
 		Select("groups.id, groups.name, groups.image, groups.small_description, CASE WHEN groups.is_private THEN 'приватная группа' ELSE 'открытая группа' END as type, COUNT(DISTINCT gu2.user_id) as member_count").
 		Joins("JOIN group_users gu ON gu.group_id = groups.id").
 		Joins("LEFT JOIN group_users gu2 ON gu2.group_id = groups.id").
-		Where("gu.user_id = ? AND gu.role_in_group = ?", user.ID, "admin"). // или другая роль
+		Where("gu.user_id = ? AND gu.role_in_group IN ?", user.ID, groups.RolesWith(groups.CapApproveRequests)).
 		Group("groups.id, groups.name, groups.image, groups.small_description, groups.is_private").
 		Order("groups.id DESC").
 		Scan(&adminGroups).Error; err != nil {
@@ -78,11 +79,13 @@ type AdminGroupInfResponse struct {
 }
 
 type GroupJoinRequestRes struct {
-	ID     uint   `json:"id"`
-	UserID uint   `json:"userId"`
-	Name   string `json:"name"`
-	Us     string `json:"us"`
-	Image  string `json:"image"`
+	ID        uint                   `json:"id"`
+	UserID    uint                   `json:"userId"`
+	Name      string                 `json:"name"`
+	Us        string                 `json:"us"`
+	Image     string                 `json:"image"`
+	Answers   []JoinRequestAnswerRes `json:"answers"`
+	CreatedAt time.Time              `json:"createdAt"`
 }
 
 type GroupSubscriberRes struct {
@@ -92,13 +95,13 @@ type GroupSubscriberRes struct {
 	RoleInGroup string `json:"role"`
 }
 
-func GetAdminGroupInfo(email string, groupID *uint) (*AdminGroupInfResponse, error) {
+func GetAdminGroupInfo(principal models.Principal, groupID *uint) (*AdminGroupInfResponse, error) {
 	if groupID == nil {
 		return nil, errors.New("invalid group ID")
 	}
 
 	var user models.User
-	if err := db.GetDB().Where("email = ?", email).First(&user).Error; err != nil {
+	if err := db.GetDB().First(&user, principal.UserID).Error; err != nil {
 		return nil, fmt.Errorf("пользователь не найден: %v", err)
 	}
 
@@ -115,11 +118,11 @@ func GetAdminGroupInfo(email string, groupID *uint) (*AdminGroupInfResponse, err
 		return nil, fmt.Errorf("failed to get group: %w", err)
 	}
 
-	if group.CreaterID == 0 || user.ID == 0 {
+	if user.ID == 0 {
 		return nil, errors.New("access denied: invalid user or group data")
 	}
-	if group.CreaterID != user.ID {
-		return nil, fmt.Errorf("access denied: user %d is not the group creator %d", &user.ID, &group.CreaterID)
+	if _, err := requireGroupCapability(db.GetDB(), user.ID, group.ID, groups.CapApproveRequests); err != nil {
+		return nil, fmt.Errorf("access denied: %v", err)
 	}
 
 	type applicationResult struct {
@@ -129,7 +132,7 @@ func GetAdminGroupInfo(email string, groupID *uint) (*AdminGroupInfResponse, err
 	appChan := make(chan applicationResult, 1)
 
 	go func() {
-		applications, err := GetPendingJoinRequestsForAdmin(email, *groupID)
+		applications, err := GetPendingJoinRequestsForAdmin(principal, *groupID)
 		appChan <- applicationResult{applications: applications, err: err}
 	}()
 
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"friendship/db"
+	"friendship/models"
+	"friendship/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Сессии входа. Каждый вход создаёт семейство refresh токенов (sid), каждый refresh токен
+// одноразовый (jti). Повторное предъявление уже использованного токена считается кражей:
+// всё семейство отзывается, и устройству нужно войти заново.
+
+const (
+	refreshTokenKey      = "refresh_token:%s"      // jti -> session_id, user_id, used
+	authSessionKey       = db.AuthSessionKey       // sid -> устройство и текущий jti; по нему же проверяются access токены
+	userAuthSessionsKey  = "user_auth_sessions:%d" // user_id -> множество sid
+	maxDeviceFieldLength = 200
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("невалидный или просроченный refresh токен")
+	ErrRefreshTokenReused  = errors.New("refresh токен уже использован, сессия завершена")
+)
+
+// markRefreshUsed атомарно помечает jti использованным и возвращает число использований, -1 — токена нет
+var markRefreshUsed = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then return -1 end
+return redis.call('HINCRBY', KEYS[1], 'used', 1)
+`)
+
+type DeviceInfo struct {
+	Name      string
+	UserAgent string
+	IP        string
+}
+
+type AuthSessionDTO struct {
+	ID         string    `json:"id"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+func truncateDeviceField(s string) string {
+	if len(s) > maxDeviceFieldLength {
+		return s[:maxDeviceFieldLength]
+	}
+	return s
+}
+
+// issueRefreshToken выпускает пару токенов в семействе sid и сохраняет новый jti
+func issueRefreshToken(redisClient *redis.Client, user *models.User, sid string, device DeviceInfo) (utils.TokenPair, error) {
+	jti := utils.GenerateSessioID(32)
+
+	tokens, err := utils.GenerateTokenPair(int(user.ID), user.Email, user.Name, user.Us, user.Image, sid, jti)
+	if err != nil {
+		return utils.TokenPair{}, err
+	}
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	tokenKey := fmt.Sprintf(refreshTokenKey, jti)
+	sessionKey := fmt.Sprintf(authSessionKey, sid)
+
+	sessionFields := map[string]interface{}{
+		"user_id":      user.ID,
+		"current_jti":  jti,
+		"last_used_at": now,
+		"user_agent":   truncateDeviceField(device.UserAgent),
+		"ip":           device.IP,
+	}
+
+	pipe := redisClient.TxPipeline()
+	pipe.HSet(ctx, tokenKey, map[string]interface{}{"session_id": sid, "user_id": user.ID, "used": 0})
+	pipe.Expire(ctx, tokenKey, utils.RefreshTokenTTL)
+	pipe.HSetNX(ctx, sessionKey, "created_at", now)
+	pipe.HSetNX(ctx, sessionKey, "device_name", truncateDeviceField(device.Name))
+	pipe.HSet(ctx, sessionKey, sessionFields)
+	pipe.Expire(ctx, sessionKey, utils.RefreshTokenTTL)
+	pipe.SAdd(ctx, fmt.Sprintf(userAuthSessionsKey, user.ID), sid)
+	pipe.Expire(ctx, fmt.Sprintf(userAuthSessionsKey, user.ID), utils.RefreshTokenTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return utils.TokenPair{}, fmt.Errorf("не удалось сохранить сессию: %v", err)
+	}
+
+	return tokens, nil
+}
+
+// StartAuthSession создаёт новую сессию входа для устройства
+func StartAuthSession(user *models.User, device DeviceInfo) (utils.TokenPair, error) {
+	redisClient := db.GetRedis()
+	if redisClient == nil {
+		return utils.TokenPair{}, errors.New("хранилище сессий недоступно")
+	}
+	return issueRefreshToken(redisClient, user, utils.GenerateSessioID(32), device)
+}
+
+// RotateRefreshToken обменивает refresh токен на новую пару. Старый токен становится недействительным.
+func RotateRefreshToken(refreshToken string, device DeviceInfo) (utils.TokenPair, error) {
+	redisClient := db.GetRedis()
+	if redisClient == nil {
+		return utils.TokenPair{}, errors.New("хранилище сессий недоступно")
+	}
+
+	claims, err := utils.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return utils.TokenPair{}, ErrRefreshTokenInvalid
+	}
+
+	used, err := markRefreshUsed.Run(ctx, redisClient, []string{fmt.Sprintf(refreshTokenKey, claims.JTI)}).Int64()
+	if err != nil {
+		return utils.TokenPair{}, err
+	}
+	if used < 0 {
+		return utils.TokenPair{}, ErrRefreshTokenInvalid
+	}
+	if used > 1 {
+		log.Printf("Повторное использование refresh токена пользователя %d, сессия %s отозвана", claims.UserID, claims.SessionID)
+		if err := revokeAuthSession(redisClient, claims.UserID, claims.SessionID); err != nil {
+			return utils.TokenPair{}, err
+		}
+		return utils.TokenPair{}, ErrRefreshTokenReused
+	}
+
+	// Семейство могло быть отозвано выходом или сменой пароля
+	exists, err := redisClient.Exists(ctx, fmt.Sprintf(authSessionKey, claims.SessionID)).Result()
+	if err != nil {
+		return utils.TokenPair{}, err
+	}
+	if exists == 0 {
+		return utils.TokenPair{}, ErrRefreshTokenInvalid
+	}
+
+	var user models.User
+	if err := db.GetDB().First(&user, claims.UserID).Error; err != nil {
+		return utils.TokenPair{}, ErrRefreshTokenInvalid
+	}
+
+	return issueRefreshToken(redisClient, &user, claims.SessionID, device)
+}
+
+func revokeAuthSession(redisClient *redis.Client, userID uint, sid string) error {
+	pipe := redisClient.TxPipeline()
+	pipe.Del(ctx, fmt.Sprintf(authSessionKey, sid))
+	pipe.SRem(ctx, fmt.Sprintf(userAuthSessionsKey, userID), sid)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Logout завершает сессию, которой принадлежит refresh токен
+func Logout(refreshToken string) error {
+	redisClient := db.GetRedis()
+	if redisClient == nil {
+		return errors.New("хранилище сессий недоступно")
+	}
+
+	claims, err := utils.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return ErrRefreshTokenInvalid
+	}
+	return revokeAuthSession(redisClient, claims.UserID, claims.SessionID)
+}
+
+// RevokeAllAuthSessions завершает все сессии пользователя
+func RevokeAllAuthSessions(userID uint) error {
+	return RevokeOtherAuthSessions(userID, "")
+}
+
+// RevokeOtherAuthSessions завершает все сессии пользователя, кроме keepSID
+func RevokeOtherAuthSessions(userID uint, keepSID string) error {
+	redisClient := db.GetRedis()
+	if redisClient == nil {
+		return errors.New("хранилище сессий недоступно")
+	}
+
+	setKey := fmt.Sprintf(userAuthSessionsKey, userID)
+	sids, err := redisClient.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return err
+	}
+
+	pipe := redisClient.TxPipeline()
+	for _, sid := range sids {
+		if sid == keepSID {
+			continue
+		}
+		pipe.Del(ctx, fmt.Sprintf(authSessionKey, sid))
+		pipe.SRem(ctx, setKey, sid)
+	}
+	if keepSID == "" {
+		pipe.Del(ctx, setKey)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// LogoutAll завершает сессии пользователя на всех устройствах
+func LogoutAll(principal models.Principal) error {
+	return RevokeAllAuthSessions(principal.UserID)
+}
+
+func parseUnixField(value string) time.Time {
+	sec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// GetAuthSessions возвращает активные сессии пользователя, последние использованные первыми
+func GetAuthSessions(principal models.Principal) ([]AuthSessionDTO, error) {
+	redisClient := db.GetRedis()
+	if redisClient == nil {
+		return nil, errors.New("хранилище сессий недоступно")
+	}
+
+	setKey := fmt.Sprintf(userAuthSessionsKey, principal.UserID)
+	sids, err := redisClient.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]AuthSessionDTO, 0, len(sids))
+	for _, sid := range sids {
+		fields, err := redisClient.HGetAll(ctx, fmt.Sprintf(authSessionKey, sid)).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			// Сессия истекла, убираем её из списка пользователя
+			redisClient.SRem(ctx, setKey, sid)
+			continue
+		}
+		result = append(result, AuthSessionDTO{
+			ID:         sid,
+			DeviceName: fields["device_name"],
+			UserAgent:  fields["user_agent"],
+			IP:         fields["ip"],
+			CreatedAt:  parseUnixField(fields["created_at"]),
+			LastUsedAt: parseUnixField(fields["last_used_at"]),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].LastUsedAt.After(result[j].LastUsedAt) })
+	return result, nil
+}
+
+// RevokeUserAuthSession завершает одну из сессий пользователя
+func RevokeUserAuthSession(principal models.Principal, sid string) error {
+	redisClient := db.GetRedis()
+	if redisClient == nil {
+		return errors.New("хранилище сессий недоступно")
+	}
+
+	member, err := redisClient.SIsMember(ctx, fmt.Sprintf(userAuthSessionsKey, principal.UserID), sid).Result()
+	if err != nil {
+		return err
+	}
+	if !member {
+		return errors.New("сессия не найдена")
+	}
+	return revokeAuthSession(redisClient, principal.UserID, sid)
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"friendship/db"
+	"friendship/models"
+	"friendship/utils"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+// testRedis подключает сервисы к Redis из REDIS_TEST_ADDR. Без переменной тест пропускается.
+func testRedis(t *testing.T) {
+	t.Helper()
+
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_TEST_ADDR не задан, тест с Redis пропущен")
+	}
+
+	store := db.NewSessionStore(addr)
+	if err := store.GetRedisClient().Ping(ctx).Err(); err != nil {
+		t.Fatalf("Redis %s недоступен: %v", addr, err)
+	}
+
+	previous := db.GlobalSessionStore
+	db.SetGlobalSessionStore(store)
+	t.Cleanup(func() {
+		db.SetGlobalSessionStore(previous)
+		store.GetRedisClient().Close()
+	})
+}
+
+func testJWTKeys(t *testing.T) {
+	t.Helper()
+
+	if utils.Log == nil {
+		utils.Log = &utils.Logger{Logger: log.New(io.Discard, "", 0)}
+	}
+	t.Setenv("JWT_KEYS_DIR", "")
+	if err := utils.InitJWTKeys(); err != nil {
+		t.Fatalf("InitJWTKeys: %v", err)
+	}
+}
+
+// testAuthUser — пользователь с уникальным ID, ключи которого удаляются после теста
+func testAuthUser(t *testing.T, id uint) *models.User {
+	t.Helper()
+
+	user := &models.User{ID: id, Email: fmt.Sprintf("user%d@example.com", id), Name: "Тест", Us: "test"}
+	t.Cleanup(func() {
+		redisClient := db.GetRedis()
+		sids, _ := redisClient.SMembers(ctx, fmt.Sprintf(userAuthSessionsKey, id)).Result()
+		for _, sid := range sids {
+			redisClient.Del(ctx, fmt.Sprintf(authSessionKey, sid))
+		}
+		redisClient.Del(ctx, fmt.Sprintf(userAuthSessionsKey, id))
+	})
+	return user
+}
+
+func TestRotateRefreshTokenDetectsReuse(t *testing.T) {
+	testRedis(t)
+	testJWTKeys(t)
+	user := testAuthUser(t, 900001)
+
+	tokens, err := StartAuthSession(user, DeviceInfo{Name: "test", IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("StartAuthSession: %v", err)
+	}
+	claims, err := utils.ParseRefreshToken(tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("ParseRefreshToken: %v", err)
+	}
+	redisClient := db.GetRedis()
+	tokenKey := fmt.Sprintf(refreshTokenKey, claims.JTI)
+	t.Cleanup(func() { redisClient.Del(ctx, tokenKey) })
+
+	// Кэш principal заполнен заранее, чтобы проверка сессии не ходила в Postgres
+	principalKey := fmt.Sprintf("principal:%d", user.ID)
+	data, _ := json.Marshal(models.NewPrincipal(*user))
+	redisClient.Set(ctx, principalKey, data, time.Minute)
+	t.Cleanup(func() { redisClient.Del(ctx, principalKey) })
+
+	principal, err := db.LoadSessionPrincipal(user.ID, claims.SessionID)
+	if err != nil {
+		t.Fatalf("LoadSessionPrincipal() для активной сессии: %v", err)
+	}
+	if principal.UserID != user.ID {
+		t.Fatalf("LoadSessionPrincipal() вернул пользователя %d, want %d", principal.UserID, user.ID)
+	}
+
+	// Первое использование — обычное обновление токена
+	used, err := markRefreshUsed.Run(ctx, redisClient, []string{tokenKey}).Int64()
+	if err != nil || used != 1 {
+		t.Fatalf("первое использование: used=%d, err=%v", used, err)
+	}
+
+	// Повторное предъявление того же токена отзывает всё семейство
+	if _, err := RotateRefreshToken(tokens.RefreshToken, DeviceInfo{}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("RotateRefreshToken() error = %v, want ErrRefreshTokenReused", err)
+	}
+
+	if n := redisClient.Exists(ctx, fmt.Sprintf(authSessionKey, claims.SessionID)).Val(); n != 0 {
+		t.Error("сессия входа не удалена после повторного использования токена")
+	}
+	if redisClient.SIsMember(ctx, fmt.Sprintf(userAuthSessionsKey, user.ID), claims.SessionID).Val() {
+		t.Error("сессия осталась в списке сессий пользователя")
+	}
+
+	// Access токены той же сессии больше не принимаются
+	if _, err := db.LoadSessionPrincipal(user.ID, claims.SessionID); !errors.Is(err, db.ErrAuthSessionRevoked) {
+		t.Errorf("LoadSessionPrincipal() error = %v, want ErrAuthSessionRevoked", err)
+	}
+
+	// Третья попытка тоже отклоняется
+	if _, err := RotateRefreshToken(tokens.RefreshToken, DeviceInfo{}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("повторная попытка: error = %v, want ErrRefreshTokenReused", err)
+	}
+}
+
+func TestRotateRefreshTokenRejectsUnknown(t *testing.T) {
+	testRedis(t)
+	testJWTKeys(t)
+
+	if _, err := RotateRefreshToken("not-a-token", DeviceInfo{}); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("мусорный токен: error = %v, want ErrRefreshTokenInvalid", err)
+	}
+
+	// Подписанный, но не сохранённый в Redis токен
+	tokens, err := utils.GenerateTokenPair(900002, "user900002@example.com", "Тест", "test", "", "missing-sid", "missing-jti")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	if _, err := RotateRefreshToken(tokens.RefreshToken, DeviceInfo{}); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("неизвестный jti: error = %v, want ErrRefreshTokenInvalid", err)
+	}
+
+	// Access токен не подходит вместо refresh
+	if _, err := RotateRefreshToken(tokens.AccessToken, DeviceInfo{}); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("access токен: error = %v, want ErrRefreshTokenInvalid", err)
+	}
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	testRedis(t)
+	testJWTKeys(t)
+	user := testAuthUser(t, 900003)
+
+	tokens, err := StartAuthSession(user, DeviceInfo{})
+	if err != nil {
+		t.Fatalf("StartAuthSession: %v", err)
+	}
+	claims, err := utils.ParseRefreshToken(tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("ParseRefreshToken: %v", err)
+	}
+	t.Cleanup(func() { db.GetRedis().Del(ctx, fmt.Sprintf(refreshTokenKey, claims.JTI)) })
+
+	if err := Logout(tokens.RefreshToken); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if _, err := db.LoadSessionPrincipal(user.ID, claims.SessionID); !errors.Is(err, db.ErrAuthSessionRevoked) {
+		t.Errorf("LoadSessionPrincipal() после выхода error = %v, want ErrAuthSessionRevoked", err)
+	}
+	if _, err := RotateRefreshToken(tokens.RefreshToken, DeviceInfo{}); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("RotateRefreshToken() после выхода error = %v, want ErrRefreshTokenInvalid", err)
+	}
+}
+
+func TestRevokeOtherAuthSessionsKeepsCurrent(t *testing.T) {
+	testRedis(t)
+	testJWTKeys(t)
+	user := testAuthUser(t, 900004)
+	redisClient := db.GetRedis()
+
+	sids := make([]string, 3)
+	for i := range sids {
+		tokens, err := StartAuthSession(user, DeviceInfo{Name: fmt.Sprintf("device %d", i)})
+		if err != nil {
+			t.Fatalf("StartAuthSession: %v", err)
+		}
+		claims, err := utils.ParseRefreshToken(tokens.RefreshToken)
+		if err != nil {
+			t.Fatalf("ParseRefreshToken: %v", err)
+		}
+		t.Cleanup(func() { redisClient.Del(ctx, fmt.Sprintf(refreshTokenKey, claims.JTI)) })
+		sids[i] = claims.SessionID
+	}
+
+	// Так ChangePassword завершает сессии на других устройствах
+	current := sids[0]
+	if err := RevokeOtherAuthSessions(user.ID, current); err != nil {
+		t.Fatalf("RevokeOtherAuthSessions: %v", err)
+	}
+
+	setKey := fmt.Sprintf(userAuthSessionsKey, user.ID)
+	if redisClient.Exists(ctx, fmt.Sprintf(authSessionKey, current)).Val() == 0 {
+		t.Error("текущая сессия завершена")
+	}
+	if !redisClient.SIsMember(ctx, setKey, current).Val() {
+		t.Error("текущая сессия пропала из списка сессий пользователя")
+	}
+	for _, sid := range sids[1:] {
+		if _, err := db.LoadSessionPrincipal(user.ID, sid); !errors.Is(err, db.ErrAuthSessionRevoked) {
+			t.Errorf("сессия %s: error = %v, want ErrAuthSessionRevoked", sid, err)
+		}
+		if redisClient.SIsMember(ctx, setKey, sid).Val() {
+			t.Errorf("сессия %s осталась в списке сессий пользователя", sid)
+		}
+	}
+
+	// Без keepSID завершаются все сессии
+	if err := RevokeAllAuthSessions(user.ID); err != nil {
+		t.Fatalf("RevokeAllAuthSessions: %v", err)
+	}
+	if _, err := db.LoadSessionPrincipal(user.ID, current); !errors.Is(err, db.ErrAuthSessionRevoked) {
+		t.Errorf("после RevokeAllAuthSessions error = %v, want ErrAuthSessionRevoked", err)
+	}
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"friendship/db"
+	"friendship/models"
+	"friendship/utils"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Смена email: пользователь подтверждает запрос паролем (и кодом 2FA, если она включена),
+// на новый адрес уходит код через SessionStore. После ввода кода email меняется, старый
+// адрес получает уведомление, все сессии входа завершаются и выдаются новые токены.
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+	// Код из приложения или код восстановления, обязателен при включённой 2FA
+	TwoFactorCode string `json:"two_factor_code" binding:"max=20"`
+}
+
+type ConfirmEmailChangeInput struct {
+	SessionID string `json:"session_id" binding:"required"`
+	Code      string `json:"code" binding:"required,len=6"`
+}
+
+var ErrEmailTaken = errors.New("пользователь с таким email уже существует")
+
+// RequestEmailChange проверяет пароль и отправляет код подтверждения на новый адрес
+func RequestEmailChange(principal models.Principal, input ChangeEmailRequest) (*models.SessionRegResponse, error) {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+
+	if strings.EqualFold(user.Email, input.NewEmail) {
+		return nil, errors.New("новый email совпадает с текущим")
+	}
+
+	var taken int64
+	if err := database.Model(&models.User{}).Where("email = ?", input.NewEmail).Count(&taken).Error; err != nil {
+		return nil, err
+	}
+	if taken > 0 {
+		return nil, ErrEmailTaken
+	}
+
+	if err := checkAccountAttempts(user.ID); err != nil {
+		return nil, err
+	}
+	if ok, _ := utils.VerifyPassword(input.Password, user.Password, user.Salt); !ok {
+		registerAccountFailure(&user)
+		return nil, errors.New("неверный пароль")
+	}
+
+	twoFactor, err := TwoFactorEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor {
+		if err := verifySecondFactor(database, user.ID, input.TwoFactorCode); err != nil {
+			if errors.Is(err, ErrTwoFactorInvalidCode) {
+				registerAccountFailure(&user)
+			}
+			return nil, err
+		}
+	}
+
+	sessionID := utils.GenerateSessioID(12)
+	code := utils.GenerationSessionCode(6)
+
+	store := db.NewSessionStore(os.Getenv("REDIS_URI"))
+	err = store.CreateSession(sessionID, code, models.SessionTypeChangeEmail, 10*time.Minute, map[string]string{
+		"user_id":   strconv.FormatUint(uint64(user.ID), 10),
+		"new_email": input.NewEmail,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go sendEmailChangeCode(input.NewEmail, code)
+
+	return &models.SessionRegResponse{SessionID: sessionID}, nil
+}
+
+// ConfirmEmailChange проверяет код с нового адреса, меняет email и выдаёт новые токены
+func ConfirmEmailChange(principal models.Principal, input ConfirmEmailChangeInput, device DeviceInfo) (utils.TokenPair, error) {
+	if err := checkVerifyAttempts(device.IP); err != nil {
+		return utils.TokenPair{}, err
+	}
+
+	store := db.NewSessionStore(os.Getenv("REDIS_URI"))
+
+	// Сессия должна принадлежать текущему пользователю, иначе чужой код не проверяем вовсе
+	fields, err := store.GetSessionFields(input.SessionID, "user_id", "new_email")
+	if err != nil {
+		return utils.TokenPair{}, err
+	}
+	if len(fields) == 0 || fields["user_id"] != strconv.FormatUint(uint64(principal.UserID), 10) {
+		return utils.TokenPair{}, fmt.Errorf("сессия не найдена или удалена")
+	}
+
+	result, err := store.VerifyCode(input.SessionID, models.SessionTypeChangeEmail, input.Code, maxVerifyCodeAttempts)
+	if err != nil {
+		return utils.TokenPair{}, err
+	}
+	switch result {
+	case db.CodeVerified:
+	case db.CodeNoSession, db.CodeWrongType:
+		return utils.TokenPair{}, fmt.Errorf("сессия не найдена или удалена")
+	case db.CodeAttemptsSpent:
+		registerVerifyFailure(device.IP)
+		return utils.TokenPair{}, ErrVerifyAttemptsSpent
+	default:
+		registerVerifyFailure(device.IP)
+		return utils.TokenPair{}, errors.New("неверный код")
+	}
+
+	var user models.User
+	if err := db.GetDB().First(&user, principal.UserID).Error; err != nil {
+		return utils.TokenPair{}, errors.New("пользователь не найден")
+	}
+
+	oldEmail := user.Email
+	newEmail := fields["new_email"]
+
+	if err := db.GetDB().Model(&user).Update("email", newEmail).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return utils.TokenPair{}, ErrEmailTaken
+		}
+		return utils.TokenPair{}, err
+	}
+
+	if err := store.DeleteSession(input.SessionID); err != nil {
+		log.Printf("Не удалось удалить сессию смены email %s: %v", input.SessionID, err)
+	}
+
+	// Email входит в токены, поэтому старые входы завершаем и выдаём токены заново
+	db.InvalidatePrincipal(user.ID)
+	if err := RevokeAllAuthSessions(user.ID); err != nil {
+		log.Printf("Не удалось завершить сессии пользователя %d после смены email: %v", user.ID, err)
+	}
+
+	go sendEmailChangedNotice(oldEmail, newEmail)
+
+	return StartAuthSession(&user, device)
+}
+
+func sendEmailChangeCode(email, code string) {
+	subject := "Подтверждение нового email"
+
+	body := fmt.Sprintf(`
+	<!DOCTYPE html>
+	<html lang="ru">
+	<head>
+		<meta charset="UTF-8">
+		<style>
+			body {
+				font-family: Arial, sans-serif;
+				background-color: #f4f6f9;
+				margin: 0;
+				padding: 0;
+			}
+			.container {
+				max-width: 480px;
+				margin: 30px auto;
+				background: #fff;
+				border-radius: 12px;
+				padding: 24px;
+				box-shadow: 0 4px 12px rgba(0,0,0,0.1);
+			}
+			h2 {
+				color: #333;
+				text-align: center;
+			}
+			p {
+				font-size: 15px;
+				color: #555;
+				line-height: 1.6;
+			}
+			.code {
+				display: block;
+				text-align: center;
+				font-size: 24px;
+				font-weight: bold;
+				margin: 20px 0;
+				padding: 12px;
+				background: #f0f4ff;
+				border: 1px dashed #4a6cf7;
+				border-radius: 8px;
+				color: #4a6cf7;
+				cursor: pointer;
+				user-select: all;
+			}
+			.footer {
+				font-size: 12px;
+				text-align: center;
+				color: #aaa;
+				margin-top: 16px;
+			}
+		</style>
+	</head>
+	<body>
+		<div class="container">
+			<h2>Смена email</h2>
+			<p>Здравствуйте! 👋</p>
+			<p>Этот адрес указан как новый email аккаунта FriendShip. Введите код, чтобы подтвердить смену:</p>
+			<div class="code">%s</div>
+			<p>Код действителен <b>10 минут</b>. Если вы не меняли email, просто игнорируйте это письмо.</p>
+			<div class="footer">© %d FriendShip</div>
+		</div>
+	</body>
+	</html>
+	`, code, time.Now().Year())
+
+	if err := utils.SendEmail(email, subject, body); err != nil {
+		log.Printf("Ошибка отправки email: %v", err)
+	}
+}
+
+func sendEmailChangedNotice(oldEmail, newEmail string) {
+	subject := "Email аккаунта изменён"
+
+	body := fmt.Sprintf(`
+	<!DOCTYPE html>
+	<html lang="ru">
+	<head>
+		<meta charset="UTF-8">
+		<style>
+			body {
+				font-family: Arial, sans-serif;
+				background-color: #f4f6f9;
+				margin: 0;
+				padding: 0;
+			}
+			.container {
+				max-width: 480px;
+				margin: 30px auto;
+				background: #fff;
+				border-radius: 12px;
+				padding: 24px;
+				box-shadow: 0 4px 12px rgba(0,0,0,0.1);
+			}
+			h2 {
+				color: #333;
+				text-align: center;
+			}
+			p {
+				font-size: 15px;
+				color: #555;
+				line-height: 1.6;
+			}
+			.footer {
+				font-size: 12px;
+				text-align: center;
+				color: #aaa;
+				margin-top: 16px;
+			}
+		</style>
+	</head>
+	<body>
+		<div class="container">
+			<h2>Email изменён</h2>
+			<p>Здравствуйте! 👋</p>
+			<p>Email вашего аккаунта FriendShip изменён на <b>%s</b>. Все устройства вышли из аккаунта.</p>
+			<p>Если это были не вы, срочно свяжитесь с поддержкой.</p>
+			<div class="footer">© %d FriendShip</div>
+		</div>
+	</body>
+	</html>
+	`, maskEmail(newEmail), time.Now().Year())
+
+	if err := utils.SendEmail(oldEmail, subject, body); err != nil {
+		log.Printf("Ошибка отправки email: %v", err)
+	}
+}
+
+// maskEmail скрывает часть адреса: ivan@example.com -> i***@example.com
+func maskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return email
+	}
+	return email[:1] + "***" + email[at:]
+}
@@ -5,22 +5,25 @@ import (
 	"friendship/db"
 	"friendship/models"
 	statsusers "friendship/models/stats_users"
+	"log"
 )
 
 type ChangeTilesPatternInput struct {
-	Count_films bool `json:"count_films"`
-	Count_games bool `json:"count_games"`
-	Count_table bool `json:"count_table"`
-	Count_other bool `json:"count_other"`
-	Count_all   bool `json:"count_all"`
-	Spent_time  bool `json:"spent_time"`
+	Count_films bool  `json:"count_films"`
+	Count_games bool  `json:"count_games"`
+	Count_table bool  `json:"count_table"`
+	Count_other bool  `json:"count_other"`
+	Count_all   bool  `json:"count_all"`
+	Spent_time  bool  `json:"spent_time"`
+	Companions  *bool `json:"companions,omitempty"` // не передан — настройка не меняется
+	Leaderboard *bool `json:"leaderboard,omitempty"`
 }
 
-func ChangePattern(email string, input ChangeTilesPatternInput) error {
+func ChangePattern(principal models.Principal, input ChangeTilesPatternInput) error {
 	db := db.GetDB()
 
 	var user models.User
-	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := db.First(&user, principal.UserID).Error; err != nil {
 		return errors.New("пользователь не найден")
 	}
 
@@ -36,16 +39,47 @@ func ChangePattern(email string, input ChangeTilesPatternInput) error {
 			Count_other: input.Count_other,
 			Count_all:   input.Count_all,
 			Spent_time:  input.Spent_time,
+			Companions:  input.Companions == nil || *input.Companions,
+			Leaderboard: input.Leaderboard == nil || *input.Leaderboard,
 		}
-		return db.Create(&settings).Error
+		if err := db.Create(&settings).Error; err != nil {
+			return err
+		}
+		if !settings.Leaderboard {
+			syncLeaderboardVisibility(user.ID, false)
+		}
+		return nil
 	}
 
-	return db.Model(&settings).Updates(map[string]interface{}{
+	updates := map[string]interface{}{
 		"count_films": input.Count_films,
 		"count_games": input.Count_games,
 		"count_table": input.Count_table,
 		"count_other": input.Count_other,
 		"count_all":   input.Count_all,
 		"spent_time":  input.Spent_time,
-	}).Error
+	}
+	if input.Companions != nil {
+		updates["companions"] = *input.Companions
+	}
+	if input.Leaderboard != nil {
+		updates["leaderboard"] = *input.Leaderboard
+	}
+
+	wasVisible := settings.Leaderboard
+	if err := db.Model(&settings).Updates(updates).Error; err != nil {
+		return err
+	}
+	if input.Leaderboard != nil && *input.Leaderboard != wasVisible {
+		syncLeaderboardVisibility(user.ID, *input.Leaderboard)
+	}
+	return nil
+}
+
+// syncLeaderboardVisibility применяет настройку к рейтингам. Настройка уже сохранена,
+// поэтому ошибка только логируется — рейтинги можно пересобрать командой rebuild-leaderboards.
+func syncLeaderboardVisibility(userID uint, visible bool) {
+	if err := setLeaderboardVisibility(db.GetDB(), userID, visible); err != nil {
+		log.Printf("Не удалось обновить видимость пользователя %d в рейтингах: %v", userID, err)
+	}
 }
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"friendship/db"
+	"friendship/models"
+	"friendship/models/groups"
+	statsusers "friendship/models/stats_users"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Граф совместных посещений строится по участникам завершённых сессий: session_users и организатор.
+// Пары копятся в co_attendance_users при подсчёте статистики, поэтому профиль
+// читает готовые числа, а «возможно, вы знакомы» дополнительно кэшируется в Redis.
+
+const (
+	topCompanionsLimit    = 5
+	peopleYouMayKnowLimit = 10
+
+	peopleYouMayKnowCacheKey = "pymk:%d"
+	peopleYouMayKnowCacheTTL = 6 * time.Hour
+)
+
+type SuggestedUser struct {
+	ID               uint   `json:"id"`
+	Name             string `json:"name"`
+	Us               string `json:"us"`
+	Image            string `json:"image"`
+	MutualCompanions uint16 `json:"mutual_companions"`
+	SharedGroups     uint16 `json:"shared_groups"`
+}
+
+type GroupCohesion struct {
+	GroupID          uint    `json:"group_id"`
+	MembersCount     int     `json:"members_count"`
+	ActiveMembers    int     `json:"active_members"` // были хотя бы на одной завершённой сессии группы
+	RepeatMembers    int     `json:"repeat_members"` // были на двух и более
+	FinishedSessions int     `json:"finished_sessions"`
+	AvgParticipants  float64 `json:"avg_participants"`
+	ConnectedPairs   int     `json:"connected_pairs"` // пары участников, хоть раз бывшие вместе
+	TotalPairs       int     `json:"total_pairs"`
+	Cohesion         float64 `json:"cohesion"` // connected_pairs / total_pairs
+}
+
+// incrementCoAttendance добавляет по единице каждой паре участников сессии (в обе стороны)
+func incrementCoAttendance(tx *gorm.DB, userIDs []uint, seenAt time.Time) error {
+	if len(userIDs) < 2 {
+		return nil
+	}
+
+	rows := make([]statsusers.CoAttendance_users, 0, len(userIDs)*(len(userIDs)-1))
+	for _, a := range userIDs {
+		for _, b := range userIDs {
+			if a == b {
+				continue
+			}
+			rows = append(rows, statsusers.CoAttendance_users{
+				UserID:      a,
+				CompanionID: b,
+				Count:       1,
+				LastSeenAt:  seenAt,
+			})
+		}
+	}
+
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "companion_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"count":        gorm.Expr("co_attendance_users.count + 1"),
+			"last_seen_at": gorm.Expr("GREATEST(co_attendance_users.last_seen_at, EXCLUDED.last_seen_at)"),
+		}),
+	}).Create(&rows).Error
+}
+
+// RebuildCoAttendance пересобирает граф совместных посещений с нуля
+func RebuildCoAttendance() (int64, error) {
+	var inserted int64
+	err := db.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM co_attendance_users").Error; err != nil {
+			return err
+		}
+
+		// Организатор считается участником, даже если его нет в session_users
+		res := tx.Exec(`
+			WITH participants AS (
+				SELECT su.session_id, su.user_id
+				FROM session_users su
+				UNION
+				SELECT s.id, s.user_id
+				FROM sessions s
+			)
+			INSERT INTO co_attendance_users (user_id, companion_id, count, last_seen_at)
+			SELECT a.user_id, b.user_id, COUNT(DISTINCT s.id), MAX(s.end_time)
+			FROM participants a
+			JOIN participants b ON b.session_id = a.session_id AND b.user_id <> a.user_id
+			JOIN sessions s ON s.id = a.session_id
+			JOIN statuses st ON st.id = s.status_id
+			WHERE st.status = 'Завершена'
+			GROUP BY a.user_id, b.user_id`)
+		if res.Error != nil {
+			return res.Error
+		}
+		inserted = res.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("ошибка пересборки графа посещений: %v", err)
+	}
+	return inserted, nil
+}
+
+// getTopCompanions — самые частые компаньоны userID так, как их видит viewerID.
+// Не попадают скрывшие себя из компаньонов или из списков участников и связанные с viewerID блокировкой.
+func getTopCompanions(database *gorm.DB, userID, viewerID uint) ([]CompanionStats, error) {
+	var rows []CompanionStats
+	err := database.Table("co_attendance_users ca").
+		Select("u.id, u.name, u.us, u.image, ca.count").
+		Joins("JOIN users u ON u.id = ca.companion_id").
+		Joins("LEFT JOIN setting_tiles t ON t.user_id = ca.companion_id").
+		Where("ca.user_id = ? AND COALESCE(t.companions, true)", userID).
+		Where("ca.companion_id NOT IN (?)", hiddenParticipantsSubquery(database)).
+		Where("ca.companion_id NOT IN (?)", blockedWithSubquery(database, viewerID)).
+		Order("ca.count DESC, ca.last_seen_at DESC").
+		Limit(topCompanionsLimit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// getPeopleYouMayKnow отдаёт рекомендации из кэша или считает их заново
+func getPeopleYouMayKnow(database *gorm.DB, userID uint) ([]SuggestedUser, error) {
+	redisClient := db.GetRedis()
+	key := fmt.Sprintf(peopleYouMayKnowCacheKey, userID)
+
+	if redisClient != nil {
+		if cached, err := redisClient.Get(ctx, key).Result(); err == nil {
+			var result []SuggestedUser
+			if err := json.Unmarshal([]byte(cached), &result); err == nil {
+				return result, nil
+			}
+		}
+	}
+
+	result, err := computePeopleYouMayKnow(database, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if redisClient != nil {
+		if data, err := json.Marshal(result); err == nil {
+			if err := redisClient.Set(ctx, key, data, peopleYouMayKnowCacheTTL).Err(); err != nil {
+				log.Printf("Не удалось сохранить рекомендации знакомств в Redis: %v", err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// invalidatePeopleYouMayKnow сбрасывает кэш рекомендаций знакомств, например после блокировки
+func invalidatePeopleYouMayKnow(userIDs ...uint) {
+	redisClient := db.GetRedis()
+	if redisClient == nil {
+		return
+	}
+	for _, userID := range userIDs {
+		if err := redisClient.Del(ctx, fmt.Sprintf(peopleYouMayKnowCacheKey, userID)).Err(); err != nil {
+			log.Printf("Не удалось сбросить кэш рекомендаций знакомств пользователя %d: %v", userID, err)
+		}
+	}
+}
+
+// computePeopleYouMayKnow — кандидаты из общих групп и компаньоны компаньонов,
+// с которыми пользователь ещё ни разу не был на сессии
+func computePeopleYouMayKnow(database *gorm.DB, userID uint) ([]SuggestedUser, error) {
+	var mutual []struct {
+		UserID uint
+		Count  uint16
+	}
+	if err := database.Raw(`
+		SELECT c2.companion_id AS user_id, COUNT(*) AS count
+		FROM co_attendance_users c1
+		JOIN co_attendance_users c2 ON c2.user_id = c1.companion_id
+		WHERE c1.user_id = ? AND c2.companion_id <> ?
+		GROUP BY c2.companion_id
+	`, userID, userID).Scan(&mutual).Error; err != nil {
+		return nil, err
+	}
+
+	var shared []struct {
+		UserID uint
+		Count  uint16
+	}
+	if err := database.Raw(`
+		SELECT gu2.user_id AS user_id, COUNT(*) AS count
+		FROM group_users gu1
+		JOIN group_users gu2 ON gu2.group_id = gu1.group_id
+		WHERE gu1.user_id = ? AND gu2.user_id <> ?
+		GROUP BY gu2.user_id
+	`, userID, userID).Scan(&shared).Error; err != nil {
+		return nil, err
+	}
+
+	var known []uint
+	if err := database.Model(&statsusers.CoAttendance_users{}).
+		Where("user_id = ?", userID).
+		Pluck("companion_id", &known).Error; err != nil {
+		return nil, err
+	}
+	knownSet := make(map[uint]struct{}, len(known))
+	for _, id := range known {
+		knownSet[id] = struct{}{}
+	}
+
+	candidates := make(map[uint]*SuggestedUser)
+	candidate := func(id uint) *SuggestedUser {
+		if c, ok := candidates[id]; ok {
+			return c
+		}
+		c := &SuggestedUser{ID: id}
+		candidates[id] = c
+		return c
+	}
+	for _, m := range mutual {
+		if _, ok := knownSet[m.UserID]; !ok {
+			candidate(m.UserID).MutualCompanions = m.Count
+		}
+	}
+	for _, s := range shared {
+		if _, ok := knownSet[s.UserID]; !ok {
+			candidate(s.UserID).SharedGroups = s.Count
+		}
+	}
+
+	if len(candidates) == 0 {
+		return []SuggestedUser{}, nil
+	}
+
+	ids := make([]uint, 0, len(candidates))
+	for id := range candidates {
+		ids = append(ids, id)
+	}
+
+	var users []models.User
+	if err := database.Table("users").
+		Select("users.id, users.name, users.us, users.image").
+		Joins("LEFT JOIN setting_tiles t ON t.user_id = users.id").
+		Where("users.id IN ? AND COALESCE(t.companions, true)", ids).
+		Where("users.id NOT IN (?)", blockedWithSubquery(database, userID)).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]SuggestedUser, 0, len(users))
+	for _, u := range users {
+		c := candidates[u.ID]
+		c.Name, c.Us, c.Image = u.Name, u.Us, u.Image
+		result = append(result, *c)
+	}
+
+	score := func(s SuggestedUser) int { return int(s.MutualCompanions)*2 + int(s.SharedGroups) }
+	sort.Slice(result, func(i, j int) bool {
+		if score(result[i]) != score(result[j]) {
+			return score(result[i]) > score(result[j])
+		}
+		return result[i].ID < result[j].ID
+	})
+	if len(result) > peopleYouMayKnowLimit {
+		result = result[:peopleYouMayKnowLimit]
+	}
+
+	return result, nil
+}
+
+// GetGroupCohesion считает, насколько участники группы ходят на сессии вместе
+func GetGroupCohesion(groupID uint) (*GroupCohesion, error) {
+	database := db.GetDB()
+
+	var group groups.Group
+	if err := database.First(&group, groupID).Error; err != nil {
+		return nil, errors.New("группа не найдена")
+	}
+
+	result := &GroupCohesion{GroupID: groupID}
+
+	var members []uint
+	if err := database.Model(&groups.GroupUsers{}).
+		Where("group_id = ?", groupID).
+		Pluck("user_id", &members).Error; err != nil {
+		return nil, err
+	}
+	result.MembersCount = len(members)
+	result.TotalPairs = len(members) * (len(members) - 1) / 2
+	if len(members) == 0 {
+		return result, nil
+	}
+
+	var attendance []struct {
+		SessionID uint
+		UserID    uint
+	}
+	if err := database.Raw(`
+		SELECT su.session_id, su.user_id
+		FROM session_users su
+		JOIN sessions s ON s.id = su.session_id
+		JOIN statuses st ON st.id = s.status_id
+		WHERE s.group_id = ? AND st.status = 'Завершена' AND su.user_id IN ?
+	`, groupID, members).Scan(&attendance).Error; err != nil {
+		return nil, err
+	}
+
+	bySession := make(map[uint][]uint)
+	perUser := make(map[uint]int)
+	for _, a := range attendance {
+		bySession[a.SessionID] = append(bySession[a.SessionID], a.UserID)
+		perUser[a.UserID]++
+	}
+
+	result.FinishedSessions = len(bySession)
+	result.ActiveMembers = len(perUser)
+	for _, n := range perUser {
+		if n >= 2 {
+			result.RepeatMembers++
+		}
+	}
+	if result.FinishedSessions > 0 {
+		result.AvgParticipants = float64(len(attendance)) / float64(result.FinishedSessions)
+	}
+
+	type pair struct{ a, b uint }
+	pairs := make(map[pair]struct{})
+	for _, participants := range bySession {
+		for i := 0; i < len(participants); i++ {
+			for j := i + 1; j < len(participants); j++ {
+				a, b := participants[i], participants[j]
+				if a > b {
+					a, b = b, a
+				}
+				pairs[pair{a, b}] = struct{}{}
+			}
+		}
+	}
+	result.ConnectedPairs = len(pairs)
+	if result.TotalPairs > 0 {
+		result.Cohesion = float64(result.ConnectedPairs) / float64(result.TotalPairs)
+	}
+
+	return result, nil
+}
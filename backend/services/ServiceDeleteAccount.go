@@ -4,18 +4,32 @@ import (
 	"errors"
 	"friendship/db"
 	"friendship/models"
+	"log"
+
+	"gorm.io/gorm"
 )
 
-func DeleteAccount(email string) error {
+func DeleteAccount(principal models.Principal) error {
 	database := db.GetDB()
 
 	var user models.User
-	if err := database.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := database.First(&user, principal.UserID).Error; err != nil {
 		return errors.New("пользователь не найден")
 	}
-	if err := database.Delete(&user).Error; err != nil {
+
+	// Сначала передаём управление группами, иначе после каскадного удаления они останутся без администратора
+	if err := database.Transaction(func(tx *gorm.DB) error {
+		if err := handleOwnedGroupsBeforeAccountDeletion(tx, user.ID); err != nil {
+			return err
+		}
+		return tx.Delete(&user).Error
+	}); err != nil {
 		return err
 	}
 
+	if err := RevokeAllAuthSessions(user.ID); err != nil {
+		log.Printf("Не удалось завершить сессии удалённого пользователя %d: %v", user.ID, err)
+	}
+	db.InvalidatePrincipal(user.ID)
 	return nil
 }
@@ -0,0 +1,440 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"friendship/db"
+	"friendship/models"
+
+	"gorm.io/gorm"
+)
+
+// Друзья и подписки. Дружба взаимная и подтверждается второй стороной,
+// подписка односторонняя и не требует подтверждения.
+
+const (
+	friendRequestNotificationType = "friend_request"
+
+	FriendStatusNone     = "none"
+	FriendStatusFriends  = "friends"
+	FriendStatusSent     = "request_sent"
+	FriendStatusReceived = "request_received"
+)
+
+type FriendDTO struct {
+	ID    uint      `json:"id"`
+	Name  string    `json:"name"`
+	Us    string    `json:"us"`
+	Image string    `json:"image"`
+	Since time.Time `json:"since"`
+}
+
+type FriendRequestDTO struct {
+	ID        uint      `json:"id"`
+	UserID    uint      `json:"userId"`
+	Name      string    `json:"name"`
+	Us        string    `json:"us"`
+	Image     string    `json:"image"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type FriendRequestsResponse struct {
+	Incoming []FriendRequestDTO `json:"incoming"`
+	Outgoing []FriendRequestDTO `json:"outgoing"`
+}
+
+type FriendRequestResult struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// UserRelation — связь текущего пользователя с просматриваемым профилем
+type UserRelation struct {
+	FriendStatus    string `json:"friend_status"` // none, friends, request_sent, request_received
+	FriendRequestID *uint  `json:"friend_request_id,omitempty"`
+	IsFollowing     bool   `json:"is_following"`
+	FollowsYou      bool   `json:"follows_you"`
+	IsBlocked       bool   `json:"is_blocked"` // вы заблокировали пользователя
+	FriendsCount    int64  `json:"friends_count"`
+	FollowersCount  int64  `json:"followers_count"`
+}
+
+// friendshipBetween ищет запись о дружбе пары в любом направлении
+func friendshipBetween(tx *gorm.DB, a, b uint) (*models.Friendship, error) {
+	var f models.Friendship
+	err := tx.Where("(requester_id = ? AND addressee_id = ?) OR (requester_id = ? AND addressee_id = ?)", a, b, b, a).
+		First(&f).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// friendsSubquery — ID друзей пользователя для использования в WHERE ... IN
+func friendsSubquery(tx *gorm.DB, userID uint) *gorm.DB {
+	return tx.Model(&models.Friendship{}).
+		Select("CASE WHEN requester_id = ? THEN addressee_id ELSE requester_id END", userID).
+		Where("status = ? AND (requester_id = ? OR addressee_id = ?)", models.FriendshipAccepted, userID, userID)
+}
+
+func areFriends(tx *gorm.DB, a, b uint) (bool, error) {
+	f, err := friendshipBetween(tx, a, b)
+	if err != nil {
+		return false, err
+	}
+	return f != nil && f.Status == models.FriendshipAccepted, nil
+}
+
+// SendFriendRequest отправляет заявку в друзья. Если встречная заявка уже есть — дружба подтверждается сразу.
+func SendFriendRequest(principal models.Principal, targetID uint) (*FriendRequestResult, error) {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+	if user.ID == targetID {
+		return nil, errors.New("нельзя добавить в друзья самого себя")
+	}
+
+	var target models.User
+	if err := database.First(&target, targetID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+
+	var result FriendRequestResult
+	err := database.Transaction(func(tx *gorm.DB) error {
+		if err := checkNotBlocked(tx, user.ID, target.ID); err != nil {
+			return err
+		}
+
+		existing, err := friendshipBetween(tx, user.ID, target.ID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		switch {
+		case existing == nil:
+			if err := tx.Create(&models.Friendship{
+				RequesterID: user.ID,
+				AddresseeID: target.ID,
+				Status:      models.FriendshipPending,
+			}).Error; err != nil {
+				return fmt.Errorf("не удалось отправить заявку: %v", err)
+			}
+		case existing.Status == models.FriendshipAccepted:
+			return errors.New("вы уже друзья")
+		case existing.Status == models.FriendshipPending && existing.RequesterID == user.ID:
+			return errors.New("заявка уже отправлена")
+		case existing.Status == models.FriendshipPending:
+			if err := tx.Model(existing).Updates(map[string]interface{}{
+				"status": models.FriendshipAccepted, "responded_at": now,
+			}).Error; err != nil {
+				return err
+			}
+			result = FriendRequestResult{Message: "Встречная заявка принята, теперь вы друзья", Status: FriendStatusFriends}
+			return nil
+		default:
+			// Отклонённую заявку можно отправить заново
+			if err := tx.Model(existing).Updates(map[string]interface{}{
+				"requester_id": user.ID, "addressee_id": target.ID,
+				"status": models.FriendshipPending, "responded_at": nil, "created_at": now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		result = FriendRequestResult{Message: "Заявка в друзья отправлена", Status: FriendStatusSent}
+		return queueNotifications(tx, []uint{target.ID}, friendRequestNotificationType, "Заявка в друзья",
+			"Новая заявка в друзья", fmt.Sprintf("%s (@%s) хочет добавить вас в друзья", user.Name, user.Us), user.Image)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetFriendRequests возвращает входящие и исходящие заявки, ожидающие ответа
+func GetFriendRequests(principal models.Principal) (*FriendRequestsResponse, error) {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+
+	var requests []models.Friendship
+	if err := database.Preload("Requester").Preload("Addressee").
+		Where("status = ? AND (requester_id = ? OR addressee_id = ?)", models.FriendshipPending, user.ID, user.ID).
+		Order("created_at DESC").
+		Find(&requests).Error; err != nil {
+		return nil, err
+	}
+
+	response := &FriendRequestsResponse{
+		Incoming: make([]FriendRequestDTO, 0),
+		Outgoing: make([]FriendRequestDTO, 0),
+	}
+	for _, r := range requests {
+		if r.AddresseeID == user.ID {
+			response.Incoming = append(response.Incoming, FriendRequestDTO{
+				ID: r.ID, UserID: r.RequesterID, Name: r.Requester.Name, Us: r.Requester.Us, Image: r.Requester.Image, CreatedAt: r.CreatedAt,
+			})
+		} else {
+			response.Outgoing = append(response.Outgoing, FriendRequestDTO{
+				ID: r.ID, UserID: r.AddresseeID, Name: r.Addressee.Name, Us: r.Addressee.Us, Image: r.Addressee.Image, CreatedAt: r.CreatedAt,
+			})
+		}
+	}
+	return response, nil
+}
+
+// RespondFriendRequest принимает или отклоняет входящую заявку
+func RespondFriendRequest(principal models.Principal, requestID uint, accept bool) error {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return errors.New("пользователь не найден")
+	}
+
+	var request models.Friendship
+	if err := database.First(&request, requestID).Error; err != nil {
+		return errors.New("заявка не найдена")
+	}
+	if request.AddresseeID != user.ID {
+		return errors.New("у вас нет доступа к этой заявке")
+	}
+	if request.Status != models.FriendshipPending {
+		return errors.New("заявка уже обработана")
+	}
+
+	status := models.FriendshipDeclined
+	if accept {
+		status = models.FriendshipAccepted
+	}
+	return database.Model(&request).Updates(map[string]interface{}{
+		"status": status, "responded_at": time.Now(),
+	}).Error
+}
+
+// RemoveFriend удаляет друга или отменяет заявку в любом направлении
+func RemoveFriend(principal models.Principal, otherID uint) error {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return errors.New("пользователь не найден")
+	}
+
+	res := database.
+		Where("(requester_id = ? AND addressee_id = ?) OR (requester_id = ? AND addressee_id = ?)", user.ID, otherID, otherID, user.ID).
+		Where("status IN ?", []string{models.FriendshipAccepted, models.FriendshipPending}).
+		Delete(&models.Friendship{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("пользователь не в друзьях")
+	}
+	return nil
+}
+
+// GetFriends возвращает список друзей пользователя
+func GetFriends(principal models.Principal) ([]FriendDTO, error) {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+
+	friends := make([]FriendDTO, 0)
+	if err := database.Table("friendships f").
+		Select("u.id, u.name, u.us, u.image, COALESCE(f.responded_at, f.created_at) AS since").
+		Joins("JOIN users u ON u.id = CASE WHEN f.requester_id = ? THEN f.addressee_id ELSE f.requester_id END", user.ID).
+		Where("f.status = ? AND (f.requester_id = ? OR f.addressee_id = ?)", models.FriendshipAccepted, user.ID, user.ID).
+		Order("u.name ASC").
+		Scan(&friends).Error; err != nil {
+		return nil, err
+	}
+	return friends, nil
+}
+
+// FollowUser подписывает пользователя на другого; повторная подписка не считается ошибкой
+func FollowUser(principal models.Principal, targetID uint) error {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return errors.New("пользователь не найден")
+	}
+	if user.ID == targetID {
+		return errors.New("нельзя подписаться на самого себя")
+	}
+	var target models.User
+	if err := database.First(&target, targetID).Error; err != nil {
+		return errors.New("пользователь не найден")
+	}
+
+	if err := checkNotBlocked(database, user.ID, target.ID); err != nil {
+		return err
+	}
+
+	follow := models.Follow{FollowerID: user.ID, FolloweeID: target.ID}
+	return database.Where(follow).FirstOrCreate(&follow).Error
+}
+
+// UnfollowUser отменяет подписку
+func UnfollowUser(principal models.Principal, targetID uint) error {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return errors.New("пользователь не найден")
+	}
+
+	res := database.Where("follower_id = ? AND followee_id = ?", user.ID, targetID).Delete(&models.Follow{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("вы не подписаны на этого пользователя")
+	}
+	return nil
+}
+
+// GetFollows возвращает подписчиков (followers == true) или подписки пользователя
+func GetFollows(principal models.Principal, followers bool) ([]FriendDTO, error) {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+
+	ownColumn, otherColumn := "f.followee_id", "f.follower_id"
+	if !followers {
+		ownColumn, otherColumn = otherColumn, ownColumn
+	}
+
+	result := make([]FriendDTO, 0)
+	if err := database.Table("follows f").
+		Select("u.id, u.name, u.us, u.image, f.created_at AS since").
+		Joins("JOIN users u ON u.id = "+otherColumn).
+		Where(ownColumn+" = ?", user.ID).
+		Order("f.created_at DESC").
+		Scan(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetUserRelation описывает отношения текущего пользователя с профилем otherID
+func GetUserRelation(principal models.Principal, otherID uint) (*UserRelation, error) {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+
+	relation := &UserRelation{FriendStatus: FriendStatusNone}
+
+	f, err := friendshipBetween(database, user.ID, otherID)
+	if err != nil {
+		return nil, err
+	}
+	if f != nil {
+		switch {
+		case f.Status == models.FriendshipAccepted:
+			relation.FriendStatus = FriendStatusFriends
+		case f.Status == models.FriendshipPending && f.RequesterID == user.ID:
+			relation.FriendStatus = FriendStatusSent
+			relation.FriendRequestID = &f.ID
+		case f.Status == models.FriendshipPending:
+			relation.FriendStatus = FriendStatusReceived
+			relation.FriendRequestID = &f.ID
+		}
+	}
+
+	var follows []models.Follow
+	if err := database.Where("(follower_id = ? AND followee_id = ?) OR (follower_id = ? AND followee_id = ?)",
+		user.ID, otherID, otherID, user.ID).Find(&follows).Error; err != nil {
+		return nil, err
+	}
+	for _, fl := range follows {
+		if fl.FollowerID == user.ID {
+			relation.IsFollowing = true
+		} else {
+			relation.FollowsYou = true
+		}
+	}
+
+	var blocks int64
+	if err := database.Model(&models.UserBlock{}).
+		Where("blocker_id = ? AND blocked_id = ?", user.ID, otherID).
+		Count(&blocks).Error; err != nil {
+		return nil, err
+	}
+	relation.IsBlocked = blocks > 0
+
+	if err := database.Model(&models.Friendship{}).
+		Where("status = ? AND (requester_id = ? OR addressee_id = ?)", models.FriendshipAccepted, otherID, otherID).
+		Count(&relation.FriendsCount).Error; err != nil {
+		return nil, err
+	}
+	if err := database.Model(&models.Follow{}).Where("followee_id = ?", otherID).
+		Count(&relation.FollowersCount).Error; err != nil {
+		return nil, err
+	}
+
+	return relation, nil
+}
+
+// friendsGoingCounts — сколько друзей пользователя записано на каждую из сессий.
+// Друзья, скрывающие свои сессии, не учитываются.
+func friendsGoingCounts(tx *gorm.DB, userID uint, sessionIDs []uint) (map[uint]int64, error) {
+	result := make(map[uint]int64, len(sessionIDs))
+	if len(sessionIDs) == 0 || userID == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		SessionID uint
+		Count     int64
+	}
+	if err := tx.Table("session_users").
+		Select("session_id, COUNT(*) AS count").
+		Where("session_id IN ? AND user_id IN (?)", sessionIDs, friendsSubquery(tx, userID)).
+		Where("user_id NOT IN (?)", hiddenParticipantsSubquery(tx)).
+		Group("session_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		result[r.SessionID] = r.Count
+	}
+	return result, nil
+}
+
+// friendsGoing — друзья пользователя, записанные на сессию
+func friendsGoing(tx *gorm.DB, userID, sessionID uint) ([]FriendDTO, error) {
+	result := make([]FriendDTO, 0)
+	if err := tx.Table("session_users su").
+		Select("u.id, u.name, u.us, u.image, su.joined_at AS since").
+		Joins("JOIN users u ON u.id = su.user_id").
+		Where("su.session_id = ? AND su.user_id IN (?)", sessionID, friendsSubquery(tx, userID)).
+		Where("su.user_id NOT IN (?)", hiddenParticipantsSubquery(tx)).
+		Order("su.joined_at ASC").
+		Scan(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
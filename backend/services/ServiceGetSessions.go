@@ -18,19 +18,20 @@ type GetNewSessionsResponse struct {
 }
 
 type SessionResponse struct {
-	ID            uint      `json:"id"`
-	Title         string    `json:"title"`
-	StartTime     time.Time `json:"start_time"`
-	SessionType   string    `json:"session_type"`
-	ImageURL      string    `json:"image_url"`
-	SessionPlace  string    `json:"session_place"`
-	Genres        []string  `json:"genres"`
-	Duration      uint16    `json:"duration"`
-	CurrentUsers  uint16    `json:"current_users"`
-	CountUsersMax uint16    `json:"count_users_max"`
-	GroupName     string    `json:"group_name"`
-	GroupID       uint      `json:"group_id"`
-	City          *string   `json:"city" default:"prikol"`
+	ID                uint      `json:"id"`
+	Title             string    `json:"title"`
+	StartTime         time.Time `json:"start_time"`
+	SessionType       string    `json:"session_type"`
+	ImageURL          string    `json:"image_url"`
+	SessionPlace      string    `json:"session_place"`
+	Genres            []string  `json:"genres"`
+	Duration          uint16    `json:"duration"`
+	CurrentUsers      uint16    `json:"current_users"`
+	CountUsersMax     uint16    `json:"count_users_max"`
+	GroupName         string    `json:"group_name"`
+	GroupID           uint      `json:"group_id"`
+	City              *string   `json:"city" default:"prikol"`
+	FriendsGoingCount int64     `json:"friends_going_count"`
 }
 
 // func getSessionGenres(sessionID uint) ([]string, error) {
@@ -83,7 +84,7 @@ type SessionResponse struct {
 
 // 	// Проверка существования пользователя
 // 	var user models.User
-// 	if err := dbTx.Where("email = ?", email).First(&user).Error; err != nil {
+// 	if err := dbTx.First(&user, principal.UserID).Error; err != nil {
 // 		dbTx.Rollback()
 // 		return nil, fmt.Errorf("пользователь не найден: %v", err)
 // 	}
@@ -186,10 +187,7 @@ type GetSessionsInput struct {
 	NewOnly     bool    `form:"new_only"` // true — только новые
 }
 
-func GetSessions(email string, input GetSessionsInput) (*PaginatedSearchResponse, error) {
-	if email == "" {
-		return nil, fmt.Errorf("email не может быть пустым")
-	}
+func GetSessions(principal models.Principal, input GetSessionsInput) (*PaginatedSearchResponse, error) {
 
 	const pageSize = 10
 	currentPage := input.Page
@@ -205,7 +203,7 @@ func GetSessions(email string, input GetSessionsInput) (*PaginatedSearchResponse
 	}()
 
 	var user models.User
-	if err := dbTx.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := dbTx.First(&user, principal.UserID).Error; err != nil {
 		dbTx.Rollback()
 		return nil, fmt.Errorf("пользователь не найден: %v", err)
 	}
@@ -284,6 +282,12 @@ func GetSessions(email string, input GetSessionsInput) (*PaginatedSearchResponse
 		metadataMap = make(map[uint]*sessions.SessionMetadata)
 	}
 
+	friendsCounts, err := friendsGoingCounts(db.GetDB(), user.ID, sessionIDs)
+	if err != nil {
+		log.Printf("Ошибка подсчета друзей на сессиях: %v", err)
+		friendsCounts = make(map[uint]int64)
+	}
+
 	var filteredResult []SessionResponse
 	for _, s := range allSessions {
 		var genres []string
@@ -320,6 +324,8 @@ func GetSessions(email string, input GetSessionsInput) (*PaginatedSearchResponse
 			GroupName:     s.Group.Name,
 			GroupID:       s.Group.ID,
 			City:          &city,
+
+			FriendsGoingCount: friendsCounts[s.ID],
 		}
 		filteredResult = append(filteredResult, resp)
 	}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"friendship/db"
+	"friendship/models"
 	"friendship/models/groups"
 
 	"gorm.io/gorm"
@@ -23,7 +24,7 @@ func GetGroupsUserSubByID(userID uint) ([]GroupResponse, error) {
 	var groupUsers []groups.GroupUsers
 	err := db.GetDB().Preload("Group").
 		Preload("Group.Categories").
-		Where("user_id = ? AND role_in_group = 'member'", userID).
+		Where("user_id = ? AND role_in_group NOT IN ?", userID, groups.RolesWith(groups.CapApproveRequests)).
 		Find(&groupUsers).Error
 
 	if err != nil {
@@ -65,12 +66,9 @@ func GetGroupsUserSubByID(userID uint) ([]GroupResponse, error) {
 	return groupResponses, nil
 }
 
-func GetGroupsUserSub(email string) ([]GroupResponse, error) {
-	if email == "" {
-		return nil, fmt.Errorf("не передан jwt")
-	}
+func GetGroupsUserSub(principal models.Principal) ([]GroupResponse, error) {
 
-	user, err := FindUserByEmail(email)
+	user, err := FindUserByID(principal.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("пользователь не найден: %v", err)
 	}
@@ -78,7 +76,7 @@ func GetGroupsUserSub(email string) ([]GroupResponse, error) {
 	var groupUsers []groups.GroupUsers
 	err = db.GetDB().Preload("Group").
 		Preload("Group.Categories").
-		Where("user_id = ? AND role_in_group = 'member'", &user.ID).
+		Where("user_id = ? AND role_in_group NOT IN ?", &user.ID, groups.RolesWith(groups.CapApproveRequests)).
 		Find(&groupUsers).Error
 
 	if err != nil {
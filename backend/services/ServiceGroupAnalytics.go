@@ -0,0 +1,365 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"friendship/db"
+	"friendship/models/groups"
+	"friendship/models/sessions"
+)
+
+// Аналитика группы для админов и операторов: активность по месяцам, заполняемость,
+// рост и отток участников, самые активные участники, жанры и популярные временные слоты.
+
+const (
+	defaultAnalyticsMonths = 12
+	maxAnalyticsMonths     = 36
+	analyticsTopLimit      = 10
+)
+
+type GroupMonthActivity struct {
+	Month            string  `json:"month"` // 2025-03
+	Sessions         int     `json:"sessions"`
+	FinishedSessions int     `json:"finished_sessions"`
+	AvgFillRate      float64 `json:"avg_fill_rate"`
+	AvgAttendance    float64 `json:"avg_attendance"`
+	Joined           int     `json:"joined"`
+	Left             int     `json:"left"`
+	MembersAtEnd     int     `json:"members_at_end"`
+	ChurnRate        float64 `json:"churn_rate"` // ушедшие / участники на начало месяца
+}
+
+type GroupActiveMember struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Us       string `json:"us"`
+	Image    string `json:"image"`
+	Attended int    `json:"attended"`
+	Hosted   int    `json:"hosted"`
+}
+
+type GroupTimeSlot struct {
+	DayOfWeek     int     `json:"day_of_week"` // 1 — понедельник
+	Hour          int     `json:"hour"`
+	Sessions      int     `json:"sessions"`
+	AvgAttendance float64 `json:"avg_attendance"`
+}
+
+type GroupAnalytics struct {
+	GroupID          uint                 `json:"group_id"`
+	From             time.Time            `json:"from"`
+	To               time.Time            `json:"to"`
+	MembersCount     int                  `json:"members_count"`
+	TotalSessions    int                  `json:"total_sessions"`
+	FinishedSessions int                  `json:"finished_sessions"`
+	AvgFillRate      float64              `json:"avg_fill_rate"`
+	AvgAttendance    float64              `json:"avg_attendance"`
+	Months           []GroupMonthActivity `json:"months"`
+	MostActive       []GroupActiveMember  `json:"most_active_members"`
+	PopularGenres    []GenreStats         `json:"popular_genres"`
+	TimeSlots        []GroupTimeSlot      `json:"time_slots"`
+	Cohesion         *GroupCohesion       `json:"cohesion"`
+}
+
+// GetGroupAnalytics собирает аналитику группы за последние months месяцев
+func GetGroupAnalytics(groupID uint, months int) (*GroupAnalytics, error) {
+	database := db.GetDB()
+
+	var group groups.Group
+	if err := database.First(&group, groupID).Error; err != nil {
+		return nil, errors.New("группа не найдена")
+	}
+
+	if months <= 0 {
+		months = defaultAnalyticsMonths
+	}
+	if months > maxAnalyticsMonths {
+		months = maxAnalyticsMonths
+	}
+
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1, 0)
+	from := to.AddDate(0, -months, 0)
+
+	result := &GroupAnalytics{
+		GroupID:       groupID,
+		From:          from,
+		To:            to,
+		Months:        make([]GroupMonthActivity, months),
+		MostActive:    []GroupActiveMember{},
+		PopularGenres: []GenreStats{},
+		TimeSlots:     []GroupTimeSlot{},
+	}
+	monthIndex := make(map[string]int, months)
+	for i := 0; i < months; i++ {
+		m := from.AddDate(0, i, 0).Format("2006-01")
+		result.Months[i].Month = m
+		monthIndex[m] = i
+	}
+
+	var finishedStatus sessions.Status
+	if err := database.Where("status = ?", "Завершена").First(&finishedStatus).Error; err != nil {
+		return nil, fmt.Errorf("статус 'Завершена' не найден: %v", err)
+	}
+
+	var sessionRows []struct {
+		ID            uint
+		StartTime     time.Time
+		CurrentUsers  uint16
+		CountUsersMax uint16
+		StatusID      uint
+	}
+	if err := database.Model(&sessions.Session{}).
+		Select("id, start_time, current_users, count_users_max, status_id").
+		Where("group_id = ? AND start_time >= ? AND start_time < ?", groupID, from, to).
+		Scan(&sessionRows).Error; err != nil {
+		return nil, err
+	}
+
+	type acc struct {
+		fill, attendance float64
+		n                int
+	}
+	monthAcc := make([]acc, months)
+	var total acc
+	finishedIDs := make([]uint, 0, len(sessionRows))
+	slots := make(map[[2]int]*GroupTimeSlot)
+
+	for _, s := range sessionRows {
+		result.TotalSessions++
+		idx, ok := monthIndex[s.StartTime.UTC().Format("2006-01")]
+		if ok {
+			result.Months[idx].Sessions++
+		}
+		if s.StatusID != finishedStatus.ID {
+			continue
+		}
+
+		finishedIDs = append(finishedIDs, s.ID)
+		result.FinishedSessions++
+
+		fill := 0.0
+		if s.CountUsersMax > 0 {
+			fill = float64(s.CurrentUsers) / float64(s.CountUsersMax)
+		}
+		total.fill += fill
+		total.attendance += float64(s.CurrentUsers)
+		total.n++
+		if ok {
+			result.Months[idx].FinishedSessions++
+			monthAcc[idx].fill += fill
+			monthAcc[idx].attendance += float64(s.CurrentUsers)
+			monthAcc[idx].n++
+		}
+
+		day := int(s.StartTime.Weekday())
+		if day == 0 {
+			day = 7
+		}
+		key := [2]int{day, s.StartTime.Hour()}
+		slot, exists := slots[key]
+		if !exists {
+			slot = &GroupTimeSlot{DayOfWeek: day, Hour: s.StartTime.Hour()}
+			slots[key] = slot
+		}
+		slot.Sessions++
+		slot.AvgAttendance += float64(s.CurrentUsers)
+	}
+
+	if total.n > 0 {
+		result.AvgFillRate = roundAnalytics(total.fill / float64(total.n))
+		result.AvgAttendance = roundAnalytics(total.attendance / float64(total.n))
+	}
+	for i := range result.Months {
+		if monthAcc[i].n > 0 {
+			result.Months[i].AvgFillRate = roundAnalytics(monthAcc[i].fill / float64(monthAcc[i].n))
+			result.Months[i].AvgAttendance = roundAnalytics(monthAcc[i].attendance / float64(monthAcc[i].n))
+		}
+	}
+
+	for _, slot := range slots {
+		slot.AvgAttendance = roundAnalytics(slot.AvgAttendance / float64(slot.Sessions))
+		result.TimeSlots = append(result.TimeSlots, *slot)
+	}
+	sort.Slice(result.TimeSlots, func(i, j int) bool {
+		a, b := result.TimeSlots[i], result.TimeSlots[j]
+		if a.Sessions != b.Sessions {
+			return a.Sessions > b.Sessions
+		}
+		if a.DayOfWeek != b.DayOfWeek {
+			return a.DayOfWeek < b.DayOfWeek
+		}
+		return a.Hour < b.Hour
+	})
+	if len(result.TimeSlots) > analyticsTopLimit {
+		result.TimeSlots = result.TimeSlots[:analyticsTopLimit]
+	}
+
+	if err := fillMembershipDynamics(groupID, result, monthIndex); err != nil {
+		return nil, err
+	}
+
+	// Организатор считается участником своей сессии, даже если его нет в session_users
+	if err := database.Raw(`
+		WITH finished AS (
+			SELECT id, user_id FROM sessions
+			WHERE group_id = ? AND status_id = ? AND start_time >= ? AND start_time < ?
+		), participants AS (
+			SELECT su.session_id, su.user_id FROM session_users su JOIN finished f ON f.id = su.session_id
+			UNION
+			SELECT f.id, f.user_id FROM finished f
+		)
+		SELECT u.id, u.name, u.us, u.image,
+		       COUNT(*) AS attended,
+		       COUNT(*) FILTER (WHERE f.user_id = u.id) AS hosted
+		FROM participants p
+		JOIN finished f ON f.id = p.session_id
+		JOIN users u ON u.id = p.user_id
+		JOIN group_users gu ON gu.user_id = u.id AND gu.group_id = ?
+		GROUP BY u.id, u.name, u.us, u.image
+		ORDER BY attended DESC, hosted DESC, u.id ASC
+		LIMIT ?
+	`, groupID, finishedStatus.ID, from, to, groupID, analyticsTopLimit).Scan(&result.MostActive).Error; err != nil {
+		return nil, err
+	}
+
+	if len(finishedIDs) > 0 {
+		metadata, err := db.GetSessionsMetadata(finishedIDs)
+		if err != nil {
+			log.Printf("Предупреждение: ошибка получения метаданных из MongoDB: %v", err)
+		} else {
+			genres := make(map[string]uint16)
+			for _, id := range finishedIDs {
+				if meta, ok := metadata[id]; ok && meta != nil {
+					for _, g := range meta.Genres {
+						if g != "" {
+							genres[g]++
+						}
+					}
+				}
+			}
+			for _, name := range topKeys(genres, analyticsTopLimit) {
+				result.PopularGenres = append(result.PopularGenres, GenreStats{Name: name, Count: genres[name]})
+			}
+		}
+	}
+
+	cohesion, err := GetGroupCohesion(groupID)
+	if err != nil {
+		return nil, err
+	}
+	result.Cohesion = cohesion
+
+	return result, nil
+}
+
+// fillMembershipDynamics считает вступления, выходы и численность по месяцам.
+// Вступления берутся у текущих участников и из записей о выходе. Численность восстанавливается
+// назад от текущей: участники без даты вступления считаются старыми.
+func fillMembershipDynamics(groupID uint, result *GroupAnalytics, monthIndex map[string]int) error {
+	database := db.GetDB()
+
+	var members []groups.GroupUsers
+	if err := database.Select("id, joined_at").Where("group_id = ?", groupID).Find(&members).Error; err != nil {
+		return err
+	}
+	result.MembersCount = len(members)
+
+	var leaves []groups.GroupMemberLeave
+	// Кто вступил в окне, вышел тоже не раньше его начала, поэтому этих выходов достаточно
+	if err := database.Select("id, left_at, joined_at").Where("group_id = ? AND left_at >= ?", groupID, result.From).Find(&leaves).Error; err != nil {
+		return err
+	}
+
+	countJoin := func(joinedAt *time.Time) {
+		if joinedAt == nil {
+			return
+		}
+		if idx, ok := monthIndex[joinedAt.UTC().Format("2006-01")]; ok {
+			result.Months[idx].Joined++
+		}
+	}
+	for _, m := range members {
+		countJoin(m.JoinedAt)
+	}
+	// Вступления ушедших берутся из записи о выходе, иначе прошлая численность занижается
+	for _, l := range leaves {
+		countJoin(l.JoinedAt)
+		if idx, ok := monthIndex[l.LeftAt.UTC().Format("2006-01")]; ok {
+			result.Months[idx].Left++
+		}
+	}
+
+	count := len(members)
+	for i := len(result.Months) - 1; i >= 0; i-- {
+		result.Months[i].MembersAtEnd = count
+		atStart := count - result.Months[i].Joined + result.Months[i].Left
+		if atStart > 0 {
+			result.Months[i].ChurnRate = roundAnalytics(float64(result.Months[i].Left) / float64(atStart))
+		}
+		count = atStart
+	}
+
+	return nil
+}
+
+func roundAnalytics(v float64) float64 {
+	return float64(int(v*100+0.5)) / 100
+}
+
+// GroupAnalyticsCSV выгружает аналитику в CSV: несколько таблиц подряд, разделённых пустой строкой
+func GroupAnalyticsCSV(a *GroupAnalytics) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	itoa := strconv.Itoa
+
+	rows := [][]string{
+		{"group_id", "from", "to", "members_count", "total_sessions", "finished_sessions", "avg_fill_rate", "avg_attendance"},
+		{fmt.Sprint(a.GroupID), a.From.Format("2006-01-02"), a.To.Format("2006-01-02"), itoa(a.MembersCount),
+			itoa(a.TotalSessions), itoa(a.FinishedSessions), f(a.AvgFillRate), f(a.AvgAttendance)},
+		{},
+		{"month", "sessions", "finished_sessions", "avg_fill_rate", "avg_attendance", "joined", "left", "members_at_end", "churn_rate"},
+	}
+	for _, m := range a.Months {
+		rows = append(rows, []string{m.Month, itoa(m.Sessions), itoa(m.FinishedSessions), f(m.AvgFillRate),
+			f(m.AvgAttendance), itoa(m.Joined), itoa(m.Left), itoa(m.MembersAtEnd), f(m.ChurnRate)})
+	}
+
+	rows = append(rows, []string{}, []string{"member_id", "name", "us", "attended", "hosted"})
+	for _, m := range a.MostActive {
+		rows = append(rows, []string{fmt.Sprint(m.ID), csvSafe(m.Name), csvSafe(m.Us), itoa(m.Attended), itoa(m.Hosted)})
+	}
+
+	rows = append(rows, []string{}, []string{"genre", "sessions"})
+	for _, g := range a.PopularGenres {
+		rows = append(rows, []string{csvSafe(g.Name), fmt.Sprint(g.Count)})
+	}
+
+	rows = append(rows, []string{}, []string{"day_of_week", "hour", "sessions", "avg_attendance"})
+	for _, s := range a.TimeSlots {
+		rows = append(rows, []string{itoa(s.DayOfWeek), itoa(s.Hour), itoa(s.Sessions), f(s.AvgAttendance)})
+	}
+
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// csvSafe не даёт пользовательскому тексту стать формулой, когда CSV открывают в таблицах
+func csvSafe(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"friendship/db"
+	"friendship/middlewares"
+	"friendship/models"
+	"friendship/models/groups"
+
+	"gorm.io/gorm"
+)
+
+// Объявления группы публикуют администраторы и операторы. Участники получают уведомление
+// в зависимости от своей настройки в группе: обо всех объявлениях, только о закреплённых или ни о каких.
+// Уведомления создаются неотправленными, доставку в Telegram и push делает notify_service.
+
+const (
+	announcementNotificationType = "group_announcement"
+
+	maxAnnouncementTitleLength = 120
+	maxAnnouncementTextLength  = 4000
+	MaxAnnouncementImages      = 4
+	maxPinnedAnnouncements     = 3
+	announcementsPageSize      = 10
+	groupInfAnnouncementsLimit = 3
+)
+
+type CreateAnnouncementInput struct {
+	Title  string   `form:"title" binding:"required"`
+	Text   string   `form:"text" binding:"required"`
+	Pinned bool     `form:"pinned"`
+	Images []string `form:"-"`
+}
+
+type AnnouncementResponse struct {
+	ID          uint       `json:"id"`
+	GroupID     uint       `json:"groupId"`
+	Title       string     `json:"title"`
+	Text        string     `json:"text"`
+	Images      []string   `json:"images"`
+	Pinned      bool       `json:"pinned"`
+	PinnedAt    *time.Time `json:"pinnedAt"`
+	AuthorID    *uint      `json:"authorId"`
+	AuthorName  string     `json:"authorName"`
+	AuthorUs    string     `json:"authorUs"`
+	AuthorImage string     `json:"authorImage"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+type AnnouncementsPage struct {
+	Items   []AnnouncementResponse `json:"items"`
+	Page    int                    `json:"page"`
+	Total   int64                  `json:"total"`
+	HasMore bool                   `json:"has_more"`
+}
+
+// CreateGroupAnnouncement публикует объявление и ставит уведомления участникам в очередь
+func CreateGroupAnnouncement(principal models.Principal, groupID uint, input CreateAnnouncementInput) (*AnnouncementResponse, error) {
+	title := strings.TrimSpace(input.Title)
+	text := strings.TrimSpace(input.Text)
+	if title == "" || text == "" {
+		return nil, errors.New("заголовок и текст объявления обязательны")
+	}
+	if utf8.RuneCountInString(title) > maxAnnouncementTitleLength {
+		return nil, fmt.Errorf("заголовок длиннее %d символов", maxAnnouncementTitleLength)
+	}
+	if utf8.RuneCountInString(text) > maxAnnouncementTextLength {
+		return nil, fmt.Errorf("текст длиннее %d символов", maxAnnouncementTextLength)
+	}
+	if len(input.Images) > MaxAnnouncementImages {
+		return nil, fmt.Errorf("к объявлению можно прикрепить не больше %d изображений", MaxAnnouncementImages)
+	}
+
+	database := db.GetDB()
+
+	var author models.User
+	if err := database.First(&author, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+
+	var announcement groups.GroupAnnouncement
+	err := database.Transaction(func(tx *gorm.DB) error {
+		if _, err := requireGroupCapability(tx, author.ID, groupID, groups.CapPostAnnouncements); err != nil {
+			return err
+		}
+
+		var group groups.Group
+		if err := tx.First(&group, groupID).Error; err != nil {
+			return errors.New("группа не найдена")
+		}
+		if group.ArchivedAt != nil {
+			return errors.New("группа в архиве")
+		}
+
+		authorID := author.ID
+		announcement = groups.GroupAnnouncement{
+			GroupID:  groupID,
+			AuthorID: &authorID,
+			Title:    title,
+			Text:     text,
+			Images:   input.Images,
+		}
+		if input.Pinned {
+			if err := checkPinnedAnnouncementsLimit(tx, groupID); err != nil {
+				return err
+			}
+			now := time.Now()
+			announcement.PinnedAt = &now
+		}
+		if err := tx.Create(&announcement).Error; err != nil {
+			return fmt.Errorf("не удалось создать объявление: %v", err)
+		}
+
+		if err := recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    groupID,
+			ActorID:    author.ID,
+			Action:     AuditAnnouncementCreated,
+			TargetType: "announcement",
+			TargetID:   announcement.ID,
+			After:      map[string]interface{}{"title": title, "pinned": input.Pinned},
+		}); err != nil {
+			return err
+		}
+
+		levels := []string{groups.AnnouncementNotifyAll}
+		if input.Pinned {
+			levels = append(levels, groups.AnnouncementNotifyPinned)
+		}
+		return queueAnnouncementNotifications(tx, group, announcement, levels)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	announcement.Author = author
+	response := buildAnnouncementResponse(announcement)
+	return &response, nil
+}
+
+// SetAnnouncementPinned закрепляет или открепляет объявление. При закреплении уведомляются
+// участники, подписанные только на закреплённые объявления.
+func SetAnnouncementPinned(principal models.Principal, groupID, announcementID uint, pinned bool) error {
+	database := db.GetDB()
+
+	actorID := principal.UserID
+
+	return database.Transaction(func(tx *gorm.DB) error {
+		var announcement groups.GroupAnnouncement
+		if err := tx.Where("id = ? AND group_id = ?", announcementID, groupID).First(&announcement).Error; err != nil {
+			return errors.New("объявление не найдено")
+		}
+		if (announcement.PinnedAt != nil) == pinned {
+			return nil
+		}
+
+		action := AuditAnnouncementUnpinned
+		var pinnedAt *time.Time
+		if pinned {
+			if err := checkPinnedAnnouncementsLimit(tx, groupID); err != nil {
+				return err
+			}
+			now := time.Now()
+			pinnedAt = &now
+			action = AuditAnnouncementPinned
+		}
+		if err := tx.Model(&announcement).Update("pinned_at", pinnedAt).Error; err != nil {
+			return err
+		}
+
+		if err := recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    groupID,
+			ActorID:    actorID,
+			Action:     action,
+			TargetType: "announcement",
+			TargetID:   announcement.ID,
+		}); err != nil {
+			return err
+		}
+
+		if !pinned {
+			return nil
+		}
+		var group groups.Group
+		if err := tx.First(&group, groupID).Error; err != nil {
+			return errors.New("группа не найдена")
+		}
+		return queueAnnouncementNotifications(tx, group, announcement, []string{groups.AnnouncementNotifyPinned})
+	})
+}
+
+// DeleteGroupAnnouncement удаляет объявление вместе с его изображениями
+func DeleteGroupAnnouncement(principal models.Principal, groupID, announcementID uint) error {
+	database := db.GetDB()
+
+	actorID := principal.UserID
+
+	var announcement groups.GroupAnnouncement
+	err := database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND group_id = ?", announcementID, groupID).First(&announcement).Error; err != nil {
+			return errors.New("объявление не найдено")
+		}
+		if err := tx.Delete(&announcement).Error; err != nil {
+			return fmt.Errorf("не удалось удалить объявление: %v", err)
+		}
+		return recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    groupID,
+			ActorID:    actorID,
+			Action:     AuditAnnouncementDeleted,
+			TargetType: "announcement",
+			TargetID:   announcement.ID,
+			Before:     map[string]interface{}{"title": announcement.Title, "text": announcement.Text, "pinned": announcement.PinnedAt != nil},
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, image := range announcement.Images {
+		if err := middlewares.DeleteImageFromS3(image); err != nil {
+			log.Printf("Не удалось удалить изображение объявления %d: %v", announcement.ID, err)
+		}
+	}
+	return nil
+}
+
+// GetGroupAnnouncements возвращает страницу объявлений: закреплённые первыми, затем новые
+func GetGroupAnnouncements(principal models.Principal, groupID uint64, page int) (*AnnouncementsPage, error) {
+	if page < 1 {
+		page = 1
+	}
+
+	user, err := FindUserByID(principal.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("пользователь не найден: %v", err)
+	}
+
+	var group groups.Group
+	if err := db.GetDB().First(&group, groupID).Error; err != nil {
+		return nil, errors.New("группа не найдена")
+	}
+	if group.IsPrivate {
+		isMember, err := checkGroupMembership(&groupID, &user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !isMember {
+			return nil, errors.New("доступ к приватной группе запрещен")
+		}
+	}
+
+	query := db.GetDB().Model(&groups.GroupAnnouncement{}).Where("group_id = ?", groupID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var announcements []groups.GroupAnnouncement
+	if err := query.Preload("Author").
+		Order("pinned_at DESC NULLS LAST").
+		Order("created_at DESC").
+		Limit(announcementsPageSize).
+		Offset((page - 1) * announcementsPageSize).
+		Find(&announcements).Error; err != nil {
+		return nil, err
+	}
+
+	items := make([]AnnouncementResponse, 0, len(announcements))
+	for _, a := range announcements {
+		items = append(items, buildAnnouncementResponse(a))
+	}
+
+	return &AnnouncementsPage{
+		Items:   items,
+		Page:    page,
+		Total:   total,
+		HasMore: int64(page*announcementsPageSize) < total,
+	}, nil
+}
+
+// getGroupInfAnnouncements — объявления для карточки группы: все закреплённые и несколько последних
+func getGroupInfAnnouncements(groupID uint64) ([]AnnouncementResponse, error) {
+	var pinned []groups.GroupAnnouncement
+	if err := db.GetDB().Preload("Author").
+		Where("group_id = ? AND pinned_at IS NOT NULL", groupID).
+		Order("pinned_at DESC").
+		Find(&pinned).Error; err != nil {
+		return nil, err
+	}
+
+	var recent []groups.GroupAnnouncement
+	if err := db.GetDB().Preload("Author").
+		Where("group_id = ? AND pinned_at IS NULL", groupID).
+		Order("created_at DESC").
+		Limit(groupInfAnnouncementsLimit).
+		Find(&recent).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]AnnouncementResponse, 0, len(pinned)+len(recent))
+	for _, a := range append(pinned, recent...) {
+		result = append(result, buildAnnouncementResponse(a))
+	}
+	return result, nil
+}
+
+// SetGroupAnnouncementsNotify меняет настройку уведомлений об объявлениях для участника группы
+func SetGroupAnnouncementsNotify(principal models.Principal, groupID uint, level string) error {
+	switch level {
+	case groups.AnnouncementNotifyAll, groups.AnnouncementNotifyPinned, groups.AnnouncementNotifyNone:
+	default:
+		return fmt.Errorf("неизвестная настройка уведомлений: %s", level)
+	}
+
+	user, err := FindUserByID(principal.UserID)
+	if err != nil {
+		return fmt.Errorf("пользователь не найден: %v", err)
+	}
+
+	res := db.GetDB().Model(&groups.GroupUsers{}).
+		Where("group_id = ? AND user_id = ?", groupID, user.ID).
+		Update("announcements_notify", level)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("вы не состоите в этой группе")
+	}
+	return nil
+}
+
+func checkPinnedAnnouncementsLimit(tx *gorm.DB, groupID uint) error {
+	var pinnedCount int64
+	if err := tx.Model(&groups.GroupAnnouncement{}).
+		Where("group_id = ? AND pinned_at IS NOT NULL", groupID).
+		Count(&pinnedCount).Error; err != nil {
+		return err
+	}
+	if pinnedCount >= maxPinnedAnnouncements {
+		return fmt.Errorf("можно закрепить не больше %d объявлений, сначала открепите одно из них", maxPinnedAnnouncements)
+	}
+	return nil
+}
+
+// queueAnnouncementNotifications создаёт уведомления участникам с подходящей настройкой, кроме автора
+func queueAnnouncementNotifications(tx *gorm.DB, group groups.Group, announcement groups.GroupAnnouncement, levels []string) error {
+	var userIDs []uint
+	query := tx.Model(&groups.GroupUsers{}).
+		Where("group_id = ? AND announcements_notify IN ?", group.ID, levels)
+	if announcement.AuthorID != nil {
+		query = query.Where("user_id <> ?", *announcement.AuthorID)
+	}
+	if err := query.Pluck("user_id", &userIDs).Error; err != nil {
+		return err
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	imageURL := group.Image
+	if len(announcement.Images) > 0 {
+		imageURL = announcement.Images[0]
+	}
+	return queueNotifications(tx, userIDs, announcementNotificationType, "Объявление в группе",
+		group.Name, announcement.Title, imageURL)
+}
+
+func buildAnnouncementResponse(a groups.GroupAnnouncement) AnnouncementResponse {
+	images := a.Images
+	if images == nil {
+		images = []string{}
+	}
+	return AnnouncementResponse{
+		ID:          a.ID,
+		GroupID:     a.GroupID,
+		Title:       a.Title,
+		Text:        a.Text,
+		Images:      images,
+		Pinned:      a.PinnedAt != nil,
+		PinnedAt:    a.PinnedAt,
+		AuthorID:    a.AuthorID,
+		AuthorName:  a.Author.Name,
+		AuthorUs:    a.Author.Us,
+		AuthorImage: a.Author.Image,
+		CreatedAt:   a.CreatedAt,
+	}
+}
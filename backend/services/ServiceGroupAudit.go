@@ -0,0 +1,251 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"friendship/db"
+	"friendship/models"
+	"friendship/models/groups"
+
+	"gorm.io/gorm"
+)
+
+// Журнал аудита группы: кто, что и над чем сделал, со снимком изменённых полей до и после.
+// Запись идёт в той же транзакции, что и само действие, поэтому журнал не расходится с данными.
+
+const (
+	AuditGroupUpdated = "group.updated"
+	AuditGroupDeleted = "group.deleted"
+
+	AuditQuestionnaireUpdated = "group.questionnaire_updated"
+
+	AuditMemberRemoved     = "member.removed"
+	AuditMemberRoleChanged = "member.role_changed"
+	AuditMemberBanned      = "member.banned"
+	AuditMemberUnbanned    = "member.unbanned"
+
+	AuditOwnershipRequested = "ownership.transfer_requested"
+	AuditOwnershipCancelled = "ownership.transfer_cancelled"
+	AuditOwnershipAccepted  = "ownership.transfer_accepted"
+	AuditOwnershipAutoMoved = "ownership.auto_reassigned"
+
+	AuditRequestApproved     = "request.approved"
+	AuditRequestRejected     = "request.rejected"
+	AuditRequestsApprovedAll = "request.approved_all"
+	AuditRequestsRejectedAll = "request.rejected_all"
+	AuditInviteSent          = "invite.sent"
+
+	AuditInviteLinkCreated = "invite_link.created"
+	AuditInviteLinkRevoked = "invite_link.revoked"
+
+	AuditSessionCreated = "session.created"
+	AuditSessionUpdated = "session.updated"
+	AuditSessionDeleted = "session.deleted"
+
+	AuditAnnouncementCreated  = "announcement.created"
+	AuditAnnouncementPinned   = "announcement.pinned"
+	AuditAnnouncementUnpinned = "announcement.unpinned"
+	AuditAnnouncementDeleted  = "announcement.deleted"
+
+	auditPageSizeDefault = 20
+	auditPageSizeMax     = 100
+)
+
+type groupAuditEntry struct {
+	GroupID    uint
+	ActorID    uint // 0 — действие системы
+	Action     string
+	TargetType string
+	TargetID   uint
+	Before     interface{}
+	After      interface{}
+}
+
+type GroupAuditFilter struct {
+	Action     string
+	ActorID    uint
+	TargetType string
+	TargetID   uint
+	From       *time.Time
+	To         *time.Time
+	Page       int
+	PageSize   int
+}
+
+type GroupAuditItem struct {
+	ID         uint            `json:"id"`
+	Action     string          `json:"action"`
+	ActorID    *uint           `json:"actor_id"`
+	ActorName  string          `json:"actor_name"`
+	ActorUs    string          `json:"actor_us"`
+	TargetType string          `json:"target_type"`
+	TargetID   *uint           `json:"target_id"`
+	Before     json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After      json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type GroupAuditPage struct {
+	Items    []GroupAuditItem `json:"items"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"page_size"`
+	Total    int64            `json:"total"`
+}
+
+// recordGroupAudit добавляет запись в журнал в рамках транзакции tx
+func recordGroupAudit(tx *gorm.DB, entry groupAuditEntry) error {
+	rec := groups.GroupAuditLog{
+		GroupID:    entry.GroupID,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+	}
+	if entry.ActorID != 0 {
+		actorID := entry.ActorID
+		rec.ActorID = &actorID
+	}
+	if entry.TargetID != 0 {
+		targetID := entry.TargetID
+		rec.TargetID = &targetID
+	}
+
+	var err error
+	if rec.Before, err = auditJSON(entry.Before); err != nil {
+		return err
+	}
+	if rec.After, err = auditJSON(entry.After); err != nil {
+		return err
+	}
+
+	if err := tx.Create(&rec).Error; err != nil {
+		return fmt.Errorf("не удалось записать действие в журнал: %v", err)
+	}
+	return nil
+}
+
+func auditJSON(v interface{}) (*string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if rv := reflect.ValueOf(v); (rv.Kind() == reflect.Map || rv.Kind() == reflect.Ptr) && rv.IsNil() {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сериализовать запись журнала: %v", err)
+	}
+	s := string(data)
+	return &s, nil
+}
+
+// auditDiff оставляет только поля, значение которых изменилось
+func auditDiff(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	b := make(map[string]interface{})
+	a := make(map[string]interface{})
+	for key, newValue := range after {
+		oldValue := before[key]
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		b[key] = oldValue
+		a[key] = newValue
+	}
+	return b, a
+}
+
+// GetGroupAuditLog возвращает страницу журнала группы, новые записи первыми
+func GetGroupAuditLog(groupID uint, filter GroupAuditFilter) (*GroupAuditPage, error) {
+	database := db.GetDB()
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 {
+		filter.PageSize = auditPageSizeDefault
+	}
+	if filter.PageSize > auditPageSizeMax {
+		filter.PageSize = auditPageSizeMax
+	}
+
+	query := database.Model(&groups.GroupAuditLog{}).Where("group_id = ?", groupID)
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var records []groups.GroupAuditLog
+	if err := query.Order("created_at DESC, id DESC").
+		Limit(filter.PageSize).
+		Offset((filter.Page - 1) * filter.PageSize).
+		Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	actorIDs := make([]uint, 0, len(records))
+	for _, r := range records {
+		if r.ActorID != nil {
+			actorIDs = append(actorIDs, *r.ActorID)
+		}
+	}
+	actors := make(map[uint]models.User, len(actorIDs))
+	if len(actorIDs) > 0 {
+		var users []models.User
+		if err := database.Select("id, name, us").Where("id IN ?", actorIDs).Find(&users).Error; err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			actors[u.ID] = u
+		}
+	}
+
+	items := make([]GroupAuditItem, 0, len(records))
+	for _, r := range records {
+		item := GroupAuditItem{
+			ID:         r.ID,
+			Action:     r.Action,
+			ActorID:    r.ActorID,
+			TargetType: r.TargetType,
+			TargetID:   r.TargetID,
+			CreatedAt:  r.CreatedAt,
+		}
+		if r.ActorID != nil {
+			item.ActorName = actors[*r.ActorID].Name
+			item.ActorUs = actors[*r.ActorID].Us
+		}
+		if r.Before != nil {
+			item.Before = json.RawMessage(*r.Before)
+		}
+		if r.After != nil {
+			item.After = json.RawMessage(*r.After)
+		}
+		items = append(items, item)
+	}
+
+	return &GroupAuditPage{
+		Items:    items,
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+		Total:    total,
+	}, nil
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"friendship/db"
+	"friendship/models"
+	"friendship/models/groups"
+
+	"gorm.io/gorm"
+)
+
+// Блокировки в группе: заблокированный пользователь исключается из группы и не может
+// вступить снова, получить приглашение или записаться на сессии группы, пока блокировка действует.
+
+const maxGroupBanHours = 24 * 365 * 5
+
+type BanUserInput struct {
+	UserID         uint   `json:"user_id" binding:"required"`
+	Reason         string `json:"reason" binding:"required,max=500"`
+	ExpiresInHours *uint  `json:"expires_in_hours"` // nil — бессрочно
+}
+
+type GroupBanResponse struct {
+	ID         uint       `json:"id"`
+	UserID     uint       `json:"user_id"`
+	Name       string     `json:"name"`
+	Us         string     `json:"us"`
+	Image      string     `json:"image"`
+	Reason     string     `json:"reason"`
+	BannedByID *uint      `json:"banned_by_id"`
+	BannedBy   string     `json:"banned_by"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LiftedAt   *time.Time `json:"lifted_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func activeBanScope(tx *gorm.DB, now time.Time) *gorm.DB {
+	return tx.Where("lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", now)
+}
+
+// checkNotBannedInGroup возвращает ошибку, если у пользователя есть действующая блокировка в группе
+func checkNotBannedInGroup(tx *gorm.DB, groupID, userID uint) error {
+	var ban groups.GroupBan
+	err := activeBanScope(tx, time.Now()).
+		Where("group_id = ? AND user_id = ?", groupID, userID).
+		Order("expires_at DESC NULLS FIRST").
+		First(&ban).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if ban.ExpiresAt != nil {
+		return fmt.Errorf("пользователь заблокирован в этой группе до %s", ban.ExpiresAt.Format("02.01.2006 15:04"))
+	}
+	return errors.New("пользователь заблокирован в этой группе")
+}
+
+// bannedUserIDs возвращает пользователей из списка с действующей блокировкой в группе
+func bannedUserIDs(tx *gorm.DB, groupID uint, userIDs []uint) (map[uint]struct{}, error) {
+	result := make(map[uint]struct{})
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+	var ids []uint
+	if err := activeBanScope(tx.Model(&groups.GroupBan{}), time.Now()).
+		Where("group_id = ? AND user_id IN ?", groupID, userIDs).
+		Distinct().Pluck("user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		result[id] = struct{}{}
+	}
+	return result, nil
+}
+
+// BanUserInGroup блокирует пользователя: исключает из группы, отклоняет его заявки и приглашения
+func BanUserInGroup(principal models.Principal, groupID uint, input BanUserInput) (*groups.GroupBan, error) {
+	database := db.GetDB()
+
+	var moderator models.User
+	if err := database.First(&moderator, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+	if moderator.ID == input.UserID {
+		return nil, errors.New("нельзя заблокировать самого себя")
+	}
+
+	var target models.User
+	if err := database.First(&target, input.UserID).Error; err != nil {
+		return nil, errors.New("блокируемый пользователь не найден")
+	}
+
+	var ban groups.GroupBan
+	err := database.Transaction(func(tx *gorm.DB) error {
+		moderatorRole, err := requireGroupCapability(tx, moderator.ID, groupID, groups.CapRemoveMembers)
+		if err != nil {
+			return err
+		}
+
+		var group groups.Group
+		if err := tx.First(&group, groupID).Error; err != nil {
+			return errors.New("группа не найдена")
+		}
+		if group.CreaterID == input.UserID {
+			return errors.New("нельзя заблокировать владельца группы")
+		}
+
+		var before map[string]interface{}
+		if targetRole, err := getUserRoleTx(tx, input.UserID, groupID); err == nil {
+			before = map[string]interface{}{"role": targetRole}
+			if group.CreaterID != moderator.ID && groups.RoleRank(targetRole) >= groups.RoleRank(moderatorRole) {
+				return errors.New("нельзя заблокировать участника с такой же или более высокой ролью")
+			}
+		}
+
+		var activeBans int64
+		if err := activeBanScope(tx.Model(&groups.GroupBan{}), time.Now()).
+			Where("group_id = ? AND user_id = ?", groupID, input.UserID).
+			Count(&activeBans).Error; err != nil {
+			return err
+		}
+		if activeBans > 0 {
+			return errors.New("пользователь уже заблокирован")
+		}
+
+		moderatorID := moderator.ID
+		ban = groups.GroupBan{
+			GroupID:    groupID,
+			UserID:     input.UserID,
+			BannedByID: &moderatorID,
+			Reason:     input.Reason,
+		}
+		if input.ExpiresInHours != nil {
+			if *input.ExpiresInHours == 0 || *input.ExpiresInHours > maxGroupBanHours {
+				return fmt.Errorf("срок блокировки должен быть от 1 до %d часов", maxGroupBanHours)
+			}
+			expiresAt := time.Now().Add(time.Duration(*input.ExpiresInHours) * time.Hour)
+			ban.ExpiresAt = &expiresAt
+		}
+		if err := tx.Create(&ban).Error; err != nil {
+			return fmt.Errorf("не удалось создать блокировку: %v", err)
+		}
+
+		if err := removeGroupMemberTx(tx, groupID, input.UserID, "banned"); err != nil {
+			return err
+		}
+		if err := tx.Model(&groups.GroupJoinRequest{}).
+			Where("group_id = ? AND user_id = ? AND status = ?", groupID, input.UserID, "pending").
+			Update("status", "rejected").Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&groups.GroupJoinInvite{}).
+			Where("group_id = ? AND user_id = ? AND status = ?", groupID, input.UserID, "pending").
+			Update("status", "rejected").Error; err != nil {
+			return err
+		}
+		return recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    groupID,
+			ActorID:    moderator.ID,
+			Action:     AuditMemberBanned,
+			TargetType: "user",
+			TargetID:   input.UserID,
+			Before:     before,
+			After:      map[string]interface{}{"ban_id": ban.ID, "reason": ban.Reason, "expires_at": ban.ExpiresAt},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ban, nil
+}
+
+// GetGroupBans возвращает блокировки группы; withHistory — включая снятые и истёкшие
+func GetGroupBans(groupID uint, withHistory bool) ([]GroupBanResponse, error) {
+	database := db.GetDB()
+
+	query := database.Preload("User").Preload("BannedBy").Where("group_id = ?", groupID)
+	if !withHistory {
+		query = activeBanScope(query, time.Now())
+	}
+
+	var bans []groups.GroupBan
+	if err := query.Order("created_at DESC").Find(&bans).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]GroupBanResponse, 0, len(bans))
+	for _, b := range bans {
+		result = append(result, GroupBanResponse{
+			ID:         b.ID,
+			UserID:     b.UserID,
+			Name:       b.User.Name,
+			Us:         b.User.Us,
+			Image:      b.User.Image,
+			Reason:     b.Reason,
+			BannedByID: b.BannedByID,
+			BannedBy:   b.BannedBy.Name,
+			ExpiresAt:  b.ExpiresAt,
+			LiftedAt:   b.LiftedAt,
+			CreatedAt:  b.CreatedAt,
+		})
+	}
+	return result, nil
+}
+
+// LiftGroupBan снимает действующую блокировку пользователя. Вернуться в группу он сможет сам.
+func LiftGroupBan(principal models.Principal, groupID, userID uint) error {
+	database := db.GetDB()
+
+	var moderator models.User
+	if err := database.First(&moderator, principal.UserID).Error; err != nil {
+		return errors.New("пользователь не найден")
+	}
+
+	return database.Transaction(func(tx *gorm.DB) error {
+		res := activeBanScope(tx.Model(&groups.GroupBan{}), time.Now()).
+			Where("group_id = ? AND user_id = ?", groupID, userID).
+			Updates(map[string]interface{}{"lifted_at": time.Now(), "lifted_by_id": moderator.ID})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("действующая блокировка не найдена")
+		}
+		return recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    groupID,
+			ActorID:    moderator.ID,
+			Action:     AuditMemberUnbanned,
+			TargetType: "user",
+			TargetID:   userID,
+		})
+	})
+}
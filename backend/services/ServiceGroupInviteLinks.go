@@ -0,0 +1,329 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"friendship/db"
+	"friendship/models"
+	"friendship/models/groups"
+	"friendship/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ссылки-приглашения: админ создаёт токен с необязательным сроком действия, лимитом использований
+// и автоодобрением. Переход по ссылке работает как JoinGroup, но для ссылки с автоодобрением
+// пользователь попадает в закрытую группу сразу, без заявки.
+// PUBLIC_APP_URL — адрес фронтенда для сборки полной ссылки, например https://friendsheep.ru
+
+const (
+	inviteLinkTokenLength = 32
+	maxInviteLinkTTLHours = 24 * 365
+)
+
+type CreateInviteLinkInput struct {
+	ExpiresInHours *uint `json:"expires_in_hours"` // nil — бессрочная
+	MaxUses        uint  `json:"max_uses"`         // 0 — без лимита
+	AutoApprove    bool  `json:"auto_approve"`
+}
+
+type InviteLinkResponse struct {
+	ID               uint       `json:"id"`
+	Token            string     `json:"token"`
+	URL              string     `json:"url"`
+	TelegramShareURL string     `json:"telegram_share_url"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	MaxUses          uint       `json:"max_uses"`
+	Uses             uint       `json:"uses"`
+	Joined           int64      `json:"joined"`
+	Requested        int64      `json:"requested"`
+	AutoApprove      bool       `json:"auto_approve"`
+	Active           bool       `json:"active"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+	CreatedByID      *uint      `json:"created_by_id"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+type InviteLinkPreview struct {
+	GroupID          uint   `json:"group_id"`
+	GroupName        string `json:"group_name"`
+	GroupImage       string `json:"group_image"`
+	SmallDescription string `json:"small_description"`
+	IsPrivate        bool   `json:"is_private"`
+	AutoApprove      bool   `json:"auto_approve"`
+	MemberCount      int64  `json:"member_count"`
+}
+
+// inviteLinkURL собирает ссылку для фронтенда; без PUBLIC_APP_URL возвращается относительный путь
+func inviteLinkURL(token string) string {
+	base := strings.TrimRight(os.Getenv("PUBLIC_APP_URL"), "/")
+	return base + "/invite/" + token
+}
+
+func inviteLinkActive(link *groups.GroupInviteLink, now time.Time) error {
+	if link.RevokedAt != nil {
+		return errors.New("ссылка отозвана")
+	}
+	if link.ExpiresAt != nil && now.After(*link.ExpiresAt) {
+		return errors.New("срок действия ссылки истёк")
+	}
+	if link.MaxUses > 0 && link.Uses >= link.MaxUses {
+		return errors.New("лимит использований ссылки исчерпан")
+	}
+	return nil
+}
+
+// CreateInviteLink создаёт новую ссылку-приглашение в группу
+func CreateInviteLink(principal models.Principal, groupID uint, input CreateInviteLinkInput) (*InviteLinkResponse, error) {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+
+	if _, err := requireGroupCapability(database, user.ID, groupID, groups.CapApproveRequests); err != nil {
+		return nil, err
+	}
+
+	var group groups.Group
+	if err := database.First(&group, groupID).Error; err != nil {
+		return nil, errors.New("группа не найдена")
+	}
+	if group.ArchivedAt != nil {
+		return nil, errors.New("группа в архиве")
+	}
+
+	link := groups.GroupInviteLink{
+		GroupID:     groupID,
+		CreatedByID: &user.ID,
+		Token:       utils.GenerateSessioID(inviteLinkTokenLength),
+		MaxUses:     input.MaxUses,
+		AutoApprove: input.AutoApprove,
+	}
+	if input.ExpiresInHours != nil {
+		if *input.ExpiresInHours == 0 || *input.ExpiresInHours > maxInviteLinkTTLHours {
+			return nil, fmt.Errorf("срок действия должен быть от 1 до %d часов", maxInviteLinkTTLHours)
+		}
+		expiresAt := time.Now().Add(time.Duration(*input.ExpiresInHours) * time.Hour)
+		link.ExpiresAt = &expiresAt
+	}
+
+	if err := database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&link).Error; err != nil {
+			return err
+		}
+		return recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    groupID,
+			ActorID:    user.ID,
+			Action:     AuditInviteLinkCreated,
+			TargetType: "invite_link",
+			TargetID:   link.ID,
+			After: map[string]interface{}{
+				"expires_at": link.ExpiresAt, "max_uses": link.MaxUses, "auto_approve": link.AutoApprove,
+			},
+		})
+	}); err != nil {
+		return nil, fmt.Errorf("не удалось создать ссылку: %v", err)
+	}
+
+	response := buildInviteLinkResponse(link, group.Name, 0, 0)
+	return &response, nil
+}
+
+// GetGroupInviteLinks возвращает все ссылки группы со статистикой использования
+func GetGroupInviteLinks(groupID uint) ([]InviteLinkResponse, error) {
+	database := db.GetDB()
+
+	var group groups.Group
+	if err := database.First(&group, groupID).Error; err != nil {
+		return nil, errors.New("группа не найдена")
+	}
+
+	var links []groups.GroupInviteLink
+	if err := database.Where("group_id = ?", groupID).Order("created_at DESC").Find(&links).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]InviteLinkResponse, 0, len(links))
+	if len(links) == 0 {
+		return result, nil
+	}
+
+	linkIDs := make([]uint, len(links))
+	for i, l := range links {
+		linkIDs[i] = l.ID
+	}
+
+	var usage []struct {
+		LinkID    uint
+		Joined    int64
+		Requested int64
+	}
+	if err := database.Model(&groups.GroupInviteLinkUse{}).
+		Select("link_id, COUNT(*) FILTER (WHERE joined) AS joined, COUNT(*) FILTER (WHERE NOT joined) AS requested").
+		Where("link_id IN ?", linkIDs).
+		Group("link_id").
+		Scan(&usage).Error; err != nil {
+		return nil, err
+	}
+	usageByLink := make(map[uint][2]int64, len(usage))
+	for _, u := range usage {
+		usageByLink[u.LinkID] = [2]int64{u.Joined, u.Requested}
+	}
+
+	for _, l := range links {
+		u := usageByLink[l.ID]
+		result = append(result, buildInviteLinkResponse(l, group.Name, u[0], u[1]))
+	}
+	return result, nil
+}
+
+func buildInviteLinkResponse(link groups.GroupInviteLink, groupName string, joined, requested int64) InviteLinkResponse {
+	linkURL := inviteLinkURL(link.Token)
+	shareText := fmt.Sprintf("Присоединяйтесь к группе «%s»", groupName)
+
+	return InviteLinkResponse{
+		ID:               link.ID,
+		Token:            link.Token,
+		URL:              linkURL,
+		TelegramShareURL: "https://t.me/share/url?url=" + url.QueryEscape(linkURL) + "&text=" + url.QueryEscape(shareText),
+		ExpiresAt:        link.ExpiresAt,
+		MaxUses:          link.MaxUses,
+		Uses:             link.Uses,
+		Joined:           joined,
+		Requested:        requested,
+		AutoApprove:      link.AutoApprove,
+		Active:           inviteLinkActive(&link, time.Now()) == nil,
+		RevokedAt:        link.RevokedAt,
+		CreatedByID:      link.CreatedByID,
+		CreatedAt:        link.CreatedAt,
+	}
+}
+
+// RevokeInviteLink отзывает ссылку; уже вступившие участники остаются в группе
+func RevokeInviteLink(principal models.Principal, groupID, linkID uint) error {
+	database := db.GetDB()
+
+	actorID := principal.UserID
+
+	return database.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&groups.GroupInviteLink{}).
+			Where("id = ? AND group_id = ? AND revoked_at IS NULL", linkID, groupID).
+			Update("revoked_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("ссылка не найдена или уже отозвана")
+		}
+		return recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    groupID,
+			ActorID:    actorID,
+			Action:     AuditInviteLinkRevoked,
+			TargetType: "invite_link",
+			TargetID:   linkID,
+		})
+	})
+}
+
+// GetInviteLinkPreview показывает, в какую группу ведёт ссылка, до вступления
+func GetInviteLinkPreview(token string) (*InviteLinkPreview, error) {
+	database := db.GetDB()
+
+	var link groups.GroupInviteLink
+	if err := database.Preload("Group").Where("token = ?", token).First(&link).Error; err != nil {
+		return nil, errors.New("ссылка не найдена")
+	}
+	if err := inviteLinkActive(&link, time.Now()); err != nil {
+		return nil, err
+	}
+	if link.Group.ArchivedAt != nil {
+		return nil, errors.New("группа в архиве")
+	}
+
+	var memberCount int64
+	if err := database.Model(&groups.GroupUsers{}).Where("group_id = ?", link.GroupID).Count(&memberCount).Error; err != nil {
+		return nil, err
+	}
+
+	return &InviteLinkPreview{
+		GroupID:          link.Group.ID,
+		GroupName:        link.Group.Name,
+		GroupImage:       link.Group.Image,
+		SmallDescription: link.Group.SmallDescription,
+		IsPrivate:        link.Group.IsPrivate,
+		AutoApprove:      link.AutoApprove,
+		MemberCount:      memberCount,
+	}, nil
+}
+
+// RedeemInviteLink вступает в группу по ссылке от имени пользователя из JWT.
+// answers нужны, если ссылка без автоодобрения ведёт в закрытую группу с анкетой.
+func RedeemInviteLink(principal models.Principal, token string, answers []JoinAnswerInput) (*JoinGroupResult, error) {
+	var user models.User
+	if err := db.GetDB().First(&user, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+	return redeemInviteLink(user, token, answers)
+}
+
+// RedeemInviteLinkByTelegram — вступление по ссылке, опубликованной в Telegram-чате, через бота.
+// Работает для пользователей, привязавших Telegram к аккаунту. Ответить на анкету через бота нельзя,
+// поэтому в группу с обязательными вопросами нужно вступать из приложения.
+func RedeemInviteLinkByTelegram(telegramID, token string) (*JoinGroupResult, error) {
+	if telegramID == "" {
+		return nil, errors.New("не указан TelegramID")
+	}
+	var user models.User
+	if err := db.GetDB().Where("telegram_id = ?", telegramID).First(&user).Error; err != nil {
+		return nil, errors.New("пользователь не найден, привяжите Telegram в профиле")
+	}
+	return redeemInviteLink(user, token, nil)
+}
+
+func redeemInviteLink(user models.User, token string, answers []JoinAnswerInput) (*JoinGroupResult, error) {
+	var result *JoinGroupResult
+
+	err := db.GetDB().Transaction(func(tx *gorm.DB) error {
+		var link groups.GroupInviteLink
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token = ?", token).First(&link).Error; err != nil {
+			return errors.New("ссылка не найдена")
+		}
+		if err := inviteLinkActive(&link, time.Now()); err != nil {
+			return err
+		}
+
+		var group groups.Group
+		if err := tx.First(&group, link.GroupID).Error; err != nil {
+			return errors.New("группа не найдена")
+		}
+
+		res, err := joinGroupAsUser(tx, user, group, link.AutoApprove, answers)
+		if err != nil {
+			return err
+		}
+		result = res
+
+		if err := tx.Model(&link).Update("uses", gorm.Expr("uses + 1")).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&groups.GroupInviteLinkUse{
+			LinkID: link.ID,
+			UserID: user.ID,
+			Joined: res.Joined,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
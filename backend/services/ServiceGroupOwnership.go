@@ -0,0 +1,335 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"friendship/db"
+	"friendship/models"
+	"friendship/models/groups"
+
+	"gorm.io/gorm"
+)
+
+// Передача владения группой идёт в два шага: владелец создаёт запрос, новый владелец его подтверждает.
+// Если группу покидает последний администратор, она не остаётся без управления:
+// администратором становится самый давний оператор или участник, а если никого нет — группа архивируется.
+
+const (
+	OwnershipTransferPending   = "pending"
+	OwnershipTransferAccepted  = "accepted"
+	OwnershipTransferDeclined  = "declined"
+	OwnershipTransferCancelled = "cancelled"
+
+	ownershipTransferTTL = 7 * 24 * time.Hour
+)
+
+type TransferOwnershipInput struct {
+	UserID uint `json:"user_id" binding:"required"`
+}
+
+type OwnershipTransferResponse struct {
+	ID         uint      `json:"id"`
+	GroupID    uint      `json:"group_id"`
+	GroupName  string    `json:"group_name"`
+	GroupImage string    `json:"group_image"`
+	FromUserID uint      `json:"from_user_id"`
+	FromName   string    `json:"from_name"`
+	FromUs     string    `json:"from_us"`
+	ToUserID   uint      `json:"to_user_id"`
+	Status     string    `json:"status"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// RequestOwnershipTransfer создаёт запрос на передачу группы. Предыдущий незавершённый запрос отменяется.
+func RequestOwnershipTransfer(principal models.Principal, groupID uint, input TransferOwnershipInput) (*groups.GroupOwnershipTransfer, error) {
+	database := db.GetDB()
+
+	var requester models.User
+	if err := database.First(&requester, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+	if requester.ID == input.UserID {
+		return nil, errors.New("вы уже владелец группы")
+	}
+
+	var transfer groups.GroupOwnershipTransfer
+	err := database.Transaction(func(tx *gorm.DB) error {
+		var group groups.Group
+		if err := tx.First(&group, groupID).Error; err != nil {
+			return errors.New("группа не найдена")
+		}
+		if group.CreaterID != requester.ID {
+			return errors.New("передать группу может только её владелец")
+		}
+
+		var target groups.GroupUsers
+		if err := tx.Where("user_id = ? AND group_id = ?", input.UserID, groupID).First(&target).Error; err != nil {
+			return errors.New("новый владелец должен состоять в группе")
+		}
+
+		if err := tx.Model(&groups.GroupOwnershipTransfer{}).
+			Where("group_id = ? AND status = ?", groupID, OwnershipTransferPending).
+			Updates(map[string]interface{}{"status": OwnershipTransferCancelled, "responded_at": time.Now()}).Error; err != nil {
+			return err
+		}
+
+		transfer = groups.GroupOwnershipTransfer{
+			GroupID:    groupID,
+			FromUserID: requester.ID,
+			ToUserID:   input.UserID,
+			Status:     OwnershipTransferPending,
+			ExpiresAt:  time.Now().Add(ownershipTransferTTL),
+		}
+		if err := tx.Create(&transfer).Error; err != nil {
+			return err
+		}
+		return recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    groupID,
+			ActorID:    requester.ID,
+			Action:     AuditOwnershipRequested,
+			TargetType: "transfer",
+			TargetID:   transfer.ID,
+			After:      map[string]interface{}{"to_user_id": input.UserID, "expires_at": transfer.ExpiresAt},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &transfer, nil
+}
+
+// CancelOwnershipTransfer отменяет незавершённый запрос владельца
+func CancelOwnershipTransfer(principal models.Principal, groupID uint) error {
+	database := db.GetDB()
+
+	var requester models.User
+	if err := database.First(&requester, principal.UserID).Error; err != nil {
+		return errors.New("пользователь не найден")
+	}
+
+	return database.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&groups.GroupOwnershipTransfer{}).
+			Where("group_id = ? AND from_user_id = ? AND status = ?", groupID, requester.ID, OwnershipTransferPending).
+			Updates(map[string]interface{}{"status": OwnershipTransferCancelled, "responded_at": time.Now()})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("нет активного запроса на передачу группы")
+		}
+		return recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    groupID,
+			ActorID:    requester.ID,
+			Action:     AuditOwnershipCancelled,
+			TargetType: "group",
+			TargetID:   groupID,
+		})
+	})
+}
+
+// GetIncomingOwnershipTransfers возвращает запросы, ожидающие подтверждения пользователем
+func GetIncomingOwnershipTransfers(principal models.Principal) ([]OwnershipTransferResponse, error) {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+
+	var transfers []groups.GroupOwnershipTransfer
+	if err := database.Preload("Group").Preload("FromUser").
+		Where("to_user_id = ? AND status = ? AND expires_at > ?", user.ID, OwnershipTransferPending, time.Now()).
+		Order("created_at DESC").
+		Find(&transfers).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]OwnershipTransferResponse, 0, len(transfers))
+	for _, t := range transfers {
+		result = append(result, OwnershipTransferResponse{
+			ID:         t.ID,
+			GroupID:    t.GroupID,
+			GroupName:  t.Group.Name,
+			GroupImage: t.Group.Image,
+			FromUserID: t.FromUserID,
+			FromName:   t.FromUser.Name,
+			FromUs:     t.FromUser.Us,
+			ToUserID:   t.ToUserID,
+			Status:     t.Status,
+			ExpiresAt:  t.ExpiresAt,
+			CreatedAt:  t.CreatedAt,
+		})
+	}
+	return result, nil
+}
+
+// checkOwnershipTransferResponse проверяет, может ли userID ответить на запрос в момент now
+func checkOwnershipTransferResponse(transfer groups.GroupOwnershipTransfer, userID uint, now time.Time) error {
+	if transfer.ToUserID != userID {
+		return errors.New("у вас нет доступа к этому запросу")
+	}
+	if transfer.Status != OwnershipTransferPending {
+		return errors.New("запрос уже обработан")
+	}
+	if now.After(transfer.ExpiresAt) {
+		return errors.New("срок действия запроса истёк")
+	}
+	return nil
+}
+
+// RespondOwnershipTransfer принимает или отклоняет запрос. При принятии новый владелец становится администратором,
+// прежний остаётся администратором.
+func RespondOwnershipTransfer(principal models.Principal, transferID uint, accept bool) error {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return errors.New("пользователь не найден")
+	}
+
+	return database.Transaction(func(tx *gorm.DB) error {
+		var transfer groups.GroupOwnershipTransfer
+		if err := tx.First(&transfer, transferID).Error; err != nil {
+			return errors.New("запрос на передачу не найден")
+		}
+
+		now := time.Now()
+		if err := checkOwnershipTransferResponse(transfer, user.ID, now); err != nil {
+			return err
+		}
+
+		if !accept {
+			return tx.Model(&transfer).Updates(map[string]interface{}{
+				"status": OwnershipTransferDeclined, "responded_at": now,
+			}).Error
+		}
+
+		var group groups.Group
+		if err := tx.First(&group, transfer.GroupID).Error; err != nil {
+			return errors.New("группа не найдена")
+		}
+		if group.CreaterID != transfer.FromUserID {
+			return errors.New("владелец группы уже сменился, запрос недействителен")
+		}
+
+		var target groups.GroupUsers
+		if err := tx.Where("user_id = ? AND group_id = ?", user.ID, group.ID).First(&target).Error; err != nil {
+			return errors.New("вы больше не состоите в группе")
+		}
+
+		previousRole := target.RoleInGroup
+		if err := tx.Model(&target).Update("role_in_group", groups.RoleAdmin).Error; err != nil {
+			return fmt.Errorf("не удалось назначить администратора: %v", err)
+		}
+		if err := tx.Model(&group).Update("creater_id", user.ID).Error; err != nil {
+			return fmt.Errorf("не удалось передать владение: %v", err)
+		}
+		if err := tx.Model(&transfer).Updates(map[string]interface{}{
+			"status": OwnershipTransferAccepted, "responded_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    group.ID,
+			ActorID:    user.ID,
+			Action:     AuditOwnershipAccepted,
+			TargetType: "transfer",
+			TargetID:   transfer.ID,
+			Before:     map[string]interface{}{"owner_id": transfer.FromUserID, "role": previousRole},
+			After:      map[string]interface{}{"owner_id": user.ID, "role": groups.RoleAdmin},
+		})
+	})
+}
+
+// ensureGroupHasAdmin вызывается после выхода пользователя leftUserID из группы.
+// Если ушёл владелец — владение переходит самому давнему администратору.
+// Если администраторов не осталось — повышается самый давний оператор, затем участник.
+// Если в группе никого нет — она архивируется.
+func ensureGroupHasAdmin(tx *gorm.DB, groupID, leftUserID uint) error {
+	var group groups.Group
+	if err := tx.First(&group, groupID).Error; err != nil {
+		return errors.New("группа не найдена")
+	}
+
+	// Незавершённые запросы от ушедшего или к ушедшему больше не имеют смысла
+	if err := tx.Model(&groups.GroupOwnershipTransfer{}).
+		Where("group_id = ? AND status = ? AND (from_user_id = ? OR to_user_id = ?)",
+			groupID, OwnershipTransferPending, leftUserID, leftUserID).
+		Updates(map[string]interface{}{"status": OwnershipTransferCancelled, "responded_at": time.Now()}).Error; err != nil {
+		return err
+	}
+
+	var successor groups.GroupUsers
+	err := tx.Where("group_id = ? AND user_id <> ?", groupID, leftUserID).
+		Order(gorm.Expr("CASE role_in_group WHEN ? THEN 0 WHEN ? THEN 1 ELSE 2 END", groups.RoleAdmin, groups.RoleOperator)).
+		Order("joined_at ASC NULLS FIRST").
+		Order("id ASC").
+		First(&successor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Группа %d осталась без участников и переведена в архив", groupID)
+		return tx.Model(&group).Update("archived_at", time.Now()).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	before := map[string]interface{}{"owner_id": group.CreaterID, "role": successor.RoleInGroup}
+	changed := false
+
+	if successor.RoleInGroup != groups.RoleAdmin {
+		if err := tx.Model(&successor).Update("role_in_group", groups.RoleAdmin).Error; err != nil {
+			return err
+		}
+		log.Printf("Группа %d осталась без администратора, назначен пользователь %d", groupID, successor.UserID)
+		changed = true
+	}
+
+	ownerID := group.CreaterID
+	if group.CreaterID == leftUserID || group.CreaterID == 0 {
+		if err := tx.Model(&group).Update("creater_id", successor.UserID).Error; err != nil {
+			return err
+		}
+		ownerID = successor.UserID
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return recordGroupAudit(tx, groupAuditEntry{
+		GroupID:    groupID,
+		Action:     AuditOwnershipAutoMoved,
+		TargetType: "user",
+		TargetID:   successor.UserID,
+		Before:     before,
+		After:      map[string]interface{}{"owner_id": ownerID, "role": groups.RoleAdmin},
+	})
+}
+
+// handleOwnedGroupsBeforeAccountDeletion передаёт управление группами удаляемого пользователя,
+// чтобы после каскадного удаления его участий ни одна группа не осталась без администратора
+func handleOwnedGroupsBeforeAccountDeletion(tx *gorm.DB, userID uint) error {
+	var groupIDs []uint
+	if err := tx.Raw(`
+		SELECT gu.group_id FROM group_users gu WHERE gu.user_id = ? AND gu.role_in_group = ?
+		UNION
+		SELECT g.id FROM groups g WHERE g.creater_id = ?
+	`, userID, groups.RoleAdmin, userID).Scan(&groupIDs).Error; err != nil {
+		return err
+	}
+
+	for _, groupID := range groupIDs {
+		if err := removeGroupMemberTx(tx, groupID, userID, "left"); err != nil {
+			return err
+		}
+		if err := ensureGroupHasAdmin(tx, groupID, userID); err != nil {
+			return fmt.Errorf("не удалось передать управление группой %d: %w", groupID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"friendship/models/groups"
+	"testing"
+	"time"
+)
+
+func TestCheckOwnershipTransferResponse(t *testing.T) {
+	now := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	pending := groups.GroupOwnershipTransfer{
+		FromUserID: 1,
+		ToUserID:   2,
+		Status:     OwnershipTransferPending,
+		ExpiresAt:  now.Add(ownershipTransferTTL),
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(tr *groups.GroupOwnershipTransfer)
+		userID  uint
+		at      time.Time
+		wantErr bool
+	}{
+		{"получатель отвечает вовремя", nil, 2, now, false},
+		{"в последний момент", nil, 2, now.Add(ownershipTransferTTL), false},
+		{"отвечает не получатель", nil, 3, now, true},
+		{"отвечает сам владелец", nil, 1, now, true},
+		{"срок истёк", nil, 2, now.Add(ownershipTransferTTL + time.Second), true},
+		{"уже принят", func(tr *groups.GroupOwnershipTransfer) { tr.Status = OwnershipTransferAccepted }, 2, now, true},
+		{"отменён", func(tr *groups.GroupOwnershipTransfer) { tr.Status = OwnershipTransferCancelled }, 2, now, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transfer := pending
+			if tt.modify != nil {
+				tt.modify(&transfer)
+			}
+			err := checkOwnershipTransferResponse(transfer, tt.userID, tt.at)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkOwnershipTransferResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"friendship/db"
+	"friendship/models"
+	"friendship/models/groups"
+
+	"gorm.io/gorm"
+)
+
+// Анкета для вступления в закрытую группу: администратор задаёт вопросы, желающий вступить
+// отвечает на них вместе с заявкой, а администратор видит ответы при рассмотрении.
+// Для открытых групп и ссылок с автоодобрением анкета не используется.
+
+const (
+	maxJoinQuestions      = 10
+	maxJoinQuestionLength = 300
+	maxJoinChoiceOptions  = 10
+	maxJoinAnswerLength   = 1000
+	minJoinChoiceOptions  = 2
+	maxJoinOptionLength   = 100
+)
+
+type JoinQuestionInput struct {
+	Text     string   `json:"text"`
+	Type     string   `json:"type"` // "text" или "choice"
+	Options  []string `json:"options"`
+	Required bool     `json:"required"`
+}
+
+type SetQuestionnaireInput struct {
+	Questions []JoinQuestionInput `json:"questions"`
+}
+
+type JoinAnswerInput struct {
+	QuestionID uint   `json:"question_id" binding:"required"`
+	Answer     string `json:"answer"`
+}
+
+type JoinRequestAnswerRes struct {
+	QuestionID *uint  `json:"questionId"`
+	Question   string `json:"question"`
+	Answer     string `json:"answer"`
+}
+
+// GetGroupQuestionnaire возвращает вопросы анкеты группы по порядку
+func GetGroupQuestionnaire(groupID uint) ([]groups.GroupJoinQuestion, error) {
+	var group groups.Group
+	if err := db.GetDB().First(&group, groupID).Error; err != nil {
+		return nil, errors.New("группа не найдена")
+	}
+	return loadJoinQuestions(db.GetDB(), groupID)
+}
+
+func loadJoinQuestions(tx *gorm.DB, groupID uint) ([]groups.GroupJoinQuestion, error) {
+	questions := make([]groups.GroupJoinQuestion, 0)
+	if err := tx.Where("group_id = ?", groupID).Order("position ASC, id ASC").Find(&questions).Error; err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+// SetGroupQuestionnaire полностью заменяет анкету группы. Пустой список вопросов отключает анкету.
+// Ответы в уже поданных заявках сохраняются вместе с текстом вопроса.
+func SetGroupQuestionnaire(principal models.Principal, groupID uint, input SetQuestionnaireInput) ([]groups.GroupJoinQuestion, error) {
+	if len(input.Questions) > maxJoinQuestions {
+		return nil, fmt.Errorf("в анкете может быть не больше %d вопросов", maxJoinQuestions)
+	}
+
+	questions := make([]groups.GroupJoinQuestion, 0, len(input.Questions))
+	for i, q := range input.Questions {
+		question, err := buildJoinQuestion(groupID, i, q)
+		if err != nil {
+			return nil, fmt.Errorf("вопрос %d: %v", i+1, err)
+		}
+		questions = append(questions, question)
+	}
+
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+
+	err := database.Transaction(func(tx *gorm.DB) error {
+		if _, err := requireGroupCapability(tx, user.ID, groupID, groups.CapEditGroup); err != nil {
+			return err
+		}
+
+		previous, err := loadJoinQuestions(tx, groupID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("group_id = ?", groupID).Delete(&groups.GroupJoinQuestion{}).Error; err != nil {
+			return fmt.Errorf("не удалось обновить анкету: %v", err)
+		}
+		if len(questions) > 0 {
+			if err := tx.Create(&questions).Error; err != nil {
+				return fmt.Errorf("не удалось обновить анкету: %v", err)
+			}
+		}
+
+		return recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    groupID,
+			ActorID:    user.ID,
+			Action:     AuditQuestionnaireUpdated,
+			TargetType: "group",
+			TargetID:   groupID,
+			Before:     map[string]interface{}{"questions": questionnaireAuditSnapshot(previous)},
+			After:      map[string]interface{}{"questions": questionnaireAuditSnapshot(questions)},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return questions, nil
+}
+
+func buildJoinQuestion(groupID uint, position int, input JoinQuestionInput) (groups.GroupJoinQuestion, error) {
+	text := strings.TrimSpace(input.Text)
+	if text == "" {
+		return groups.GroupJoinQuestion{}, errors.New("текст вопроса не может быть пустым")
+	}
+	if utf8.RuneCountInString(text) > maxJoinQuestionLength {
+		return groups.GroupJoinQuestion{}, fmt.Errorf("текст вопроса длиннее %d символов", maxJoinQuestionLength)
+	}
+
+	question := groups.GroupJoinQuestion{
+		GroupID:  groupID,
+		Position: position,
+		Text:     text,
+		Required: input.Required,
+	}
+
+	switch input.Type {
+	case "", groups.QuestionTypeText:
+		if len(input.Options) > 0 {
+			return groups.GroupJoinQuestion{}, errors.New("варианты ответа задаются только для вопроса с выбором")
+		}
+		question.Type = groups.QuestionTypeText
+	case groups.QuestionTypeChoice:
+		if len(input.Options) < minJoinChoiceOptions || len(input.Options) > maxJoinChoiceOptions {
+			return groups.GroupJoinQuestion{}, fmt.Errorf("у вопроса с выбором должно быть от %d до %d вариантов", minJoinChoiceOptions, maxJoinChoiceOptions)
+		}
+		seen := make(map[string]struct{}, len(input.Options))
+		options := make([]string, 0, len(input.Options))
+		for _, o := range input.Options {
+			o = strings.TrimSpace(o)
+			if o == "" || utf8.RuneCountInString(o) > maxJoinOptionLength {
+				return groups.GroupJoinQuestion{}, fmt.Errorf("вариант ответа должен быть от 1 до %d символов", maxJoinOptionLength)
+			}
+			if _, ok := seen[o]; ok {
+				return groups.GroupJoinQuestion{}, fmt.Errorf("вариант «%s» повторяется", o)
+			}
+			seen[o] = struct{}{}
+			options = append(options, o)
+		}
+		question.Type = groups.QuestionTypeChoice
+		question.Options = options
+	default:
+		return groups.GroupJoinQuestion{}, fmt.Errorf("неизвестный тип вопроса: %s", input.Type)
+	}
+
+	return question, nil
+}
+
+func questionnaireAuditSnapshot(questions []groups.GroupJoinQuestion) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(questions))
+	for _, q := range questions {
+		result = append(result, map[string]interface{}{
+			"text": q.Text, "type": q.Type, "options": q.Options, "required": q.Required,
+		})
+	}
+	return result
+}
+
+// buildJoinAnswers проверяет ответы по анкете группы и готовит их к сохранению вместе с заявкой
+func buildJoinAnswers(tx *gorm.DB, groupID uint, answers []JoinAnswerInput) ([]groups.GroupJoinRequestAnswer, error) {
+	questions, err := loadJoinQuestions(tx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	byQuestion := make(map[uint]string, len(answers))
+	for _, a := range answers {
+		if _, ok := byQuestion[a.QuestionID]; ok {
+			return nil, fmt.Errorf("повторный ответ на вопрос %d", a.QuestionID)
+		}
+		byQuestion[a.QuestionID] = strings.TrimSpace(a.Answer)
+	}
+
+	result := make([]groups.GroupJoinRequestAnswer, 0, len(questions))
+	for _, q := range questions {
+		answer, ok := byQuestion[q.ID]
+		delete(byQuestion, q.ID)
+
+		if !ok || answer == "" {
+			if q.Required {
+				return nil, fmt.Errorf("ответьте на обязательный вопрос «%s»", q.Text)
+			}
+			continue
+		}
+
+		switch q.Type {
+		case groups.QuestionTypeChoice:
+			if !containsString(q.Options, answer) {
+				return nil, fmt.Errorf("для вопроса «%s» выберите один из предложенных вариантов", q.Text)
+			}
+		default:
+			if utf8.RuneCountInString(answer) > maxJoinAnswerLength {
+				return nil, fmt.Errorf("ответ на вопрос «%s» длиннее %d символов", q.Text, maxJoinAnswerLength)
+			}
+		}
+
+		questionID := q.ID
+		result = append(result, groups.GroupJoinRequestAnswer{
+			QuestionID:   &questionID,
+			QuestionText: q.Text,
+			Answer:       answer,
+		})
+	}
+
+	if len(byQuestion) > 0 {
+		return nil, errors.New("есть ответы на вопросы, которых нет в анкете группы")
+	}
+	return result, nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// loadJoinRequestAnswers возвращает ответы анкеты по ID заявок
+func loadJoinRequestAnswers(tx *gorm.DB, requestIDs []uint) (map[uint][]JoinRequestAnswerRes, error) {
+	result := make(map[uint][]JoinRequestAnswerRes, len(requestIDs))
+	if len(requestIDs) == 0 {
+		return result, nil
+	}
+
+	var answers []groups.GroupJoinRequestAnswer
+	if err := tx.Where("request_id IN ?", requestIDs).Order("id ASC").Find(&answers).Error; err != nil {
+		return nil, err
+	}
+	for _, a := range answers {
+		result[a.RequestID] = append(result[a.RequestID], JoinRequestAnswerRes{
+			QuestionID: a.QuestionID,
+			Question:   a.QuestionText,
+			Answer:     a.Answer,
+		})
+	}
+	return result, nil
+}
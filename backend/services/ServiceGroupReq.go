@@ -10,41 +10,32 @@ import (
 	"gorm.io/gorm"
 )
 
-// доп функция на проверку админки
-func checkAdminPermissions(db *gorm.DB, email string, groupID uint) (*models.User, error) {
+// доп функция на проверку права рассматривать заявки
+func checkAdminPermissions(db *gorm.DB, principal models.Principal, groupID uint) (*models.User, error) {
 	var user models.User
-	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := db.First(&user, principal.UserID).Error; err != nil {
 		return nil, errors.New("пользователь не найден")
 	}
 
-	var adminCheck groups.GroupUsers
-	if err := db.Where("user_id = ? AND group_id = ? AND role_in_group = ?", user.ID, groupID, "admin").First(&adminCheck).Error; err != nil {
-		return nil, errors.New("вы не администратор этой группы")
+	if _, err := requireGroupCapability(db, user.ID, groupID, groups.CapApproveRequests); err != nil {
+		return nil, errors.New("у вас нет прав рассматривать заявки в этой группе")
 	}
 	return &user, nil
 }
 
-func GetPendingJoinRequestsForAdmin(email string, groupID uint) ([]GroupJoinRequestRes, error) {
+func GetPendingJoinRequestsForAdmin(principal models.Principal, groupID uint) ([]GroupJoinRequestRes, error) {
 	var user models.User
-	if err := db.GetDB().Where("email = ?", email).First(&user).Error; err != nil {
+	if err := db.GetDB().First(&user, principal.UserID).Error; err != nil {
 		return nil, errors.New("пользователь не найден")
 	}
 
-	// Проверяем, является ли пользователь администратором данной группы
-	var groupUser struct {
-		ID   uint
-		Role string
-	}
-	err := db.GetDB().
-		Table("group_users").
-		Where("user_id = ? AND group_id = ? AND role_in_group = ?", user.ID, groupID, "admin").
-		First(&groupUser).Error
-	if err != nil {
-		return nil, errors.New("пользователь не является администратором данной группы")
+	// Проверяем, может ли пользователь рассматривать заявки в группе
+	if _, err := requireGroupCapability(db.GetDB(), user.ID, groupID, groups.CapApproveRequests); err != nil {
+		return nil, errors.New("у пользователя нет прав рассматривать заявки в данной группе")
 	}
 
 	var requests []groups.GroupJoinRequest
-	err = db.GetDB().
+	err := db.GetDB().
 		Where("group_id = ? AND status = ?", groupID, "pending").
 		Preload("User").
 		Preload("Group").
@@ -53,11 +44,25 @@ func GetPendingJoinRequestsForAdmin(email string, groupID uint) ([]GroupJoinRequ
 		return nil, fmt.Errorf("failed to get pending requests: %w", err)
 	}
 
+	requestIDs := make([]uint, 0, len(requests))
+	for _, request := range requests {
+		requestIDs = append(requestIDs, request.ID)
+	}
+	answers, err := loadJoinRequestAnswers(db.GetDB(), requestIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get questionnaire answers: %w", err)
+	}
+
 	var result []GroupJoinRequestRes
 	for _, request := range requests {
 		res := GroupJoinRequestRes{
-			ID:     request.ID,
-			UserID: request.UserID,
+			ID:        request.ID,
+			UserID:    request.UserID,
+			Answers:   answers[request.ID],
+			CreatedAt: request.CreatedAt,
+		}
+		if res.Answers == nil {
+			res.Answers = []JoinRequestAnswerRes{}
 		}
 		if request.User.Name != "" {
 			res.Name = request.User.Name
@@ -73,7 +78,7 @@ func GetPendingJoinRequestsForAdmin(email string, groupID uint) ([]GroupJoinRequ
 	return result, nil
 }
 
-func ApproveJoinRequestByID(email string, requestID string) error {
+func ApproveJoinRequestByID(principal models.Principal, requestID string) error {
 	tx := db.GetDB().Begin()
 	if tx.Error != nil {
 		return tx.Error
@@ -95,7 +100,13 @@ func ApproveJoinRequestByID(email string, requestID string) error {
 		return errors.New("заявка уже обработана")
 	}
 
-	if _, err := checkAdminPermissions(tx, email, request.GroupID); err != nil {
+	moderator, err := checkAdminPermissions(tx, principal, request.GroupID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := checkNotBannedInGroup(tx, request.GroupID, request.UserID); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -109,17 +120,30 @@ func ApproveJoinRequestByID(email string, requestID string) error {
 	member := groups.GroupUsers{
 		UserID:      request.UserID,
 		GroupID:     request.GroupID,
-		RoleInGroup: "member",
+		RoleInGroup: groups.RoleMember,
 	}
 	if err := tx.Create(&member).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
 
+	if err := recordGroupAudit(tx, groupAuditEntry{
+		GroupID:    request.GroupID,
+		ActorID:    moderator.ID,
+		Action:     AuditRequestApproved,
+		TargetType: "user",
+		TargetID:   request.UserID,
+		Before:     map[string]interface{}{"request_id": request.ID, "status": "pending"},
+		After:      map[string]interface{}{"request_id": request.ID, "status": "approved"},
+	}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	return tx.Commit().Error
 }
 
-func RejectJoinRequestByID(email string, requestID string) error {
+func RejectJoinRequestByID(principal models.Principal, requestID string) error {
 	db := db.GetDB()
 	var request groups.GroupJoinRequest
 	if err := db.Preload("Group").First(&request, requestID).Error; err != nil {
@@ -130,15 +154,29 @@ func RejectJoinRequestByID(email string, requestID string) error {
 		return errors.New("заявка уже обработана")
 	}
 
-	if _, err := checkAdminPermissions(db, email, request.GroupID); err != nil {
+	moderator, err := checkAdminPermissions(db, principal, request.GroupID)
+	if err != nil {
 		return err
 	}
 
-	return db.Model(&request).Update("status", "rejected").Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&request).Update("status", "rejected").Error; err != nil {
+			return err
+		}
+		return recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    request.GroupID,
+			ActorID:    moderator.ID,
+			Action:     AuditRequestRejected,
+			TargetType: "user",
+			TargetID:   request.UserID,
+			Before:     map[string]interface{}{"request_id": request.ID, "status": "pending"},
+			After:      map[string]interface{}{"request_id": request.ID, "status": "rejected"},
+		})
+	})
 }
 
 // ApproveAllJoinRequests одобряет все ожидающие заявки для указанной группы.
-func ApproveAllJoinRequests(email string, groupID uint) error {
+func ApproveAllJoinRequests(principal models.Principal, groupID uint) error {
 	tx := db.GetDB().Begin()
 	if tx.Error != nil {
 		return tx.Error
@@ -149,7 +187,8 @@ func ApproveAllJoinRequests(email string, groupID uint) error {
 		}
 	}()
 
-	if _, err := checkAdminPermissions(tx, email, groupID); err != nil {
+	moderator, err := checkAdminPermissions(tx, principal, groupID)
+	if err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -165,18 +204,47 @@ func ApproveAllJoinRequests(email string, groupID uint) error {
 		return errors.New("нет ожидающих заявок для этой группы")
 	}
 
+	requestUserIDs := make([]uint, len(requests))
+	for i, req := range requests {
+		requestUserIDs[i] = req.UserID
+	}
+	banned, err := bannedUserIDs(tx, groupID, requestUserIDs)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("ошибка при проверке блокировок")
+	}
+
+	// Заявки заблокированных пользователей отклоняются, остальные одобряются
+	if len(banned) > 0 {
+		bannedIDs := make([]uint, 0, len(banned))
+		for id := range banned {
+			bannedIDs = append(bannedIDs, id)
+		}
+		if err := tx.Model(&groups.GroupJoinRequest{}).
+			Where("group_id = ? AND status = ? AND user_id IN ?", groupID, "pending", bannedIDs).
+			Update("status", "rejected").Error; err != nil {
+			tx.Rollback()
+			return errors.New("ошибка при обновлении статуса заявок")
+		}
+	}
+
 	newMembers := make([]groups.GroupUsers, 0, len(requests))
 	for _, req := range requests {
+		if _, ok := banned[req.UserID]; ok {
+			continue
+		}
 		newMembers = append(newMembers, groups.GroupUsers{
 			UserID:      req.UserID,
 			GroupID:     req.GroupID,
-			RoleInGroup: "member",
+			RoleInGroup: groups.RoleMember,
 		})
 	}
 
-	if err := tx.Create(&newMembers).Error; err != nil {
-		tx.Rollback()
-		return errors.New("ошибка при добавлении новых участников")
+	if len(newMembers) > 0 {
+		if err := tx.Create(&newMembers).Error; err != nil {
+			tx.Rollback()
+			return errors.New("ошибка при добавлении новых участников")
+		}
 	}
 
 	if err := tx.Model(&groups.GroupJoinRequest{}).Where("group_id = ? AND status = ?", groupID, "pending").Update("status", "approved").Error; err != nil {
@@ -184,13 +252,30 @@ func ApproveAllJoinRequests(email string, groupID uint) error {
 		return errors.New("ошибка при обновлении статуса заявок")
 	}
 
+	approvedIDs := make([]uint, 0, len(newMembers))
+	for _, m := range newMembers {
+		approvedIDs = append(approvedIDs, m.UserID)
+	}
+	if err := recordGroupAudit(tx, groupAuditEntry{
+		GroupID:    groupID,
+		ActorID:    moderator.ID,
+		Action:     AuditRequestsApprovedAll,
+		TargetType: "group",
+		TargetID:   groupID,
+		After:      map[string]interface{}{"approved_user_ids": approvedIDs, "rejected_banned": len(banned)},
+	}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	return tx.Commit().Error
 }
 
 // RejectAllJoinRequests отклоняет все ожидающие заявки для указанной группы.
-func RejectAllJoinRequests(email string, groupID uint) error {
+func RejectAllJoinRequests(principal models.Principal, groupID uint) error {
 	db := db.GetDB()
-	if _, err := checkAdminPermissions(db, email, groupID); err != nil {
+	moderator, err := checkAdminPermissions(db, principal, groupID)
+	if err != nil {
 		return err
 	}
 
@@ -203,11 +288,19 @@ func RejectAllJoinRequests(email string, groupID uint) error {
 		return errors.New("нет ожидающих заявок для этой группы")
 	}
 
-	if err := db.Model(&groups.GroupJoinRequest{}).Where("group_id = ? AND status = ?", groupID, "pending").Update("status", "rejected").Error; err != nil {
-		return errors.New("ошибка при отклонении заявок")
-	}
-
-	return nil
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&groups.GroupJoinRequest{}).Where("group_id = ? AND status = ?", groupID, "pending").Update("status", "rejected").Error; err != nil {
+			return errors.New("ошибка при отклонении заявок")
+		}
+		return recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    groupID,
+			ActorID:    moderator.ID,
+			Action:     AuditRequestsRejectedAll,
+			TargetType: "group",
+			TargetID:   groupID,
+			After:      map[string]interface{}{"rejected": count},
+		})
+	})
 }
 
 type SentJoinRequestsReq struct {
@@ -215,10 +308,7 @@ type SentJoinRequestsReq struct {
 	UserID  uint `form:"user_id" binding:"required"`
 }
 
-func SentJoinRequests(email string, input SentJoinRequestsReq) (*JoinGroupResult, error) {
-	if email == "" {
-		return nil, errors.New("email не может быть пустым")
-	}
+func SentJoinRequests(principal models.Principal, input SentJoinRequestsReq) (*JoinGroupResult, error) {
 	if input.GroupID == 0 {
 		return nil, errors.New("groupID не может быть пустым")
 	}
@@ -231,13 +321,13 @@ func SentJoinRequests(email string, input SentJoinRequestsReq) (*JoinGroupResult
 		return nil, tx.Error
 	}
 
-	if _, err := checkAdminPermissions(tx, email, input.GroupID); err != nil {
+	if _, err := checkAdminPermissions(tx, principal, input.GroupID); err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
 	var user models.User
-	if err := tx.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := tx.First(&user, principal.UserID).Error; err != nil {
 		tx.Rollback()
 		return nil, errors.New("пользователь не найден")
 	}
@@ -254,6 +344,16 @@ func SentJoinRequests(email string, input SentJoinRequestsReq) (*JoinGroupResult
 		return nil, errors.New("пользователь уже в группе")
 	}
 
+	if err := checkNotBlocked(tx, user.ID, input.UserID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := checkNotBannedInGroup(tx, input.GroupID, input.UserID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	invite := groups.GroupJoinInvite{
 		UserID:  input.UserID,
 		GroupID: group.ID,
@@ -265,6 +365,18 @@ func SentJoinRequests(email string, input SentJoinRequestsReq) (*JoinGroupResult
 		return nil, fmt.Errorf("ошибка создания приглашения: %v", err)
 	}
 
+	if err := recordGroupAudit(tx, groupAuditEntry{
+		GroupID:    group.ID,
+		ActorID:    user.ID,
+		Action:     AuditInviteSent,
+		TargetType: "user",
+		TargetID:   input.UserID,
+		After:      map[string]interface{}{"invite_id": invite.ID},
+	}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return nil, fmt.Errorf("ошибка сохранения транзакции: %v", err)
 	}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"friendship/db"
+	"friendship/models"
+	"friendship/models/groups"
+
+	"gorm.io/gorm"
+)
+
+// Управление ролями в группе: повышение и понижение на одну ступень member → operator → admin.
+// Владелец — Group.CreaterID, его роль менять нельзя; передача владения — в ServiceGroupOwnership.go.
+
+type GroupPermissionsResponse struct {
+	GroupID      uint                `json:"group_id"`
+	Role         string              `json:"role"`
+	IsOwner      bool                `json:"is_owner"`
+	Capabilities []groups.Capability `json:"capabilities"`
+}
+
+// requireGroupCapability возвращает роль пользователя, если она даёт право capability
+func requireGroupCapability(database *gorm.DB, userID, groupID uint, capability groups.Capability) (string, error) {
+	var member groups.GroupUsers
+	if err := database.Where("user_id = ? AND group_id = ?", userID, groupID).First(&member).Error; err != nil {
+		return "", errors.New("вы не состоите в группе или нет доступа")
+	}
+	if !groups.HasCapability(member.RoleInGroup, capability) {
+		return member.RoleInGroup, fmt.Errorf("у вас нет необходимых прав в этой группе (%s)", capability)
+	}
+	return member.RoleInGroup, nil
+}
+
+// GetMyGroupPermissions возвращает роль и права текущего пользователя в группе
+func GetMyGroupPermissions(principal models.Principal, groupID uint) (*GroupPermissionsResponse, error) {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+
+	var group groups.Group
+	if err := database.First(&group, groupID).Error; err != nil {
+		return nil, errors.New("группа не найдена")
+	}
+
+	role, err := getUserRole(user.ID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GroupPermissionsResponse{
+		GroupID:      groupID,
+		Role:         role,
+		IsOwner:      group.CreaterID == user.ID,
+		Capabilities: groups.CapabilitiesOf(role),
+	}, nil
+}
+
+// PromoteMember повышает участника на одну ступень
+func PromoteMember(principal models.Principal, groupID, targetUserID uint) (string, error) {
+	return changeMemberRole(principal, groupID, targetUserID, 1)
+}
+
+// DemoteMember понижает участника на одну ступень
+func DemoteMember(principal models.Principal, groupID, targetUserID uint) (string, error) {
+	return changeMemberRole(principal, groupID, targetUserID, -1)
+}
+
+// nextGroupRole возвращает роль на step ступеней выше (или ниже) текущей.
+// Понизить администратора может только владелец группы.
+func nextGroupRole(current string, step int, requesterIsOwner bool) (string, error) {
+	rank := groups.RoleRank(current)
+	if rank < 0 {
+		return "", fmt.Errorf("неизвестная роль участника: %s", current)
+	}
+	newRank := rank + step
+	if newRank < 0 {
+		return "", errors.New("участник уже имеет минимальную роль")
+	}
+	if newRank >= len(groups.Roles) {
+		return "", errors.New("участник уже имеет максимальную роль")
+	}
+	if step < 0 && current == groups.RoleAdmin && !requesterIsOwner {
+		return "", errors.New("понизить администратора может только владелец группы")
+	}
+	return groups.Roles[newRank], nil
+}
+
+func changeMemberRole(principal models.Principal, groupID, targetUserID uint, step int) (string, error) {
+	database := db.GetDB()
+
+	var requester models.User
+	if err := database.First(&requester, principal.UserID).Error; err != nil {
+		return "", errors.New("пользователь не найден")
+	}
+	if requester.ID == targetUserID {
+		return "", errors.New("нельзя изменить собственную роль")
+	}
+
+	var group groups.Group
+	if err := database.First(&group, groupID).Error; err != nil {
+		return "", errors.New("группа не найдена")
+	}
+	if group.CreaterID == targetUserID {
+		return "", errors.New("нельзя изменить роль владельца группы")
+	}
+
+	if _, err := requireGroupCapability(database, requester.ID, groupID, groups.CapManageRoles); err != nil {
+		return "", err
+	}
+
+	var target groups.GroupUsers
+	if err := database.Where("user_id = ? AND group_id = ?", targetUserID, groupID).First(&target).Error; err != nil {
+		return "", errors.New("пользователь не состоит в группе")
+	}
+
+	newRole, err := nextGroupRole(target.RoleInGroup, step, group.CreaterID == requester.ID)
+	if err != nil {
+		return "", err
+	}
+
+	previousRole := target.RoleInGroup
+	err = database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&target).Update("role_in_group", newRole).Error; err != nil {
+			return fmt.Errorf("не удалось изменить роль: %v", err)
+		}
+		return recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    groupID,
+			ActorID:    requester.ID,
+			Action:     AuditMemberRoleChanged,
+			TargetType: "user",
+			TargetID:   targetUserID,
+			Before:     map[string]interface{}{"role": previousRole},
+			After:      map[string]interface{}{"role": newRole},
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return newRole, nil
+}
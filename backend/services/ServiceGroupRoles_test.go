@@ -0,0 +1,38 @@
+package services
+
+import (
+	"friendship/models/groups"
+	"testing"
+)
+
+func TestNextGroupRole(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		step    int
+		owner   bool
+		want    string
+		wantErr bool
+	}{
+		{"участник в операторы", groups.RoleMember, 1, false, groups.RoleOperator, false},
+		{"оператор в администраторы", groups.RoleOperator, 1, false, groups.RoleAdmin, false},
+		{"выше администратора некуда", groups.RoleAdmin, 1, true, "", true},
+		{"оператор в участники", groups.RoleOperator, -1, false, groups.RoleMember, false},
+		{"ниже участника некуда", groups.RoleMember, -1, true, "", true},
+		{"администратора понижает не владелец", groups.RoleAdmin, -1, false, "", true},
+		{"администратора понижает владелец", groups.RoleAdmin, -1, true, groups.RoleOperator, false},
+		{"неизвестная роль", "owner", 1, true, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextGroupRole(tt.current, tt.step, tt.owner)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("nextGroupRole() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("nextGroupRole() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"friendship/models/groups"
 	"friendship/models/sessions"
 	"log"
+	"sort"
 	"strings"
 
 	"gorm.io/gorm"
@@ -71,10 +72,11 @@ func parseContacts(contactsStr string) map[string]string {
 }
 
 type JoinGroupInput struct {
-	GroupID uint `json:"groupId" binding:"required"`
+	GroupID uint              `json:"groupId" binding:"required"`
+	Answers []JoinAnswerInput `json:"answers"` // ответы на анкету закрытой группы
 }
 
-func CreateGroup(email *string, input CreateGroupInput) (group *groups.Group, err error) {
+func CreateGroup(principal models.Principal, input CreateGroupInput) (group *groups.Group, err error) {
 	if err := ValidateInput(input); err != nil {
 		return nil, fmt.Errorf("невалидная структура данных: %v", err)
 	}
@@ -86,8 +88,8 @@ func CreateGroup(email *string, input CreateGroupInput) (group *groups.Group, er
 	}
 
 	var creator models.User
-	if err := db.GetDB().Where("email = ?", email).First(&creator).Error; err != nil {
-		return nil, fmt.Errorf("создатель не найден по email (%s): %v", *email, err)
+	if err := db.GetDB().First(&creator, principal.UserID).Error; err != nil {
+		return nil, fmt.Errorf("создатель не найден (id %d): %v", principal.UserID, err)
 	}
 
 	// Загрузка категорий
@@ -130,7 +132,7 @@ func CreateGroup(email *string, input CreateGroupInput) (group *groups.Group, er
 	groupUser := groups.GroupUsers{
 		UserID:      creator.ID,
 		GroupID:     newGroup.ID,
-		RoleInGroup: "admin",
+		RoleInGroup: groups.RoleAdmin,
 	}
 	if err = tx.Create(&groupUser).Error; err != nil {
 		return nil, fmt.Errorf("ошибка добавления пользователя в группу: %v", err)
@@ -166,42 +168,78 @@ func CreateGroup(email *string, input CreateGroupInput) (group *groups.Group, er
 	return newGroup, nil
 }
 
-func JoinGroup(email string, input JoinGroupInput) (*JoinGroupResult, error) {
+func JoinGroup(principal models.Principal, input JoinGroupInput) (*JoinGroupResult, error) {
 	var user models.User
-	if err := db.GetDB().Where("email = ?", email).First(&user).Error; err != nil {
+	if err := db.GetDB().First(&user, principal.UserID).Error; err != nil {
 		return nil, fmt.Errorf("пользователь не найден")
 	}
 
+	var group groups.Group
+	if err := db.GetDB().Where("id = ?", input.GroupID).First(&group).Error; err != nil {
+		return nil, fmt.Errorf("группа не найдена")
+	}
+
+	var result *JoinGroupResult
+	err := db.GetDB().Transaction(func(tx *gorm.DB) error {
+		res, err := joinGroupAsUser(tx, user, group, false, input.Answers)
+		result = res
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// joinGroupAsUser — общая логика вступления: в открытую группу (или при autoApprove) пользователь
+// добавляется сразу, в закрытую — создаётся заявка на рассмотрение администратором вместе с ответами на анкету
+func joinGroupAsUser(tx *gorm.DB, user models.User, group groups.Group, autoApprove bool, answers []JoinAnswerInput) (*JoinGroupResult, error) {
 	var existing groups.GroupUsers
-	if err := db.GetDB().
-		Where("user_id = ? AND group_id = ?", user.ID, input.GroupID).
+	if err := tx.
+		Where("user_id = ? AND group_id = ?", user.ID, group.ID).
 		First(&existing).Error; err == nil {
 		return nil, fmt.Errorf("пользователь уже в группе")
 	}
 
 	var existingRequest groups.GroupJoinRequest
-	if err := db.GetDB().
-		Where("user_id = ? AND group_id = ?", user.ID, input.GroupID).
+	if err := tx.
+		Where("user_id = ? AND group_id = ?", user.ID, group.ID).
 		First(&existingRequest).Error; err == nil {
-		if existingRequest.Status == "pending" {
+		if existingRequest.Status == "pending" && !autoApprove {
 			return nil, fmt.Errorf("заявка уже отправлена и ожидает подтверждения")
 		}
 	}
 
-	var group groups.Group
-	if err := db.GetDB().Where("id = ?", input.GroupID).First(&group).Error; err != nil {
-		return nil, fmt.Errorf("группа не найдена")
+	if group.ArchivedAt != nil {
+		return nil, fmt.Errorf("группа в архиве")
 	}
 
-	if group.IsPrivate {
+	if err := checkNotBannedInGroup(tx, group.ID, user.ID); err != nil {
+		return nil, err
+	}
+
+	if group.IsPrivate && !autoApprove {
+		requestAnswers, err := buildJoinAnswers(tx, group.ID, answers)
+		if err != nil {
+			return nil, err
+		}
+
 		request := groups.GroupJoinRequest{
 			UserID:  user.ID,
 			GroupID: group.ID,
 			Status:  "pending",
 		}
-		if err := db.GetDB().Create(&request).Error; err != nil {
+		if err := tx.Create(&request).Error; err != nil {
 			return nil, fmt.Errorf("ошибка создания заявки: %v", err)
 		}
+		if len(requestAnswers) > 0 {
+			for i := range requestAnswers {
+				requestAnswers[i].RequestID = request.ID
+			}
+			if err := tx.Create(&requestAnswers).Error; err != nil {
+				return nil, fmt.Errorf("ошибка сохранения ответов анкеты: %v", err)
+			}
+		}
 		return &JoinGroupResult{
 			Message: "Заявка на вступление отправлена, ожидайте подтверждения от администратора группы",
 			Joined:  false,
@@ -210,11 +248,16 @@ func JoinGroup(email string, input JoinGroupInput) (*JoinGroupResult, error) {
 		member := groups.GroupUsers{
 			UserID:      user.ID,
 			GroupID:     group.ID,
-			RoleInGroup: "member",
+			RoleInGroup: groups.RoleMember,
 		}
-		if err := db.GetDB().Create(&member).Error; err != nil {
+		if err := tx.Create(&member).Error; err != nil {
 			return nil, fmt.Errorf("ошибка добавления пользователя в группу: %v", err)
 		}
+		if existingRequest.ID != 0 && existingRequest.Status == "pending" {
+			if err := tx.Model(&existingRequest).Update("status", "approved").Error; err != nil {
+				return nil, fmt.Errorf("ошибка обновления заявки: %v", err)
+			}
+		}
 		return &JoinGroupResult{
 			Message: "Пользователь успешно присоединился к группе",
 			Joined:  true,
@@ -222,12 +265,33 @@ func JoinGroup(email string, input JoinGroupInput) (*JoinGroupResult, error) {
 	}
 }
 
-func DeleteGroup(groupID uint) error {
+func DeleteGroup(principal models.Principal, groupID uint) error {
+	actorID := principal.UserID
+
+	var existing groups.Group
+	if err := db.GetDB().Preload("Categories").Preload("Contacts").First(&existing, groupID).Error; err != nil {
+		return fmt.Errorf("группа не найдена")
+	}
+
 	group := groups.Group{ID: groupID}
-	result := db.GetDB().Select("Categories").Delete(&group)
+	var result *gorm.DB
+	err := db.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    groupID,
+			ActorID:    actorID,
+			Action:     AuditGroupDeleted,
+			TargetType: "group",
+			TargetID:   groupID,
+			Before:     groupAuditSnapshot(existing),
+		}); err != nil {
+			return err
+		}
+		result = tx.Select("Categories").Delete(&group)
+		return result.Error
+	})
 
-	if result.Error != nil {
-		return fmt.Errorf("ошибка при удалении группы: %w", result.Error)
+	if err != nil {
+		return fmt.Errorf("ошибка при удалении группы: %w", err)
 	}
 
 	if result.RowsAffected == 0 {
@@ -238,18 +302,19 @@ func DeleteGroup(groupID uint) error {
 }
 
 type GroupInf struct {
-	ID           uint                    `json:"id"`
-	Name         string                  `json:"name"`
-	Description  string                  `json:"description"`
-	Image        string                  `json:"image"`
-	City         string                  `json:"city"`
-	Creater      uint                    `json:"creater"`
-	CountMembers int64                   `json:"count_members"`
-	Subscription bool                    `json:"subscription"`
-	Users        []UsersGroups           `json:"users"`
-	Categories   []*string               `json:"categories"`
-	Contacts     []*Contacts             `json:"contacts"`
-	Sessions     []SessionDetailResponse `json:"sessions"`
+	ID            uint                    `json:"id"`
+	Name          string                  `json:"name"`
+	Description   string                  `json:"description"`
+	Image         string                  `json:"image"`
+	City          string                  `json:"city"`
+	Creater       uint                    `json:"creater"`
+	CountMembers  int64                   `json:"count_members"`
+	Subscription  bool                    `json:"subscription"`
+	Users         []UsersGroups           `json:"users"`
+	Categories    []*string               `json:"categories"`
+	Contacts      []*Contacts             `json:"contacts"`
+	Sessions      []SessionDetailResponse `json:"sessions"`
+	Announcements []AnnouncementResponse  `json:"announcements"` // закреплённые и несколько последних
 }
 
 type UsersGroups struct {
@@ -263,11 +328,11 @@ type Contacts struct {
 	Link *string `json:"link"`
 }
 
-func GetGroupInf(groupID *uint64, email *string) (*GroupInf, error) {
+func GetGroupInf(groupID *uint64, principal models.Principal) (*GroupInf, error) {
 	if *groupID == 0 {
 		return nil, errors.New("некорректный ID группы")
 	}
-	user, err := FindUserByEmail(*email)
+	user, err := FindUserByID(principal.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("пользователь не найден: %v", err)
 	}
@@ -351,12 +416,18 @@ func GetGroupInf(groupID *uint64, email *string) (*GroupInf, error) {
 	}
 	information.Contacts = contacts
 
-	sessions, err := getGroupSessions(*groupID)
+	sessions, err := getGroupSessions(*groupID, user.ID)
 	if err != nil {
 		return nil, err
 	}
 	information.Sessions = sessions
 
+	announcements, err := getGroupInfAnnouncements(*groupID)
+	if err != nil {
+		return nil, err
+	}
+	information.Announcements = announcements
+
 	return &information, nil
 }
 
@@ -373,7 +444,7 @@ type GroupUpdateInput struct {
 	Contacts         *string `json:"contacts"`
 }
 
-func UpdateGroup(groupID uint, input GroupUpdateInput) (err error) {
+func UpdateGroup(principal models.Principal, groupID uint, input GroupUpdateInput) (err error) {
 	tx := db.GetDB().Begin()
 	if tx.Error != nil {
 		return fmt.Errorf("не удалось начать транзакцию: %v", tx.Error)
@@ -388,10 +459,13 @@ func UpdateGroup(groupID uint, input GroupUpdateInput) (err error) {
 		}
 	}()
 
+	actorID := principal.UserID
+
 	var group groups.Group
-	if err = tx.First(&group, groupID).Error; err != nil {
+	if err = tx.Preload("Categories").Preload("Contacts").First(&group, groupID).Error; err != nil {
 		return fmt.Errorf("группа не найдена")
 	}
+	before := groupAuditSnapshot(group)
 
 	// Сохраняем старый URL изображения для последующего удаления
 	oldImageURL := group.Image
@@ -445,6 +519,24 @@ func UpdateGroup(groupID uint, input GroupUpdateInput) (err error) {
 		}
 	}
 
+	var updated groups.Group
+	if err = tx.Preload("Categories").Preload("Contacts").First(&updated, groupID).Error; err != nil {
+		return fmt.Errorf("группа не найдена")
+	}
+	if auditBefore, auditAfter := auditDiff(before, groupAuditSnapshot(updated)); len(auditAfter) > 0 {
+		if err = recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    groupID,
+			ActorID:    actorID,
+			Action:     AuditGroupUpdated,
+			TargetType: "group",
+			TargetID:   groupID,
+			Before:     auditBefore,
+			After:      auditAfter,
+		}); err != nil {
+			return err
+		}
+	}
+
 	// Коммитим транзакцию
 	if err = tx.Commit().Error; err != nil {
 		return err
@@ -559,7 +651,7 @@ func getGroupUsers(groupID uint64) ([]UsersGroups, error) {
 	return users, nil
 }
 
-func getGroupSessions(groupID uint64) ([]SessionDetailResponse, error) {
+func getGroupSessions(groupID uint64, userID uint) ([]SessionDetailResponse, error) {
 	var Gsessions []sessions.Session
 
 	err := db.GetDB().
@@ -588,6 +680,11 @@ func getGroupSessions(groupID uint64) ([]SessionDetailResponse, error) {
 		metadataMap = make(map[uint]*sessions.SessionMetadata)
 	}
 
+	friendsCounts, err := friendsGoingCounts(db.GetDB(), userID, sessionIDs)
+	if err != nil {
+		return nil, err
+	}
+
 	sessionResponses := make([]SessionDetailResponse, 0, len(Gsessions))
 	for _, session := range Gsessions {
 		if session.ID == 0 {
@@ -654,6 +751,8 @@ func getGroupSessions(groupID uint64) ([]SessionDetailResponse, error) {
 			CurrantUsers:  *currentUsers,
 			CountUsersMax: *countUsersMax,
 			ImageURL:      *imageURL,
+
+			FriendsGoingCount: friendsCounts[session.ID],
 		}
 
 		// Получаем метаданные для текущей сессии
@@ -672,3 +771,28 @@ func getGroupSessions(groupID uint64) ([]SessionDetailResponse, error) {
 
 	return sessionResponses, nil
 }
+
+// groupAuditSnapshot — редактируемые поля группы для журнала аудита
+func groupAuditSnapshot(g groups.Group) map[string]interface{} {
+	categories := make([]uint, 0, len(g.Categories))
+	for _, c := range g.Categories {
+		categories = append(categories, c.ID)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i] < categories[j] })
+
+	contacts := make(map[string]string, len(g.Contacts))
+	for _, c := range g.Contacts {
+		contacts[c.Name] = c.Link
+	}
+
+	return map[string]interface{}{
+		"name":              g.Name,
+		"description":       g.Description,
+		"small_description": g.SmallDescription,
+		"image":             g.Image,
+		"is_private":        g.IsPrivate,
+		"city":              g.City,
+		"categories":        categories,
+		"contacts":          contacts,
+	}
+}
@@ -0,0 +1,551 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"friendship/db"
+	"friendship/models"
+	"friendship/models/groups"
+	statsusers "friendship/models/stats_users"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Рейтинги хранятся в Redis sorted sets: leaderboard:{scope}:{metric}:{window}.
+// scope — global, group:<id> или city:<город>; window — all, неделя (2025-W07) или месяц (2025-03).
+// Период сессии определяется по её end_time, так что пересборка и инкременты дают одно и то же.
+// Скрывших себя пользователей в рейтингах нет: при скрытии они удаляются из всех наборов,
+// инкременты и пересборка их пропускают, а при возврате их очки собираются заново по сессиям.
+// Поэтому чтение рейтинга не зависит от числа скрытых.
+
+const (
+	LeaderboardAttended = "attended"
+	LeaderboardHosted   = "hosted"
+	LeaderboardHours    = "hours"
+	LeaderboardStreak   = "streak"
+
+	LeaderboardWindowWeek  = "week"
+	LeaderboardWindowMonth = "month"
+	LeaderboardWindowAll   = "all"
+
+	LeaderboardScopeGlobal = "global"
+	LeaderboardScopeGroup  = "group"
+	LeaderboardScopeCity   = "city"
+
+	leaderboardKeyPrefix = "leaderboard:"
+	leaderboardWeekTTL   = 8 * 7 * 24 * time.Hour
+	leaderboardMonthTTL  = 400 * 24 * time.Hour
+
+	leaderboardDefaultLimit = 20
+	leaderboardMaxLimit     = 100
+)
+
+type LeaderboardQuery struct {
+	Scope   string `form:"scope"`
+	GroupID uint   `form:"group_id"`
+	City    string `form:"city"`
+	Metric  string `form:"metric"`
+	Window  string `form:"window"`
+	Period  string `form:"period"` // конкретная неделя/месяц, по умолчанию — текущие
+	Limit   int    `form:"limit"`
+}
+
+type LeaderboardEntry struct {
+	Rank  int     `json:"rank"`
+	ID    uint    `json:"id"`
+	Name  string  `json:"name"`
+	Us    string  `json:"us"`
+	Image string  `json:"image"`
+	Score float64 `json:"score"`
+}
+
+type LeaderboardResponse struct {
+	Scope   string             `json:"scope"`
+	Metric  string             `json:"metric"`
+	Window  string             `json:"window"`
+	Period  string             `json:"period"`
+	Entries []LeaderboardEntry `json:"entries"`
+	Me      *LeaderboardEntry  `json:"me,omitempty"`
+}
+
+// leaderboardSession — то, что нужно рейтингу от завершённой сессии
+type leaderboardSession struct {
+	HostID       uint
+	GroupID      uint
+	City         string
+	EndTime      time.Time
+	Duration     uint64
+	Participants []uint
+}
+
+func leaderboardWeekPeriod(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+func leaderboardMonthPeriod(t time.Time) string {
+	return t.Format("2006-01")
+}
+
+func leaderboardScopes(groupID uint, city string) []string {
+	scopes := []string{LeaderboardScopeGlobal, fmt.Sprintf("%s:%d", LeaderboardScopeGroup, groupID)}
+	if city = strings.ToLower(strings.TrimSpace(city)); city != "" {
+		scopes = append(scopes, LeaderboardScopeCity+":"+city)
+	}
+	return scopes
+}
+
+func leaderboardKey(scope, metric, period string) string {
+	return leaderboardKeyPrefix + scope + ":" + metric + ":" + period
+}
+
+// applySessionToLeaderboards добавляет в pipeline инкременты по одной сессии.
+// streaks — лучшая серия участников за всё время, пишется через ZADD GT только в окно all:
+// за неделю или месяц такая серия ничего не говорит.
+// include отсекает пользователей, которых в рейтинге быть не должно.
+func applySessionToLeaderboards(pipe redis.Pipeliner, s leaderboardSession, streaks map[uint]uint16, include func(uint) bool) {
+	week := leaderboardWeekPeriod(s.EndTime)
+	month := leaderboardMonthPeriod(s.EndTime)
+
+	for _, scope := range leaderboardScopes(s.GroupID, s.City) {
+		periods := []struct {
+			name string
+			ttl  time.Duration
+		}{
+			{LeaderboardWindowAll, 0},
+			{week, leaderboardWeekTTL},
+			{month, leaderboardMonthTTL},
+		}
+
+		for _, p := range periods {
+			touched := []string{}
+			add := func(metric string, uid uint, value float64) {
+				key := leaderboardKey(scope, metric, p.name)
+				pipe.ZIncrBy(ctx, key, value, fmt.Sprint(uid))
+				touched = append(touched, key)
+			}
+
+			for _, uid := range s.Participants {
+				if !include(uid) {
+					continue
+				}
+				add(LeaderboardAttended, uid, 1)
+				add(LeaderboardHours, uid, float64(s.Duration))
+
+				if streak, ok := streaks[uid]; ok && p.name == LeaderboardWindowAll {
+					key := leaderboardKey(scope, LeaderboardStreak, p.name)
+					pipe.ZAddArgs(ctx, key, redis.ZAddArgs{
+						GT:      true,
+						Members: []redis.Z{{Score: float64(streak), Member: fmt.Sprint(uid)}},
+					})
+					touched = append(touched, key)
+				}
+			}
+			if include(s.HostID) {
+				add(LeaderboardHosted, s.HostID, 1)
+			}
+
+			if p.ttl > 0 {
+				for _, key := range touched {
+					pipe.Expire(ctx, key, p.ttl)
+				}
+			}
+		}
+	}
+}
+
+// updateLeaderboardsForSession вызывается после коммита статистики по сессии
+func updateLeaderboardsForSession(database *gorm.DB, s leaderboardSession) error {
+	redisClient := db.GetRedis()
+	if redisClient == nil {
+		return errors.New("Redis клиент недоступен")
+	}
+
+	streaks, err := loadStreaks(database, s.Participants)
+	if err != nil {
+		return err
+	}
+
+	hidden, err := hiddenLeaderboardUsers(database, append([]uint{s.HostID}, s.Participants...))
+	if err != nil {
+		return err
+	}
+
+	pipe := redisClient.Pipeline()
+	applySessionToLeaderboards(pipe, s, streaks, notHidden(hidden))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("ошибка обновления рейтингов: %v", err)
+	}
+	return nil
+}
+
+func loadStreaks(database *gorm.DB, userIDs []uint) (map[uint]uint16, error) {
+	var rows []statsusers.SideStats_users
+	if err := database.Where("user_id IN ?", userIDs).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[uint]uint16, len(rows))
+	for _, r := range rows {
+		if r.UserID != nil {
+			result[*r.UserID] = safeUint16Value(r.SeriesSesionCount)
+		}
+	}
+	return result, nil
+}
+
+// RebuildLeaderboards удаляет все рейтинги и собирает их заново по завершённым сессиям
+func RebuildLeaderboards() (int, error) {
+	redisClient := db.GetRedis()
+	if redisClient == nil {
+		return 0, errors.New("Redis клиент недоступен")
+	}
+	database := db.GetDB()
+
+	iter := redisClient.Scan(ctx, 0, leaderboardKeyPrefix+"*", 500).Iterator()
+	for iter.Next(ctx) {
+		if err := redisClient.Del(ctx, iter.Val()).Err(); err != nil {
+			return 0, err
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return 0, err
+	}
+
+	var rows []struct {
+		ID        uint
+		UserID    uint
+		GroupID   uint
+		City      string
+		Duration  uint16
+		StartTime time.Time
+		EndTime   time.Time
+	}
+	if err := database.Raw(`
+		SELECT s.id, s.user_id, s.group_id, g.city, s.duration, s.start_time, s.end_time
+		FROM sessions s
+		JOIN statuses st ON st.id = s.status_id
+		JOIN groups g ON g.id = s.group_id
+		WHERE st.status = 'Завершена'
+		ORDER BY s.end_time ASC
+	`).Scan(&rows).Error; err != nil {
+		return 0, err
+	}
+
+	var attendance []struct {
+		SessionID uint
+		UserID    uint
+	}
+	if err := database.Raw(`
+		SELECT su.session_id, su.user_id
+		FROM session_users su
+		JOIN sessions s ON s.id = su.session_id
+		JOIN statuses st ON st.id = s.status_id
+		WHERE st.status = 'Завершена'
+	`).Scan(&attendance).Error; err != nil {
+		return 0, err
+	}
+	participants := make(map[uint][]uint)
+	userSet := make(map[uint]struct{})
+	for _, a := range attendance {
+		participants[a.SessionID] = append(participants[a.SessionID], a.UserID)
+		userSet[a.UserID] = struct{}{}
+	}
+	// Создатель считается участником, как и в UpdateStatisticsForFinishedSession
+	for _, r := range rows {
+		if !slices.Contains(participants[r.ID], r.UserID) {
+			participants[r.ID] = append(participants[r.ID], r.UserID)
+		}
+		userSet[r.UserID] = struct{}{}
+	}
+
+	streaks, err := loadStreaks(database, keys(userSet))
+	if err != nil {
+		return 0, err
+	}
+
+	hidden, err := hiddenLeaderboardUsers(database, nil)
+	if err != nil {
+		return 0, err
+	}
+	include := notHidden(hidden)
+
+	pipe := redisClient.Pipeline()
+	for i, r := range rows {
+		applySessionToLeaderboards(pipe, leaderboardSession{
+			HostID:       r.UserID,
+			GroupID:      r.GroupID,
+			City:         r.City,
+			EndTime:      r.EndTime,
+			Duration:     sessionDurationForStats(r.Duration, r.StartTime, r.EndTime),
+			Participants: participants[r.ID],
+		}, streaks, include)
+
+		if (i+1)%100 == 0 {
+			if _, err := pipe.Exec(ctx); err != nil {
+				return i, fmt.Errorf("ошибка записи рейтингов: %v", err)
+			}
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return len(rows), fmt.Errorf("ошибка записи рейтингов: %v", err)
+	}
+
+	return len(rows), nil
+}
+
+// GetLeaderboard отдаёт топ рейтинга и место текущего пользователя
+func GetLeaderboard(principal models.Principal, q LeaderboardQuery) (*LeaderboardResponse, error) {
+	database := db.GetDB()
+	redisClient := db.GetRedis()
+	if redisClient == nil {
+		return nil, errors.New("Redis клиент недоступен")
+	}
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+
+	switch q.Metric {
+	case "":
+		q.Metric = LeaderboardAttended
+	case LeaderboardAttended, LeaderboardHosted, LeaderboardHours, LeaderboardStreak:
+	default:
+		return nil, fmt.Errorf("неизвестная метрика: %s", q.Metric)
+	}
+
+	now := time.Now()
+	period := q.Period
+	switch q.Window {
+	case "", LeaderboardWindowAll:
+		q.Window = LeaderboardWindowAll
+		period = LeaderboardWindowAll
+	case LeaderboardWindowWeek:
+		if period == "" {
+			period = leaderboardWeekPeriod(now)
+		}
+	case LeaderboardWindowMonth:
+		if period == "" {
+			period = leaderboardMonthPeriod(now)
+		}
+	default:
+		return nil, fmt.Errorf("неизвестный период: %s", q.Window)
+	}
+	if q.Metric == LeaderboardStreak && q.Window != LeaderboardWindowAll {
+		return nil, errors.New("рейтинг по серии доступен только за всё время")
+	}
+
+	scope := LeaderboardScopeGlobal
+	switch q.Scope {
+	case "", LeaderboardScopeGlobal:
+		q.Scope = LeaderboardScopeGlobal
+	case LeaderboardScopeGroup:
+		var group groups.Group
+		if err := database.First(&group, q.GroupID).Error; err != nil {
+			return nil, errors.New("группа не найдена")
+		}
+		if group.IsPrivate {
+			if _, err := getUserRole(user.ID, group.ID); err != nil {
+				return nil, errors.New("рейтинг приватной группы доступен только участникам")
+			}
+		}
+		scope = fmt.Sprintf("%s:%d", LeaderboardScopeGroup, group.ID)
+	case LeaderboardScopeCity:
+		city := strings.ToLower(strings.TrimSpace(q.City))
+		if city == "" {
+			return nil, errors.New("не указан город")
+		}
+		scope = LeaderboardScopeCity + ":" + city
+	default:
+		return nil, fmt.Errorf("неизвестная область рейтинга: %s", q.Scope)
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = leaderboardDefaultLimit
+	}
+	if limit > leaderboardMaxLimit {
+		limit = leaderboardMaxLimit
+	}
+
+	key := leaderboardKey(scope, q.Metric, period)
+
+	raw, err := redisClient.ZRevRangeWithScores(ctx, key, 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения рейтинга: %v", err)
+	}
+
+	resp := &LeaderboardResponse{
+		Scope:   q.Scope,
+		Metric:  q.Metric,
+		Window:  q.Window,
+		Period:  period,
+		Entries: []LeaderboardEntry{},
+	}
+
+	ids := make([]uint, 0, len(raw))
+	for _, z := range raw {
+		var id uint
+		if _, err := fmt.Sscan(fmt.Sprint(z.Member), &id); err != nil {
+			continue
+		}
+		resp.Entries = append(resp.Entries, LeaderboardEntry{
+			Rank:  len(resp.Entries) + 1,
+			ID:    id,
+			Score: leaderboardScore(q.Metric, z.Score),
+		})
+		ids = append(ids, id)
+	}
+
+	if len(ids) > 0 {
+		var users []models.User
+		if err := database.Select("id, name, us, image").Where("id IN ?", ids).Find(&users).Error; err != nil {
+			return nil, err
+		}
+		byID := make(map[uint]models.User, len(users))
+		for _, u := range users {
+			byID[u.ID] = u
+		}
+		for i := range resp.Entries {
+			u := byID[resp.Entries[i].ID]
+			resp.Entries[i].Name, resp.Entries[i].Us, resp.Entries[i].Image = u.Name, u.Us, u.Image
+		}
+	}
+
+	// Скрытого пользователя в наборе нет, и его места в ответе не будет
+	score, err := redisClient.ZScore(ctx, key, fmt.Sprint(user.ID)).Result()
+	if err == nil {
+		above, err := redisClient.ZCount(ctx, key, fmt.Sprintf("(%v", score), "+inf").Result()
+		if err != nil {
+			return nil, err
+		}
+		resp.Me = &LeaderboardEntry{
+			Rank:  int(above) + 1,
+			ID:    user.ID,
+			Name:  user.Name,
+			Us:    user.Us,
+			Image: user.Image,
+			Score: leaderboardScore(q.Metric, score),
+		}
+	} else if err != redis.Nil {
+		log.Printf("Ошибка чтения места пользователя в рейтинге: %v", err)
+	}
+
+	return resp, nil
+}
+
+func leaderboardScore(metric string, raw float64) float64 {
+	if metric == LeaderboardHours {
+		// Очки часов копятся в минутах, как spent_time
+		return float64(int(spentHours(uint64(raw))*10)) / 10
+	}
+	return raw
+}
+
+// hiddenLeaderboardUsers возвращает скрывших себя пользователей среди userIDs, nil — среди всех
+func hiddenLeaderboardUsers(database *gorm.DB, userIDs []uint) (map[uint]struct{}, error) {
+	query := database.Model(&statsusers.SettingTile{}).Where("leaderboard = ?", false)
+	if userIDs != nil {
+		if len(userIDs) == 0 {
+			return map[uint]struct{}{}, nil
+		}
+		query = query.Where("user_id IN ?", userIDs)
+	}
+
+	var ids []uint
+	if err := query.Pluck("user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[uint]struct{}, len(ids))
+	for _, id := range ids {
+		result[id] = struct{}{}
+	}
+	return result, nil
+}
+
+func notHidden(hidden map[uint]struct{}) func(uint) bool {
+	return func(uid uint) bool {
+		_, ok := hidden[uid]
+		return !ok
+	}
+}
+
+// setLeaderboardVisibility убирает пользователя из всех рейтингов или возвращает его туда,
+// заново собрав его очки по завершённым сессиям
+func setLeaderboardVisibility(database *gorm.DB, userID uint, visible bool) error {
+	redisClient := db.GetRedis()
+	if redisClient == nil {
+		return errors.New("Redis клиент недоступен")
+	}
+
+	member := fmt.Sprint(userID)
+	iter := redisClient.Scan(ctx, 0, leaderboardKeyPrefix+"*", 500).Iterator()
+	pipe := redisClient.Pipeline()
+	for iter.Next(ctx) {
+		pipe.ZRem(ctx, iter.Val(), member)
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	// Очки удаляются и при возврате, чтобы пересборка не удвоила их
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("ошибка обновления рейтингов: %v", err)
+	}
+	if !visible {
+		return nil
+	}
+
+	var rows []struct {
+		UserID    uint
+		GroupID   uint
+		City      string
+		Duration  uint16
+		StartTime time.Time
+		EndTime   time.Time
+		Attended  bool
+	}
+	if err := database.Raw(`
+		SELECT s.user_id, s.group_id, g.city, s.duration, s.start_time, s.end_time,
+			(s.user_id = ? OR EXISTS (SELECT 1 FROM session_users su WHERE su.session_id = s.id AND su.user_id = ?)) AS attended
+		FROM sessions s
+		JOIN statuses st ON st.id = s.status_id
+		JOIN groups g ON g.id = s.group_id
+		WHERE st.status = 'Завершена'
+		  AND (s.user_id = ? OR EXISTS (SELECT 1 FROM session_users su WHERE su.session_id = s.id AND su.user_id = ?))
+		ORDER BY s.end_time ASC
+	`, userID, userID, userID, userID).Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	streaks, err := loadStreaks(database, []uint{userID})
+	if err != nil {
+		return err
+	}
+
+	only := func(uid uint) bool { return uid == userID }
+	pipe = redisClient.Pipeline()
+	for _, r := range rows {
+		var participants []uint
+		if r.Attended {
+			participants = []uint{userID}
+		}
+		applySessionToLeaderboards(pipe, leaderboardSession{
+			HostID:       r.UserID,
+			GroupID:      r.GroupID,
+			City:         r.City,
+			EndTime:      r.EndTime,
+			Duration:     sessionDurationForStats(r.Duration, r.StartTime, r.EndTime),
+			Participants: participants,
+		}, streaks, only)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("ошибка обновления рейтингов: %v", err)
+	}
+	return nil
+}
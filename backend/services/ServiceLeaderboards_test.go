@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// recordingPipe запоминает команды рейтинга вместо отправки в Redis
+type recordingPipe struct {
+	redis.Pipeliner
+	incr    map[string]float64 // key|member -> сумма инкрементов
+	zadd    map[string]float64 // key|member -> счёт
+	expires map[string]time.Duration
+}
+
+func newRecordingPipe() *recordingPipe {
+	return &recordingPipe{
+		incr:    map[string]float64{},
+		zadd:    map[string]float64{},
+		expires: map[string]time.Duration{},
+	}
+}
+
+func (p *recordingPipe) ZIncrBy(ctx context.Context, key string, increment float64, member string) *redis.FloatCmd {
+	p.incr[key+"|"+member] += increment
+	return redis.NewFloatCmd(ctx)
+}
+
+func (p *recordingPipe) ZAddArgs(ctx context.Context, key string, args redis.ZAddArgs) *redis.IntCmd {
+	for _, m := range args.Members {
+		p.zadd[key+"|"+fmt.Sprint(m.Member)] = m.Score
+	}
+	return redis.NewIntCmd(ctx)
+}
+
+func (p *recordingPipe) Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd {
+	p.expires[key] = expiration
+	return redis.NewBoolCmd(ctx)
+}
+
+func testLeaderboardSession() leaderboardSession {
+	return leaderboardSession{
+		HostID:       1,
+		GroupID:      5,
+		City:         " Казань ",
+		EndTime:      time.Date(2025, 3, 12, 21, 0, 0, 0, time.UTC),
+		Duration:     90,
+		Participants: []uint{1, 2, 3},
+	}
+}
+
+func includeAll(uint) bool { return true }
+
+func TestApplySessionToLeaderboards(t *testing.T) {
+	pipe := newRecordingPipe()
+	applySessionToLeaderboards(pipe, testLeaderboardSession(), map[uint]uint16{2: 4}, includeAll)
+
+	week := leaderboardWeekPeriod(testLeaderboardSession().EndTime)
+	scopes := []string{"global", "group:5", "city:казань"}
+
+	for _, scope := range scopes {
+		for _, period := range []string{LeaderboardWindowAll, week, "2025-03"} {
+			for _, uid := range []string{"1", "2", "3"} {
+				if got := pipe.incr[leaderboardKey(scope, LeaderboardAttended, period)+"|"+uid]; got != 1 {
+					t.Errorf("%s/%s: attended пользователя %s = %v, want 1", scope, period, uid, got)
+				}
+				if got := pipe.incr[leaderboardKey(scope, LeaderboardHours, period)+"|"+uid]; got != 90 {
+					t.Errorf("%s/%s: hours пользователя %s = %v, want 90 минут", scope, period, uid, got)
+				}
+			}
+			if got := pipe.incr[leaderboardKey(scope, LeaderboardHosted, period)+"|1"]; got != 1 {
+				t.Errorf("%s/%s: hosted организатора = %v, want 1", scope, period, got)
+			}
+			if _, ok := pipe.incr[leaderboardKey(scope, LeaderboardHosted, period)+"|2"]; ok {
+				t.Errorf("%s/%s: hosted засчитан участнику", scope, period)
+			}
+		}
+
+		if got := pipe.zadd[leaderboardKey(scope, LeaderboardStreak, LeaderboardWindowAll)+"|2"]; got != 4 {
+			t.Errorf("%s: серия пользователя 2 = %v, want 4", scope, got)
+		}
+	}
+
+	// Серия пишется только в окно all
+	for key := range pipe.zadd {
+		if !strings.Contains(key, ":"+LeaderboardStreak+":"+LeaderboardWindowAll+"|") {
+			t.Errorf("серия записана вне окна all: %s", key)
+		}
+	}
+
+	// У недельных и месячных ключей есть срок жизни, у all — нет
+	for key, ttl := range pipe.expires {
+		switch {
+		case strings.HasSuffix(key, ":"+LeaderboardWindowAll):
+			t.Errorf("у ключа %s не должно быть срока жизни", key)
+		case strings.HasSuffix(key, ":"+week) && ttl != leaderboardWeekTTL:
+			t.Errorf("срок жизни %s = %v, want %v", key, ttl, leaderboardWeekTTL)
+		case strings.HasSuffix(key, ":2025-03") && ttl != leaderboardMonthTTL:
+			t.Errorf("срок жизни %s = %v, want %v", key, ttl, leaderboardMonthTTL)
+		}
+	}
+	if _, ok := pipe.expires[leaderboardKey("global", LeaderboardAttended, week)]; !ok {
+		t.Error("недельному ключу не выставлен срок жизни")
+	}
+}
+
+func TestApplySessionToLeaderboardsSkipsHidden(t *testing.T) {
+	pipe := newRecordingPipe()
+	hidden := map[uint]struct{}{1: {}, 3: {}}
+	applySessionToLeaderboards(pipe, testLeaderboardSession(), map[uint]uint16{1: 7, 2: 4}, notHidden(hidden))
+
+	for key := range pipe.incr {
+		if !strings.HasSuffix(key, "|2") {
+			t.Errorf("скрытый пользователь попал в рейтинг: %s", key)
+		}
+	}
+	for key := range pipe.zadd {
+		if !strings.HasSuffix(key, "|2") {
+			t.Errorf("серия скрытого пользователя попала в рейтинг: %s", key)
+		}
+	}
+	if len(pipe.incr) == 0 {
+		t.Error("видимый пользователь не попал в рейтинг")
+	}
+}
+
+func TestLeaderboardScore(t *testing.T) {
+	tests := []struct {
+		metric string
+		raw    float64
+		want   float64
+	}{
+		{LeaderboardHours, 90, 1.5},
+		{LeaderboardHours, 6000, 100},
+		{LeaderboardHours, 100, 1.6},
+		{LeaderboardHours, 0, 0},
+		{LeaderboardAttended, 12, 12},
+		{LeaderboardStreak, 4, 4},
+	}
+
+	for _, tt := range tests {
+		if got := leaderboardScore(tt.metric, tt.raw); got != tt.want {
+			t.Errorf("leaderboardScore(%s, %v) = %v, want %v", tt.metric, tt.raw, got, tt.want)
+		}
+	}
+}
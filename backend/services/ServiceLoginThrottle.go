@@ -0,0 +1,283 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"friendship/db"
+	"friendship/models"
+	"friendship/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Защита от перебора паролей и кодов. Ошибки считаются в Redis отдельно по аккаунту и по IP:
+// после нескольких ошибок каждая следующая попытка возможна только через растущую паузу,
+// а после лимита ключ блокируется на время. О блокировке аккаунта владельцу уходит письмо.
+
+const (
+	throttleFailsKey = "throttle:%s:%s:fails" // число ошибок в окне
+	throttleNextKey  = "throttle:%s:%s:next"  // пауза до следующей попытки
+	throttleLockKey  = "throttle:%s:%s:lock"  // временная блокировка
+)
+
+type attemptLimiter struct {
+	name         string
+	window       time.Duration // сколько помнить ошибки
+	freeAttempts int64         // ошибок без паузы
+	maxDelay     time.Duration
+	lockAfter    int64
+	lockFor      time.Duration
+}
+
+var (
+	loginAccountLimiter = attemptLimiter{name: "login_user", window: 15 * time.Minute, freeAttempts: 3, maxDelay: 30 * time.Second, lockAfter: 10, lockFor: 15 * time.Minute}
+	loginIPLimiter      = attemptLimiter{name: "login_ip", window: 15 * time.Minute, freeAttempts: 10, maxDelay: 30 * time.Second, lockAfter: 50, lockFor: 30 * time.Minute}
+	verifyIPLimiter     = attemptLimiter{name: "verify_ip", window: 15 * time.Minute, freeAttempts: 5, maxDelay: 30 * time.Second, lockAfter: 20, lockFor: 30 * time.Minute}
+)
+
+// TooManyAttemptsError — попытка отклонена до проверки пароля или кода
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return fmt.Sprintf("слишком много неудачных попыток, повторите через %d с", int(e.RetryAfter.Seconds()+0.5))
+}
+
+var ErrInvalidCredentials = errors.New("неверный логин или пароль")
+
+func throttleRedis() (*redis.Client, error) {
+	redisClient := db.GetRedis()
+	if redisClient == nil {
+		return nil, errors.New("хранилище сессий недоступно")
+	}
+	return redisClient, nil
+}
+
+// check возвращает ошибку, если для subject действует блокировка или пауза
+func (l attemptLimiter) check(redisClient *redis.Client, subject string) error {
+	pipe := redisClient.Pipeline()
+	lockTTL := pipe.PTTL(ctx, fmt.Sprintf(throttleLockKey, l.name, subject))
+	nextTTL := pipe.PTTL(ctx, fmt.Sprintf(throttleNextKey, l.name, subject))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	wait := lockTTL.Val()
+	if nextTTL.Val() > wait {
+		wait = nextTTL.Val()
+	}
+	if wait > 0 {
+		return &TooManyAttemptsError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// fail учитывает ошибку. locked = true, если именно эта ошибка привела к блокировке.
+func (l attemptLimiter) fail(redisClient *redis.Client, subject string) (bool, error) {
+	failsKey := fmt.Sprintf(throttleFailsKey, l.name, subject)
+
+	fails, err := redisClient.Incr(ctx, failsKey).Result()
+	if err != nil {
+		return false, err
+	}
+	if fails == 1 {
+		redisClient.Expire(ctx, failsKey, l.window)
+	}
+
+	delay, lock := l.penalty(fails)
+	if lock {
+		locked, err := redisClient.SetNX(ctx, fmt.Sprintf(throttleLockKey, l.name, subject), 1, l.lockFor).Result()
+		if err != nil {
+			return false, err
+		}
+		// После блокировки счёт начинается заново
+		redisClient.Del(ctx, failsKey)
+		return locked, nil
+	}
+
+	if delay > 0 {
+		if err := redisClient.Set(ctx, fmt.Sprintf(throttleNextKey, l.name, subject), 1, delay).Err(); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// penalty — что полагается за fails-ю ошибку в окне: блокировка либо пауза до следующей попытки.
+// Первые freeAttempts ошибок проходят без паузы, дальше она удваивается с 1 с до maxDelay.
+func (l attemptLimiter) penalty(fails int64) (delay time.Duration, lock bool) {
+	if fails >= l.lockAfter {
+		return 0, true
+	}
+	if fails <= l.freeAttempts {
+		return 0, false
+	}
+	delay = l.maxDelay
+	if shift := fails - l.freeAttempts - 1; shift < 6 {
+		if d := time.Second << shift; d < delay {
+			delay = d
+		}
+	}
+	return delay, false
+}
+
+func (l attemptLimiter) reset(redisClient *redis.Client, subject string) {
+	redisClient.Del(ctx,
+		fmt.Sprintf(throttleFailsKey, l.name, subject),
+		fmt.Sprintf(throttleNextKey, l.name, subject),
+	)
+}
+
+func accountSubject(userID uint) string {
+	return fmt.Sprintf("%d", userID)
+}
+
+// checkAccountAttempts проверяет, можно ли сейчас входить в аккаунт
+func checkAccountAttempts(userID uint) error {
+	redisClient, err := throttleRedis()
+	if err != nil {
+		return err
+	}
+	return loginAccountLimiter.check(redisClient, accountSubject(userID))
+}
+
+// registerAccountFailure учитывает неверный пароль или код второго фактора
+func registerAccountFailure(user *models.User) {
+	redisClient, err := throttleRedis()
+	if err != nil {
+		return
+	}
+	locked, err := loginAccountLimiter.fail(redisClient, accountSubject(user.ID))
+	if err != nil {
+		log.Printf("Не удалось учесть неудачный вход пользователя %d: %v", user.ID, err)
+		return
+	}
+	if locked {
+		log.Printf("Вход в аккаунт %d временно заблокирован после неудачных попыток", user.ID)
+		go sendLockoutEmail(user.Email)
+	}
+}
+
+func resetAccountFailures(userID uint) {
+	if redisClient, err := throttleRedis(); err == nil {
+		loginAccountLimiter.reset(redisClient, accountSubject(userID))
+	}
+}
+
+// Login проверяет email и пароль с учётом ограничений по IP и аккаунту
+func Login(email, password, ip string) (*models.User, error) {
+	redisClient, err := throttleRedis()
+	if err != nil {
+		return nil, err
+	}
+	if err := loginIPLimiter.check(redisClient, ip); err != nil {
+		return nil, err
+	}
+
+	user, err := FindUserByEmail(email)
+	if err != nil {
+		if _, err := loginIPLimiter.fail(redisClient, ip); err != nil {
+			log.Printf("Не удалось учесть неудачный вход с %s: %v", ip, err)
+		}
+		return nil, errors.New("пользователь не найден")
+	}
+
+	if err := checkAccountAttempts(user.ID); err != nil {
+		return nil, err
+	}
+
+	ok, needsRehash := utils.VerifyPassword(password, user.Password, user.Salt)
+	if !ok {
+		if _, err := loginIPLimiter.fail(redisClient, ip); err != nil {
+			log.Printf("Не удалось учесть неудачный вход с %s: %v", ip, err)
+		}
+		registerAccountFailure(user)
+		return nil, ErrInvalidCredentials
+	}
+
+	resetAccountFailures(user.ID)
+	if needsRehash {
+		upgradePasswordHash(user, password)
+	}
+	return user, nil
+}
+
+// checkVerifyAttempts ограничивает перебор кодов подтверждения с одного IP
+func checkVerifyAttempts(ip string) error {
+	redisClient, err := throttleRedis()
+	if err != nil {
+		return err
+	}
+	return verifyIPLimiter.check(redisClient, ip)
+}
+
+func registerVerifyFailure(ip string) {
+	redisClient, err := throttleRedis()
+	if err != nil {
+		return
+	}
+	if _, err := verifyIPLimiter.fail(redisClient, ip); err != nil {
+		log.Printf("Не удалось учесть неверный код с %s: %v", ip, err)
+	}
+}
+
+func sendLockoutEmail(email string) {
+	subject := "Вход в аккаунт временно заблокирован"
+
+	body := fmt.Sprintf(`
+	<!DOCTYPE html>
+	<html lang="ru">
+	<head>
+		<meta charset="UTF-8">
+		<style>
+			body {
+				font-family: Arial, sans-serif;
+				background-color: #f4f6f9;
+				margin: 0;
+				padding: 0;
+			}
+			.container {
+				max-width: 480px;
+				margin: 30px auto;
+				background: #fff;
+				border-radius: 12px;
+				padding: 24px;
+				box-shadow: 0 4px 12px rgba(0,0,0,0.1);
+			}
+			h2 {
+				color: #333;
+				text-align: center;
+			}
+			p {
+				font-size: 15px;
+				color: #555;
+				line-height: 1.6;
+			}
+			.footer {
+				font-size: 12px;
+				text-align: center;
+				color: #aaa;
+				margin-top: 16px;
+			}
+		</style>
+	</head>
+	<body>
+		<div class="container">
+			<h2>Вход временно заблокирован</h2>
+			<p>Здравствуйте! 👋</p>
+			<p>Мы заметили много неудачных попыток входа в ваш аккаунт и временно заблокировали вход на <b>%d минут</b>.</p>
+			<p>Если это были не вы, рекомендуем сменить пароль и включить двухфакторную аутентификацию.</p>
+			<div class="footer">© %d FriendShip</div>
+		</div>
+	</body>
+	</html>
+	`, int(loginAccountLimiter.lockFor.Minutes()), time.Now().Year())
+
+	if err := utils.SendEmail(email, subject, body); err != nil {
+		log.Printf("Ошибка отправки email: %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"friendship/db"
+	"testing"
+	"time"
+)
+
+func TestAttemptLimiterPenalty(t *testing.T) {
+	tests := []struct {
+		fails     int64
+		wantDelay time.Duration
+		wantLock  bool
+	}{
+		{1, 0, false},
+		{3, 0, false},
+		{4, time.Second, false},
+		{5, 2 * time.Second, false},
+		{6, 4 * time.Second, false},
+		{8, 16 * time.Second, false},
+		{9, 30 * time.Second, false}, // 32 с упирается в maxDelay
+		{10, 0, true},
+		{11, 0, true},
+	}
+
+	for _, tt := range tests {
+		delay, lock := loginAccountLimiter.penalty(tt.fails)
+		if delay != tt.wantDelay || lock != tt.wantLock {
+			t.Errorf("penalty(%d) = (%v, %v), want (%v, %v)", tt.fails, delay, lock, tt.wantDelay, tt.wantLock)
+		}
+	}
+}
+
+func TestAttemptLimiterPenaltyLongRun(t *testing.T) {
+	// Сдвиг не должен переполняться на длинных сериях до блокировки
+	for fails := loginIPLimiter.freeAttempts + 1; fails < loginIPLimiter.lockAfter; fails++ {
+		delay, lock := loginIPLimiter.penalty(fails)
+		if lock {
+			t.Fatalf("penalty(%d): блокировка раньше lockAfter", fails)
+		}
+		if delay <= 0 || delay > loginIPLimiter.maxDelay {
+			t.Fatalf("penalty(%d) = %v, want (0, %v]", fails, delay, loginIPLimiter.maxDelay)
+		}
+	}
+	if _, lock := loginIPLimiter.penalty(loginIPLimiter.lockAfter); !lock {
+		t.Error("нет блокировки на lockAfter")
+	}
+}
+
+func TestAttemptLimiterLockout(t *testing.T) {
+	testRedis(t)
+	redisClient := db.GetRedis()
+
+	limiter := attemptLimiter{
+		name:         fmt.Sprintf("test_%d", time.Now().UnixNano()),
+		window:       time.Minute,
+		freeAttempts: 1,
+		maxDelay:     30 * time.Second,
+		lockAfter:    3,
+		lockFor:      time.Minute,
+	}
+	const subject = "127.0.0.1"
+	t.Cleanup(func() {
+		redisClient.Del(ctx,
+			fmt.Sprintf(throttleFailsKey, limiter.name, subject),
+			fmt.Sprintf(throttleNextKey, limiter.name, subject),
+			fmt.Sprintf(throttleLockKey, limiter.name, subject),
+		)
+	})
+
+	retryAfter := func() time.Duration {
+		t.Helper()
+		err := limiter.check(redisClient, subject)
+		if err == nil {
+			return 0
+		}
+		var tooMany *TooManyAttemptsError
+		if !errors.As(err, &tooMany) {
+			t.Fatalf("check: %v", err)
+		}
+		return tooMany.RetryAfter
+	}
+
+	if wait := retryAfter(); wait != 0 {
+		t.Fatalf("до ошибок действует пауза %v", wait)
+	}
+
+	// Бесплатная ошибка
+	if locked, err := limiter.fail(redisClient, subject); err != nil || locked {
+		t.Fatalf("первая ошибка: locked=%v, err=%v", locked, err)
+	}
+	if wait := retryAfter(); wait != 0 {
+		t.Errorf("после бесплатной ошибки пауза %v", wait)
+	}
+
+	// Дальше — пауза
+	if locked, err := limiter.fail(redisClient, subject); err != nil || locked {
+		t.Fatalf("вторая ошибка: locked=%v, err=%v", locked, err)
+	}
+	if wait := retryAfter(); wait <= 0 || wait > time.Second {
+		t.Errorf("после второй ошибки пауза %v, want (0, 1s]", wait)
+	}
+
+	// На lockAfter — блокировка
+	locked, err := limiter.fail(redisClient, subject)
+	if err != nil || !locked {
+		t.Fatalf("третья ошибка: locked=%v, err=%v", locked, err)
+	}
+	if wait := retryAfter(); wait <= 30*time.Second || wait > time.Minute {
+		t.Errorf("после блокировки пауза %v, want около %v", wait, limiter.lockFor)
+	}
+
+	// Успешный вход не снимает блокировку, только счётчик и паузу
+	limiter.reset(redisClient, subject)
+	if wait := retryAfter(); wait <= 0 {
+		t.Error("reset снял блокировку")
+	}
+}
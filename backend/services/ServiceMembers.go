@@ -5,20 +5,26 @@ import (
 	"friendship/db"
 	"friendship/models"
 	"friendship/models/groups"
+
+	"gorm.io/gorm"
 )
 
 func getUserRole(userID, groupID uint) (string, error) {
+	return getUserRoleTx(db.GetDB(), userID, groupID)
+}
+
+func getUserRoleTx(tx *gorm.DB, userID, groupID uint) (string, error) {
 	var groupUser groups.GroupUsers
-	err := db.GetDB().Where("user_id = ? AND group_id = ?", userID, groupID).First(&groupUser).Error
+	err := tx.Where("user_id = ? AND group_id = ?", userID, groupID).First(&groupUser).Error
 	if err != nil {
 		return "", errors.New("пользователь не состоит в группе")
 	}
 	return groupUser.RoleInGroup, nil
 }
 
-func RemoveUserFromGroup(requesterEmail string, groupID, targetUserID uint) error {
+func RemoveUserFromGroup(principal models.Principal, groupID, targetUserID uint) error {
 	var requester models.User
-	if err := db.GetDB().Where("email = ?", requesterEmail).First(&requester).Error; err != nil {
+	if err := db.GetDB().First(&requester, principal.UserID).Error; err != nil {
 		return errors.New("пользователь не найден")
 	}
 
@@ -31,30 +37,83 @@ func RemoveUserFromGroup(requesterEmail string, groupID, targetUserID uint) erro
 		return errors.New("используйте /leave для выхода")
 	}
 
-	return db.GetDB().Where("user_id = ? AND group_id = ?", targetUserID, groupID).Delete(&groups.GroupUsers{}).Error
+	requesterRole, err := requireGroupCapability(db.GetDB(), requester.ID, groupID, groups.CapRemoveMembers)
+	if err != nil {
+		return err
+	}
+
+	var group groups.Group
+	if err := db.GetDB().First(&group, groupID).Error; err != nil {
+		return errors.New("группа не найдена")
+	}
+	if group.CreaterID == targetUserID {
+		return errors.New("нельзя удалить владельца группы")
+	}
+
+	targetRole, err := getUserRole(targetUserID, groupID)
+	if err != nil {
+		return err
+	}
+	// Удалять можно только участников с ролью ниже своей; владелец может удалить любого
+	if group.CreaterID != requester.ID && groups.RoleRank(targetRole) >= groups.RoleRank(requesterRole) {
+		return errors.New("нельзя удалить участника с такой же или более высокой ролью")
+	}
+
+	return db.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := removeGroupMemberTx(tx, groupID, targetUserID, "removed"); err != nil {
+			return err
+		}
+		return recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    groupID,
+			ActorID:    requester.ID,
+			Action:     AuditMemberRemoved,
+			TargetType: "user",
+			TargetID:   targetUserID,
+			Before:     map[string]interface{}{"role": targetRole},
+		})
+	})
 }
 
-func LeaveGroup(email string, groupID uint) error {
+func LeaveGroup(principal models.Principal, groupID uint) error {
 	var user models.User
-	if err := db.GetDB().Where("email = ?", email).First(&user).Error; err != nil {
+	if err := db.GetDB().First(&user, principal.UserID).Error; err != nil {
 		return errors.New("пользователь не найден")
 	}
 
-	role, err := getUserRole(user.ID, groupID)
-	if err != nil {
+	if _, err := getUserRole(user.ID, groupID); err != nil {
 		return err
 	}
 
-	if role == "admin" {
-		var adminCount int64
-		db.GetDB().Model(&groups.GroupUsers{}).
-			Where("group_id = ? AND role_in_group = ?", groupID, "admin").
-			Count(&adminCount)
+	// Если ушёл последний администратор, управление перейдёт другому участнику
+	return db.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := removeGroupMemberTx(tx, groupID, user.ID, "left"); err != nil {
+			return err
+		}
+		return ensureGroupHasAdmin(tx, groupID, user.ID)
+	})
+}
 
-		if adminCount <= 1 {
-			return errors.New("нельзя выйти — вы единственный админ")
+// removeGroupMemberTx удаляет участника и записывает выход для статистики оттока
+func removeGroupMemberTx(tx *gorm.DB, groupID, userID uint, reason string) error {
+	var member groups.GroupUsers
+	if err := tx.Select("id, joined_at").Where("user_id = ? AND group_id = ?", userID, groupID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
 		}
+		return err
 	}
 
-	return db.GetDB().Where("user_id = ? AND group_id = ?", user.ID, groupID).Delete(&groups.GroupUsers{}).Error
+	res := tx.Delete(&groups.GroupUsers{}, member.ID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil
+	}
+	return tx.Create(&groups.GroupMemberLeave{
+		GroupID:  groupID,
+		UserID:   userID,
+		Reason:   reason,
+		JoinedAt: member.JoinedAt,
+	}).Error
 }
@@ -164,7 +164,7 @@ func GetNewsByID(id int) (*NewsDTO, error) {
 	return result, nil
 }
 
-func CreateComment(newsID uint, email string, input CreateCommentInput) (*CommentResponse, error) {
+func CreateComment(newsID uint, principal models.Principal, input CreateCommentInput) (*CommentResponse, error) {
 	database := db.GetDB()
 
 	if input.Text == "" {
@@ -172,7 +172,7 @@ func CreateComment(newsID uint, email string, input CreateCommentInput) (*Commen
 	}
 
 	var user models.User
-	if err := database.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := database.First(&user, principal.UserID).Error; err != nil {
 		return nil, err
 	}
 
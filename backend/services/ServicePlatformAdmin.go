@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"friendship/db"
+	"friendship/models"
+	"friendship/models/sessions"
+	"friendship/utils"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Операционные функции для friendship-admin
+
+const (
+	PlatformRoleUser  = "user"
+	PlatformRoleAdmin = "admin"
+)
+
+type ReprocessReport struct {
+	Total            int             `json:"total"`
+	Processed        int             `json:"processed"`
+	AlreadyProcessed int             `json:"already_processed"`
+	Failed           map[uint]string `json:"failed"`
+}
+
+// CreatePlatformAdmin создаёт подтверждённого пользователя с ролью администратора платформы
+func CreatePlatformAdmin(name, email, password string) (*models.User, error) {
+	if err := GetValidator().Var(password, "password"); err != nil {
+		return nil, errors.New("пароль не соответствует требованиям безопасности")
+	}
+	if err := GetValidator().Var(email, "required,email"); err != nil {
+		return nil, errors.New("некорректный email")
+	}
+
+	user := models.User{
+		Name:         name,
+		Password:     utils.HashPassword(password),
+		Email:        email,
+		Us:           generateUsername(),
+		VerifiedUser: true,
+		Role:         PlatformRoleAdmin,
+	}
+
+	if err := db.GetDB().Create(&user).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("пользователь с таким email уже существует")
+		}
+		return nil, err
+	}
+
+	if err := createDefaultUserStats(user.ID); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// SetPlatformRole меняет роль пользователя на платформе (user/admin)
+func SetPlatformRole(email, role string) error {
+	if role != PlatformRoleUser && role != PlatformRoleAdmin {
+		return fmt.Errorf("неизвестная роль: %s", role)
+	}
+
+	user, err := FindUserByEmail(email)
+	if err != nil {
+		return errors.New("пользователь не найден")
+	}
+	if err := db.GetDB().Model(user).Update("role", role).Error; err != nil {
+		return err
+	}
+	db.InvalidatePrincipal(user.ID)
+	return nil
+}
+
+// ReprocessFinishedSessions повторно запускает подсчёт статистики для завершённых сессий,
+// закончившихся в промежутке [from, to]. Уже учтённые сессии пропускаются самим
+// UpdateStatisticsForFinishedSession, поэтому повторный запуск безопасен.
+func ReprocessFinishedSessions(ctx context.Context, from, to time.Time) (*ReprocessReport, error) {
+	var finished sessions.Status
+	if err := db.GetDB().Where("status = ?", "Завершена").First(&finished).Error; err != nil {
+		return nil, fmt.Errorf("статус 'Завершена' не найден: %v", err)
+	}
+
+	var sessionIDs []uint
+	if err := db.GetDB().Model(&sessions.Session{}).
+		Where("status_id = ? AND end_time BETWEEN ? AND ?", finished.ID, from, to).
+		Order("end_time ASC").
+		Pluck("id", &sessionIDs).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении сессий: %v", err)
+	}
+
+	report := &ReprocessReport{
+		Total:  len(sessionIDs),
+		Failed: make(map[uint]string),
+	}
+
+	for _, id := range sessionIDs {
+		processed, err := ReprocessFinishedSession(ctx, id)
+		if err != nil {
+			report.Failed[id] = err.Error()
+			continue
+		}
+		if processed {
+			report.Processed++
+		} else {
+			report.AlreadyProcessed++
+		}
+	}
+
+	return report, nil
+}
+
+// ReprocessFinishedSession запускает подсчёт статистики для одной сессии.
+// Возвращает false, если сессия уже была учтена ранее.
+func ReprocessFinishedSession(ctx context.Context, sessionID uint) (bool, error) {
+	var count int64
+	if err := db.GetDB().Model(&models.StatsProcessedEvent{}).
+		Where("session_id = ?", sessionID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	if err := UpdateStatisticsForFinishedSession(ctx, sessionID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RecountSessionUsers пересчитывает CurrentUsers по таблице session_users.
+// Если sessionID == 0, пересчитываются все сессии. Возвращает число исправленных строк.
+func RecountSessionUsers(sessionID uint) (int64, error) {
+	query := `
+		UPDATE sessions s
+		SET current_users = sub.cnt
+		FROM (
+			SELECT s2.id, COUNT(su.id) AS cnt
+			FROM sessions s2
+			LEFT JOIN session_users su ON su.session_id = s2.id
+			GROUP BY s2.id
+		) sub
+		WHERE sub.id = s.id
+		  AND s.current_users <> sub.cnt`
+
+	args := []interface{}{}
+	if sessionID != 0 {
+		query += " AND s.id = ?"
+		args = append(args, sessionID)
+	}
+
+	res := db.GetDB().Exec(query, args...)
+	if res.Error != nil {
+		return 0, fmt.Errorf("ошибка пересчёта участников: %v", res.Error)
+	}
+	return res.RowsAffected, nil
+}
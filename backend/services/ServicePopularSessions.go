@@ -127,6 +127,11 @@ func GetPopularSessionsFromCache() (*CachedPopularSessions, error) {
 	return &result, nil
 }
 
+// RefreshPopularSessionsCache принудительно пересобирает кэш популярных сессий
+func RefreshPopularSessionsCache() error {
+	return updatePopularSessionsCache()
+}
+
 // updatePopularSessionsCache обновляет кэш популярных сессий
 func updatePopularSessionsCache() error {
 	redisClient := db.GetRedis()
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"friendship/db"
+	"friendship/models"
+	"friendship/models/sessions"
+
+	"gorm.io/gorm"
+)
+
+// Персональная лента: открытые сессии в статусе "Набор", ранжированные по жанрам и типам,
+// которые пользователь посещает, его городу, группам и компаньонам.
+// Каждый сигнал добавляет к score свой вес и строку в объяснение.
+
+const (
+	RECOMMENDATIONS_CACHE_KEY = "recommendations:%d"
+	RECOMMENDATIONS_CACHE_TTL = 30 * time.Minute
+
+	recommendationsLimit     = 20
+	recommendationCandidates = 300
+
+	weightGenre      = 3.0
+	weightType       = 2.0
+	weightGroup      = 2.0
+	weightCity       = 1.5
+	weightCompanion  = 1.0
+	maxCompanionHits = 3
+)
+
+type RecommendedSession struct {
+	PopularSessionResponse
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+type CachedRecommendations struct {
+	Sessions  []RecommendedSession `json:"sessions"`
+	UpdatedAt time.Time            `json:"updated_at"`
+	Count     int                  `json:"count"`
+}
+
+type recommendationProfile struct {
+	genres     map[string]uint16
+	maxGenre   uint16
+	topType    string
+	cities     map[string]struct{}
+	groups     map[uint]struct{}
+	companions map[uint]string
+}
+
+// GetRecommendedSessions возвращает персональную ленту из кэша или считает её заново
+func GetRecommendedSessions(principal models.Principal) (*CachedRecommendations, error) {
+	var user models.User
+	if err := db.GetDB().First(&user, principal.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("пользователь не найден")
+		}
+		return nil, err
+	}
+
+	redisClient := db.GetRedis()
+	key := fmt.Sprintf(RECOMMENDATIONS_CACHE_KEY, user.ID)
+
+	if redisClient != nil {
+		if cached, err := redisClient.Get(ctx, key).Result(); err == nil {
+			var result CachedRecommendations
+			if err := json.Unmarshal([]byte(cached), &result); err == nil {
+				return &result, nil
+			}
+		}
+	}
+
+	recommended, err := buildRecommendations(db.GetDB(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CachedRecommendations{
+		Sessions:  recommended,
+		UpdatedAt: time.Now(),
+		Count:     len(recommended),
+	}
+
+	if redisClient != nil {
+		if data, err := json.Marshal(result); err == nil {
+			if err := redisClient.Set(ctx, key, data, RECOMMENDATIONS_CACHE_TTL).Err(); err != nil {
+				log.Printf("Не удалось сохранить рекомендации в Redis: %v", err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// invalidateRecommendations сбрасывает кэш ленты, например после записи на сессию
+func invalidateRecommendations(userID uint) {
+	redisClient := db.GetRedis()
+	if redisClient == nil {
+		return
+	}
+	if err := redisClient.Del(ctx, fmt.Sprintf(RECOMMENDATIONS_CACHE_KEY, userID)).Err(); err != nil {
+		log.Printf("Не удалось сбросить кэш рекомендаций пользователя %d: %v", userID, err)
+	}
+}
+
+func buildRecommendations(database *gorm.DB, userID uint) ([]RecommendedSession, error) {
+	profile, err := loadRecommendationProfile(database, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var recruitmentStatus sessions.Status
+	if err := database.Where("status = ?", "Набор").First(&recruitmentStatus).Error; err != nil {
+		return nil, fmt.Errorf("статус 'Набор' не найден: %v", err)
+	}
+
+	var candidates []struct {
+		ID                uint
+		Title             string
+		StartTime         time.Time
+		EndTime           time.Time
+		Duration          uint16
+		CurrentUsers      uint16
+		CountUsersMax     uint16
+		ImageURL          string
+		SessionTypeName   string
+		SessionPlaceTitle string
+		GroupID           uint
+		GroupName         string
+		GroupCity         string
+	}
+
+	// Открытые сессии публичных групп и групп пользователя, куда он ещё не записан
+	if err := database.Raw(`
+		SELECT
+			s.id, s.title, s.start_time, s.end_time, s.duration,
+			s.current_users, s.count_users_max, s.image_url,
+			c.name AS session_type_name,
+			sgp.title AS session_place_title,
+			g.id AS group_id, g.name AS group_name, g.city AS group_city
+		FROM sessions s
+		JOIN categories c ON s.session_type_id = c.id
+		JOIN session_group_places sgp ON s.session_place_id = sgp.id
+		JOIN groups g ON s.group_id = g.id
+		WHERE s.status_id = ?
+		  AND s.start_time > NOW()
+		  AND s.current_users < s.count_users_max
+		  AND (g.is_private = false OR EXISTS (
+		        SELECT 1 FROM group_users gu WHERE gu.group_id = g.id AND gu.user_id = ?))
+		  AND NOT EXISTS (
+		        SELECT 1 FROM session_users su WHERE su.session_id = s.id AND su.user_id = ?)
+		ORDER BY s.start_time ASC
+		LIMIT ?
+	`, recruitmentStatus.ID, userID, userID, recommendationCandidates).Scan(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("ошибка выполнения SQL запроса: %v", err)
+	}
+
+	if len(candidates) == 0 {
+		return []RecommendedSession{}, nil
+	}
+
+	sessionIDs := make([]uint, len(candidates))
+	for i, s := range candidates {
+		sessionIDs[i] = s.ID
+	}
+
+	metadataMap, err := db.GetSessionsMetadata(sessionIDs)
+	if err != nil {
+		log.Printf("Предупреждение: ошибка получения метаданных из MongoDB: %v", err)
+		metadataMap = make(map[uint]*sessions.SessionMetadata)
+	}
+
+	companionsBySession := make(map[uint][]string)
+	if len(profile.companions) > 0 {
+		companionIDs := make([]uint, 0, len(profile.companions))
+		for id := range profile.companions {
+			companionIDs = append(companionIDs, id)
+		}
+		var joined []struct {
+			SessionID uint
+			UserID    uint
+		}
+		if err := database.Table("session_users").
+			Select("session_id, user_id").
+			Where("session_id IN ? AND user_id IN ?", sessionIDs, companionIDs).
+			Scan(&joined).Error; err != nil {
+			return nil, err
+		}
+		for _, j := range joined {
+			companionsBySession[j.SessionID] = append(companionsBySession[j.SessionID], profile.companions[j.UserID])
+		}
+	}
+
+	result := make([]RecommendedSession, 0, len(candidates))
+	for _, s := range candidates {
+		item := RecommendedSession{
+			PopularSessionResponse: PopularSessionResponse{
+				ID:            s.ID,
+				Title:         s.Title,
+				StartTime:     s.StartTime,
+				EndTime:       s.EndTime,
+				Duration:      s.Duration,
+				SessionType:   s.SessionTypeName,
+				SessionPlace:  s.SessionPlaceTitle,
+				ImageURL:      s.ImageURL,
+				CurrentUsers:  s.CurrentUsers,
+				CountUsersMax: s.CountUsersMax,
+				GroupName:     s.GroupName,
+				Genres:        []string{},
+			},
+			Reasons: []string{},
+		}
+		if s.CountUsersMax > 0 {
+			item.PopularityRate = float64(s.CurrentUsers) / float64(s.CountUsersMax)
+		}
+		if metadata, ok := metadataMap[s.ID]; ok && metadata != nil {
+			item.Genres = metadata.Genres
+		}
+
+		var bestGenre string
+		var bestGenreCount uint16
+		for _, g := range item.Genres {
+			if c := profile.genres[g]; c > bestGenreCount {
+				bestGenre, bestGenreCount = g, c
+			}
+		}
+		if bestGenreCount > 0 {
+			item.Score += weightGenre * float64(bestGenreCount) / float64(profile.maxGenre)
+			item.Reasons = append(item.Reasons, fmt.Sprintf("Жанр «%s» — вы часто его выбираете", bestGenre))
+		}
+
+		if profile.topType != "" && s.SessionTypeName == profile.topType {
+			item.Score += weightType
+			item.Reasons = append(item.Reasons, fmt.Sprintf("Ваш любимый тип сессий: %s", s.SessionTypeName))
+		}
+
+		if _, ok := profile.groups[s.GroupID]; ok {
+			item.Score += weightGroup
+			item.Reasons = append(item.Reasons, fmt.Sprintf("Из вашей группы «%s»", s.GroupName))
+		}
+
+		if _, ok := profile.cities[strings.ToLower(s.GroupCity)]; ok && s.GroupCity != "" {
+			item.Score += weightCity
+			item.Reasons = append(item.Reasons, fmt.Sprintf("В вашем городе: %s", s.GroupCity))
+		}
+
+		if names := companionsBySession[s.ID]; len(names) > 0 {
+			hits := len(names)
+			if hits > maxCompanionHits {
+				hits = maxCompanionHits
+			}
+			item.Score += weightCompanion * float64(hits)
+			item.Reasons = append(item.Reasons, fmt.Sprintf("Идут ваши компаньоны: %s", strings.Join(names, ", ")))
+		}
+
+		if item.Score == 0 {
+			continue
+		}
+		// Заполненность — только для разрешения ничьих
+		item.Score += item.PopularityRate * 0.1
+
+		result = append(result, item)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+	if len(result) > recommendationsLimit {
+		result = result[:recommendationsLimit]
+	}
+
+	return result, nil
+}
+
+func loadRecommendationProfile(database *gorm.DB, userID uint) (*recommendationProfile, error) {
+	profile := &recommendationProfile{
+		genres:     make(map[string]uint16),
+		cities:     make(map[string]struct{}),
+		groups:     make(map[uint]struct{}),
+		companions: make(map[uint]string),
+	}
+
+	var genres []struct {
+		Name  string
+		Count uint16
+	}
+	if err := database.Table("sessions_stats_genres_users").
+		Select("genres.name, sessions_stats_genres_users.count").
+		Joins("JOIN genres ON sessions_stats_genres_users.genre_id = genres.id").
+		Where("sessions_stats_genres_users.user_id = ?", userID).
+		Scan(&genres).Error; err != nil {
+		return nil, err
+	}
+	for _, g := range genres {
+		profile.genres[g.Name] = g.Count
+		if g.Count > profile.maxGenre {
+			profile.maxGenre = g.Count
+		}
+	}
+
+	if err := database.Table("pop_session_types").
+		Select("categories.name").
+		Joins("JOIN categories ON categories.id = pop_session_types.session_type_id").
+		Where("pop_session_types.user_id = ?", userID).
+		Limit(1).
+		Scan(&profile.topType).Error; err != nil {
+		return nil, err
+	}
+
+	var groupRows []struct {
+		ID   uint
+		City string
+	}
+	if err := database.Table("group_users").
+		Select("groups.id, groups.city").
+		Joins("JOIN groups ON groups.id = group_users.group_id").
+		Where("group_users.user_id = ?", userID).
+		Scan(&groupRows).Error; err != nil {
+		return nil, err
+	}
+	for _, g := range groupRows {
+		profile.groups[g.ID] = struct{}{}
+		if g.City != "" {
+			profile.cities[strings.ToLower(g.City)] = struct{}{}
+		}
+	}
+
+	companions, err := getTopCompanions(database, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range companions {
+		profile.companions[c.ID] = c.Name
+	}
+
+	return profile, nil
+}
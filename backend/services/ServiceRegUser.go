@@ -33,6 +33,8 @@ type VerifySessionInput struct {
 
 var validate = binding.Validator.Engine().(*validator.Validate)
 
+var ErrVerifyAttemptsSpent = errors.New("превышено количество попыток ввода кода, сессия удалена")
+
 func generateUsername() string {
 	username := "user"
 
@@ -60,12 +62,9 @@ func CreateUser(input CreateUserInput) (*models.User, error) {
 
 	us := generateUsername()
 
-	hashPass, salt := utils.HashPassword(input.Password)
-
 	user := models.User{
 		Name:     input.Name,
-		Password: hashPass,
-		Salt:     salt,
+		Password: utils.HashPassword(input.Password),
 		Email:    input.Email,
 		Us:       us,
 	}
@@ -79,43 +78,54 @@ func CreateUser(input CreateUserInput) (*models.User, error) {
 		return nil, err
 	}
 
+	if err := createDefaultUserStats(user.ID); err != nil {
+		return nil, err
+	}
+
+	if err := store.DeleteSession(input.SessionID); err != nil {
+		fmt.Printf("Не удалось удалить сессию %s: %v\n", input.SessionID, err)
+	}
+
+	return nil, nil
+}
+
+// createDefaultUserStats создаёт строки статистики и настройки тайлов для нового пользователя
+func createDefaultUserStats(userID uint) error {
 	defaultTiles := statsusers.SettingTile{
-		UserID:      user.ID,
+		UserID:      userID,
 		Count_films: true,
 		Count_games: true,
 		Count_table: true,
 		Count_other: false,
 		Count_all:   true,
 		Spent_time:  false,
+		Companions:  true,
+		Leaderboard: true,
 	}
 
 	StatsUser := statsusers.SessionStats_users{
-		UserID: user.ID,
+		UserID: userID,
 	}
 
 	defaultDay := uint16(1)
 	SideInf := statsusers.SideStats_users{
-		UserID:     &user.ID,
+		UserID:     &userID,
 		MostPopDay: &defaultDay,
 	}
 
 	if err := db.GetDB().Create(&StatsUser).Error; err != nil {
-		return nil, fmt.Errorf("не удалось создать статистику пользователя: %v", err)
+		return fmt.Errorf("не удалось создать статистику пользователя: %v", err)
 	}
 
 	if err := db.GetDB().Create(&defaultTiles).Error; err != nil {
-		return nil, fmt.Errorf("не удалось создать настройки тайлов: %v", err)
+		return fmt.Errorf("не удалось создать настройки тайлов: %v", err)
 	}
 
 	if err := db.GetDB().Create(&SideInf).Error; err != nil {
-		return nil, fmt.Errorf("не удалось создать статистику сессии: %v", err)
-	}
-
-	if err := store.DeleteSession(input.SessionID); err != nil {
-		fmt.Printf("Не удалось удалить сессию %s: %v\n", input.SessionID, err)
+		return fmt.Errorf("не удалось создать статистику сессии: %v", err)
 	}
 
-	return nil, nil
+	return nil
 }
 
 func CreateSessionRegister(email string) (*models.SessionRegResponse, error) {
@@ -204,69 +214,74 @@ func CreateSessionRegister(email string) (*models.SessionRegResponse, error) {
 	return &models.SessionRegResponse{SessionID: Id}, nil
 }
 
-func VerifySession(input VerifySessionInput) (bool, error) {
-	store := db.NewSessionStore(os.Getenv("REDIS_URI"))
+// maxVerifyCodeAttempts — неверных кодов на одну сессию подтверждения, после чего она удаляется
+const maxVerifyCodeAttempts = 3
 
-	fields, err := store.GetSessionFields(input.SessionID, "code", "type", "attempts")
-	if err != nil {
+// VerifySession проверяет код из письма. Ошибки считаются по сессии и по IP.
+func VerifySession(input VerifySessionInput, ip string) (bool, error) {
+	if err := checkVerifyAttempts(ip); err != nil {
 		return false, err
 	}
 
-	if fields["code"] == "" || fields["type"] == "" {
-		return false, fmt.Errorf("сессия не найдена или удалена")
-	}
-
-	if fields["type"] != input.Type {
-		return false, nil
-	}
+	store := db.NewSessionStore(os.Getenv("REDIS_URI"))
 
-	attemptsStr := fields["attempts"]
-	attempts, err := strconv.Atoi(attemptsStr)
+	result, err := store.VerifyCode(input.SessionID, models.SessionTypeReg(input.Type), input.Code, maxVerifyCodeAttempts)
 	if err != nil {
-		attempts = 0
+		return false, err
 	}
 
-	if fields["code"] != input.Code {
-		attempts++
-		if attempts >= 3 {
-			err := store.DeleteSession(input.SessionID)
-			if err != nil {
-				return false, err
-			}
-			return false, fmt.Errorf("превышено количество попыток ввода кода, сессия удалена")
-		}
-
-		err = store.UpdateSessionField(input.SessionID, "attempts", attempts)
-		if err != nil {
-			return false, err
-		}
+	switch result {
+	case db.CodeVerified:
+		return true, nil
+	case db.CodeNoSession:
+		return false, fmt.Errorf("сессия не найдена или удалена")
+	case db.CodeWrongType:
+		return false, nil
+	case db.CodeAttemptsSpent:
+		registerVerifyFailure(ip)
+		return false, ErrVerifyAttemptsSpent
+	default:
+		registerVerifyFailure(ip)
 		return false, nil
 	}
+}
+
+// upgradePasswordHash пересчитывает хэш пароля с текущими параметрами после успешного входа.
+// Условие на старый хэш не даёт затереть пароль, сменённый параллельно.
+func upgradePasswordHash(user *models.User, password string) {
+	newHash := utils.HashPassword(password)
 
-	err = store.UpdateSessionField(input.SessionID, "is_verified", "1")
+	err := db.GetDB().Model(&models.User{}).
+		Where("id = ? AND password = ?", user.ID, user.Password).
+		Updates(map[string]interface{}{"password": newHash, "salt": ""}).Error
 	if err != nil {
-		return false, err
+		log.Printf("Не удалось обновить хэш пароля пользователя %d: %v", user.ID, err)
+		return
 	}
 
-	_ = store.UpdateSessionField(input.SessionID, "attempts", 0)
-
-	return true, nil
+	user.Password = newHash
+	user.Salt = ""
 }
 
-func ChangePassword(email, newPassword string) error {
+// ChangePassword меняет пароль и завершает сессии входа на других устройствах
+func ChangePassword(principal models.Principal, newPassword string) error {
 	var user models.User
-	if err := db.GetDB().Where("email = ?", email).First(&user).Error; err != nil {
+	if err := db.GetDB().First(&user, principal.UserID).Error; err != nil {
 		return errors.New("пользователь не найден")
 	}
 
-	hashPass, salt := utils.HashPassword(newPassword)
-
-	user.Password = hashPass
-	user.Salt = salt
+	user.Password = utils.HashPassword(newPassword)
+	user.Salt = ""
 
 	if err := db.GetDB().Save(&user).Error; err != nil {
 		return err
 	}
 
+	// Остальные устройства выходят из аккаунта, текущая сессия остаётся
+	db.InvalidatePrincipal(user.ID)
+	if err := RevokeOtherAuthSessions(user.ID, principal.SessionID); err != nil {
+		log.Printf("Не удалось завершить сессии пользователя %d после смены пароля: %v", user.ID, err)
+	}
+
 	return nil
 }
@@ -1,12 +1,14 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"friendship/db"
 	"friendship/models"
 	"friendship/utils"
 	"log"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -18,6 +20,8 @@ type ConfirmResetPasswordInput struct {
 	SessionID string `json:"session_id" binding:"required"`
 	Email     string `json:"email" binding:"required,email"`
 	Password  string `json:"password" binding:"required,password"`
+	// Код из приложения или код восстановления, обязателен при включённой 2FA
+	TwoFactorCode string `json:"two_factor_code" binding:"max=20"`
 }
 
 func CreateSessionReset(email string) (*models.SessionRegResponse, error) {
@@ -30,7 +34,8 @@ func CreateSessionReset(email string) (*models.SessionRegResponse, error) {
 	code := utils.GenerationSessionCode(6)
 
 	store := db.NewSessionStore(os.Getenv("REDIS_URI"))
-	err := store.CreateSession(sessionID, code, models.SessionTypeResetPassword, 10*time.Minute)
+	// Сессия привязана к email: подтверждённым кодом можно сбросить пароль только этого аккаунта
+	err := store.CreateSession(sessionID, code, models.SessionTypeResetPassword, 10*time.Minute, map[string]string{"email": user.Email})
 	if err != nil {
 		return nil, err
 	}
@@ -113,31 +118,61 @@ func CreateSessionReset(email string) (*models.SessionRegResponse, error) {
 func ResetPassword(input ConfirmResetPasswordInput) error {
 	store := db.NewSessionStore(os.Getenv("REDIS_URI"))
 
-	fields, err := store.GetSessionFields(input.SessionID, "code", "type", "attempts")
+	fields, err := store.GetSessionFields(input.SessionID, "type", "is_verified", "email")
 	if err != nil {
 		return err
 	}
 
-	if fields == nil {
-		return fmt.Errorf("Превышено количество попыток смены пароля, сессия деактивирована")
+	if len(fields) == 0 {
+		return fmt.Errorf("сессия не найдена или удалена")
 	}
 
 	if fields["type"] != string(models.SessionTypeResetPassword) {
 		return fmt.Errorf("неверный тип сессии")
 	}
 
+	if fields["is_verified"] != "1" {
+		return fmt.Errorf("код сессии не подтверждён")
+	}
+
+	if !strings.EqualFold(fields["email"], input.Email) {
+		return fmt.Errorf("сессия выдана для другого email")
+	}
+
 	var user models.User
 	if err := db.GetDB().Where("email = ?", input.Email).First(&user).Error; err != nil {
 		return fmt.Errorf("пользователь не найден")
 	}
 
-	hashPass, salt := utils.HashPassword(input.Password)
-	user.Password = hashPass
-	user.Salt = salt
+	twoFactor, err := TwoFactorEnabled(user.ID)
+	if err != nil {
+		return err
+	}
+	if twoFactor {
+		if err := checkAccountAttempts(user.ID); err != nil {
+			return err
+		}
+		if err := verifySecondFactor(db.GetDB(), user.ID, input.TwoFactorCode); err != nil {
+			if errors.Is(err, ErrTwoFactorInvalidCode) {
+				registerAccountFailure(&user)
+			}
+			return err
+		}
+	}
+
+	user.Password = utils.HashPassword(input.Password)
+	user.Salt = ""
 
 	if err := db.GetDB().Save(&user).Error; err != nil {
 		return err
 	}
 
+	// После сброса пароля старые входы больше не должны работать
+	if err := RevokeAllAuthSessions(user.ID); err != nil {
+		log.Printf("Не удалось завершить сессии пользователя %d после сброса пароля: %v", user.ID, err)
+	}
+
+	resetAccountFailures(user.ID)
+
 	return store.DeleteSession(input.SessionID)
 }
@@ -34,7 +34,8 @@ func SearchGroups(name string, page int, sortBy, order, category string) (*GetGr
 
 	query := dbConn.Model(&groups.Group{}).
 		Preload("Categories").
-		Preload("Creater")
+		Preload("Creater").
+		Where("groups.archived_at IS NULL")
 
 	if name != "" {
 		searchPattern := "%" + name + "%"
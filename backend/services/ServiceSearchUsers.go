@@ -27,7 +27,10 @@ func SearchUsers(name string, page int, userId uint) (*GetUsersResponse, error)
 	var users []models.User
 	var total int64
 
-	query := db.GetDB().Model(&models.User{}).Where("id != ?", userId)
+	query := db.GetDB().Model(&models.User{}).
+		Where("id != ?", userId).
+		Where("id NOT IN (?)", blockedWithSubquery(db.GetDB(), userId)).
+		Where("id NOT IN (?)", unsearchableSubquery(db.GetDB()))
 
 	if name != "" {
 		if strings.HasPrefix(name, "@") {
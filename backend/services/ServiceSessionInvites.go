@@ -0,0 +1,231 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"friendship/db"
+	"friendship/models"
+	"friendship/models/groups"
+	"friendship/models/sessions"
+
+	"gorm.io/gorm"
+)
+
+const (
+	sessionInviteNotificationType = "session_invite"
+	maxSessionInvitesPerRequest   = 50
+)
+
+type InviteFriendsInput struct {
+	UserIDs []uint `json:"user_ids" binding:"required,min=1"`
+}
+
+type SkippedInvite struct {
+	UserID uint   `json:"user_id"`
+	Reason string `json:"reason"`
+}
+
+type InviteFriendsResponse struct {
+	Invited []uint          `json:"invited"`
+	Skipped []SkippedInvite `json:"skipped"`
+}
+
+type SessionInviteDTO struct {
+	ID           uint      `json:"id"`
+	SessionID    uint      `json:"sessionId"`
+	SessionTitle string    `json:"sessionTitle"`
+	StartTime    time.Time `json:"startTime"`
+	GroupID      uint      `json:"groupId"`
+	FromUserID   uint      `json:"fromUserId"`
+	FromUserName string    `json:"fromUserName"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// InviteFriendsToSession приглашает друзей на сессию, в которой участвует пользователь.
+// Неподходящие приглашённые пропускаются с указанием причины.
+func InviteFriendsToSession(principal models.Principal, sessionID uint, input InviteFriendsInput) (*InviteFriendsResponse, error) {
+	if len(input.UserIDs) > maxSessionInvitesPerRequest {
+		return nil, fmt.Errorf("за раз можно пригласить не больше %d друзей", maxSessionInvitesPerRequest)
+	}
+
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+
+	var session sessions.Session
+	if err := database.Preload("Group").Preload("Status").First(&session, sessionID).Error; err != nil {
+		return nil, errors.New("сессия не найдена")
+	}
+	if session.Status.Status != "Набор" {
+		return nil, errors.New("набор на сессию закрыт")
+	}
+	if session.CurrentUsers >= session.CountUsersMax {
+		return nil, errors.New("сессия заполнена")
+	}
+
+	var participation int64
+	if err := database.Model(&sessions.SessionUser{}).
+		Where("session_id = ? AND user_id = ?", session.ID, user.ID).
+		Count(&participation).Error; err != nil {
+		return nil, err
+	}
+	if participation == 0 {
+		return nil, errors.New("приглашать могут только участники сессии")
+	}
+
+	response := &InviteFriendsResponse{Invited: make([]uint, 0), Skipped: make([]SkippedInvite, 0)}
+	err := database.Transaction(func(tx *gorm.DB) error {
+		var friendIDs []uint
+		if err := friendsSubquery(tx, user.ID).Scan(&friendIDs).Error; err != nil {
+			return err
+		}
+		friends := make(map[uint]struct{}, len(friendIDs))
+		for _, id := range friendIDs {
+			friends[id] = struct{}{}
+		}
+
+		banned, err := bannedUserIDs(tx, session.GroupID, input.UserIDs)
+		if err != nil {
+			return err
+		}
+
+		blocked, err := blockedWith(tx, user.ID, input.UserIDs)
+		if err != nil {
+			return err
+		}
+
+		var joinedIDs []uint
+		if err := tx.Model(&sessions.SessionUser{}).
+			Where("session_id = ? AND user_id IN ?", session.ID, input.UserIDs).
+			Pluck("user_id", &joinedIDs).Error; err != nil {
+			return err
+		}
+		joined := make(map[uint]struct{}, len(joinedIDs))
+		for _, id := range joinedIDs {
+			joined[id] = struct{}{}
+		}
+
+		members := make(map[uint]struct{})
+		if session.Group.IsPrivate {
+			var memberIDs []uint
+			if err := tx.Model(&groups.GroupUsers{}).
+				Where("group_id = ? AND user_id IN ?", session.GroupID, input.UserIDs).
+				Pluck("user_id", &memberIDs).Error; err != nil {
+				return err
+			}
+			for _, id := range memberIDs {
+				members[id] = struct{}{}
+			}
+		}
+
+		seen := make(map[uint]struct{}, len(input.UserIDs))
+		for _, id := range input.UserIDs {
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+
+			reason := ""
+			if _, ok := blocked[id]; ok {
+				reason = "взаимодействие с пользователем ограничено"
+			} else if _, ok := friends[id]; !ok {
+				reason = "пользователь не в друзьях"
+			} else if _, ok := joined[id]; ok {
+				reason = "пользователь уже участвует в сессии"
+			} else if _, ok := banned[id]; ok {
+				reason = "пользователь заблокирован в группе"
+			} else if _, ok := members[id]; session.Group.IsPrivate && !ok {
+				reason = "пользователь не состоит в приватной группе"
+			}
+			if reason != "" {
+				response.Skipped = append(response.Skipped, SkippedInvite{UserID: id, Reason: reason})
+				continue
+			}
+
+			invite := sessions.SessionInvite{SessionID: session.ID, ToUserID: id}
+			res := tx.Where(invite).
+				Attrs(sessions.SessionInvite{FromUserID: user.ID, Status: "pending"}).
+				FirstOrCreate(&invite)
+			if res.Error != nil {
+				return fmt.Errorf("не удалось создать приглашение: %v", res.Error)
+			}
+			if res.RowsAffected == 0 {
+				if invite.Status == "pending" {
+					response.Skipped = append(response.Skipped, SkippedInvite{UserID: id, Reason: "приглашение уже отправлено"})
+					continue
+				}
+				// Повторное приглашение после отказа
+				if err := tx.Model(&invite).Updates(map[string]interface{}{
+					"from_user_id": user.ID, "status": "pending", "responded_at": nil, "created_at": time.Now(),
+				}).Error; err != nil {
+					return err
+				}
+			}
+			response.Invited = append(response.Invited, id)
+		}
+
+		return queueNotifications(tx, response.Invited, sessionInviteNotificationType, "Приглашение на сессию",
+			"Приглашение на сессию", fmt.Sprintf("%s приглашает вас на «%s»", user.Name, session.Title), session.ImageURL)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// getPendingSessionInvites возвращает ожидающие приглашения на сессии, по которым ещё идёт набор
+func getPendingSessionInvites(tx *gorm.DB, userID uint) ([]SessionInviteDTO, error) {
+	result := make([]SessionInviteDTO, 0)
+	if err := tx.Table("session_invites si").
+		Select("si.id, si.session_id, s.title AS session_title, s.start_time, s.group_id, si.from_user_id, u.name AS from_user_name, si.created_at").
+		Joins("JOIN sessions s ON s.id = si.session_id").
+		Joins("JOIN statuses st ON st.id = s.status_id").
+		Joins("JOIN users u ON u.id = si.from_user_id").
+		Where("si.to_user_id = ? AND si.status = ? AND st.status = ?", userID, "pending", "Набор").
+		Order("si.created_at DESC").
+		Scan(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RespondSessionInvite принимает приглашение (с записью на сессию) или отклоняет его
+func RespondSessionInvite(principal models.Principal, inviteID uint, accept bool) error {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return errors.New("пользователь не найден")
+	}
+
+	var invite sessions.SessionInvite
+	if err := database.Preload("Session").First(&invite, inviteID).Error; err != nil {
+		return errors.New("приглашение не найдено")
+	}
+	if invite.ToUserID != user.ID {
+		return errors.New("у вас нет доступа к этому приглашению")
+	}
+	if invite.Status != "pending" {
+		return errors.New("приглашение уже обработано")
+	}
+
+	status := "declined"
+	if accept {
+		if err := JoinToSession(principal, SessionJoinInput{
+			SessionID: invite.SessionID,
+			GroupID:   invite.Session.GroupID,
+		}); err != nil {
+			return err
+		}
+		status = "accepted"
+	}
+
+	return database.Model(&invite).Updates(map[string]interface{}{
+		"status": status, "responded_at": time.Now(),
+	}).Error
+}
@@ -8,6 +8,7 @@ import (
 	"friendship/models/groups"
 	"friendship/models/sessions"
 	statsusers "friendship/models/stats_users"
+	"log"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -56,18 +57,20 @@ type SessionDetailResponse struct {
 }
 
 type SubSessionDetail struct {
-	ID            uint      `json:"id"`
-	Title         string    `json:"title"`
-	SessionType   string    `json:"session_type"`
-	SessionPlace  string    `json:"session_place"`
-	GroupID       uint      `json:"group_id"`
-	StartTime     time.Time `json:"start_time"`
-	EndTime       time.Time `json:"end_time"`
-	Duration      uint16    `json:"duration"`
-	CurrantUsers  uint16    `json:"current_users"`
-	CountUsersMax uint16    `json:"count_users_max"`
-	ImageURL      string    `json:"image_url"`
-	IsSub         bool      `json:"is_sub"`
+	ID                uint        `json:"id"`
+	Title             string      `json:"title"`
+	SessionType       string      `json:"session_type"`
+	SessionPlace      string      `json:"session_place"`
+	GroupID           uint        `json:"group_id"`
+	StartTime         time.Time   `json:"start_time"`
+	EndTime           time.Time   `json:"end_time"`
+	Duration          uint16      `json:"duration"`
+	CurrantUsers      uint16      `json:"current_users"`
+	CountUsersMax     uint16      `json:"count_users_max"`
+	ImageURL          string      `json:"image_url"`
+	IsSub             bool        `json:"is_sub"`
+	FriendsGoingCount int64       `json:"friends_going_count"`
+	FriendsGoing      []FriendDTO `json:"friends_going,omitempty"` // только в детальной информации о сессии
 }
 
 type PaginatedSearchResponse struct {
@@ -122,10 +125,7 @@ func (input SessionInput) CalculateEndTime() time.Time {
 	return input.StartTime.Add(time.Duration(input.Duration) * time.Minute)
 }
 
-func CreateSession(email string, input SessionInput) (bool, error) {
-	if email == "" {
-		return false, fmt.Errorf("не передан jwt")
-	}
+func CreateSession(principal models.Principal, input SessionInput) (bool, error) {
 
 	// Валидация времени начала
 	if err := input.ValidateStartTime(); err != nil {
@@ -142,14 +142,21 @@ func CreateSession(email string, input SessionInput) (bool, error) {
 	}
 
 	var creator models.User
-	if err := db.GetDB().Where("email = ?", email).First(&creator).Error; err != nil {
-		return false, fmt.Errorf("пользователь не найден (%s): %v", email, err)
+	if err := db.GetDB().First(&creator, principal.UserID).Error; err != nil {
+		return false, fmt.Errorf("пользователь не найден (id %d): %v", principal.UserID, err)
 	}
 
 	var group groups.Group
 	if err := db.GetDB().Where("id = ?", input.GroupID).First(&group).Error; err != nil {
 		return false, fmt.Errorf("группа не найдена (%d): %v", input.GroupID, err)
 	}
+	if group.ArchivedAt != nil {
+		return false, fmt.Errorf("группа в архиве")
+	}
+
+	if _, err := requireGroupCapability(db.GetDB(), creator.ID, group.ID, groups.CapCreateSessions); err != nil {
+		return false, fmt.Errorf("нельзя создать сессию в этой группе: %v", err)
+	}
 
 	var sessionPlace sessions.SessionGroupPlace
 	if err := db.GetDB().Where("id = ?", input.SessionPlace).First(&sessionPlace).Error; err != nil {
@@ -241,14 +248,32 @@ func CreateSession(email string, input SessionInput) (bool, error) {
 		UserID:    creator.ID,
 	}
 
-	if err := db.GetDB().Create(&addNewUserToSession).Error; err != nil {
+	// Запись в журнале появляется только вместе с организатором, когда сессия создана целиком:
+	// журнал неизменяемый, и откатить запись о несостоявшейся сессии уже нельзя
+	if err := db.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&addNewUserToSession).Error; err != nil {
+			return err
+		}
+		return recordGroupAudit(tx, groupAuditEntry{
+			GroupID:    session.GroupID,
+			ActorID:    creator.ID,
+			Action:     AuditSessionCreated,
+			TargetType: "session",
+			TargetID:   session.ID,
+			After:      sessionAuditSnapshot(session),
+		})
+	}); err != nil {
+		if _, mongoErr := collection.DeleteOne(context.TODO(), bson.M{"session_id": session.ID}); mongoErr != nil {
+			log.Printf("Не удалось удалить метаданные сессии %d: %v", session.ID, mongoErr)
+		}
+		db.GetDB().Delete(&session)
 		return false, fmt.Errorf("ошибка создания сессии: %v", err)
 	}
 
 	return true, nil
 }
 
-func JoinToSession(email *string, input SessionJoinInput) error {
+func JoinToSession(principal models.Principal, input SessionJoinInput) error {
 	dbTx := db.GetDB().Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -257,7 +282,7 @@ func JoinToSession(email *string, input SessionJoinInput) error {
 	}()
 
 	var user models.User
-	if err := dbTx.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := dbTx.First(&user, principal.UserID).Error; err != nil {
 		dbTx.Rollback()
 		return fmt.Errorf("пользователь не найден: %v", err)
 	}
@@ -268,6 +293,11 @@ func JoinToSession(email *string, input SessionJoinInput) error {
 		return fmt.Errorf("сессия не найдена: %v", err)
 	}
 
+	if err := checkNotBannedInGroup(dbTx, session.GroupID, user.ID); err != nil {
+		dbTx.Rollback()
+		return err
+	}
+
 	if session.Group.IsPrivate == true {
 		var groupUser groups.GroupUsers
 		if err := dbTx.Where("group_id = ? AND user_id = ?", session.GroupID, user.ID).First(&groupUser).Error; err != nil {
@@ -300,10 +330,15 @@ func JoinToSession(email *string, input SessionJoinInput) error {
 		return fmt.Errorf("ошибка добавления пользователя в сессию: %v", err)
 	}
 
-	return dbTx.Commit().Error
+	if err := dbTx.Commit().Error; err != nil {
+		return err
+	}
+
+	invalidateRecommendations(user.ID)
+	return nil
 }
 
-func LeaveSession(email string, sessionID uint) error {
+func LeaveSession(principal models.Principal, sessionID uint) error {
 	dbTx := db.GetDB().Begin()
 
 	defer func() {
@@ -313,7 +348,7 @@ func LeaveSession(email string, sessionID uint) error {
 	}()
 
 	var user models.User
-	if err := dbTx.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := dbTx.First(&user, principal.UserID).Error; err != nil {
 		dbTx.Rollback()
 		return fmt.Errorf("пользователь не найден")
 	}
@@ -364,7 +399,7 @@ type SessionUpdateInput struct {
 	SessionPlaceID *uint      `json:"session_place_id"`
 }
 
-func DeleteSession(email string, sessionID uint) error {
+func DeleteSession(principal models.Principal, sessionID uint) error {
 	dbTx := db.GetDB().Begin()
 
 	defer func() {
@@ -374,7 +409,7 @@ func DeleteSession(email string, sessionID uint) error {
 	}()
 
 	var user models.User
-	if err := dbTx.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := dbTx.First(&user, principal.UserID).Error; err != nil {
 		dbTx.Rollback()
 		return fmt.Errorf("пользователь не найден: %v", err)
 	}
@@ -385,14 +420,9 @@ func DeleteSession(email string, sessionID uint) error {
 		return fmt.Errorf("сессия не найдена: %v", err)
 	}
 
-	var member groups.GroupUsers
-	if err := dbTx.Where("user_id = ? AND group_id = ?", user.ID, session.GroupID).First(&member).Error; err != nil {
-		dbTx.Rollback()
-		return fmt.Errorf("вы не состоите в группе или нет доступа")
-	}
-	if member.RoleInGroup != "admin" && member.RoleInGroup != "operator" {
+	if _, err := requireGroupCapability(dbTx, user.ID, session.GroupID, groups.CapEditSessions); err != nil {
 		dbTx.Rollback()
-		return fmt.Errorf("у вас нет прав на удаление этой сессии")
+		return fmt.Errorf("у вас нет прав на удаление этой сессии: %v", err)
 	}
 
 	if err := dbTx.Where("session_id = ?", session.ID).Delete(&sessions.SessionUser{}).Error; err != nil {
@@ -405,6 +435,18 @@ func DeleteSession(email string, sessionID uint) error {
 		return fmt.Errorf("не удалось удалить уведомления: %v", err)
 	}
 
+	if err := recordGroupAudit(dbTx, groupAuditEntry{
+		GroupID:    session.GroupID,
+		ActorID:    user.ID,
+		Action:     AuditSessionDeleted,
+		TargetType: "session",
+		TargetID:   session.ID,
+		Before:     sessionAuditSnapshot(session),
+	}); err != nil {
+		dbTx.Rollback()
+		return err
+	}
+
 	if err := dbTx.Delete(&session).Error; err != nil {
 		dbTx.Rollback()
 		return fmt.Errorf("не удалось удалить сессию: %v", err)
@@ -429,25 +471,23 @@ func DeleteSession(email string, sessionID uint) error {
 	return dbTx.Commit().Error
 }
 
-func UpdateSession(email string, sessionID uint, input SessionUpdateInput) error {
+func UpdateSession(principal models.Principal, sessionID uint, input SessionUpdateInput) error {
 	var ses sessions.Session
 	if err := db.GetDB().Preload("Group").First(&ses, sessionID).Error; err != nil {
 		return fmt.Errorf("сессия не найдена: %v", err)
 	}
 
 	var user models.User
-	if err := db.GetDB().Where("email = ?", email).First(&user).Error; err != nil {
+	if err := db.GetDB().First(&user, principal.UserID).Error; err != nil {
 		return fmt.Errorf("пользователь не найден")
 	}
 
-	role, err := getUserRole(user.ID, ses.GroupID)
-	if err != nil {
-		return fmt.Errorf("ошибка проверки роли: %v", err)
-	}
-	if role != "admin" {
-		return fmt.Errorf("доступ запрещён: только администратор может редактировать сессию")
+	if _, err := requireGroupCapability(db.GetDB(), user.ID, ses.GroupID, groups.CapEditSessions); err != nil {
+		return fmt.Errorf("доступ запрещён: %v", err)
 	}
 
+	before := sessionAuditSnapshot(ses)
+
 	if input.Title != nil {
 		ses.Title = *input.Title
 	}
@@ -473,7 +513,13 @@ func UpdateSession(email string, sessionID uint, input SessionUpdateInput) error
 		ses.SessionPlaceID = *input.SessionPlaceID
 	}
 
-	if err := db.GetDB().Save(&ses).Error; err != nil {
+	tx := db.GetDB().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.Rollback()
+
+	if err := tx.Save(&ses).Error; err != nil {
 		return fmt.Errorf("не удалось обновить сессию: %v", err)
 	}
 
@@ -518,7 +564,38 @@ func UpdateSession(email string, sessionID uint, input SessionUpdateInput) error
 		}
 	}
 
-	return nil
+	auditBefore, auditAfter := auditDiff(before, sessionAuditSnapshot(ses))
+	for key, value := range update {
+		auditAfter["metadata."+key] = value
+	}
+	if err := recordGroupAudit(tx, groupAuditEntry{
+		GroupID:    ses.GroupID,
+		ActorID:    user.ID,
+		Action:     AuditSessionUpdated,
+		TargetType: "session",
+		TargetID:   ses.ID,
+		Before:     auditBefore,
+		After:      auditAfter,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// sessionAuditSnapshot — поля сессии для журнала аудита
+func sessionAuditSnapshot(s sessions.Session) map[string]interface{} {
+	return map[string]interface{}{
+		"title":            s.Title,
+		"start_time":       s.StartTime.UTC().Format(time.RFC3339),
+		"end_time":         s.EndTime.UTC().Format(time.RFC3339),
+		"duration":         s.Duration,
+		"count_users_max":  s.CountUsersMax,
+		"image_url":        s.ImageURL,
+		"session_type_id":  s.SessionTypeID,
+		"session_place_id": s.SessionPlaceID,
+		"status_id":        s.StatusID,
+	}
 }
 
 // func SearchSessions(email, query *string, categoryID *uint, sessionType *string, page *int) (*PaginatedSearchResponse, error) {
@@ -547,7 +624,7 @@ func UpdateSession(email string, sessionID uint, input SessionUpdateInput) error
 // 	}()
 
 // 	var user models.User
-// 	if err := dbTx.Where("email = ?", *email).First(&user).Error; err != nil {
+// 	if err := dbTx.First(&user, principal.UserID).Error; err != nil {
 // 		dbTx.Rollback()
 // 		if err == gorm.ErrRecordNotFound {
 // 			return nil, fmt.Errorf("пользователь не найден")
@@ -744,10 +821,7 @@ func UpdateSession(email string, sessionID uint, input SessionUpdateInput) error
 // 	}, nil
 // }
 
-func GetInfoAboutSession(email *string, sessionID *uint) (*SessionDetailResponse, error) {
-	if email == nil || *email == "" {
-		return nil, fmt.Errorf("email не может быть пустым")
-	}
+func GetInfoAboutSession(principal models.Principal, sessionID *uint) (*SessionDetailResponse, error) {
 	if sessionID == nil || *sessionID == 0 {
 		return nil, fmt.Errorf("sessionID должен быть больше 0")
 	}
@@ -761,7 +835,7 @@ func GetInfoAboutSession(email *string, sessionID *uint) (*SessionDetailResponse
 	}()
 
 	var user models.User
-	if err := dbTx.Where("email = ?", *email).First(&user).Error; err != nil {
+	if err := dbTx.First(&user, principal.UserID).Error; err != nil {
 		dbTx.Rollback()
 		if err == gorm.ErrRecordNotFound {
 			return nil, fmt.Errorf("пользователь не найден")
@@ -831,6 +905,14 @@ func GetInfoAboutSession(email *string, sessionID *uint) (*SessionDetailResponse
 		IsSub:         len(sessionUsers) > 0,
 	}
 
+	friends, err := friendsGoing(dbTx, user.ID, session.ID)
+	if err != nil {
+		dbTx.Rollback()
+		return nil, fmt.Errorf("ошибка при получении друзей на сессии: %v", err)
+	}
+	subIng.FriendsGoing = friends
+	subIng.FriendsGoingCount = int64(len(friends))
+
 	sessionInf.Session = subIng
 
 	metadata, err := db.GetSessionMetadataId(sessionID)
@@ -846,10 +928,7 @@ func GetInfoAboutSession(email *string, sessionID *uint) (*SessionDetailResponse
 	return &sessionInf, nil
 }
 
-func GetSessionsUserGroups(email *string, page int) (*GetNewSessionsResponse, error) {
-	if *email == "" {
-		return nil, fmt.Errorf("email не может быть пустым")
-	}
+func GetSessionsUserGroups(principal models.Principal, page int) (*GetNewSessionsResponse, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -865,7 +944,7 @@ func GetSessionsUserGroups(email *string, page int) (*GetNewSessionsResponse, er
 	}()
 
 	var user models.User
-	if err := dbTx.Where("email = ?", *email).First(&user).Error; err != nil {
+	if err := dbTx.First(&user, principal.UserID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			dbTx.Rollback()
 			return nil, fmt.Errorf("пользователь не найден")
@@ -941,6 +1020,12 @@ func GetSessionsUserGroups(email *string, page int) (*GetNewSessionsResponse, er
 		metadataMap = make(map[uint]*sessions.SessionMetadata)
 	}
 
+	friendsCounts, err := friendsGoingCounts(dbTx, user.ID, sessionIDs)
+	if err != nil {
+		dbTx.Rollback()
+		return nil, fmt.Errorf("ошибка при подсчете друзей на сессиях: %v", err)
+	}
+
 	for _, session := range sessionModels {
 		var genres []string
 		var groupName *string
@@ -972,6 +1057,8 @@ func GetSessionsUserGroups(email *string, page int) (*GetNewSessionsResponse, er
 			Genres:        genres,
 			GroupName:     *groupName,
 			City:          &city,
+
+			FriendsGoingCount: friendsCounts[session.ID],
 		}
 		sessionsUser = append(sessionsUser, sessionResponse)
 	}
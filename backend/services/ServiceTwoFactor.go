@@ -0,0 +1,384 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"friendship/db"
+	"friendship/models"
+	"friendship/utils"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Двухфакторная аутентификация по TOTP. Подключение в два шага: пользователь получает секрет,
+// добавляет его в приложение и подтверждает кодом — только после этого 2FA включается
+// и выдаются коды восстановления. Вход с включённой 2FA тоже в два шага: после пароля
+// выдаётся короткоживущий challenge токен, который обменивается на пару токенов вместе с кодом.
+
+const (
+	twoFactorChallengeKey  = "2fa_challenge:%s" // токен -> user_id, attempts
+	twoFactorChallengeTTL  = 5 * time.Minute
+	maxTwoFactorAttempts   = 5
+	recoveryCodesCount     = 10
+	defaultTwoFactorIssuer = "FriendSheep"
+)
+
+var (
+	ErrTwoFactorRequired      = errors.New("требуется код двухфакторной аутентификации")
+	ErrTwoFactorInvalidCode   = errors.New("неверный код двухфакторной аутентификации")
+	ErrTwoFactorChallengeGone = errors.New("время на ввод кода истекло, войдите заново")
+)
+
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"` // для QR-кода в приложении-аутентификаторе
+}
+
+type TwoFactorCodeInput struct {
+	Code string `json:"code" binding:"required,max=20"`
+}
+
+type DisableTwoFactorInput struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required,max=20"` // код из приложения или код восстановления
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorStatus struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at"`
+	RecoveryCodesRemaining int64      `json:"recovery_codes_remaining"`
+}
+
+func twoFactorIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return defaultTwoFactorIssuer
+}
+
+// loadTwoFactor возвращает настройки 2FA пользователя, nil — если 2FA не подключалась
+func loadTwoFactor(tx *gorm.DB, userID uint) (*models.UserTwoFactor, error) {
+	var settings models.UserTwoFactor
+	err := tx.Where("user_id = ?", userID).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// TwoFactorEnabled сообщает, нужен ли пользователю второй фактор при входе
+func TwoFactorEnabled(userID uint) (bool, error) {
+	settings, err := loadTwoFactor(db.GetDB(), userID)
+	if err != nil {
+		return false, err
+	}
+	return settings != nil && settings.Enabled, nil
+}
+
+// acceptTOTP проверяет код из приложения. Шаг фиксируется условным обновлением,
+// поэтому один и тот же код нельзя использовать дважды даже параллельными запросами.
+func acceptTOTP(tx *gorm.DB, settings *models.UserTwoFactor, code string) (bool, error) {
+	step, ok := utils.ValidateTOTP(settings.Secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	res := tx.Model(&models.UserTwoFactor{}).
+		Where("id = ? AND last_used_step < ?", settings.ID, step).
+		Update("last_used_step", step)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// useRecoveryCode гасит код восстановления, если он есть и ещё не использован
+func useRecoveryCode(tx *gorm.DB, userID uint, code string) (bool, error) {
+	res := tx.Model(&models.UserRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, utils.HashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// verifySecondFactor принимает код из приложения или код восстановления
+func verifySecondFactor(tx *gorm.DB, userID uint, code string) error {
+	if code == "" {
+		return ErrTwoFactorRequired
+	}
+	settings, err := loadTwoFactor(tx, userID)
+	if err != nil {
+		return err
+	}
+	if settings == nil || !settings.Enabled {
+		return nil
+	}
+
+	if ok, err := acceptTOTP(tx, settings, code); err != nil || ok {
+		return err
+	}
+	if ok, err := useRecoveryCode(tx, userID, code); err != nil || ok {
+		return err
+	}
+	return ErrTwoFactorInvalidCode
+}
+
+// replaceRecoveryCodes удаляет старые коды восстановления и выдаёт новые
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.UserRecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodesCount)
+	rows := make([]models.UserRecoveryCode, recoveryCodesCount)
+	for i := range codes {
+		codes[i] = utils.GenerateRecoveryCode()
+		rows[i] = models.UserRecoveryCode{UserID: userID, CodeHash: utils.HashRecoveryCode(codes[i])}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, fmt.Errorf("не удалось сохранить коды восстановления: %v", err)
+	}
+	return codes, nil
+}
+
+func GetTwoFactorStatus(principal models.Principal) (*TwoFactorStatus, error) {
+	database := db.GetDB()
+
+	settings, err := loadTwoFactor(database, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	status := &TwoFactorStatus{}
+	if settings == nil || !settings.Enabled {
+		return status, nil
+	}
+
+	status.Enabled = true
+	status.EnabledAt = settings.EnabledAt
+	if err := database.Model(&models.UserRecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", principal.UserID).
+		Count(&status.RecoveryCodesRemaining).Error; err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// BeginTwoFactorSetup выдаёт новый секрет. 2FA включится после подтверждения кодом.
+func BeginTwoFactorSetup(principal models.Principal) (*TwoFactorSetupResponse, error) {
+	database := db.GetDB()
+
+	settings, err := loadTwoFactor(database, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if settings != nil && settings.Enabled {
+		return nil, errors.New("двухфакторная аутентификация уже включена")
+	}
+
+	secret := utils.GenerateTOTPSecret()
+	if settings == nil {
+		settings = &models.UserTwoFactor{UserID: principal.UserID, Secret: secret}
+		if err := database.Create(settings).Error; err != nil {
+			return nil, err
+		}
+	} else if err := database.Model(settings).Updates(map[string]interface{}{
+		"secret": secret, "last_used_step": 0,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPAuthURI(twoFactorIssuer(), principal.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactorSetup включает 2FA, если код из приложения верный, и возвращает коды восстановления.
+// Коды показываются один раз, сохраняются только их хэши.
+func ConfirmTwoFactorSetup(principal models.Principal, input TwoFactorCodeInput) (*RecoveryCodesResponse, error) {
+	var codes []string
+	err := db.GetDB().Transaction(func(tx *gorm.DB) error {
+		settings, err := loadTwoFactor(tx, principal.UserID)
+		if err != nil {
+			return err
+		}
+		if settings == nil {
+			return errors.New("сначала начните подключение двухфакторной аутентификации")
+		}
+		if settings.Enabled {
+			return errors.New("двухфакторная аутентификация уже включена")
+		}
+
+		ok, err := acceptTOTP(tx, settings, input.Code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrTwoFactorInvalidCode
+		}
+
+		if err := tx.Model(settings).Updates(map[string]interface{}{
+			"enabled": true, "enabled_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+
+		codes, err = replaceRecoveryCodes(tx, principal.UserID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableTwoFactor отключает 2FA после проверки пароля и второго фактора
+func DisableTwoFactor(principal models.Principal, input DisableTwoFactorInput) error {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return errors.New("пользователь не найден")
+	}
+	if ok, _ := utils.VerifyPassword(input.Password, user.Password, user.Salt); !ok {
+		return errors.New("неверный пароль")
+	}
+
+	return database.Transaction(func(tx *gorm.DB) error {
+		settings, err := loadTwoFactor(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		if settings == nil || !settings.Enabled {
+			return errors.New("двухфакторная аутентификация не включена")
+		}
+		if err := verifySecondFactor(tx, user.ID, input.Code); err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(settings).Error
+	})
+}
+
+// RegenerateRecoveryCodes заменяет коды восстановления новыми. Требует код из приложения.
+func RegenerateRecoveryCodes(principal models.Principal, input TwoFactorCodeInput) (*RecoveryCodesResponse, error) {
+	var codes []string
+	err := db.GetDB().Transaction(func(tx *gorm.DB) error {
+		settings, err := loadTwoFactor(tx, principal.UserID)
+		if err != nil {
+			return err
+		}
+		if settings == nil || !settings.Enabled {
+			return errors.New("двухфакторная аутентификация не включена")
+		}
+
+		ok, err := acceptTOTP(tx, settings, input.Code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrTwoFactorInvalidCode
+		}
+
+		codes, err = replaceRecoveryCodes(tx, principal.UserID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// StartTwoFactorChallenge вызывается после проверки пароля и возвращает challenge токен
+func StartTwoFactorChallenge(userID uint) (string, error) {
+	redisClient := db.GetRedis()
+	if redisClient == nil {
+		return "", errors.New("хранилище сессий недоступно")
+	}
+
+	token := utils.GenerateSessioID(32)
+	key := fmt.Sprintf(twoFactorChallengeKey, token)
+
+	pipe := redisClient.TxPipeline()
+	pipe.HSet(ctx, key, map[string]interface{}{"user_id": userID, "attempts": 0})
+	pipe.Expire(ctx, key, twoFactorChallengeTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", fmt.Errorf("не удалось сохранить challenge: %v", err)
+	}
+	return token, nil
+}
+
+// CompleteTwoFactorLogin проверяет код по challenge токену и возвращает пользователя,
+// для которого можно начинать сессию входа. После нескольких ошибок токен сгорает.
+func CompleteTwoFactorLogin(challengeToken, code string) (*models.User, error) {
+	redisClient := db.GetRedis()
+	if redisClient == nil {
+		return nil, errors.New("хранилище сессий недоступно")
+	}
+
+	key := fmt.Sprintf(twoFactorChallengeKey, challengeToken)
+	userIDStr, err := redisClient.HGet(ctx, key, "user_id").Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrTwoFactorChallengeGone
+	}
+	if err != nil {
+		return nil, err
+	}
+	userID, err := strconv.ParseUint(userIDStr, 10, 64)
+	if err != nil {
+		return nil, ErrTwoFactorChallengeGone
+	}
+
+	var user models.User
+	if err := db.GetDB().First(&user, userID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+	if err := checkAccountAttempts(user.ID); err != nil {
+		return nil, err
+	}
+
+	attempts, err := redisClient.HIncrBy(ctx, key, "attempts", 1).Result()
+	if err != nil {
+		return nil, err
+	}
+	if attempts > maxTwoFactorAttempts {
+		redisClient.Del(ctx, key)
+		return nil, ErrTwoFactorChallengeGone
+	}
+
+	if err := verifySecondFactor(db.GetDB(), user.ID, code); err != nil {
+		if errors.Is(err, ErrTwoFactorInvalidCode) {
+			// Новые challenge можно получать снова, поэтому ошибки считаются и на аккаунт
+			registerAccountFailure(&user)
+		}
+		return nil, err
+	}
+
+	// Токен одноразовый: если его уже погасил параллельный запрос, вход не выдаём
+	deleted, err := redisClient.Del(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if deleted == 0 {
+		return nil, ErrTwoFactorChallengeGone
+	}
+
+	resetAccountFailures(user.ID)
+	return &user, nil
+}
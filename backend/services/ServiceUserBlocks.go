@@ -0,0 +1,156 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"friendship/db"
+	"friendship/models"
+	"friendship/models/sessions"
+
+	"gorm.io/gorm"
+)
+
+// Блокировка пользователей. Блокировка действует в обе стороны: пока она есть, пользователи
+// не находят друг друга в поиске, не дружат, не подписываются и не приглашают друг друга.
+
+var errUserBlocked = errors.New("пользователь ограничил взаимодействие с вами")
+
+type BlockedUserDTO struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Us        string    `json:"us"`
+	Image     string    `json:"image"`
+	BlockedAt time.Time `json:"blockedAt"`
+}
+
+// checkNotBlocked возвращает ошибку, если actor и target заблокировали друг друга
+func checkNotBlocked(tx *gorm.DB, actorID, targetID uint) error {
+	var block models.UserBlock
+	err := tx.Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)",
+		actorID, targetID, targetID, actorID).First(&block).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if block.BlockerID == actorID {
+		return errors.New("вы заблокировали этого пользователя")
+	}
+	return errUserBlocked
+}
+
+// blockedWithSubquery — ID пользователей, связанных с userID блокировкой в любом направлении
+func blockedWithSubquery(tx *gorm.DB, userID uint) *gorm.DB {
+	return tx.Model(&models.UserBlock{}).
+		Select("CASE WHEN blocker_id = ? THEN blocked_id ELSE blocker_id END", userID).
+		Where("blocker_id = ? OR blocked_id = ?", userID, userID)
+}
+
+// blockedWith возвращает пользователей из списка, связанных с userID блокировкой
+func blockedWith(tx *gorm.DB, userID uint, userIDs []uint) (map[uint]struct{}, error) {
+	result := make(map[uint]struct{})
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+	var ids []uint
+	if err := tx.Model(&models.UserBlock{}).
+		Select("CASE WHEN blocker_id = ? THEN blocked_id ELSE blocker_id END", userID).
+		Where("(blocker_id = ? AND blocked_id IN ?) OR (blocked_id = ? AND blocker_id IN ?)", userID, userIDs, userID, userIDs).
+		Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		result[id] = struct{}{}
+	}
+	return result, nil
+}
+
+// BlockUser блокирует пользователя: разрывает дружбу и подписки, отклоняет приглашения на сессии между ними
+func BlockUser(principal models.Principal, targetID uint) error {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return errors.New("пользователь не найден")
+	}
+	if user.ID == targetID {
+		return errors.New("нельзя заблокировать самого себя")
+	}
+	var target models.User
+	if err := database.First(&target, targetID).Error; err != nil {
+		return errors.New("пользователь не найден")
+	}
+
+	err := database.Transaction(func(tx *gorm.DB) error {
+		block := models.UserBlock{BlockerID: user.ID, BlockedID: target.ID}
+		res := tx.Where(block).FirstOrCreate(&block)
+		if res.Error != nil {
+			return fmt.Errorf("не удалось заблокировать пользователя: %v", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("пользователь уже заблокирован")
+		}
+
+		if err := tx.Where("(requester_id = ? AND addressee_id = ?) OR (requester_id = ? AND addressee_id = ?)",
+			user.ID, target.ID, target.ID, user.ID).Delete(&models.Friendship{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("(follower_id = ? AND followee_id = ?) OR (follower_id = ? AND followee_id = ?)",
+			user.ID, target.ID, target.ID, user.ID).Delete(&models.Follow{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&sessions.SessionInvite{}).
+			Where("status = ? AND ((from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?))",
+				"pending", user.ID, target.ID, target.ID, user.ID).
+			Updates(map[string]interface{}{"status": "declined", "responded_at": time.Now()}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidatePeopleYouMayKnow(user.ID, target.ID)
+	return nil
+}
+
+// UnblockUser снимает блокировку. Дружба и подписки не восстанавливаются.
+func UnblockUser(principal models.Principal, targetID uint) error {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return errors.New("пользователь не найден")
+	}
+
+	res := database.Where("blocker_id = ? AND blocked_id = ?", user.ID, targetID).Delete(&models.UserBlock{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("пользователь не заблокирован")
+	}
+	return nil
+}
+
+// GetBlockedUsers возвращает пользователей, заблокированных текущим пользователем
+func GetBlockedUsers(principal models.Principal) ([]BlockedUserDTO, error) {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+
+	result := make([]BlockedUserDTO, 0)
+	if err := database.Table("user_blocks b").
+		Select("u.id, u.name, u.us, u.image, b.created_at AS blocked_at").
+		Joins("JOIN users u ON u.id = b.blocked_id").
+		Where("b.blocker_id = ?", user.ID).
+		Order("b.created_at DESC").
+		Scan(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
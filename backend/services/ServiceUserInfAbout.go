@@ -27,6 +27,13 @@ type InformationAboutUser struct {
 	PopularGenres    []GenreStats  `json:"popular_genres"`
 	UserStats        UserStatsInfo `json:"user_stats"`
 	Tiles            []string      `json:"tiles"`
+
+	Achievements     []AchievementDTO `json:"achievements"`
+	TopCompanions    []CompanionStats `json:"top_companions,omitempty"`
+	PeopleYouMayKnow []SuggestedUser  `json:"people_you_may_know,omitempty"`
+
+	Relation      *UserRelation `json:"relation,omitempty"` // только при просмотре чужого профиля
+	ProfileHidden bool          `json:"profile_hidden,omitempty"`
 }
 
 type SessionInfo struct {
@@ -70,14 +77,14 @@ type UpdateUserRequest struct {
 	Status *string `json:"status,omitempty" binding:"omitempty,min=1,max=50"`
 }
 
-func GetInfAboutUser(email string) (*InformationAboutUser, error) {
+func GetInfAboutUser(principal models.Principal) (*InformationAboutUser, error) {
 	database := db.GetDB()
 	if database == nil {
 		return nil, errors.New("база данных недоступна")
 	}
 
 	var user models.User
-	if err := database.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := database.First(&user, principal.UserID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -129,6 +136,21 @@ func GetInfAboutUser(email string) (*InformationAboutUser, error) {
 		return nil, err
 	}
 
+	achievements, err := getUserAchievements(database, user.ID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	topCompanions, err := getTopCompanions(database, user.ID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	peopleYouMayKnow, err := getPeopleYouMayKnow(database, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	result := &InformationAboutUser{
 		Name:             user.Name,
 		Us:               user.Us,
@@ -142,25 +164,47 @@ func GetInfAboutUser(email string) (*InformationAboutUser, error) {
 		PopularGenres:    popularGenres,
 		UserStats:        userStats,
 		Tiles:            enabledTiles,
+		Achievements:     achievements,
+		TopCompanions:    topCompanions,
+		PeopleYouMayKnow: peopleYouMayKnow,
 	}
 
 	return result, nil
 }
 
-func GetInfAboutAnotherUser(email string) (*InformationAboutUser, error) {
+// GetInfAboutAnotherUser возвращает профиль пользователя userID так, как его видит viewer,
+// с учётом настроек приватности
+func GetInfAboutAnotherUser(viewer models.Principal, userID uint) (*InformationAboutUser, error) {
 	database := db.GetDB()
 	if database == nil {
 		return nil, errors.New("база данных недоступна")
 	}
 
 	var user models.User
-	if err := database.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := database.First(&user, userID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
 		return nil, err
 	}
 
+	privacy, err := loadPrivacy(database, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	visible, err := canViewProfile(database, viewer.UserID, privacy)
+	if err != nil {
+		return nil, err
+	}
+	if !visible {
+		return &InformationAboutUser{
+			Name:          user.Name,
+			Us:            user.Us,
+			Image:         user.Image,
+			ProfileHidden: true,
+		}, nil
+	}
+
 	var tiles statsusers.SettingTile
 	if err := database.Where("user_id = ?", user.ID).First(&tiles).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
@@ -186,9 +230,12 @@ func GetInfAboutAnotherUser(email string) (*InformationAboutUser, error) {
 		enabledTiles = append(enabledTiles, "spent_time")
 	}
 
-	upcomingSessions, err := getUpcomingSessions(database, user.ID)
-	if err != nil {
-		return nil, err
+	var upcomingSessions []SessionInfo
+	if !privacy.HideUpcomingSessions {
+		upcomingSessions, err = getUpcomingSessions(database, user.ID)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// recentSessions, err := getRecentSessions(database, user.ID)
@@ -196,14 +243,35 @@ func GetInfAboutAnotherUser(email string) (*InformationAboutUser, error) {
 	// 	return nil, err
 	// }
 
-	popularGenres, err := getPopularGenres(database, user.ID)
-	if err != nil {
-		return nil, err
-	}
+	var popularGenres []GenreStats
+	var userStats UserStatsInfo
+	var achievements []AchievementDTO
+	var topCompanions []CompanionStats
+	if privacy.HideStats {
+		enabledTiles = nil
+	} else {
+		popularGenres, err = getPopularGenres(database, user.ID)
+		if err != nil {
+			return nil, err
+		}
 
-	userStats, err := getUserStats(database, user.ID)
-	if err != nil {
-		return nil, err
+		userStats, err = getUserStats(database, user.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Значки и компаньоны считаются по той же статистике, поэтому скрываются вместе с ней
+		achievements, err = getUserAchievements(database, user.ID, true)
+		if err != nil {
+			return nil, err
+		}
+
+		if tiles.Companions || tiles.ID == 0 {
+			topCompanions, err = getTopCompanions(database, user.ID, viewer.UserID)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	result := &InformationAboutUser{
@@ -219,6 +287,8 @@ func GetInfAboutAnotherUser(email string) (*InformationAboutUser, error) {
 		PopularGenres: popularGenres,
 		UserStats:     userStats,
 		Tiles:         enabledTiles,
+		Achievements:  achievements,
+		TopCompanions: topCompanions,
 	}
 
 	return result, nil
@@ -424,11 +494,11 @@ func safeStringSlice(slice []string) []string {
 	return slice
 }
 
-func UpdateUserProfile(email string, req UpdateUserRequest) (*models.User, error) {
+func UpdateUserProfile(principal models.Principal, req UpdateUserRequest) (*models.User, error) {
 	database := db.GetDB()
 
 	var user models.User
-	if err := database.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := database.First(&user, principal.UserID).Error; err != nil {
 		return nil, errors.New("пользователь не найден")
 	}
 
@@ -442,7 +512,7 @@ func UpdateUserProfile(email string, req UpdateUserRequest) (*models.User, error
 	}
 	if req.Us != nil && *req.Us != "" {
 		var existing models.User
-		if err := database.Where("us = ? AND email <> ?", *req.Us, email).First(&existing).Error; err == nil {
+		if err := database.Where("us = ? AND id <> ?", *req.Us, principal.UserID).First(&existing).Error; err == nil {
 			return nil, errors.New("US уже используется")
 		}
 		updates["us"] = *req.Us
@@ -2,11 +2,14 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"friendship/db"
 	"friendship/models"
 	"friendship/models/groups"
 	"friendship/models/sessions"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type NotificationDTO struct {
@@ -27,15 +30,16 @@ type InviteDTO struct {
 }
 
 type GetNotifyResponse struct {
-	Notifications []NotificationDTO `json:"notifications"`
-	Invites       []InviteDTO       `json:"invites"`
+	Notifications  []NotificationDTO  `json:"notifications"`
+	Invites        []InviteDTO        `json:"invites"`
+	SessionInvites []SessionInviteDTO `json:"sessionInvites"`
 }
 
-func GetNotify(email string) (*GetNotifyResponse, error) {
+func GetNotify(principal models.Principal) (*GetNotifyResponse, error) {
 	database := db.GetDB()
 
 	var user models.User
-	if err := database.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := database.First(&user, principal.UserID).Error; err != nil {
 		return nil, errors.New("пользователь не найден")
 	}
 
@@ -55,6 +59,11 @@ func GetNotify(email string) (*GetNotifyResponse, error) {
 		return nil, err
 	}
 
+	sessionInvites, err := getPendingSessionInvites(database, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	var notifDTOs []NotificationDTO
 	for _, n := range notifications {
 		notifDTOs = append(notifDTOs, NotificationDTO{
@@ -79,16 +88,17 @@ func GetNotify(email string) (*GetNotifyResponse, error) {
 	}
 
 	return &GetNotifyResponse{
-		Notifications: notifDTOs,
-		Invites:       inviteDTOs,
+		Notifications:  notifDTOs,
+		Invites:        inviteDTOs,
+		SessionInvites: sessionInvites,
 	}, nil
 }
 
-func GetNotifyInf(email string) (bool, error) {
+func GetNotifyInf(principal models.Principal) (bool, error) {
 	database := db.GetDB()
 
 	var user models.User
-	if err := database.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := database.First(&user, principal.UserID).Error; err != nil {
 		return false, errors.New("пользователь не найден")
 	}
 
@@ -108,7 +118,12 @@ func GetNotifyInf(email string) (bool, error) {
 		return false, err
 	}
 
-	hasNotifications := len(notifications) > 0 || len(invites) > 0
+	sessionInvites, err := getPendingSessionInvites(database, user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	hasNotifications := len(notifications) > 0 || len(invites) > 0 || len(sessionInvites) > 0
 	return hasNotifications, nil
 }
 
@@ -124,7 +139,7 @@ func MarkNotificationViewed(notificationID uint, userID uint) error {
 	return database.Save(&notif).Error
 }
 
-func ApproveInviteByID(email string, inviteID uint) error {
+func ApproveInviteByID(principal models.Principal, inviteID uint) error {
 	tx := db.GetDB().Begin()
 	if tx.Error != nil {
 		return tx.Error
@@ -136,7 +151,7 @@ func ApproveInviteByID(email string, inviteID uint) error {
 	}()
 
 	var user models.User
-	if err := tx.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := tx.First(&user, principal.UserID).Error; err != nil {
 		tx.Rollback()
 		return errors.New("пользователь не найден")
 	}
@@ -157,6 +172,16 @@ func ApproveInviteByID(email string, inviteID uint) error {
 		return errors.New("приглашение уже обработано")
 	}
 
+	if invite.Group.ArchivedAt != nil {
+		tx.Rollback()
+		return errors.New("группа в архиве")
+	}
+
+	if err := checkNotBannedInGroup(tx, invite.GroupID, user.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	if err := tx.Model(&invite).Update("status", "approved").Error; err != nil {
 		tx.Rollback()
 		return err
@@ -165,7 +190,7 @@ func ApproveInviteByID(email string, inviteID uint) error {
 	member := groups.GroupUsers{
 		UserID:      invite.UserID,
 		GroupID:     invite.GroupID,
-		RoleInGroup: "member",
+		RoleInGroup: groups.RoleMember,
 	}
 	if err := tx.Create(&member).Error; err != nil {
 		tx.Rollback()
@@ -175,11 +200,11 @@ func ApproveInviteByID(email string, inviteID uint) error {
 	return tx.Commit().Error
 }
 
-func RejectInviteByID(email string, inviteID uint) error {
+func RejectInviteByID(principal models.Principal, inviteID uint) error {
 	database := db.GetDB()
 
 	var user models.User
-	if err := database.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := database.First(&user, principal.UserID).Error; err != nil {
 		return errors.New("пользователь не найден")
 	}
 
@@ -198,3 +223,35 @@ func RejectInviteByID(email string, inviteID uint) error {
 
 	return database.Model(&invite).Update("status", "rejected").Error
 }
+
+// queueNotifications создаёт неотправленные уведомления пользователям. Они сразу видны в приложении,
+// а в Telegram и push их доставляет notify_service.
+func queueNotifications(tx *gorm.DB, userIDs []uint, typeName, typeDescription, title, text, imageURL string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	var nt sessions.NotificationType
+	if err := tx.Where(sessions.NotificationType{Name: typeName}).
+		Attrs(sessions.NotificationType{Description: typeDescription, HoursBefore: 0}).
+		FirstOrCreate(&nt).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	notifications := make([]sessions.Notification, 0, len(userIDs))
+	for _, userID := range userIDs {
+		notifications = append(notifications, sessions.Notification{
+			UserID:             userID,
+			NotificationTypeID: nt.ID,
+			SendAt:             now,
+			Title:              title,
+			Text:               text,
+			ImageURL:           imageURL,
+		})
+	}
+	if err := tx.CreateInBatches(&notifications, 500).Error; err != nil {
+		return fmt.Errorf("не удалось создать уведомления: %v", err)
+	}
+	return nil
+}
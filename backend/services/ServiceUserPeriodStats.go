@@ -0,0 +1,407 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"friendship/db"
+	"friendship/middlewares"
+	"friendship/models"
+	"friendship/utils"
+
+	"gorm.io/gorm"
+)
+
+// Статистика по периодам (месяц/год) считается на лету по завершённым сессиям,
+// в отличие от UserStatsInfo, где лежат только счётчики за всё время.
+
+const (
+	PeriodMonth = "month"
+	PeriodYear  = "year"
+
+	periodTopLimit = 5
+
+	recapCardCacheKey     = "recap_card:%d:%d" // hash url, rendered_at; без TTL, чтобы удалить старый файл при перерисовке
+	recapCardRefreshAfter = 24 * time.Hour
+)
+
+type PeriodStats struct {
+	Period          string             `json:"period"` // "2025" или "2025-03"
+	From            time.Time          `json:"from"`
+	To              time.Time          `json:"to"`
+	SessionsCount   uint16             `json:"sessions_count"`
+	SpentTime       uint64             `json:"spent_time"`
+	TopGenres       []GenreStats       `json:"top_genres"`
+	TopSessionTypes []SessionTypeStats `json:"top_session_types"`
+	TopCompanions   []CompanionStats   `json:"top_companions"`
+}
+
+type SessionTypeStats struct {
+	Name  string `json:"name"`
+	Count uint16 `json:"count"`
+}
+
+type CompanionStats struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Us    string `json:"us"`
+	Image string `json:"image"`
+	Count uint16 `json:"count"`
+}
+
+type YearRecap struct {
+	Year            int           `json:"year"`
+	Summary         PeriodStats   `json:"summary"`
+	Months          []PeriodStats `json:"months"`
+	BusiestMonth    string        `json:"busiest_month,omitempty"`
+	CreatedSessions uint16        `json:"created_sessions"`
+	CardURL         string        `json:"card_url,omitempty"`
+}
+
+type periodBucket struct {
+	stats      PeriodStats
+	genres     map[string]uint16
+	types      map[string]uint16
+	companions map[uint]uint16
+}
+
+// GetUserPeriodStats возвращает статистику пользователя по месяцам выбранного года
+// или по годам с момента регистрации
+func GetUserPeriodStats(principal models.Principal, granularity string, year int) ([]PeriodStats, error) {
+	var user models.User
+	if err := db.GetDB().First(&user, principal.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("пользователь не найден")
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if year == 0 {
+		year = now.Year()
+	}
+
+	var buckets []*periodBucket
+	switch granularity {
+	case "", PeriodMonth:
+		buckets = monthBuckets(year)
+	case PeriodYear:
+		for y := user.DataRegister.Year(); y <= now.Year(); y++ {
+			from := time.Date(y, time.January, 1, 0, 0, 0, 0, time.UTC)
+			buckets = append(buckets, newPeriodBucket(fmt.Sprintf("%d", y), from, from.AddDate(1, 0, 0)))
+		}
+	default:
+		return nil, fmt.Errorf("неизвестная гранулярность: %s", granularity)
+	}
+
+	if err := fillPeriodBuckets(db.GetDB(), user.ID, buckets); err != nil {
+		return nil, err
+	}
+
+	result := make([]PeriodStats, len(buckets))
+	for i, b := range buckets {
+		result[i] = b.stats
+	}
+	return result, nil
+}
+
+// GetYearRecap собирает итоги года и рисует карточку для шаринга.
+// Ошибка загрузки карточки не мешает вернуть сами итоги.
+func GetYearRecap(principal models.Principal, year int) (*YearRecap, error) {
+	var user models.User
+	if err := db.GetDB().First(&user, principal.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("пользователь не найден")
+		}
+		return nil, err
+	}
+
+	if year < 2000 || year > time.Now().Year() {
+		return nil, errors.New("некорректный год")
+	}
+
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	summary := newPeriodBucket(fmt.Sprintf("%d", year), from, from.AddDate(1, 0, 0))
+	months := monthBuckets(year)
+
+	if err := fillPeriodBuckets(db.GetDB(), user.ID, append([]*periodBucket{summary}, months...)); err != nil {
+		return nil, err
+	}
+
+	var created int64
+	if err := db.GetDB().Raw(`
+		select count(*)
+		from sessions s
+		join statuses st on st.id = s.status_id
+		where st.status = 'Завершена'
+		  and s.user_id = ?
+		  and s.start_time >= ? and s.start_time < ?
+	`, user.ID, from, from.AddDate(1, 0, 0)).Scan(&created).Error; err != nil {
+		return nil, err
+	}
+
+	recap := &YearRecap{
+		Year:            year,
+		Summary:         summary.stats,
+		Months:          make([]PeriodStats, len(months)),
+		CreatedSessions: uint16(created),
+	}
+
+	var busiest uint16
+	var card utils.RecapCardData
+	card.Year = year
+	card.SessionsCount = summary.stats.SessionsCount
+	card.SpentHours = uint64(spentHours(summary.stats.SpentTime))
+	for i, m := range months {
+		recap.Months[i] = m.stats
+		card.MonthSessions[i] = m.stats.SessionsCount
+		if m.stats.SessionsCount > busiest {
+			busiest = m.stats.SessionsCount
+			recap.BusiestMonth = m.stats.Period
+		}
+	}
+
+	cardURL, err := getRecapCardURL(user.ID, card)
+	if err != nil {
+		log.Printf("Не удалось подготовить карточку итогов для пользователя %d: %v", user.ID, err)
+	}
+	recap.CardURL = cardURL
+
+	return recap, nil
+}
+
+// getRecapCardURL отдаёт ссылку из кэша или рисует и загружает новую карточку.
+// Карточку текущего года перерисовываем раз в сутки, прежний файл при этом удаляется из S3.
+// Итоги прошлых лет уже не меняются, их карточка рисуется один раз.
+func getRecapCardURL(userID uint, card utils.RecapCardData) (string, error) {
+	redisClient := db.GetRedis()
+	key := fmt.Sprintf(recapCardCacheKey, userID, card.Year)
+
+	var previous string
+	if redisClient != nil {
+		if fields, err := redisClient.HGetAll(ctx, key).Result(); err == nil && fields["url"] != "" {
+			previous = fields["url"]
+			renderedAt := parseUnixField(fields["rendered_at"])
+			if card.Year < time.Now().Year() || time.Since(renderedAt) < recapCardRefreshAfter {
+				return previous, nil
+			}
+		}
+	}
+
+	data, err := utils.RenderRecapCard(card)
+	if err != nil {
+		return "", err
+	}
+
+	url, err := middlewares.UploadImageToS3(bytes.NewReader(data), fmt.Sprintf("recap_%d.png", card.Year), "recaps")
+	if err != nil {
+		return "", err
+	}
+
+	if redisClient != nil {
+		// Del убирает строковое значение, которое хранилось здесь раньше
+		pipe := redisClient.TxPipeline()
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, "url", url, "rendered_at", time.Now().Unix())
+		if _, err := pipe.Exec(ctx); err != nil {
+			// В кэше осталась прежняя ссылка, её файл удалять нельзя
+			log.Printf("Не удалось сохранить ссылку на карточку в Redis: %v", err)
+			return url, nil
+		}
+	}
+
+	if previous != "" && previous != url {
+		if err := middlewares.DeleteImageFromS3(previous); err != nil {
+			log.Printf("Не удалось удалить старую карточку итогов %s: %v", previous, err)
+		}
+	}
+	return url, nil
+}
+
+func monthBuckets(year int) []*periodBucket {
+	buckets := make([]*periodBucket, 0, 12)
+	for m := time.January; m <= time.December; m++ {
+		from := time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+		buckets = append(buckets, newPeriodBucket(from.Format("2006-01"), from, from.AddDate(0, 1, 0)))
+	}
+	return buckets
+}
+
+func newPeriodBucket(period string, from, to time.Time) *periodBucket {
+	return &periodBucket{
+		stats: PeriodStats{
+			Period:          period,
+			From:            from,
+			To:              to,
+			TopGenres:       []GenreStats{},
+			TopSessionTypes: []SessionTypeStats{},
+			TopCompanions:   []CompanionStats{},
+		},
+		genres:     make(map[string]uint16),
+		types:      make(map[string]uint16),
+		companions: make(map[uint]uint16),
+	}
+}
+
+// fillPeriodBuckets раскладывает завершённые сессии пользователя по корзинам.
+// Корзины могут пересекаться (год и его месяцы), сессия попадает в каждую подходящую.
+func fillPeriodBuckets(database *gorm.DB, userID uint, buckets []*periodBucket) error {
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	from, to := buckets[0].stats.From, buckets[0].stats.To
+	for _, b := range buckets[1:] {
+		if b.stats.From.Before(from) {
+			from = b.stats.From
+		}
+		if b.stats.To.After(to) {
+			to = b.stats.To
+		}
+	}
+
+	rows, err := loadFinishedSessionsForUser(database, userID, from, to)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	sessionIDs := make([]uint, len(rows))
+	for i, r := range rows {
+		sessionIDs[i] = r.ID
+	}
+
+	metadata, err := db.GetSessionsMetadata(sessionIDs)
+	if err != nil {
+		return fmt.Errorf("не удалось получить жанры из MongoDB: %v", err)
+	}
+
+	var pairs []struct {
+		SessionID uint
+		UserID    uint
+	}
+	if err := database.Table("session_users").
+		Select("session_id, user_id").
+		Where("session_id IN ? AND user_id <> ?", sessionIDs, userID).
+		Scan(&pairs).Error; err != nil {
+		return err
+	}
+	companionsBySession := make(map[uint][]uint)
+	for _, p := range pairs {
+		companionsBySession[p.SessionID] = append(companionsBySession[p.SessionID], p.UserID)
+	}
+
+	for _, r := range rows {
+		for _, b := range buckets {
+			if r.StartTime.Before(b.stats.From) || !r.StartTime.Before(b.stats.To) {
+				continue
+			}
+
+			b.stats.SessionsCount++
+			b.stats.SpentTime += sessionDurationForStats(r.Duration, r.StartTime, r.EndTime)
+			if r.SessionTypeName != "" {
+				b.types[r.SessionTypeName]++
+			}
+			if meta, ok := metadata[r.ID]; ok && meta != nil {
+				for _, g := range meta.Genres {
+					if g != "" {
+						b.genres[g]++
+					}
+				}
+			}
+			for _, companionID := range companionsBySession[r.ID] {
+				b.companions[companionID]++
+			}
+		}
+	}
+
+	companionIDs := make(map[uint]struct{})
+	for _, b := range buckets {
+		for _, name := range topKeys(b.genres, periodTopLimit) {
+			b.stats.TopGenres = append(b.stats.TopGenres, GenreStats{Name: name, Count: b.genres[name]})
+		}
+		for _, name := range topKeys(b.types, periodTopLimit) {
+			b.stats.TopSessionTypes = append(b.stats.TopSessionTypes, SessionTypeStats{Name: name, Count: b.types[name]})
+		}
+		for _, id := range topKeys(b.companions, periodTopLimit) {
+			b.stats.TopCompanions = append(b.stats.TopCompanions, CompanionStats{ID: id, Count: b.companions[id]})
+			companionIDs[id] = struct{}{}
+		}
+	}
+
+	if len(companionIDs) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(companionIDs))
+	for id := range companionIDs {
+		ids = append(ids, id)
+	}
+	var users []models.User
+	if err := database.Select("id, name, us, image").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return err
+	}
+	usersByID := make(map[uint]models.User, len(users))
+	for _, u := range users {
+		usersByID[u.ID] = u
+	}
+
+	for _, b := range buckets {
+		for i := range b.stats.TopCompanions {
+			u := usersByID[b.stats.TopCompanions[i].ID]
+			b.stats.TopCompanions[i].Name = u.Name
+			b.stats.TopCompanions[i].Us = u.Us
+			b.stats.TopCompanions[i].Image = u.Image
+		}
+	}
+
+	return nil
+}
+
+// loadFinishedSessionsForUser — завершённые сессии, где пользователь был участником или создателем.
+// Нулевые from/to означают отсутствие границы.
+func loadFinishedSessionsForUser(database *gorm.DB, userID uint, from, to time.Time) ([]finishedSessionRow, error) {
+	query := database.Table("sessions s").
+		Select("s.id, s.session_type_id, c.name as session_type_name, s.user_id, s.current_users, s.duration, s.start_time, s.end_time").
+		Joins("JOIN statuses st ON st.id = s.status_id").
+		Joins("LEFT JOIN categories c ON c.id = s.session_type_id").
+		Where("st.status = ?", "Завершена").
+		Where("s.user_id = ? OR EXISTS (SELECT 1 FROM session_users su WHERE su.session_id = s.id AND su.user_id = ?)", userID, userID)
+
+	if !from.IsZero() {
+		query = query.Where("s.start_time >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("s.start_time < ?", to)
+	}
+
+	var rows []finishedSessionRow
+	if err := query.Order("s.id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// topKeys возвращает до limit ключей с наибольшими значениями
+func topKeys[K string | uint](counts map[K]uint16, limit int) []K {
+	keys := make([]K, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys
+}
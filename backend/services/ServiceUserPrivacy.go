@@ -0,0 +1,130 @@
+package services
+
+import (
+	"errors"
+
+	"friendship/db"
+	"friendship/models"
+
+	"gorm.io/gorm"
+)
+
+// Настройки приватности применяются к чужим профилям, поиску пользователей
+// и спискам участников в информации о сессии.
+
+type UpdatePrivacyInput struct {
+	ProfileVisibility    *string `json:"profile_visibility" binding:"omitempty,oneof=everyone friends nobody"`
+	HideUpcomingSessions *bool   `json:"hide_upcoming_sessions"`
+	HideStats            *bool   `json:"hide_stats"`
+	Unsearchable         *bool   `json:"unsearchable"`
+}
+
+func defaultPrivacy(userID uint) models.UserPrivacy {
+	return models.UserPrivacy{UserID: userID, ProfileVisibility: models.ProfileVisibilityEveryone}
+}
+
+// loadPrivacy возвращает настройки пользователя или значения по умолчанию
+func loadPrivacy(tx *gorm.DB, userID uint) (models.UserPrivacy, error) {
+	var privacy models.UserPrivacy
+	err := tx.Where("user_id = ?", userID).First(&privacy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return defaultPrivacy(userID), nil
+	}
+	if err != nil {
+		return models.UserPrivacy{}, err
+	}
+	return privacy, nil
+}
+
+func GetPrivacySettings(principal models.Principal) (*models.UserPrivacy, error) {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+
+	privacy, err := loadPrivacy(database, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &privacy, nil
+}
+
+// UpdatePrivacySettings меняет только переданные настройки
+func UpdatePrivacySettings(principal models.Principal, input UpdatePrivacyInput) (*models.UserPrivacy, error) {
+	database := db.GetDB()
+
+	var user models.User
+	if err := database.First(&user, principal.UserID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
+	}
+
+	privacy := defaultPrivacy(user.ID)
+	if err := database.Where("user_id = ?", user.ID).Attrs(privacy).FirstOrCreate(&privacy).Error; err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if input.ProfileVisibility != nil {
+		updates["profile_visibility"] = *input.ProfileVisibility
+	}
+	if input.HideUpcomingSessions != nil {
+		updates["hide_upcoming_sessions"] = *input.HideUpcomingSessions
+	}
+	if input.HideStats != nil {
+		updates["hide_stats"] = *input.HideStats
+	}
+	if input.Unsearchable != nil {
+		updates["unsearchable"] = *input.Unsearchable
+	}
+	if len(updates) > 0 {
+		if err := database.Model(&privacy).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return &privacy, nil
+}
+
+// canViewProfile решает, видит ли viewerID профиль владельца настроек privacy
+func canViewProfile(tx *gorm.DB, viewerID uint, privacy models.UserPrivacy) (bool, error) {
+	if viewerID == privacy.UserID {
+		return true, nil
+	}
+	blocked, err := blockedWith(tx, viewerID, []uint{privacy.UserID})
+	if err != nil || len(blocked) > 0 {
+		return false, err
+	}
+
+	switch privacy.ProfileVisibility {
+	case models.ProfileVisibilityNobody:
+		return false, nil
+	case models.ProfileVisibilityFriends:
+		friends, err := areFriends(tx, viewerID, privacy.UserID)
+		if err != nil || friends {
+			return friends, err
+		}
+		var shared int64
+		if err := tx.Table("group_users gu1").
+			Joins("JOIN group_users gu2 ON gu2.group_id = gu1.group_id").
+			Where("gu1.user_id = ? AND gu2.user_id = ?", viewerID, privacy.UserID).
+			Count(&shared).Error; err != nil {
+			return false, err
+		}
+		return shared > 0, nil
+	default:
+		return true, nil
+	}
+}
+
+// unsearchableSubquery — пользователи, скрытые из поиска
+func unsearchableSubquery(tx *gorm.DB) *gorm.DB {
+	return tx.Model(&models.UserPrivacy{}).Select("user_id").Where("unsearchable = ?", true)
+}
+
+// hiddenParticipantsSubquery — пользователи, которых не показывают в списках участников сессий
+func hiddenParticipantsSubquery(tx *gorm.DB) *gorm.DB {
+	return tx.Model(&models.UserPrivacy{}).Select("user_id").
+		Where("hide_upcoming_sessions = ? OR profile_visibility = ?", true, models.ProfileVisibilityNobody)
+}
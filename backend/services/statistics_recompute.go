@@ -0,0 +1,373 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"friendship/db"
+	"friendship/models"
+	statsusers "friendship/models/stats_users"
+)
+
+// Полный пересчёт статистики пользователей с нуля по session_users, sessions и жанрам из Mongo.
+// Инкрементальный UpdateStatisticsForFinishedSession не умеет чинить уже посчитанное,
+// поэтому после багов или смены правил подсчёта запускается этот пересчёт.
+
+const defaultRecomputeBatchSize = 100
+
+type RecomputeStatsOptions struct {
+	UserID    uint // 0 — все пользователи
+	BatchSize int
+	Apply     bool // false — только сравнить и показать расхождения
+}
+
+type StatsDifference struct {
+	UserID   uint        `json:"user_id"`
+	Field    string      `json:"field"`
+	Stored   interface{} `json:"stored"`
+	Computed interface{} `json:"computed"`
+}
+
+type RecomputeStatsReport struct {
+	UsersChecked int               `json:"users_checked"`
+	UsersChanged int               `json:"users_changed"`
+	UsersUpdated int               `json:"users_updated"`
+	Differences  []StatsDifference `json:"differences"`
+}
+
+type recomputedUserStats struct {
+	CountFilms      uint16
+	CountGames      uint16
+	CountTableGames uint16
+	CountAnother    uint16
+	CountAll        uint16
+	SpentTime       uint64
+
+	CountCreateSession uint16
+	SeriesSesionCount  uint16
+	MostBigSession     uint16
+	MostPopDay         *uint16
+
+	TopSessionType uint
+	Genres         map[string]uint16
+}
+
+type finishedSessionRow struct {
+	ID              uint
+	SessionTypeID   uint
+	SessionTypeName string
+	UserID          uint
+	CurrentUsers    uint16
+	Duration        uint16
+	StartTime       time.Time
+	EndTime         time.Time
+}
+
+// RecomputeUserStatistics пересобирает статистику одного или всех пользователей пачками.
+// Перед перезаписью расхождения с сохранёнными значениями попадают в отчёт.
+func RecomputeUserStatistics(ctx context.Context, opts RecomputeStatsOptions) (*RecomputeStatsReport, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultRecomputeBatchSize
+	}
+
+	report := &RecomputeStatsReport{Differences: []StatsDifference{}}
+	database := db.GetDB().WithContext(ctx)
+
+	if opts.UserID != 0 {
+		if err := recomputeBatch(ctx, database, []uint{opts.UserID}, opts.Apply, report); err != nil {
+			return report, err
+		}
+		return report, nil
+	}
+
+	var lastID uint
+	for {
+		var userIDs []uint
+		if err := database.Model(&models.User{}).
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(batchSize).
+			Pluck("id", &userIDs).Error; err != nil {
+			return report, fmt.Errorf("ошибка при получении пользователей: %v", err)
+		}
+		if len(userIDs) == 0 {
+			break
+		}
+
+		if err := recomputeBatch(ctx, database, userIDs, opts.Apply, report); err != nil {
+			return report, err
+		}
+
+		lastID = userIDs[len(userIDs)-1]
+	}
+
+	return report, nil
+}
+
+func recomputeBatch(ctx context.Context, database *gorm.DB, userIDs []uint, apply bool, report *RecomputeStatsReport) error {
+	for _, uid := range userIDs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		computed, err := computeUserStatsFromScratch(database, uid)
+		if err != nil {
+			return fmt.Errorf("пользователь %d: %w", uid, err)
+		}
+
+		diffs, err := diffUserStats(database, uid, computed)
+		if err != nil {
+			return fmt.Errorf("пользователь %d: %w", uid, err)
+		}
+
+		report.UsersChecked++
+		if len(diffs) == 0 {
+			continue
+		}
+		report.UsersChanged++
+		report.Differences = append(report.Differences, diffs...)
+
+		if !apply {
+			continue
+		}
+
+		if err := database.Transaction(func(tx *gorm.DB) error {
+			return overwriteUserStats(tx, uid, computed)
+		}); err != nil {
+			return fmt.Errorf("пользователь %d: не удалось перезаписать статистику: %w", uid, err)
+		}
+		report.UsersUpdated++
+
+		if _, err := awardAchievements(ctx, uid, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func computeUserStatsFromScratch(database *gorm.DB, userID uint) (*recomputedUserStats, error) {
+	rows, err := loadFinishedSessionsForUser(database, userID, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &recomputedUserStats{Genres: make(map[string]uint16)}
+	sessionIDs := make([]uint, 0, len(rows))
+
+	for _, r := range rows {
+		sessionIDs = append(sessionIDs, r.ID)
+
+		result.CountAll++
+		result.SpentTime += sessionDurationForStats(r.Duration, r.StartTime, r.EndTime)
+		switch r.SessionTypeID {
+		case 1:
+			result.CountFilms++
+		case 2:
+			result.CountGames++
+		case 3:
+			result.CountTableGames++
+		default:
+			result.CountAnother++
+		}
+
+		if r.UserID == userID {
+			result.CountCreateSession++
+			if r.CurrentUsers > result.MostBigSession {
+				result.MostBigSession = r.CurrentUsers
+			}
+		}
+	}
+
+	streak, err := computeLongestAttendanceStreak(database, userID)
+	if err != nil {
+		return nil, err
+	}
+	result.SeriesSesionCount = streak
+
+	popDay, err := computeMostPopularDayByAttendance(database, userID)
+	if err != nil {
+		return nil, err
+	}
+	result.MostPopDay = popDay
+
+	var top struct {
+		TID uint
+		C   int
+	}
+	if err := database.Raw(`
+		select s.session_type_id as tid, count(*) as c
+		from session_users su
+		join sessions s on s.id = su.session_id
+		join statuses st on st.id = s.status_id
+		where su.user_id = ?
+		  and st.status = 'Завершена'
+		  and s.session_type_id IS NOT NULL
+		group by 1
+		order by c desc, tid asc
+		limit 1
+	`, userID).Scan(&top).Error; err != nil {
+		return nil, err
+	}
+	result.TopSessionType = top.TID
+
+	if len(sessionIDs) > 0 {
+		metadata, err := db.GetSessionsMetadata(sessionIDs)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось получить жанры из MongoDB: %v", err)
+		}
+		for _, id := range sessionIDs {
+			meta, ok := metadata[id]
+			if !ok || meta == nil {
+				continue
+			}
+			for _, g := range meta.Genres {
+				if g == "" {
+					continue
+				}
+				result.Genres[g]++
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func diffUserStats(database *gorm.DB, userID uint, computed *recomputedUserStats) ([]StatsDifference, error) {
+	var sessionStats statsusers.SessionStats_users
+	if err := database.Where("user_id = ?", userID).Limit(1).Find(&sessionStats).Error; err != nil {
+		return nil, err
+	}
+
+	var sideStats statsusers.SideStats_users
+	if err := database.Where("user_id = ?", userID).Limit(1).Find(&sideStats).Error; err != nil {
+		return nil, err
+	}
+
+	var topType statsusers.PopSessionType
+	if err := database.Where("user_id = ?", userID).Limit(1).Find(&topType).Error; err != nil {
+		return nil, err
+	}
+
+	var storedGenres []struct {
+		Name  string
+		Count uint16
+	}
+	if err := database.Table("sessions_stats_genres_users").
+		Select("genres.name, sessions_stats_genres_users.count").
+		Joins("JOIN genres ON sessions_stats_genres_users.genre_id = genres.id").
+		Where("sessions_stats_genres_users.user_id = ?", userID).
+		Scan(&storedGenres).Error; err != nil {
+		return nil, err
+	}
+
+	var diffs []StatsDifference
+	add := func(field string, stored, computed interface{}) {
+		if fmt.Sprint(stored) != fmt.Sprint(computed) {
+			diffs = append(diffs, StatsDifference{UserID: userID, Field: field, Stored: stored, Computed: computed})
+		}
+	}
+
+	add("count_films", sessionStats.CountFilms, computed.CountFilms)
+	add("count_games", sessionStats.CountGames, computed.CountGames)
+	add("count_table_games", sessionStats.CountTableGames, computed.CountTableGames)
+	add("count_another", sessionStats.CountAnother, computed.CountAnother)
+	add("count_all", sessionStats.CountAll, computed.CountAll)
+	add("spent_time", sessionStats.SpentTime, computed.SpentTime)
+
+	add("count_create_session", safeUint16Value(sideStats.CountCreateSession), computed.CountCreateSession)
+	add("series_session_count", safeUint16Value(sideStats.SeriesSesionCount), computed.SeriesSesionCount)
+	add("most_big_session", safeUint16Value(sideStats.MostBigSession), computed.MostBigSession)
+	add("most_pop_day", safeUint16Value(sideStats.MostPopDay), safeUint16Value(computed.MostPopDay))
+
+	add("top_session_type", topType.SessionTypeID, computed.TopSessionType)
+
+	stored := make(map[string]uint16, len(storedGenres))
+	for _, g := range storedGenres {
+		stored[g.Name] = g.Count
+	}
+	names := make([]string, 0, len(stored)+len(computed.Genres))
+	for name := range stored {
+		names = append(names, name)
+	}
+	for name := range computed.Genres {
+		if _, ok := stored[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add("genre:"+name, stored[name], computed.Genres[name])
+	}
+
+	return diffs, nil
+}
+
+func overwriteUserStats(tx *gorm.DB, userID uint, computed *recomputedUserStats) error {
+	if err := ensureUserStatsRows(tx, userID); err != nil {
+		return err
+	}
+
+	if err := tx.Model(&statsusers.SessionStats_users{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]any{
+			"count_films":       computed.CountFilms,
+			"count_games":       computed.CountGames,
+			"count_table_games": computed.CountTableGames,
+			"count_another":     computed.CountAnother,
+			"count_all":         computed.CountAll,
+			"spent_time":        computed.SpentTime,
+		}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&statsusers.SideStats_users{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]any{
+			"count_create_session": computed.CountCreateSession,
+			"series_sesion_count":  computed.SeriesSesionCount,
+			"most_big_session":     computed.MostBigSession,
+			"most_pop_day":         computed.MostPopDay,
+		}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(&statsusers.PopSessionType{}).Error; err != nil {
+		return err
+	}
+	if computed.TopSessionType != 0 {
+		if err := tx.Create(&statsusers.PopSessionType{
+			UserID:        userID,
+			SessionTypeID: computed.TopSessionType,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(&statsusers.SessionsStatsGenres_users{}).Error; err != nil {
+		return err
+	}
+	for name, count := range computed.Genres {
+		var g statsusers.Genre
+		if err := tx.Where("name = ?", name).FirstOrCreate(&g, statsusers.Genre{Name: name}).Error; err != nil {
+			return err
+		}
+
+		uid := userID
+		genreID := g.ID
+		c := count
+		if err := tx.Create(&statsusers.SessionsStatsGenres_users{
+			UserID:  &uid,
+			GenreID: &genreID,
+			Count:   &c,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"gorm.io/gorm"
@@ -11,6 +12,7 @@ import (
 
 	"friendship/db"
 	"friendship/models"
+	"friendship/models/groups"
 	"friendship/models/sessions"
 	statsusers "friendship/models/stats_users"
 )
@@ -113,12 +115,7 @@ func UpdateStatisticsForFinishedSession(ctx context.Context, sessionID uint) err
 	userIDs[s.UserID] = struct{}{}
 
 	// 7) Инкременты по участию для всех: count_all и по типам
-	sessionDuration := uint64(0)
-	if s.Duration > 0 {
-		sessionDuration = uint64(s.Duration)
-	} else {
-		sessionDuration = uint64(s.EndTime.Sub(s.StartTime).Seconds())
-	}
+	sessionDuration := sessionDurationForStats(s.Duration, s.StartTime, s.EndTime)
 
 	for uid := range userIDs {
 		if err := ensureUserStatsRows(tx, uid); err != nil {
@@ -198,7 +195,41 @@ func UpdateStatisticsForFinishedSession(ctx context.Context, sessionID uint) err
 		}
 	}
 
-	return tx.Commit().Error
+	// 10) Граф совместных посещений: +1 каждой паре участников
+	if err := incrementCoAttendance(tx, keys(userIDs), s.EndTime); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	// 11) Достижения проверяются по уже сохранённой статистике; ошибка не откатывает подсчёт
+	for uid := range userIDs {
+		if _, err := awardAchievements(ctx, uid, s.ID); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+
+	// 12) Рейтинги в Redis
+	var city string
+	if err := db.GetDB().WithContext(ctx).Model(&groups.Group{}).
+		Select("city").Where("id = ?", s.GroupID).Scan(&city).Error; err != nil {
+		log.Printf("Не удалось получить город группы %d: %v", s.GroupID, err)
+	}
+	if err := updateLeaderboardsForSession(db.GetDB().WithContext(ctx), leaderboardSession{
+		HostID:       s.UserID,
+		GroupID:      s.GroupID,
+		City:         city,
+		EndTime:      s.EndTime,
+		Duration:     sessionDuration,
+		Participants: keys(userIDs),
+	}); err != nil {
+		log.Printf("Сессия %d: %v", s.ID, err)
+	}
+
+	return nil
 }
 
 // === Всякая штука для приколов =================================================================
@@ -253,6 +284,23 @@ func ensureUserStatsRows(tx *gorm.DB, userID uint) error {
 	return nil
 }
 
+// sessionDurationForStats — длительность в минутах, которая идёт в spent_time.
+// Duration сессии задаётся в минутах, запасной вариант по времени начала и конца — тоже.
+func sessionDurationForStats(duration uint16, start, end time.Time) uint64 {
+	if duration > 0 {
+		return uint64(duration)
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return uint64(end.Sub(start).Minutes())
+}
+
+// spentHours переводит spent_time (минуты) в часы
+func spentHours(spentTime uint64) float64 {
+	return float64(spentTime) / 60
+}
+
 func incrementTypeCounter(tx *gorm.DB, userID, sessionTypeID uint) error {
 	switch sessionTypeID {
 	case 1:
@@ -451,23 +499,33 @@ func updateCategoryStats(tx *gorm.DB, userID uint) error {
 		Count         int
 	}
 
+	// Участие — как для count_all и жанров: создатель или участник (тот же фильтр, что в пересчёте)
 	var counts []categoryCount
 	err := tx.Raw(`
 		select s.session_type_id, count(*) as count
-		from session_users su
-		join sessions s on s.id = su.session_id
+		from sessions s
 		join statuses st on st.id = s.status_id
-		where su.user_id = ?
-		  and st.status = 'Завершена'
+		where st.status = 'Завершена'
+		  and (s.user_id = ? or exists (select 1 from session_users su where su.session_id = s.id and su.user_id = ?))
 		group by s.session_type_id
-	`, userID).Scan(&counts).Error
+	`, userID, userID).Scan(&counts).Error
 
 	if err != nil {
 		return err
 	}
 
+	// Все прочие типы сводятся в count_another, поэтому сначала суммируем
+	totals := make(map[uint]int)
 	for _, count := range counts {
-		if err := incrementTypeCategoryCount(tx, userID, count.SessionTypeID, count.Count); err != nil {
+		bucket := count.SessionTypeID
+		if bucket > 3 {
+			bucket = 0
+		}
+		totals[bucket] += count.Count
+	}
+
+	for sessionTypeID, total := range totals {
+		if err := incrementTypeCategoryCount(tx, userID, sessionTypeID, total); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionDurationForStats(t *testing.T) {
+	start := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		duration uint16
+		end      time.Time
+		want     uint64
+	}{
+		{"duration в минутах", 90, start.Add(5 * time.Hour), 90},
+		{"по времени начала и конца", 0, start.Add(2*time.Hour + 30*time.Minute), 150},
+		{"неполная минута отбрасывается", 0, start.Add(45*time.Minute + 59*time.Second), 45},
+		{"конец раньше начала", 0, start.Add(-time.Hour), 0},
+		{"конец равен началу", 0, start, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sessionDurationForStats(tt.duration, start, tt.end); got != tt.want {
+				t.Errorf("sessionDurationForStats() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpentHours(t *testing.T) {
+	tests := []struct {
+		minutes uint64
+		want    float64
+	}{
+		{0, 0},
+		{30, 0.5},
+		{60, 1},
+		{150, 2.5},
+		{6000, 100},
+	}
+
+	for _, tt := range tests {
+		if got := spentHours(tt.minutes); got != tt.want {
+			t.Errorf("spentHours(%d) = %v, want %v", tt.minutes, got, tt.want)
+		}
+	}
+}
@@ -3,26 +3,148 @@ package utils
 import (
 	"crypto/rand"
 	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
 
 	"golang.org/x/crypto/argon2"
 )
 
-func generateSaltRaw(length int) []byte {
-	salt := make([]byte, length)
+// Пароли хэшируются Argon2id и хранятся в формате PHC:
+//
+//	$argon2id$v=19$m=65536,t=3,p=4$<соль>$<хэш>
+//
+// Параметры записаны в самой строке, поэтому стоимость можно менять через ARGON2_MEMORY_KIB,
+// ARGON2_TIME и ARGON2_THREADS без миграции: старые хэши проверяются со своими параметрами
+// и пересчитываются при следующем успешном входе.
+//
+// Старый формат — hex хэш в Password и hex соль в отдельном поле Salt с фиксированными
+// параметрами m=64 МБ, t=1, p=4.
+
+type argon2Params struct {
+	memory  uint32 // KiB
+	time    uint32
+	threads uint8
+}
+
+const (
+	passwordSaltLen = 16
+	passwordKeyLen  = 32
+)
+
+var (
+	passwordParams = argon2Params{memory: 64 * 1024, time: 3, threads: 4}
+	legacyParams   = argon2Params{memory: 64 * 1024, time: 1, threads: 4}
+)
+
+// InitPasswordHashing читает параметры Argon2id из окружения. Незаданные остаются по умолчанию.
+func InitPasswordHashing() error {
+	params := passwordParams
+
+	if v := os.Getenv("ARGON2_MEMORY_KIB"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || n < 8*1024 {
+			return fmt.Errorf("ARGON2_MEMORY_KIB должен быть числом не меньше 8192")
+		}
+		params.memory = uint32(n)
+	}
+	if v := os.Getenv("ARGON2_TIME"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || n < 1 {
+			return fmt.Errorf("ARGON2_TIME должен быть положительным числом")
+		}
+		params.time = uint32(n)
+	}
+	if v := os.Getenv("ARGON2_THREADS"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 8)
+		if err != nil || n < 1 {
+			return fmt.Errorf("ARGON2_THREADS должен быть числом от 1 до 255")
+		}
+		params.threads = uint8(n)
+	}
+
+	passwordParams = params
+	return nil
+}
+
+// HashPassword возвращает хэш пароля в формате PHC с текущими параметрами
+func HashPassword(password string) string {
+	salt := make([]byte, passwordSaltLen)
 	rand.Read(salt)
-	return salt
+
+	p := passwordParams
+	hash := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, passwordKeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.memory, p.time, p.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash),
+	)
 }
 
-func HashPassword(password string) (hashHex, saltHex string) {
-	salt := generateSaltRaw(16)
-	hash := argon2.IDKey([]byte(password), salt, 1, 64*1024, 4, 32)
-	return hex.EncodeToString(hash), hex.EncodeToString(salt)
+// VerifyPassword сверяет пароль с сохранённым хэшем. legacySalt нужен только для хэшей
+// старого формата. needsRehash = true, если пароль верный, но хэш стоит пересчитать:
+// он в старом формате или с параметрами, отличными от текущих.
+func VerifyPassword(password, storedHash, legacySalt string) (ok, needsRehash bool) {
+	if !strings.HasPrefix(storedHash, "$argon2id$") {
+		ok := compareLegacy(password, storedHash, legacySalt)
+		return ok, ok
+	}
+
+	params, salt, hash, err := decodePasswordHash(storedHash)
+	if err != nil {
+		return false, false
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(hash)))
+	if subtle.ConstantTimeCompare(computed, hash) != 1 {
+		return false, false
+	}
+
+	return true, params != passwordParams || len(hash) != passwordKeyLen
 }
 
-func ComparePasswords(password, storedHash, saltHex string) bool {
-	salt, _ := hex.DecodeString(saltHex)
-	hash := argon2.IDKey([]byte(password), salt, 1, 64*1024, 4, 32)
-	hashHex := hex.EncodeToString(hash)
-	return subtle.ConstantTimeCompare([]byte(hashHex), []byte(storedHash)) == 1
+func compareLegacy(password, storedHash, saltHex string) bool {
+	salt, err := hex.DecodeString(saltHex)
+	if err != nil || len(salt) == 0 {
+		return false
+	}
+	p := legacyParams
+	hash := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, passwordKeyLen)
+	return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(hash)), []byte(storedHash)) == 1
+}
+
+func decodePasswordHash(encoded string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", соль, хэш
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, fmt.Errorf("некорректный формат хэша")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("неподдерживаемая версия argon2")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, fmt.Errorf("некорректные параметры хэша")
+	}
+	if params.time == 0 || params.threads == 0 {
+		return params, nil, nil, fmt.Errorf("некорректные параметры хэша")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(hash) == 0 {
+		return params, nil, nil, fmt.Errorf("некорректный хэш")
+	}
+
+	return params, salt, hash, nil
 }
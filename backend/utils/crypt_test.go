@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Небольшие параметры, чтобы тесты не тратили по 64 МБ на хэш
+func testPasswordParams(t *testing.T) {
+	t.Helper()
+
+	saved := passwordParams
+	t.Cleanup(func() { passwordParams = saved })
+
+	t.Setenv("ARGON2_MEMORY_KIB", "8192")
+	t.Setenv("ARGON2_TIME", "1")
+	t.Setenv("ARGON2_THREADS", "1")
+	if err := InitPasswordHashing(); err != nil {
+		t.Fatalf("InitPasswordHashing: %v", err)
+	}
+}
+
+func TestHashPasswordPHC(t *testing.T) {
+	testPasswordParams(t)
+
+	hash := HashPassword("Secret-Passw0rd")
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=8192,t=1,p=1$") {
+		t.Fatalf("неожиданный формат хэша: %s", hash)
+	}
+	if HashPassword("Secret-Passw0rd") == hash {
+		t.Error("два хэша одного пароля совпали, соль не случайна")
+	}
+
+	ok, needsRehash := VerifyPassword("Secret-Passw0rd", hash, "")
+	if !ok || needsRehash {
+		t.Errorf("VerifyPassword(верный) = (%v, %v), want (true, false)", ok, needsRehash)
+	}
+
+	ok, needsRehash = VerifyPassword("secret-passw0rd", hash, "")
+	if ok || needsRehash {
+		t.Errorf("VerifyPassword(неверный) = (%v, %v), want (false, false)", ok, needsRehash)
+	}
+}
+
+func TestVerifyPasswordRehashOnParamsChange(t *testing.T) {
+	testPasswordParams(t)
+	hash := HashPassword("Secret-Passw0rd")
+
+	// Стоимость подняли — старый хэш проверяется со своими параметрами, но требует пересчёта
+	t.Setenv("ARGON2_TIME", "2")
+	if err := InitPasswordHashing(); err != nil {
+		t.Fatal(err)
+	}
+
+	ok, needsRehash := VerifyPassword("Secret-Passw0rd", hash, "")
+	if !ok || !needsRehash {
+		t.Errorf("VerifyPassword() после смены параметров = (%v, %v), want (true, true)", ok, needsRehash)
+	}
+
+	// Неверный пароль не должен запускать пересчёт
+	if ok, needsRehash := VerifyPassword("wrong", hash, ""); ok || needsRehash {
+		t.Errorf("VerifyPassword(неверный) = (%v, %v), want (false, false)", ok, needsRehash)
+	}
+
+	rehashed := HashPassword("Secret-Passw0rd")
+	if !strings.Contains(rehashed, ",t=2,") {
+		t.Errorf("новый хэш посчитан не с текущими параметрами: %s", rehashed)
+	}
+	if ok, needsRehash := VerifyPassword("Secret-Passw0rd", rehashed, ""); !ok || needsRehash {
+		t.Errorf("VerifyPassword(пересчитанный) = (%v, %v), want (true, false)", ok, needsRehash)
+	}
+}
+
+func TestVerifyPasswordLegacy(t *testing.T) {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	p := legacyParams
+	stored := hex.EncodeToString(argon2.IDKey([]byte("Secret-Passw0rd"), salt, p.time, p.memory, p.threads, passwordKeyLen))
+	saltHex := hex.EncodeToString(salt)
+
+	ok, needsRehash := VerifyPassword("Secret-Passw0rd", stored, saltHex)
+	if !ok || !needsRehash {
+		t.Errorf("VerifyPassword(старый формат) = (%v, %v), want (true, true)", ok, needsRehash)
+	}
+
+	if ok, _ := VerifyPassword("wrong", stored, saltHex); ok {
+		t.Error("старый формат принял неверный пароль")
+	}
+	if ok, _ := VerifyPassword("Secret-Passw0rd", stored, ""); ok {
+		t.Error("старый формат принят без соли")
+	}
+	if ok, _ := VerifyPassword("Secret-Passw0rd", stored, "zz"); ok {
+		t.Error("старый формат принят с битой солью")
+	}
+}
+
+func TestVerifyPasswordMalformed(t *testing.T) {
+	testPasswordParams(t)
+	valid := HashPassword("Secret-Passw0rd")
+	parts := strings.Split(valid, "$")
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"пусто", ""},
+		{"мало частей", "$argon2id$v=19$m=8192,t=1,p=1$" + parts[4]},
+		{"другая версия", strings.Replace(valid, "v=19", "v=16", 1)},
+		{"битые параметры", strings.Replace(valid, "m=8192,t=1,p=1", "m=8192,t=x,p=1", 1)},
+		{"нулевое время", strings.Replace(valid, "t=1,", "t=0,", 1)},
+		{"нулевые потоки", strings.Replace(valid, "p=1$", "p=0$", 1)},
+		{"битая соль", strings.Join([]string{"", parts[1], parts[2], parts[3], "!!!", parts[5]}, "$")},
+		{"пустой хэш", strings.Join([]string{"", parts[1], parts[2], parts[3], parts[4], ""}, "$")},
+		{"чужой хэш", strings.Replace(valid, parts[5], parts[4], 1)},
+	}
+
+	for _, tt := range tests {
+		if ok, needsRehash := VerifyPassword("Secret-Passw0rd", tt.hash, "00"); ok || needsRehash {
+			t.Errorf("%s: VerifyPassword() = (%v, %v), want (false, false)", tt.name, ok, needsRehash)
+		}
+	}
+}
+
+func TestInitPasswordHashingRejectsBadEnv(t *testing.T) {
+	saved := passwordParams
+	t.Cleanup(func() { passwordParams = saved })
+
+	tests := []struct {
+		key, value string
+	}{
+		{"ARGON2_MEMORY_KIB", "1024"},
+		{"ARGON2_MEMORY_KIB", "много"},
+		{"ARGON2_TIME", "0"},
+		{"ARGON2_THREADS", "0"},
+		{"ARGON2_THREADS", "256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			if err := InitPasswordHashing(); err == nil {
+				t.Errorf("InitPasswordHashing() принял %s=%s", tt.key, tt.value)
+			}
+			if passwordParams != saved {
+				t.Error("параметры изменились несмотря на ошибку")
+			}
+		})
+	}
+}
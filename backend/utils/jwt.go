@@ -2,8 +2,6 @@ package utils
 
 import (
 	"fmt"
-	"friendship/models"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -14,12 +12,50 @@ type TokenPair struct {
 	RefreshToken string
 }
 
-type UserFinder func(email string) (*models.User, error)
+// AccessClaims — данные access токена. Email здесь тот, что был при входе, актуальный
+// берётся из principal, который загружает JWTAuthMiddleware.
+type AccessClaims struct {
+	UserID    uint
+	Email     string
+	SessionID string
+}
+
+// RefreshClaims — данные refresh токена, нужные для ротации
+type RefreshClaims struct {
+	UserID    uint
+	Email     string
+	JTI       string
+	SessionID string
+	ExpiresAt time.Time
+}
+
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+func signToken(key *signingKey, claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+// verificationKey выбирает открытый ключ по kid из заголовка токена
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok || kid == "" {
+		return nil, fmt.Errorf("в токене нет kid")
+	}
+	key, ok := jwtKeys.publicKey(kid)
+	if !ok {
+		return nil, fmt.Errorf("неизвестный ключ подписи %q", kid)
+	}
+	return key, nil
+}
 
-func GenerateTokenPair(id int, email, name, us, image string) (TokenPair, error) {
-	secretKey := os.Getenv("SECRET_KEY_JWT")
-	if len(secretKey) == 0 {
-		return TokenPair{}, fmt.Errorf("пустой секретный ключ")
+// GenerateTokenPair выпускает access и refresh токены. sessionID — семейство refresh токенов
+// одного входа (устройства), jti — идентификатор конкретного refresh токена.
+func GenerateTokenPair(id int, email, name, us, image, sessionID, jti string) (TokenPair, error) {
+	key, err := jwtKeys.signing()
+	if err != nil {
+		return TokenPair{}, err
 	}
 
 	now := time.Now()
@@ -31,30 +67,28 @@ func GenerateTokenPair(id int, email, name, us, image string) (TokenPair, error)
 		"Us":       us,
 		"Username": name,
 		"Image":    image,
+		"sid":      sessionID,
 		"exp":      now.Add(20 * time.Minute).Unix(),
 		"iat":      now.Unix(),
 	}
 
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessString, err := accessToken.SignedString([]byte(secretKey))
+	accessString, err := signToken(key, accessClaims)
 	if err != nil {
 		return TokenPair{}, err
 	}
 
-	// Refresh токен (30 дней жизни)
+	// Refresh токен (30 дней жизни), одноразовый: при обновлении выдаётся новый
 	refreshClaims := jwt.MapClaims{
-		"Id":       id,
-		"Email":    email,
-		"Us":       us,
-		"Username": name,
-		"Image":    image,
-		"exp":      now.Add(30 * 24 * time.Hour).Unix(),
-		"iat":      now.Unix(),
-		"typ":      "refresh",
+		"Id":    id,
+		"Email": email,
+		"sid":   sessionID,
+		"jti":   jti,
+		"exp":   now.Add(RefreshTokenTTL).Unix(),
+		"iat":   now.Unix(),
+		"typ":   "refresh",
 	}
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshString, err := refreshToken.SignedString([]byte(secretKey))
+	refreshString, err := signToken(key, refreshClaims)
 	if err != nil {
 		return TokenPair{}, err
 	}
@@ -65,77 +99,89 @@ func GenerateTokenPair(id int, email, name, us, image string) (TokenPair, error)
 	}, nil
 }
 
-func RefreshTokens(refreshTokenString string, findUser UserFinder) (TokenPair, error) {
-	secretKey := os.Getenv("SECRET_KEY_JWT")
-	if len(secretKey) == 0 {
-		return TokenPair{}, fmt.Errorf("пустой секретный ключ")
-	}
-
-	token, err := jwt.Parse(refreshTokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("неподдерживаемый метод подписи: %v", token.Header["alg"])
-		}
-		return []byte(secretKey), nil
-	})
+// ParseRefreshToken проверяет подпись и срок refresh токена и возвращает его claims.
+// Отозван ли токен, проверяет вызывающий код.
+func ParseRefreshToken(refreshTokenString string) (*RefreshClaims, error) {
+	token, err := jwt.Parse(refreshTokenString, verificationKey, jwt.WithValidMethods([]string{"EdDSA"}))
 
 	if err != nil {
-		return TokenPair{}, fmt.Errorf("невалидный refresh токен: %w", err)
+		return nil, fmt.Errorf("невалидный refresh токен: %w", err)
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok || !token.Valid {
-		return TokenPair{}, fmt.Errorf("невалидный refresh токен")
+		return nil, fmt.Errorf("невалидный refresh токен")
 	}
 
 	if typ, ok := claims["typ"].(string); !ok || typ != "refresh" {
-		return TokenPair{}, fmt.Errorf("токен не является refresh токеном")
+		return nil, fmt.Errorf("токен не является refresh токеном")
 	}
 
 	email, okEmail := claims["Email"].(string)
 	if !okEmail {
-		return TokenPair{}, fmt.Errorf("неверный формат Email в claims")
+		return nil, fmt.Errorf("неверный формат Email в claims")
 	}
 
 	idFloat, okId := claims["Id"].(float64)
 	if !okId {
-		return TokenPair{}, fmt.Errorf("неверный формат Id в claims")
+		return nil, fmt.Errorf("неверный формат Id в claims")
 	}
-	id := int(idFloat)
 
-	user, err := findUser(email)
-	if err != nil {
-		return TokenPair{}, err
+	jti, okJti := claims["jti"].(string)
+	sessionID, okSid := claims["sid"].(string)
+	if !okJti || !okSid || jti == "" || sessionID == "" {
+		return nil, fmt.Errorf("refresh токен выпущен до введения ротации, требуется повторный вход")
 	}
 
-	return GenerateTokenPair(id, email, user.Name, user.Us, user.Image)
-}
-
-func ParseJWT(tokenString string) (string, error) {
-	secretKey := os.Getenv("SECRET_KEY_JWT")
-	if secretKey == "" {
-		return "", fmt.Errorf("секретный ключ JWT не найден в переменных окружения")
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return nil, fmt.Errorf("неверный формат exp в claims")
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("неподдерживаемый метод подписи: %v", token.Header["alg"])
-		}
-		return []byte(secretKey), nil
-	})
+	return &RefreshClaims{
+		UserID:    uint(idFloat),
+		Email:     email,
+		JTI:       jti,
+		SessionID: sessionID,
+		ExpiresAt: exp.Time,
+	}, nil
+}
+
+// ParseAccessToken проверяет подпись и срок access токена и возвращает его claims
+func ParseAccessToken(tokenString string) (*AccessClaims, error) {
+	token, err := jwt.Parse(tokenString, verificationKey, jwt.WithValidMethods([]string{"EdDSA"}))
 
 	if err != nil || !token.Valid {
-		return "", fmt.Errorf("некорректный токен: %v", err)
+		return nil, fmt.Errorf("некорректный токен: %v", err)
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", fmt.Errorf("некорректные claims")
+		return nil, fmt.Errorf("некорректные claims")
+	}
+
+	if typ, _ := claims["typ"].(string); typ == "refresh" {
+		return nil, fmt.Errorf("refresh токен нельзя использовать для доступа")
+	}
+
+	idFloat, ok := claims["Id"].(float64)
+	if !ok || idFloat < 1 {
+		return nil, fmt.Errorf("неверный формат Id в claims")
 	}
 
 	email, ok := claims["Email"].(string)
 	if !ok {
-		return "", fmt.Errorf("email не найден в токене")
+		return nil, fmt.Errorf("email не найден в токене")
+	}
+
+	sessionID, ok := claims["sid"].(string)
+	if !ok || sessionID == "" {
+		return nil, fmt.Errorf("sid не найден в токене")
 	}
 
-	return email, nil
+	return &AccessClaims{
+		UserID:    uint(idFloat),
+		Email:     email,
+		SessionID: sessionID,
+	}, nil
 }
@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Ключи подписи JWT (EdDSA, Ed25519). Ключи лежат в каталоге JWT_KEYS_DIR, по одному PEM-файлу
+// на ключ, kid — имя файла без расширения. Файл с закрытым ключом (PKCS#8) можно использовать
+// для подписи, файл только с открытым ключом — лишь для проверки старых токенов.
+// Ротация: добавить новый ключ, дождаться, пока JWKS заберут другие сервисы, переключить
+// JWT_ACTIVE_KID, а старый ключ удалить после истечения выданных им refresh токенов (30 дней).
+
+type signingKey struct {
+	kid     string
+	private ed25519.PrivateKey
+}
+
+type jwtKeyStore struct {
+	mu           sync.RWMutex
+	active       *signingKey
+	verification map[string]ed25519.PublicKey
+}
+
+var jwtKeys = &jwtKeyStore{verification: map[string]ed25519.PublicKey{}}
+
+// JWK — открытый ключ в формате RFC 8037 (OKP, Ed25519)
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// InitJWTKeys загружает ключи подписи. Без JWT_KEYS_DIR создаётся временный ключ,
+// и после перезапуска все выданные токены становятся недействительными.
+func InitJWTKeys() error {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		Log.Println("JWT_KEYS_DIR не задан, используется временный ключ подписи JWT")
+		jwtKeys.set(&signingKey{kid: "ephemeral", private: private}, map[string]ed25519.PublicKey{
+			"ephemeral": private.Public().(ed25519.PublicKey),
+		})
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	privateKeys := map[string]ed25519.PrivateKey{}
+	verification := map[string]ed25519.PublicKey{}
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		private, public, err := readEd25519PEM(file)
+		if err != nil {
+			return fmt.Errorf("ключ %s: %v", kid, err)
+		}
+		verification[kid] = public
+		if private != nil {
+			privateKeys[kid] = private
+		}
+	}
+
+	activeKid := os.Getenv("JWT_ACTIVE_KID")
+	if activeKid == "" {
+		// По умолчанию подписываем последним по имени ключом, например 2026-10.pem
+		for kid := range privateKeys {
+			if kid > activeKid {
+				activeKid = kid
+			}
+		}
+	}
+	private, ok := privateKeys[activeKid]
+	if !ok {
+		return fmt.Errorf("закрытый ключ подписи JWT %q не найден в %s", activeKid, dir)
+	}
+
+	jwtKeys.set(&signingKey{kid: activeKid, private: private}, verification)
+	return nil
+}
+
+func readEd25519PEM(path string) (ed25519.PrivateKey, ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, fmt.Errorf("файл не в формате PEM")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		private, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, nil, fmt.Errorf("поддерживаются только ключи Ed25519")
+		}
+		return private, private.Public().(ed25519.PublicKey), nil
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		public, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, nil, fmt.Errorf("поддерживаются только ключи Ed25519")
+		}
+		return nil, public, nil
+	default:
+		return nil, nil, fmt.Errorf("неизвестный тип PEM-блока %q", block.Type)
+	}
+}
+
+func (s *jwtKeyStore) set(active *signingKey, verification map[string]ed25519.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active = active
+	s.verification = verification
+}
+
+func (s *jwtKeyStore) signing() (*signingKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.active == nil {
+		return nil, fmt.Errorf("ключи подписи JWT не инициализированы")
+	}
+	return s.active, nil
+}
+
+func (s *jwtKeyStore) publicKey(kid string) (ed25519.PublicKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.verification[kid]
+	return key, ok
+}
+
+// PublicJWKS возвращает открытые ключи для проверки токенов другими сервисами
+func PublicJWKS() JWKSet {
+	jwtKeys.mu.RLock()
+	defer jwtKeys.mu.RUnlock()
+
+	kids := make([]string, 0, len(jwtKeys.verification))
+	for kid := range jwtKeys.verification {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := JWKSet{Keys: make([]JWK, 0, len(kids))}
+	for _, kid := range kids {
+		set.Keys = append(set.Keys, JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(jwtKeys.verification[kid]),
+			Kid: kid,
+			Alg: "EdDSA",
+			Use: "sig",
+		})
+	}
+	return set
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+)
+
+// Карточка «итоги года» рисуется без внешних шрифтов: цифры и нужные буквы
+// заданы растровым шрифтом 5x7, который масштабируется целиком.
+
+const (
+	recapCardWidth  = 1200
+	recapCardHeight = 630
+)
+
+type RecapCardData struct {
+	Year          int
+	SessionsCount uint16
+	SpentHours    uint64
+	MonthSessions [12]uint16
+}
+
+var (
+	recapBackground = color.RGBA{R: 0x1f, G: 0x23, B: 0x3a, A: 0xff}
+	recapPanel      = color.RGBA{R: 0x2b, G: 0x30, B: 0x4f, A: 0xff}
+	recapText       = color.RGBA{R: 0xf5, G: 0xf5, B: 0xf7, A: 0xff}
+	recapMuted      = color.RGBA{R: 0x9a, G: 0xa0, B: 0xc3, A: 0xff}
+	recapAccent     = color.RGBA{R: 0x5e, G: 0xc2, B: 0x8f, A: 0xff}
+)
+
+var recapGlyphs = map[rune][7]string{
+	'0': {".###.", "#...#", "#..##", "#.#.#", "##..#", "#...#", ".###."},
+	'1': {"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'2': {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3': {"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	'4': {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5': {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6': {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7': {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8': {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9': {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+	'А': {".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'В': {"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	'Г': {"#####", "#....", "#....", "#....", "#....", "#....", "#...."},
+	'Е': {"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	'И': {"#...#", "#...#", "#..##", "#.#.#", "##..#", "#...#", "#...#"},
+	'Й': {".#.#.", "#...#", "#..##", "#.#.#", "##..#", "#...#", "#...#"},
+	'О': {".###.", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'С': {".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	'Т': {"#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'Ч': {"#...#", "#...#", "#...#", ".####", "....#", "....#", "....#"},
+}
+
+// RenderRecapCard рисует PNG-карточку с итогами года
+func RenderRecapCard(data RecapCardData) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, recapCardWidth, recapCardHeight))
+	fillRect(img, 0, 0, recapCardWidth, recapCardHeight, recapBackground)
+
+	drawText(img, "ИТОГИ "+strconv.Itoa(data.Year), 60, 50, 8, recapText)
+
+	sessions := strconv.FormatUint(uint64(data.SessionsCount), 10)
+	hours := strconv.FormatUint(data.SpentHours, 10)
+
+	drawText(img, sessions, 60, 150, 12, recapAccent)
+	drawText(img, "СЕССИЙ", 60, 250, 4, recapMuted)
+
+	drawText(img, hours, 620, 150, 12, recapAccent)
+	drawText(img, "ЧАСОВ", 620, 250, 4, recapMuted)
+
+	// Помесячная гистограмма
+	chartTop, chartBottom := 320, 580
+	fillRect(img, 40, chartTop-20, recapCardWidth-40, chartBottom+20, recapPanel)
+
+	var maxCount uint16
+	for _, c := range data.MonthSessions {
+		if c > maxCount {
+			maxCount = c
+		}
+	}
+
+	slot := (recapCardWidth - 120) / len(data.MonthSessions)
+	barWidth := slot * 2 / 3
+	for i, c := range data.MonthSessions {
+		x := 60 + i*slot + (slot-barWidth)/2
+		height := 4
+		if maxCount > 0 && c > 0 {
+			height = int(c) * (chartBottom - chartTop) / int(maxCount)
+			if height < 4 {
+				height = 4
+			}
+		}
+		barColor := recapAccent
+		if c == 0 {
+			barColor = recapMuted
+		}
+		fillRect(img, x, chartBottom-height, x+barWidth, chartBottom, barColor)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("не удалось закодировать карточку: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func fillRect(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+func drawText(img *image.RGBA, text string, x, y, scale int, c color.RGBA) {
+	for _, r := range text {
+		if glyph, ok := recapGlyphs[r]; ok {
+			for row, line := range glyph {
+				for col, px := range line {
+					if px != '#' {
+						continue
+					}
+					fillRect(img, x+col*scale, y+row*scale, x+(col+1)*scale, y+(row+1)*scale, c)
+				}
+			}
+		}
+		x += 6 * scale
+	}
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP по RFC 6238: HMAC-SHA1, 6 цифр, шаг 30 секунд — параметры по умолчанию
+// для Google Authenticator и совместимых приложений.
+
+const (
+	totpDigits = 6
+	totpPeriod = 30
+	totpSkew   = 1 // допускаем расхождение часов на один шаг в каждую сторону
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func GenerateTOTPSecret() string {
+	secret := make([]byte, 20)
+	_, _ = rand.Read(secret)
+	return totpEncoding.EncodeToString(secret)
+}
+
+// TOTPAuthURI — ссылка otpauth:// для QR-кода в приложении-аутентификаторе
+func TOTPAuthURI(issuer, account, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// ValidateTOTP проверяет код и возвращает номер шага, которому он соответствует.
+// Шаг нужен вызывающему коду, чтобы не принять один и тот же код дважды.
+func ValidateTOTP(secret, code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+
+	current := now.Unix() / totpPeriod
+	for delta := int64(-totpSkew); delta <= totpSkew; delta++ {
+		step := current + delta
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateRecoveryCode — одноразовый код восстановления вида XXXXX-XXXXX
+func GenerateRecoveryCode() string {
+	code := GenerationSessionCode(10)
+	return code[:5] + "-" + code[5:]
+}
+
+// HashRecoveryCode хэширует код восстановления. Коды случайные и длинные, поэтому
+// достаточно SHA-256 без соли, а хэш можно искать в базе напрямую.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Секрет из приложения B RFC 6238 — ASCII "12345678901234567890"
+var rfcTOTPSecret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestValidateTOTPRFCVectors(t *testing.T) {
+	// Ожидаемые значения — последние 6 цифр 8-значных кодов SHA1 из RFC 6238
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		now := time.Unix(tt.unix, 0)
+		step, ok := ValidateTOTP(rfcTOTPSecret, tt.code, now)
+		if !ok {
+			t.Errorf("код %s на %d не принят", tt.code, tt.unix)
+			continue
+		}
+		if want := tt.unix / totpPeriod; step != want {
+			t.Errorf("шаг для %d = %d, want %d", tt.unix, step, want)
+		}
+	}
+}
+
+func TestValidateTOTPWindow(t *testing.T) {
+	secret := GenerateTOTPSecret()
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000015, 0)
+	current := now.Unix() / totpPeriod
+
+	for delta := int64(-3); delta <= 3; delta++ {
+		step, ok := ValidateTOTP(secret, totpCode(key, current+delta), now)
+		wantOK := delta >= -totpSkew && delta <= totpSkew
+		if ok != wantOK {
+			t.Errorf("сдвиг %d шагов: принят = %v, want %v", delta, ok, wantOK)
+		}
+		if ok && step != current+delta {
+			t.Errorf("сдвиг %d шагов: шаг = %d, want %d", delta, step, current+delta)
+		}
+	}
+}
+
+// Защита от повтора сравнивает шаг с last_used_step, поэтому один и тот же код
+// должен давать один и тот же шаг всё время, пока он принимается.
+func TestValidateTOTPStepStableForReplay(t *testing.T) {
+	secret := GenerateTOTPSecret()
+	key, _ := totpEncoding.DecodeString(secret)
+
+	issuedStep := int64(1700000000 / totpPeriod)
+	issued := time.Unix(issuedStep*totpPeriod, 0)
+	code := totpCode(key, issuedStep)
+
+	for offset := -totpPeriod; offset < 2*totpPeriod; offset += 5 {
+		at := issued.Add(time.Duration(offset) * time.Second)
+		step, ok := ValidateTOTP(secret, code, at)
+		if !ok {
+			t.Errorf("код не принят через %d с", offset)
+			continue
+		}
+		if step != issuedStep {
+			t.Errorf("через %d с шаг = %d, want %d", offset, step, issuedStep)
+		}
+	}
+
+	// Код следующего шага получает больший номер и проходит проверку last_used_step < step
+	next, ok := ValidateTOTP(secret, totpCode(key, issuedStep+1), issued)
+	if !ok || next <= issuedStep {
+		t.Errorf("код следующего шага: шаг = %d, ok = %v", next, ok)
+	}
+}
+
+func TestValidateTOTPRejectsMalformed(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+	}{
+		{"пустой код", rfcTOTPSecret, ""},
+		{"короткий код", rfcTOTPSecret, "28708"},
+		{"длинный код", rfcTOTPSecret, "94287082"},
+		{"неверный код", rfcTOTPSecret, "287083"},
+		{"битый секрет", "not base32!", "287082"},
+	}
+
+	for _, tt := range tests {
+		if _, ok := ValidateTOTP(tt.secret, tt.code, now); ok {
+			t.Errorf("%s: код принят", tt.name)
+		}
+	}
+
+	// Пробелы по краям и секрет в нижнем регистре допустимы
+	if _, ok := ValidateTOTP(rfcTOTPSecret, " 287082 ", now); !ok {
+		t.Error("код с пробелами не принят")
+	}
+	if _, ok := ValidateTOTP(strings.ToLower(rfcTOTPSecret), "287082", now); !ok {
+		t.Error("секрет в нижнем регистре не принят")
+	}
+}
+
+func TestHashRecoveryCodeNormalizes(t *testing.T) {
+	want := HashRecoveryCode("ABCDE-12345")
+	for _, code := range []string{"abcde-12345", "ABCDE12345", " abcde12345 "} {
+		if got := HashRecoveryCode(code); got != want {
+			t.Errorf("HashRecoveryCode(%q) отличается от ABCDE-12345", code)
+		}
+	}
+	if HashRecoveryCode("ABCDE-12346") == want {
+		t.Error("разные коды дают одинаковый хэш")
+	}
+}
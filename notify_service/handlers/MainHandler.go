@@ -8,17 +8,13 @@ import (
 )
 
 func RegisterDevice(c *gin.Context) {
-	email := c.MustGet("email").(string)
-	if email == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "не передан jwt"})
-		return
-	}
+	userID := c.MustGet("user_id").(uint)
 	var input services.DeviceRegistrationRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный json"})
 		return
 	}
-	if err := services.RegisterDevice(email, input); err != nil {
+	if err := services.RegisterDevice(userID, input); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -2,6 +2,8 @@ package middlewares
 
 import (
 	"net/http"
+	"notify_service/db"
+	"notify_service/models"
 	"notify_service/utils"
 	"strings"
 
@@ -24,15 +26,22 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		email, err := utils.ParseJWT(parts[1])
+		userID, err := utils.ParseJWT(parts[1])
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Невалидный токен: " + err.Error()})
 			c.Abort()
 			return
 		}
 
-		// Сохраняем email в контекст
-		c.Set("email", email)
+		var user models.User
+		if err := db.GetDB().Select("id").First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден"})
+			c.Abort()
+			return
+		}
+
+		// Сохраняем id пользователя в контекст
+		c.Set("user_id", user.ID)
 		c.Next()
 	}
 }
@@ -35,6 +35,7 @@ func StartNotificationWorker(db *gorm.DB) {
 	go func() {
 		for range ticker.C {
 			processSessions(db)
+			processPendingNotifications(db)
 		}
 	}()
 }
@@ -72,6 +73,10 @@ func processSessions(db *gorm.DB) {
 
 	for _, s := range sessionsList {
 		for _, nt := range types {
+			// Типы без смещения (например, "achievement") не относятся к напоминаниям
+			if nt.HoursBefore <= 0 {
+				continue
+			}
 			notifyTime := s.StartTime.Add(-time.Duration(nt.HoursBefore) * time.Hour)
 
 			if now.After(notifyTime.Add(-30*time.Second)) && now.Before(notifyTime.Add(30*time.Second)) {
@@ -182,11 +187,14 @@ func sendSessionNotification(db *gorm.DB, s sessions.Session, nt sessions.Notifi
 	}
 
 	if len(userIDs) > 0 {
-		sendFCMNotifications(db, userIDs, randomTitle, text, s.ImageURL, s.ID)
+		sendFCMNotifications(db, userIDs, randomTitle, text, s.ImageURL, map[string]string{
+			"session_id": fmt.Sprintf("%d", s.ID),
+			"type":       "session_reminder",
+		})
 	}
 }
 
-func sendFCMNotifications(db *gorm.DB, userIDs []uint, title, text, imageURL string, sessionID uint) {
+func sendFCMNotifications(db *gorm.DB, userIDs []uint, title, text, imageURL string, data map[string]string) {
 	var deviceTokens []models.DeviceUser
 	isActive := true
 	if err := db.Where("user_id IN ? AND is_active = ?", userIDs, isActive).
@@ -196,22 +204,17 @@ func sendFCMNotifications(db *gorm.DB, userIDs []uint, title, text, imageURL str
 	}
 
 	if len(deviceTokens) == 0 {
-		log.Printf("No active device tokens found for session %d\n", sessionID)
+		log.Printf("No active device tokens found for %s notification\n", data["type"])
 		return
 	}
 
-	log.Printf("Sending FCM notifications to %d devices for session %d\n", len(deviceTokens), sessionID)
+	log.Printf("Sending FCM %s notifications to %d devices\n", data["type"], len(deviceTokens))
 
 	for _, dt := range deviceTokens {
 		if dt.DeviceToken == nil || dt.UserID == nil {
 			continue
 		}
 
-		data := map[string]string{
-			"session_id": fmt.Sprintf("%d", sessionID),
-			"type":       "session_reminder",
-		}
-
 		err := firebase.SendPushNotification(*dt.DeviceToken, title, text, imageURL, data)
 		if err != nil {
 			log.Printf("Error sending FCM to user %d (token: %s): %v\n", *dt.UserID, (*dt.DeviceToken)[:20]+"...", err)
@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"notify_service/models"
+	"notify_service/models/sessions"
+)
+
+// Уведомления, которые backend создаёт неотправленными (например, объявления групп),
+// доставляются здесь в Telegram и push. Текст уже сохранён в самом уведомлении.
+
+const pendingNotificationsBatch = 500
+
+type pendingKey struct {
+	Type     string
+	Title    string
+	Text     string
+	ImageURL string
+}
+
+func processPendingNotifications(db *gorm.DB) {
+	var pending []sessions.Notification
+	if err := db.Where("sent = ? AND send_at <= ?", false, time.Now()).
+		Order("id ASC").
+		Limit(pendingNotificationsBatch).
+		Find(&pending).Error; err != nil {
+		log.Println("Error fetching pending notifications:", err)
+		return
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	typeNames := map[uint]string{}
+	var types []sessions.NotificationType
+	if err := db.Find(&types).Error; err != nil {
+		log.Println("Error fetching notification types:", err)
+		return
+	}
+	for _, nt := range types {
+		typeNames[nt.ID] = nt.Name
+	}
+
+	ids := make([]uint, 0, len(pending))
+	recipients := map[pendingKey][]uint{}
+	for _, n := range pending {
+		ids = append(ids, n.ID)
+		key := pendingKey{Type: typeNames[n.NotificationTypeID], Title: n.Title, Text: n.Text, ImageURL: n.ImageURL}
+		recipients[key] = append(recipients[key], n.UserID)
+	}
+
+	// Помечаем заранее, чтобы при ошибке доставки не отправить одно и то же повторно
+	if err := db.Model(&sessions.Notification{}).Where("id IN ?", ids).Update("sent", true).Error; err != nil {
+		log.Println("Error marking notifications as sent:", err)
+		return
+	}
+
+	for key, userIDs := range recipients {
+		log.Printf("Sending %s notification to %d users\n", key.Type, len(userIDs))
+
+		var users []models.User
+		if err := db.Where("id IN ? AND telegram_id IS NOT NULL", userIDs).Find(&users).Error; err != nil {
+			log.Println("Error fetching users for telegram:", err)
+		}
+		telegramIDs := []int64{}
+		for _, u := range users {
+			if tid, err := parseTelegramID(*u.TelegramID); err == nil {
+				telegramIDs = append(telegramIDs, tid)
+			}
+		}
+		if len(telegramIDs) > 0 {
+			sendToTelegramBot(TelegramMessage{
+				Items: []TelegramItem{{
+					TelegramIDs: telegramIDs,
+					ImageURL:    key.ImageURL,
+					Title:       key.Title,
+					Text:        key.Text,
+				}},
+			})
+		}
+
+		sendFCMNotifications(db, userIDs, key.Title, key.Text, key.ImageURL, map[string]string{"type": key.Type})
+	}
+}
@@ -18,17 +18,7 @@ type DeviceRegistrationRequest struct {
 }
 
 // регистрирует новое устройство или обновляет существующее
-func RegisterDevice(email string, req DeviceRegistrationRequest) error {
-	if email == "" {
-		return fmt.Errorf("не передан jwt")
-	}
-	var user models.User
-	if err := db.GetDB().Where("email = ?", email).First(&user).Error; err != nil {
-		return fmt.Errorf("failed to find user: %v", err)
-	}
-
-	userID := user.ID
-
+func RegisterDevice(userID uint, req DeviceRegistrationRequest) error {
 	deviceInfoJSON := ""
 	if req.DeviceInfo != nil {
 		infoBytes, err := json.Marshal(req.DeviceInfo)
@@ -1,138 +1,142 @@
 package utils
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"notify_service/models"
+	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-type TokenPair struct {
-	AccessToken  string
-	RefreshToken string
+// Токены выпускает backend и подписывает ключами Ed25519. Здесь они только проверяются
+// открытыми ключами из JWKS backend, ключ выбирается по kid из заголовка токена.
+
+const (
+	jwksRefreshInterval = 10 * time.Minute
+	jwksMinRefetch      = time.Minute // не чаще при неизвестном kid
+)
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
 }
 
-type UserFinder func(email string) (*models.User, error)
+type jwksCache struct {
+	mu        sync.RWMutex
+	keys      map[string]ed25519.PublicKey
+	fetchedAt time.Time
+}
 
-func GenerateTokenPair(email, us, name, image string) (TokenPair, error) {
-	secretKey := os.Getenv("SECRET_KEY_JWT")
-	if len(secretKey) == 0 {
-		return TokenPair{}, fmt.Errorf("пустой секретный ключ")
-	}
+var publicKeys = &jwksCache{keys: map[string]ed25519.PublicKey{}}
 
-	now := time.Now()
+var jwksClient = &http.Client{Timeout: 5 * time.Second}
 
-	// Access токен (20 минут жизни)
-	accessClaims := jwt.MapClaims{
-		"Email":    email,
-		"Us":       us,
-		"Username": name,
-		"Image":    image,
-		"exp":      now.Add(20 * time.Minute).Unix(),
-		"iat":      now.Unix(),
+func jwksURL() string {
+	if url := os.Getenv("JWKS_URL"); url != "" {
+		return url
 	}
+	return os.Getenv("FRIENDSHIP_URL") + "/.well-known/jwks.json"
+}
 
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessString, err := accessToken.SignedString([]byte(secretKey))
+func (c *jwksCache) refresh() error {
+	resp, err := jwksClient.Get(jwksURL())
 	if err != nil {
-		return TokenPair{}, err
+		return fmt.Errorf("не удалось получить JWKS: %v", err)
 	}
-
-	// Refresh токен (30 дней жизни)
-	refreshClaims := jwt.MapClaims{
-		"Email":    email,
-		"Us":       us,
-		"Username": name,
-		"Image":    image, // Добавляем Image и в refresh токен
-		"exp":      now.Add(30 * 24 * time.Hour).Unix(),
-		"iat":      now.Unix(),
-		"typ":      "refresh",
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("JWKS вернул статус %d", resp.StatusCode)
 	}
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshString, err := refreshToken.SignedString([]byte(secretKey))
-	if err != nil {
-		return TokenPair{}, err
+	var set struct {
+		Keys []jwk `json:"keys"`
 	}
-
-	return TokenPair{
-		AccessToken:  accessString,
-		RefreshToken: refreshString,
-	}, nil
-}
-
-func RefreshTokens(refreshTokenString string, findUser UserFinder) (TokenPair, error) {
-	secretKey := os.Getenv("SECRET_KEY_JWT")
-	if len(secretKey) == 0 {
-		return TokenPair{}, fmt.Errorf("пустой секретный ключ")
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("некорректный JWKS: %v", err)
 	}
 
-	token, err := jwt.Parse(refreshTokenString, func(token *jwt.Token) (interface{}, error) {
-		// Проверка типа подписи
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("неподдерживаемый метод подписи: %v", token.Header["alg"])
+	keys := make(map[string]ed25519.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "OKP" || k.Crv != "Ed25519" || k.Kid == "" {
+			continue
 		}
-		return []byte(secretKey), nil
-	})
-
-	if err != nil {
-		return TokenPair{}, fmt.Errorf("невалидный refresh токен: %w", err)
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			continue
+		}
+		keys[k.Kid] = ed25519.PublicKey(x)
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		return TokenPair{}, fmt.Errorf("невалидный refresh токен")
-	}
+	c.mu.Lock()
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+	return nil
+}
 
-	// Правильное извлечение типа токена
-	if typ, ok := claims["typ"].(string); !ok || typ != "refresh" {
-		return TokenPair{}, fmt.Errorf("токен не является refresh токеном")
-	}
+// key возвращает открытый ключ по kid, при необходимости перечитывая JWKS
+func (c *jwksCache) key(kid string) (ed25519.PublicKey, error) {
+	c.mu.RLock()
+	key, ok := c.keys[kid]
+	age := time.Since(c.fetchedAt)
+	c.mu.RUnlock()
 
-	// Правильное извлечение значений из claims
-	email, okEmail := claims["Email"].(string)
-	if !okEmail {
-		return TokenPair{}, fmt.Errorf("неверный формат Email в claims")
+	if ok && age < jwksRefreshInterval {
+		return key, nil
 	}
-
-	user, err := findUser(email)
-	if err != nil {
-		return TokenPair{}, err
+	if ok || age >= jwksMinRefetch {
+		if err := c.refresh(); err != nil {
+			if ok {
+				// backend недоступен — проверяем уже известным ключом
+				return key, nil
+			}
+			return nil, err
+		}
 	}
 
-	return GenerateTokenPair(email, user.Name, user.Us, user.Image)
-}
-
-func ParseJWT(tokenString string) (string, error) {
-	secretKey := os.Getenv("SECRET_KEY_JWT")
-	if secretKey == "" {
-		return "", fmt.Errorf("секретный ключ JWT не найден в переменных окружения")
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
 	}
+	return nil, fmt.Errorf("неизвестный ключ подписи %q", kid)
+}
 
+// ParseJWT проверяет access токен и возвращает Id пользователя. Email в токене не используется:
+// он меняется, а Id — нет.
+func ParseJWT(tokenString string) (uint, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Проверка типа подписи
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("неподдерживаемый метод подписи: %v", token.Header["alg"])
+		kid, ok := token.Header["kid"].(string)
+		if !ok || kid == "" {
+			return nil, fmt.Errorf("в токене нет kid")
 		}
-		return []byte(secretKey), nil
-	})
+		return publicKeys.key(kid)
+	}, jwt.WithValidMethods([]string{"EdDSA"}))
 
 	if err != nil || !token.Valid {
-		return "", fmt.Errorf("некорректный токен: %v", err)
+		return 0, fmt.Errorf("некорректный токен: %v", err)
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", fmt.Errorf("некорректные claims")
+		return 0, fmt.Errorf("некорректные claims")
 	}
 
-	// Правильное извлечение email
-	email, ok := claims["Email"].(string)
-	if !ok {
-		return "", fmt.Errorf("email не найден в токене")
+	if typ, _ := claims["typ"].(string); typ == "refresh" {
+		return 0, fmt.Errorf("refresh токен нельзя использовать для доступа")
+	}
+
+	id, ok := claims["Id"].(float64)
+	if !ok || id <= 0 {
+		return 0, fmt.Errorf("id пользователя не найден в токене")
 	}
 
-	return email, nil
+	return uint(id), nil
 }